@@ -62,6 +62,17 @@ type Config struct {
 		Timeout        time.Duration `mapstructure:"HTTPTimeout"`
 		CustomerBroker *customer.Broker
 	} `mapstructure:"server"`
+	Events struct {
+		PollInterval time.Duration `mapstructure:"pollInterval"`
+		BatchSize    int           `mapstructure:"batchSize"`
+		RedisStream  string        `mapstructure:"redisStream"`
+	} `mapstructure:"events"`
+	Jobs struct {
+		Workers         int           `mapstructure:"workers"`
+		PollInterval    time.Duration `mapstructure:"pollInterval"`
+		LeaseTimeout    time.Duration `mapstructure:"leaseTimeout"`
+		ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+	} `mapstructure:"jobs"`
 	UpstreamServices struct {
 		Customer    string `mapstructure:"customer"`
 		Auth        string `mapstructure:"auth"`
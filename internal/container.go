@@ -7,12 +7,18 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/FACorreiaa/fitme-grpc/config"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/activity"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/calculator"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/meals"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/measurements"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/preferences"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/webhooks"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/workout"
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
 )
 
 type MealServiceContainer struct {
@@ -23,6 +29,7 @@ type MealServiceContainer struct {
 	TrackMealProgressService  *meals.TrackMealProgressService
 	GoalRecommendationService *meals.GoalRecommendationService
 	MealReminderService       *meals.MealReminderService
+	MicronutrientService      *meals.MicronutrientService
 }
 
 type ServiceContainer struct {
@@ -30,13 +37,25 @@ type ServiceContainer struct {
 	AuthService *auth.Service
 	//CustomerService    *domain.CustomerService
 	CalculatorService  *calculator.CalculatorService
+	ExpenditureService *calculator.ExpenditureService
+	GoalPlanService    *calculator.GoalPlanService
+	BodyCompService    *calculator.BodyCompositionService
+	CarbCycleService   *calculator.CarbCycleService
+	MacroHistService   *calculator.MacroHistoryService
 	ServiceActivity    *activity.ServiceActivity
 	WorkoutService     *workout.ServiceWorkout
 	MeasurementService *measurements.ServiceMeasurement
+	UnitPreferences    *preferences.Units
 	MealServices       *MealServiceContainer
+	EventBus           *events.Bus
+	EventDispatcher    *events.Dispatcher
+	JobQueue           *jobs.Queue
+	WebhookService     *webhooks.ServiceWebhooks
+	TenantResolver     *tenant.Resolver
+	TenantService      *tenant.ServiceTenant
 }
 
-func NewServiceContainer(ctx context.Context, pgPool *pgxpool.Pool, redisClient *redis.Client, brokers *container.Brokers) *ServiceContainer {
+func NewServiceContainer(ctx context.Context, cfg *config.Config, pgPool *pgxpool.Pool, redisClient *redis.Client, brokers *container.Brokers) *ServiceContainer {
 	sessionManager := auth.NewSessionManager(pgPool, redisClient)
 	authRepo := auth.NewRepository(pgPool, redisClient, sessionManager)
 	calculatorRepo := calculator.NewCalculatorRepository(pgPool, redisClient, sessionManager)
@@ -46,12 +65,19 @@ func NewServiceContainer(ctx context.Context, pgPool *pgxpool.Pool, redisClient
 	authService := auth.NewService(ctx, authRepo, pgPool, redisClient, sessionManager)
 	//customerService := domain.NewCustomerService(ctx, pgPool, redisClient)
 	calculatorService := calculator.NewCalculatorService(ctx, calculatorRepo)
-	activityService := activity.NewCalculatorService(ctx, activityRepo)
+	expenditureService := calculator.NewExpenditureService(ctx, calculatorRepo)
+	goalPlanService := calculator.NewGoalPlanService(ctx, calculatorRepo)
+	bodyCompService := calculator.NewBodyCompositionService(ctx, calculatorRepo)
+	carbCycleService := calculator.NewCarbCycleService(ctx, calculatorRepo)
+	macroHistService := calculator.NewMacroHistoryService(ctx, calculatorRepo)
+	unitPreferences := preferences.NewUnits(pgPool, redisClient)
+	timezones := preferences.NewTimezones(pgPool, redisClient)
+	activityService := activity.NewCalculatorService(ctx, activityRepo, activityRepo, activityRepo, activityRepo, activityRepo, activityRepo, timezones)
 	workoutService := workout.NewServiceWorkout(ctx, workoutRepo)
-	measurementService := measurements.NewMeasurementService(ctx, measurementRepo)
+	measurementService := measurements.NewMeasurementService(ctx, measurementRepo, unitPreferences)
 
 	// meals
-	mealPlanRepo := meals.NewMealPlanRepository(pgPool, redisClient, sessionManager)
+	mealPlanRepo := meals.NewMealPlanRepository(pgPool, redisClient, sessionManager, calculatorRepo)
 	dietPreferenceRepo := meals.NewDietPreferenceRepository(pgPool, redisClient, sessionManager)
 	foodLogRepo := meals.NewFoodLogRepository(pgPool, redisClient, sessionManager)
 	ingredientRepo := meals.NewIngredientRepository(pgPool, redisClient, sessionManager)
@@ -67,17 +93,60 @@ func NewServiceContainer(ctx context.Context, pgPool *pgxpool.Pool, redisClient
 		TrackMealProgressService:  meals.NewTrackMealProgressService(ctx, trackMealProgressRepo),
 		GoalRecommendationService: meals.NewGoalRecommendationService(ctx, goalRecommendationRepo),
 		MealReminderService:       meals.NewMealReminderService(ctx, mealReminderRepo),
+		MicronutrientService:      meals.NewMicronutrientService(ctx, foodLogRepo),
 	}
 
+	// events
+	eventBus := events.NewBus()
+	events.RegisterDefaultSubscribers(eventBus, pgPool)
+	goalPlanService.Register(eventBus)
+	eventsCfg := events.DispatcherConfig{
+		PollInterval: cfg.Events.PollInterval,
+		BatchSize:    cfg.Events.BatchSize,
+		RedisStream:  cfg.Events.RedisStream,
+	}
+	jobsCfg := jobs.Config{
+		Workers:         cfg.Jobs.Workers,
+		PollInterval:    cfg.Jobs.PollInterval,
+		LeaseTimeout:    cfg.Jobs.LeaseTimeout,
+		ShutdownTimeout: cfg.Jobs.ShutdownTimeout,
+	}
+	eventDispatcher := events.NewDispatcher(pgPool, redisClient, eventBus, eventsCfg)
+
+	// background jobs
+	jobQueue := jobs.NewQueue(pgPool, jobsCfg)
+
+	// webhooks
+	webhookRepo := webhooks.NewRepositoryWebhooks(pgPool, redisClient, sessionManager, jobQueue)
+	webhookService := webhooks.NewServiceWebhooks(ctx, webhookRepo, webhooks.NewSender(nil))
+	webhookService.Register(eventBus, jobQueue)
+
+	// gym tenancy
+	tenantRepo := tenant.NewRepositoryTenant(pgPool, redisClient, sessionManager)
+	tenantResolver := tenant.NewResolver(tenantRepo, redisClient)
+	tenantService := tenant.NewServiceTenant(ctx, tenantRepo, tenantResolver)
+
 	return &ServiceContainer{
 		Brokers:     brokers,
 		AuthService: authService,
 		//CustomerService:    customerService,
 		CalculatorService:  calculatorService,
+		ExpenditureService: expenditureService,
+		GoalPlanService:    goalPlanService,
+		BodyCompService:    bodyCompService,
+		CarbCycleService:   carbCycleService,
+		MacroHistService:   macroHistService,
 		ServiceActivity:    activityService,
 		WorkoutService:     workoutService,
 		MeasurementService: measurementService,
+		UnitPreferences:    unitPreferences,
 		MealServices:       mealServices,
+		EventBus:           eventBus,
+		EventDispatcher:    eventDispatcher,
+		JobQueue:           jobQueue,
+		WebhookService:     webhookService,
+		TenantResolver:     tenantResolver,
+		TenantService:      tenantService,
 	}
 }
 
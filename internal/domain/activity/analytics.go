@@ -0,0 +1,262 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Bucket is the period sessions are summed over.
+type Bucket string
+
+const (
+	BucketDay   Bucket = "DAY"
+	BucketWeek  Bucket = "WEEK"
+	BucketMonth Bucket = "MONTH"
+)
+
+// Enough for daily buckets over two and a half years, or monthly ones over
+// any history a user has.
+const maxAnalyticsBuckets = 1000
+
+// ParseBucket reads a bucket name, case-insensitively; empty means days.
+func ParseBucket(s string) (Bucket, error) {
+	switch b := Bucket(strings.ToUpper(strings.TrimSpace(s))); b {
+	case "":
+		return BucketDay, nil
+	case BucketDay, BucketWeek, BucketMonth:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown bucket %q", s)
+}
+
+// truncate is the start of the bucket holding t, in t's location. Weeks
+// start on Monday, as Postgres' date_trunc has them.
+func (b Bucket) truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch b {
+	case BucketWeek:
+		back := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-back, 0, 0, 0, 0, t.Location())
+	case BucketMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (b Bucket) next(t time.Time) time.Time {
+	switch b {
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	case BucketMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// sqlUnit is the date_trunc field for b.
+func (b Bucket) sqlUnit() string {
+	return strings.ToLower(string(b))
+}
+
+// AnalyticsRequest covers the local dates From to To, both inclusive, as
+// YYYY-MM-DD.
+type AnalyticsRequest struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Bucket     string `json:"bucket,omitempty"`
+	ByActivity bool   `json:"by_activity,omitempty"`
+}
+
+// AnalyticsBucket sums the sessions started in one period, for one activity
+// when grouped.
+type AnalyticsBucket struct {
+	Start           string  `json:"start"`
+	ActivityID      string  `json:"activity_id,omitempty"`
+	ActivityName    string  `json:"activity_name,omitempty"`
+	Sessions        int     `json:"sessions"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	CaloriesBurned  int64   `json:"calories_burned"`
+	DistanceM       float64 `json:"distance_m"`
+}
+
+// ActivityAnalytics is the bucketed series and its totals.
+type ActivityAnalytics struct {
+	Timezone string            `json:"timezone"`
+	Bucket   Bucket            `json:"bucket"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Buckets  []AnalyticsBucket `json:"buckets"`
+	Totals   AnalyticsBucket   `json:"totals"`
+}
+
+// BucketQuery is what the repository aggregates: sessions starting in
+// [From, To), truncated to Bucket in Location.
+type BucketQuery struct {
+	UserID     string
+	From, To   time.Time
+	Location   *time.Location
+	Bucket     Bucket
+	ByActivity bool
+}
+
+// ActivityAnalytics sums duration, calories, distance and session count per
+// day, week or month of the user's timezone, optionally per activity.
+// Without grouping every period in the range is present, empty ones as
+// zero, so the series can be charted as is.
+func (a *ServiceActivity) ActivityAnalytics(ctx context.Context, userID string, req *AnalyticsRequest) (*ActivityAnalytics, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/ActivityAnalytics")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	bucket, err := ParseBucket(req.Bucket)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	loc, err := a.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := time.ParseInLocation(time.DateOnly, req.From, loc)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "from must be a YYYY-MM-DD date")
+	}
+	to, err := time.ParseInLocation(time.DateOnly, req.To, loc)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "to must be a YYYY-MM-DD date")
+	}
+	if to.Before(from) {
+		return nil, status.Error(codes.InvalidArgument, "to is before from")
+	}
+	starts := bucketStarts(bucket, from, to)
+	if len(starts) > maxAnalyticsBuckets {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"the range spans more than %d buckets; use a larger bucket", maxAnalyticsBuckets)
+	}
+
+	rows, err := a.stats.SessionBuckets(ctx, &BucketQuery{
+		UserID:     userID,
+		From:       from,
+		To:         to.AddDate(0, 0, 1),
+		Location:   loc,
+		Bucket:     bucket,
+		ByActivity: req.ByActivity,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	report := &ActivityAnalytics{
+		Timezone: loc.String(),
+		Bucket:   bucket,
+		From:     req.From,
+		To:       req.To,
+		Buckets:  rows,
+	}
+	if !req.ByActivity {
+		report.Buckets = fillBuckets(starts, rows)
+	}
+	for _, b := range rows {
+		report.Totals.Sessions += b.Sessions
+		report.Totals.DurationSeconds += b.DurationSeconds
+		report.Totals.CaloriesBurned += b.CaloriesBurned
+		report.Totals.DistanceM += b.DistanceM
+	}
+	report.Totals.DistanceM = round1(report.Totals.DistanceM)
+
+	span.SetAttributes(
+		attribute.String("analytics.bucket", string(bucket)),
+		attribute.Int("analytics.buckets", len(report.Buckets)),
+	)
+	return report, nil
+}
+
+// bucketStarts lists the starts of the buckets covering from to to. It
+// stops one past maxAnalyticsBuckets, enough to tell the range is too long.
+func bucketStarts(b Bucket, from, to time.Time) []string {
+	var starts []string
+	for t := b.truncate(from); !t.After(to); t = b.next(t) {
+		starts = append(starts, t.Format(time.DateOnly))
+		if len(starts) > maxAnalyticsBuckets {
+			break
+		}
+	}
+	return starts
+}
+
+// fillBuckets lays the aggregated rows over every bucket start.
+func fillBuckets(starts []string, rows []AnalyticsBucket) []AnalyticsBucket {
+	byStart := make(map[string]AnalyticsBucket, len(rows))
+	for _, r := range rows {
+		byStart[r.Start] = r
+	}
+	filled := make([]AnalyticsBucket, 0, len(starts))
+	for _, s := range starts {
+		b, ok := byStart[s]
+		if !ok {
+			b = AnalyticsBucket{Start: s}
+		}
+		filled = append(filled, b)
+	}
+	return filled
+}
+
+// location is the user's timezone, UTC when none is wired.
+func (a *ServiceActivity) location(ctx context.Context, userID string) (*time.Location, error) {
+	if a.timezones == nil {
+		return time.UTC, nil
+	}
+	return a.timezones.Resolve(ctx, userID)
+}
+
+// SessionBuckets aggregates in Postgres on the (user_id, start_time) index,
+// so a long history is a single indexed range scan. start_time holds UTC.
+func (a *RepositoryActivity) SessionBuckets(ctx context.Context, q *BucketQuery) ([]AnalyticsBucket, error) {
+	group, columns := "", "'', ''"
+	if q.ByActivity {
+		group = ", es.activity_id, a.name"
+		columns = "COALESCE(es.activity_id::text, ''), COALESCE(a.name, '')"
+	}
+	query := `
+		SELECT to_char(date_trunc($4, (es.start_time AT TIME ZONE 'UTC') AT TIME ZONE $5), 'YYYY-MM-DD') AS bucket,
+		       ` + columns + `,
+		       COUNT(*),
+		       COALESCE(SUM(COALESCE(es.duration_hours, 0) * 3600 + COALESCE(es.duration_minutes, 0) * 60
+		                    + COALESCE(es.duration_seconds, 0)), 0)::bigint,
+		       COALESCE(SUM(es.calories_burned), 0)::bigint,
+		       COALESCE(SUM(es.distance_m), 0)::float8
+		FROM exercise_session es
+		LEFT JOIN activity a ON a.id = es.activity_id
+		WHERE es.user_id = $1 AND es.start_time >= $2 AND es.start_time < $3
+		GROUP BY 1` + group + `
+		ORDER BY 1, 5 DESC`
+
+	rows, err := a.pgpool.Query(ctx, query,
+		q.UserID, q.From.UTC(), q.To.UTC(), q.Bucket.sqlUnit(), q.Location.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []AnalyticsBucket
+	for rows.Next() {
+		var b AnalyticsBucket
+		if err = rows.Scan(&b.Start, &b.ActivityID, &b.ActivityName, &b.Sessions,
+			&b.DurationSeconds, &b.CaloriesBurned, &b.DistanceM); err != nil {
+			return nil, fmt.Errorf("failed to scan bucket: %w", err)
+		}
+		b.DistanceM = round1(b.DistanceM)
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
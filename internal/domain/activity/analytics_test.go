@@ -0,0 +1,68 @@
+package activity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Bucket
+		wantErr bool
+	}{
+		{"", BucketDay, false},
+		{"week", BucketWeek, false},
+		{" MONTH ", BucketMonth, false},
+		{"year", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBucket(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseBucket(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestBucketStarts(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a Wednesday to a Monday, across the end of daylight saving time
+	from := time.Date(2024, 10, 23, 0, 0, 0, 0, lisbon)
+	to := time.Date(2024, 11, 4, 0, 0, 0, 0, lisbon)
+
+	tests := []struct {
+		bucket Bucket
+		want   []string
+	}{
+		{BucketWeek, []string{"2024-10-21", "2024-10-28", "2024-11-04"}},
+		{BucketMonth, []string{"2024-10-01", "2024-11-01"}},
+	}
+	for _, tt := range tests {
+		if got := bucketStarts(tt.bucket, from, to); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s starts = %v, want %v", tt.bucket, got, tt.want)
+		}
+	}
+	if days := bucketStarts(BucketDay, from, to); len(days) != 13 || days[4] != "2024-10-27" {
+		t.Errorf("day starts = %v", days)
+	}
+	if got := bucketStarts(BucketDay, from, from.AddDate(5, 0, 0)); len(got) != maxAnalyticsBuckets+1 {
+		t.Errorf("long range stops after %d buckets", len(got))
+	}
+}
+
+func TestFillBuckets(t *testing.T) {
+	rows := []AnalyticsBucket{{Start: "2024-05-02", Sessions: 2, DurationSeconds: 3600}}
+	got := fillBuckets([]string{"2024-05-01", "2024-05-02", "2024-05-03"}, rows)
+	want := []AnalyticsBucket{
+		{Start: "2024-05-01"},
+		{Start: "2024-05-02", Sessions: 2, DurationSeconds: 3600},
+		{Start: "2024-05-03"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fillBuckets = %+v", got)
+	}
+}
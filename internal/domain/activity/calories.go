@@ -0,0 +1,105 @@
+package activity
+
+import (
+	"math"
+	"time"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/gender"
+)
+
+// Burn follows the Compendium of Physical Activities: one MET is 1 kcal per
+// kg of bodyweight per hour. The catalog's calories_per_hour figures assume
+// the reference weight, which is also what a user without a weigh-in gets.
+const referenceWeightKg = 70
+
+// StopActivityTracker reports the heart rate estimate in this header.
+const heartRateCaloriesHeader = "x-calories-heart-rate"
+
+// Profile is what a user's burn and intensity depend on. MaxHR and
+// RestingHR are the user's own settings, zero when not configured.
+type Profile struct {
+	WeightKg  float64 `json:"weight_kg"`
+	Age       int     `json:"age"`
+	Gender    string  `json:"gender"`
+	MaxHR     int     `json:"max_heart_rate,omitempty"`
+	RestingHR int     `json:"resting_heart_rate,omitempty"`
+}
+
+// MaxHeartRate is the configured maximum, or 220 minus age.
+func (p Profile) MaxHeartRate() int {
+	if p.MaxHR > 0 {
+		return p.MaxHR
+	}
+	if p.Age <= 0 || p.Age >= 120 {
+		return defaultMaxHeartRate
+	}
+	return 220 - p.Age
+}
+
+// RestingHeartRate is the configured resting rate, or a typical adult's.
+func (p Profile) RestingHeartRate() int {
+	if p.RestingHR > 0 {
+		return p.RestingHR
+	}
+	return defaultRestingHeartRate
+}
+
+// metCalories is the burn of d at met for a body of weightKg.
+func metCalories(met, weightKg float64, d time.Duration) float64 {
+	if met <= 0 || d <= 0 {
+		return 0
+	}
+	if weightKg <= 0 {
+		weightKg = referenceWeightKg
+	}
+	return met * weightKg * d.Hours()
+}
+
+// sampleHolds calls fn with each sample and how long its reading stands:
+// until the next sample or now, at most maxSampleGap.
+func sampleHolds(samples []Sample, now time.Time, fn func(s Sample, held time.Duration)) {
+	for i, s := range samples {
+		until := now
+		if i+1 < len(samples) {
+			until = samples[i+1].RecordedAt
+		}
+		held := until.Sub(s.RecordedAt)
+		if held > maxSampleGap {
+			held = maxSampleGap
+		}
+		if held > 0 {
+			fn(s, held)
+		}
+	}
+}
+
+// heartRateCalories estimates burn from heart rate with the Keytel et al.
+// (2005) equations, which need age, weight and gender. ok is false when the
+// profile or the samples can't support an estimate.
+func heartRateCalories(samples []Sample, p Profile, now time.Time) (kcal float64, ok bool) {
+	if p.Age <= 0 || p.WeightKg <= 0 {
+		return 0, false
+	}
+	var perMinute func(hr float64) float64
+	switch gender.Normalize(p.Gender) {
+	case gender.Male:
+		perMinute = func(hr float64) float64 {
+			return (-55.0969 + 0.6309*hr + 0.1988*p.WeightKg + 0.2017*float64(p.Age)) / 4.184
+		}
+	case gender.Female:
+		perMinute = func(hr float64) float64 {
+			return (-20.4022 + 0.4472*hr - 0.1263*p.WeightKg + 0.074*float64(p.Age)) / 4.184
+		}
+	default:
+		return 0, false
+	}
+
+	sampleHolds(samples, now, func(s Sample, held time.Duration) {
+		if s.HeartRate <= 0 {
+			return
+		}
+		ok = true
+		kcal += math.Max(0, perMinute(float64(s.HeartRate))) * held.Minutes()
+	})
+	return kcal, ok
+}
@@ -0,0 +1,450 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxActivityNameLength = 255
+	maxActivityMET        = 25
+	defaultCatalogLimit   = 20
+	maxCatalogLimit       = 100
+)
+
+// UserActivity is a catalog entry as one user sees it. UserID is set on the
+// user's own activities and empty on the global catalog. LastUsedAt is the
+// start of the user's latest session of it, nil if none.
+type UserActivity struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id,omitempty"`
+	Name            string     `json:"name"`
+	MET             float64    `json:"met"`
+	CaloriesPerHour float64    `json:"calories_per_hour"`
+	Favourite       bool       `json:"favourite"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	// Score ranks search results, 1 for an exact match.
+	Score     float64    `json:"score,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ActivityInput is a custom activity. Give MET, or CaloriesPerHour for the
+// 70 kg reference body the catalog's figures assume; the other is derived.
+type ActivityInput struct {
+	Name            string  `json:"name"`
+	MET             float64 `json:"met,omitempty"`
+	CaloriesPerHour float64 `json:"calories_per_hour,omitempty"`
+}
+
+// normalise validates in and fills in whichever of MET and calories per
+// hour is missing.
+func (in *ActivityInput) normalise() error {
+	in.Name = strings.Join(strings.Fields(in.Name), " ")
+	switch {
+	case in.Name == "":
+		return errors.New("name is required")
+	case len(in.Name) > maxActivityNameLength:
+		return fmt.Errorf("name is longer than %d characters", maxActivityNameLength)
+	case in.MET < 0 || in.CaloriesPerHour < 0:
+		return errors.New("MET and calories per hour cannot be negative")
+	case in.MET == 0 && in.CaloriesPerHour == 0:
+		return errors.New("MET or calories per hour is required")
+	case in.MET > 0 && in.CaloriesPerHour > 0:
+		return errors.New("give MET or calories per hour, not both")
+	}
+	if in.MET == 0 {
+		in.MET = in.CaloriesPerHour / referenceWeightKg
+	}
+	in.MET = math.Round(in.MET*10) / 10
+	if in.MET < 1 || in.MET > maxActivityMET {
+		return fmt.Errorf("MET must be between 1 and %d", maxActivityMET)
+	}
+	if in.CaloriesPerHour == 0 {
+		in.CaloriesPerHour = in.MET * referenceWeightKg
+	}
+	return nil
+}
+
+// requireIDs checks the user and any activity ids are uuids. The catalog
+// queries compare them with the uuid columns as they are, so the primary
+// key and the per-user indexes serve them.
+func requireIDs(userID string, activityIDs ...string) error {
+	if err := requireID("user", userID); err != nil {
+		return err
+	}
+	for _, id := range activityIDs {
+		if err := requireID("Activity ID", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func requireID(name, id string) error {
+	if id == "" {
+		return status.Errorf(codes.InvalidArgument, "%s is required", name)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s", name)
+	}
+	return nil
+}
+
+// catalogLimit is limit within bounds, the default when unset.
+func catalogLimit(limit int) int {
+	if limit <= 0 {
+		return defaultCatalogLimit
+	}
+	return min(limit, maxCatalogLimit)
+}
+
+// CatalogRepository is implemented by RepositoryActivity. Users see the
+// global catalog and their own activities, never anyone else's.
+type CatalogRepository interface {
+	CreateActivity(ctx context.Context, userID string, in *ActivityInput) (*UserActivity, error)
+	UpdateActivity(ctx context.Context, userID, activityID string, in *ActivityInput) (*UserActivity, error)
+	DeleteActivity(ctx context.Context, userID, activityID string) error
+	SetFavourite(ctx context.Context, userID, activityID string, favourite bool) error
+	QuickStartActivities(ctx context.Context, userID string, limit int) ([]UserActivity, error)
+	SearchActivities(ctx context.Context, userID, query string, limit int) ([]UserActivity, error)
+}
+
+// CreateActivity adds an activity to the user's own catalog.
+func (a *ServiceActivity) CreateActivity(ctx context.Context, userID string, in *ActivityInput) (*UserActivity, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/CreateActivity")
+	defer span.End()
+
+	if err := requireIDs(userID); err != nil {
+		return nil, err
+	}
+	if err := in.normalise(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	act, err := a.catalog.CreateActivity(ctx, userID, in)
+	if err != nil {
+		return nil, catalogError(err)
+	}
+	span.SetAttributes(attribute.String("activity.id", act.ID))
+	return act, nil
+}
+
+// UpdateActivity renames or re-rates one of the user's activities. Past
+// sessions keep the calories they were saved with.
+func (a *ServiceActivity) UpdateActivity(ctx context.Context, userID, activityID string, in *ActivityInput) (*UserActivity, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/UpdateActivity")
+	defer span.End()
+
+	if err := requireIDs(userID, activityID); err != nil {
+		return nil, err
+	}
+	if err := in.normalise(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	act, err := a.catalog.UpdateActivity(ctx, userID, activityID, in)
+	if err != nil {
+		return nil, catalogError(err)
+	}
+	return act, nil
+}
+
+// DeleteActivity removes one of the user's activities from the catalog.
+// Sessions logged with it keep pointing at it.
+func (a *ServiceActivity) DeleteActivity(ctx context.Context, userID, activityID string) error {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/DeleteActivity")
+	defer span.End()
+
+	if err := requireIDs(userID, activityID); err != nil {
+		return err
+	}
+	return catalogError(a.catalog.DeleteActivity(ctx, userID, activityID))
+}
+
+// SetFavourite marks or unmarks an activity as a favourite; doing either
+// twice is a no-op.
+func (a *ServiceActivity) SetFavourite(ctx context.Context, userID, activityID string, favourite bool) error {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/SetFavourite")
+	defer span.End()
+
+	if err := requireIDs(userID, activityID); err != nil {
+		return err
+	}
+	return catalogError(a.catalog.SetFavourite(ctx, userID, activityID, favourite))
+}
+
+// QuickStartActivities lists the user's favourites, then the activities
+// they logged most recently, for starting a workout in one tap.
+func (a *ServiceActivity) QuickStartActivities(ctx context.Context, userID string, limit int) ([]UserActivity, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/QuickStartActivities")
+	defer span.End()
+
+	if err := requireIDs(userID); err != nil {
+		return nil, err
+	}
+	acts, err := a.catalog.QuickStartActivities(ctx, userID, catalogLimit(limit))
+	if err != nil {
+		return nil, catalogError(err)
+	}
+	span.SetAttributes(attribute.Int("activities.count", len(acts)))
+	return acts, nil
+}
+
+// SearchActivities finds catalog entries by trigram similarity, so typos
+// and partial words still match: "swimmng" finds the swimming entries.
+func (a *ServiceActivity) SearchActivities(ctx context.Context, userID, query string, limit int) ([]UserActivity, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/SearchActivities")
+	defer span.End()
+
+	if err := requireIDs(userID); err != nil {
+		return nil, err
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	acts, err := a.catalog.SearchActivities(ctx, userID, query, catalogLimit(limit))
+	if err != nil {
+		return nil, catalogError(err)
+	}
+	span.SetAttributes(attribute.Int("activities.count", len(acts)))
+	return acts, nil
+}
+
+// visibleActivity restricts activity a to the global catalog and the
+// activities of the user in $1, leaving out deleted ones.
+const visibleActivity = `(a.user_id IS NULL OR a.user_id = $1) AND a.deleted_at IS NULL`
+
+const userActivityColumns = `a.id::text, COALESCE(a.user_id::text, ''), COALESCE(a.name, ''),
+	COALESCE(a.met, 0)::float8, COALESCE(a.calories_per_hour, 0)::float8,
+	EXISTS (SELECT 1 FROM favourite_activities f WHERE f.user_id = $1 AND f.activity_id = a.id),
+	(SELECT MAX(es.start_time) FROM exercise_session es WHERE es.user_id = $1 AND es.activity_id = a.id),
+	a.created_at, a.updated_at`
+
+func scanUserActivity(row pgx.Row, extra ...any) (*UserActivity, error) {
+	var act UserActivity
+	dest := append([]any{&act.ID, &act.UserID, &act.Name, &act.MET, &act.CaloriesPerHour,
+		&act.Favourite, &act.LastUsedAt, &act.CreatedAt, &act.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &act, nil
+}
+
+func collectUserActivities(rows pgx.Rows, withScore bool) ([]UserActivity, error) {
+	defer rows.Close()
+	acts := make([]UserActivity, 0)
+	for rows.Next() {
+		var score float64
+		var extra []any
+		if withScore {
+			extra = append(extra, &score)
+		}
+		act, err := scanUserActivity(rows, extra...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		act.Score = math.Round(score*100) / 100
+		acts = append(acts, *act)
+	}
+	return acts, rows.Err()
+}
+
+func catalogError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "activity catalog: %v", err)
+}
+
+func errActivityName(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return status.Error(codes.AlreadyExists, "you already have an activity with this name")
+	}
+	return nil
+}
+
+func (a *RepositoryActivity) CreateActivity(ctx context.Context, userID string, in *ActivityInput) (*UserActivity, error) {
+	act, err := scanUserActivity(a.pgpool.QueryRow(ctx, `
+		WITH a AS (
+			INSERT INTO activity (user_id, name, met, calories_per_hour, duration_minutes, total_calories)
+			VALUES ($1, $2, $3, $4, 60, $4)
+			RETURNING *
+		)
+		SELECT `+userActivityColumns+` FROM a`, userID, in.Name, in.MET, in.CaloriesPerHour))
+	if err != nil {
+		if e := errActivityName(err); e != nil {
+			return nil, e
+		}
+		return nil, fmt.Errorf("failed to create activity: %w", err)
+	}
+	return act, nil
+}
+
+// ownActivity explains why the user can't change activityID: it is
+// someone else's, or part of the global catalog.
+func (a *RepositoryActivity) ownActivity(ctx context.Context, userID, activityID string) error {
+	var global bool
+	err := a.pgpool.QueryRow(ctx, `
+		SELECT a.user_id IS NULL FROM activity a
+		WHERE a.id = $2 AND `+visibleActivity, userID, activityID).Scan(&global)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return status.Error(codes.NotFound, "activity not found")
+	case err != nil:
+		return fmt.Errorf("failed to fetch activity: %w", err)
+	case global:
+		return status.Error(codes.PermissionDenied, "catalog activities can't be changed")
+	}
+	return nil
+}
+
+func (a *RepositoryActivity) UpdateActivity(ctx context.Context, userID, activityID string, in *ActivityInput) (*UserActivity, error) {
+	act, err := scanUserActivity(a.pgpool.QueryRow(ctx, `
+		WITH a AS (
+			UPDATE activity
+			SET name = $3, met = $4, calories_per_hour = $5, total_calories = $5 * COALESCE(duration_minutes, 60) / 60,
+			    updated_at = now()
+			WHERE id = $2 AND user_id = $1 AND deleted_at IS NULL
+			RETURNING *
+		)
+		SELECT `+userActivityColumns+` FROM a`, userID, activityID, in.Name, in.MET, in.CaloriesPerHour))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if err = a.ownActivity(ctx, userID, activityID); err != nil {
+				return nil, err
+			}
+			return nil, status.Error(codes.NotFound, "activity not found")
+		}
+		if e := errActivityName(err); e != nil {
+			return nil, e
+		}
+		return nil, fmt.Errorf("failed to update activity: %w", err)
+	}
+	return act, nil
+}
+
+// DeleteActivity only hides the activity: sessions, trackers and stats
+// still refer to it. Favourites of it go.
+func (a *RepositoryActivity) DeleteActivity(ctx context.Context, userID, activityID string) error {
+	return a.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE activity SET deleted_at = now(), updated_at = now()
+			WHERE id = $2 AND user_id = $1 AND deleted_at IS NULL`, userID, activityID)
+		if err != nil {
+			return fmt.Errorf("failed to delete activity: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			if err = a.ownActivity(ctx, userID, activityID); err != nil {
+				return err
+			}
+			return status.Error(codes.NotFound, "activity not found")
+		}
+		if _, err = tx.Exec(ctx, `DELETE FROM favourite_activities WHERE activity_id = $1`, activityID); err != nil {
+			return fmt.Errorf("failed to remove favourites: %w", err)
+		}
+		return nil
+	})
+}
+
+func (a *RepositoryActivity) SetFavourite(ctx context.Context, userID, activityID string, favourite bool) error {
+	if !favourite {
+		if _, err := a.pgpool.Exec(ctx, `
+			DELETE FROM favourite_activities WHERE user_id = $1 AND activity_id = $2`, userID, activityID); err != nil {
+			return fmt.Errorf("failed to remove favourite: %w", err)
+		}
+		return nil
+	}
+
+	tag, err := a.pgpool.Exec(ctx, `
+		INSERT INTO favourite_activities (user_id, activity_id)
+		SELECT $1, a.id FROM activity a
+		WHERE a.id = $2 AND `+visibleActivity+`
+		ON CONFLICT (user_id, activity_id) DO NOTHING`, userID, activityID)
+	if err != nil {
+		return fmt.Errorf("failed to add favourite: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		// already a favourite, or not an activity the user can see
+		return a.activityVisible(ctx, userID, activityID)
+	}
+	return nil
+}
+
+func (a *RepositoryActivity) activityVisible(ctx context.Context, userID, activityID string) error {
+	var found bool
+	err := a.pgpool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM activity a WHERE a.id = $2 AND `+visibleActivity+`)`,
+		userID, activityID).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("failed to fetch activity: %w", err)
+	}
+	if !found {
+		return status.Error(codes.NotFound, "activity not found")
+	}
+	return nil
+}
+
+func (a *RepositoryActivity) QuickStartActivities(ctx context.Context, userID string, limit int) ([]UserActivity, error) {
+	rows, err := a.pgpool.Query(ctx, `
+		WITH picks AS (
+			SELECT f.activity_id, TRUE AS favourite, NULL::timestamp AS last_used
+			FROM favourite_activities f
+			WHERE f.user_id = $1
+			UNION ALL
+			SELECT es.activity_id, FALSE, MAX(es.start_time)
+			FROM exercise_session es
+			WHERE es.user_id = $1 AND es.activity_id IS NOT NULL
+			GROUP BY es.activity_id
+		), ranked AS (
+			SELECT activity_id, bool_or(favourite) AS favourite, MAX(last_used) AS last_used
+			FROM picks
+			GROUP BY activity_id
+		)
+		SELECT `+userActivityColumns+`
+		FROM ranked r
+		JOIN activity a ON a.id = r.activity_id
+		WHERE `+visibleActivity+`
+		ORDER BY r.favourite DESC, r.last_used DESC NULLS LAST, a.name
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quick start activities: %w", err)
+	}
+	return collectUserActivities(rows, false)
+}
+
+// SearchActivities ranks by the better of whole-name and word similarity,
+// so a query matching one word of a long catalog name still scores well.
+// Both operators are served by the trigram index on activity.name.
+func (a *RepositoryActivity) SearchActivities(ctx context.Context, userID, query string, limit int) ([]UserActivity, error) {
+	rows, err := a.pgpool.Query(ctx, `
+		SELECT `+userActivityColumns+`,
+		       GREATEST(similarity(a.name, $2), word_similarity($2, a.name))::float8 AS score
+		FROM activity a
+		WHERE `+visibleActivity+`
+		  AND (a.name % $2 OR $2 <% a.name)
+		ORDER BY score DESC, (a.user_id IS NOT NULL) DESC, a.name
+		LIMIT $3`, userID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search activities: %w", err)
+	}
+	return collectUserActivities(rows, true)
+}
@@ -0,0 +1,84 @@
+package activity
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestActivityInputNormalise(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      ActivityInput
+		wantMET float64
+		wantCPH float64
+		wantErr string
+	}{
+		{"met", ActivityInput{Name: "  Padel,   doubles ", MET: 6.04}, 6, 420, ""},
+		{"calories", ActivityInput{Name: "Trail run", CaloriesPerHour: 700}, 10, 700, ""},
+		{"no name", ActivityInput{MET: 5}, 0, 0, "name is required"},
+		{"no rate", ActivityInput{Name: "Yoga"}, 0, 0, "MET or calories per hour is required"},
+		{"both", ActivityInput{Name: "Yoga", MET: 3, CaloriesPerHour: 210}, 0, 0, "give MET or calories per hour, not both"},
+		{"too high", ActivityInput{Name: "Rocket", MET: 40}, 0, 0, "MET must be between 1 and 25"},
+		{"below rest", ActivityInput{Name: "Nap", CaloriesPerHour: 35}, 0, 0, "MET must be between 1 and 25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			err := in.normalise()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("normalise() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || in.MET != tt.wantMET || in.CaloriesPerHour != tt.wantCPH {
+				t.Errorf("normalise() = %+v, %v", in, err)
+			}
+		})
+	}
+
+	in := ActivityInput{Name: "  Padel,   doubles ", MET: 6}
+	_ = in.normalise()
+	if in.Name != "Padel, doubles" {
+		t.Errorf("name = %q", in.Name)
+	}
+}
+
+func TestCatalogLimit(t *testing.T) {
+	for limit, want := range map[int]int{0: defaultCatalogLimit, -3: defaultCatalogLimit, 5: 5, 1000: maxCatalogLimit} {
+		if got := catalogLimit(limit); got != want {
+			t.Errorf("catalogLimit(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestRequireIDs(t *testing.T) {
+	const user, activity = "7b3c2f6e-4a51-4d0e-9c39-3f1e8a2b5d10", "0d9e6a57-1c2b-4f3e-8a90-b6c7d8e9f012"
+	tests := []struct {
+		name     string
+		userID   string
+		activity []string
+		wantErr  string
+	}{
+		{"user", user, nil, ""},
+		{"user and activity", user, []string{activity}, ""},
+		{"no user", "", nil, "user is required"},
+		{"bad user", "42", nil, "invalid user"},
+		{"no activity", user, []string{""}, "Activity ID is required"},
+		{"bad activity", user, []string{"running"}, "invalid Activity ID"},
+	}
+	for _, tt := range tests {
+		err := requireIDs(tt.userID, tt.activity...)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != tt.wantErr {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
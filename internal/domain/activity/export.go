@@ -0,0 +1,384 @@
+package activity
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	pbw "github.com/FACorreiaa/fitme-protos/modules/workout/generated"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/workoutfile"
+)
+
+// ExportCSV is the spreadsheet export next to the workout file formats.
+const ExportCSV workoutfile.Format = "CSV"
+
+const (
+	maxExportRange    = 366 * 24 * time.Hour
+	maxExportSessions = 1000
+	exportChunkSize   = 64 * 1024
+)
+
+var exportContentTypes = map[workoutfile.Format]string{
+	workoutfile.GPX: "application/gpx+xml",
+	workoutfile.TCX: "application/vnd.garmin.tcx+xml",
+	ExportCSV:       "text/csv",
+}
+
+// ExportRequest picks one session by id, or every session starting in
+// [From, To).
+type ExportRequest struct {
+	SessionID string    `json:"session_id,omitempty"`
+	From      time.Time `json:"from,omitempty"`
+	To        time.Time `json:"to,omitempty"`
+	Format    string    `json:"format"`
+}
+
+// ExportedSession is a saved session as a workout file would describe it.
+type ExportedSession struct {
+	ID           string
+	ActivityID   string
+	ActivityName string
+	Workout      *workoutfile.Activity
+}
+
+func parseExportFormat(s string) (workoutfile.Format, error) {
+	if strings.EqualFold(strings.TrimSpace(s), string(ExportCSV)) {
+		return ExportCSV, nil
+	}
+	f, err := workoutfile.ParseFormat(s)
+	if err != nil || f == workoutfile.FIT {
+		return "", status.Errorf(codes.InvalidArgument, "sessions export as GPX, TCX or CSV, not %q", s)
+	}
+	return f, nil
+}
+
+func (r *ExportRequest) validate() error {
+	if r.SessionID != "" {
+		if _, err := uuid.Parse(r.SessionID); err != nil {
+			return status.Error(codes.InvalidArgument, "invalid session id")
+		}
+		return nil
+	}
+	if r.From.IsZero() || r.To.IsZero() || !r.From.Before(r.To) {
+		return status.Error(codes.InvalidArgument, "a session id or a from and to range is required")
+	}
+	if r.To.Sub(r.From) > maxExportRange {
+		return status.Error(codes.InvalidArgument, "export ranges are limited to a year")
+	}
+	return nil
+}
+
+// ExportSessions renders one session, or the sessions in a date range, with
+// their laps and recorded samples as GPX, TCX or CSV, and streams the file
+// in chunks the way DownloadWorkoutPlan does: name and content type on the
+// first. A range holding more than 1000 sessions is refused rather than
+// cut short.
+func (a *ServiceActivity) ExportSessions(req *ExportRequest, stream grpc.ServerStreamingServer[pbw.FileChunk]) error {
+	ctx := stream.Context()
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/ExportSessions")
+	defer span.End()
+
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
+	format, err := parseExportFormat(req.Format)
+	if err != nil {
+		return err
+	}
+	if err = req.validate(); err != nil {
+		return err
+	}
+
+	sessions, err := a.files.ExportSessions(ctx, userID, req)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "failed to export sessions: %v", err)
+	}
+	if len(sessions) == 0 {
+		return status.Error(codes.NotFound, "no exercise sessions to export")
+	}
+
+	var data []byte
+	if format == ExportCSV {
+		data, err = sessionsCSV(sessions)
+	} else {
+		workouts := make([]*workoutfile.Activity, 0, len(sessions))
+		for _, s := range sessions {
+			workouts = append(workouts, s.Workout)
+		}
+		data, err = workoutfile.Encode(format, workouts)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to render sessions: %v", err)
+	}
+
+	span.SetAttributes(
+		attribute.String("export.format", string(format)),
+		attribute.Int("export.sessions", len(sessions)),
+		attribute.Int("export.bytes", len(data)),
+	)
+	return sendFileChunks(stream, exportFileName(req, format), exportContentTypes[format], data)
+}
+
+func exportFileName(req *ExportRequest, format workoutfile.Format) string {
+	ext := strings.ToLower(string(format))
+	if req.SessionID != "" {
+		return fmt.Sprintf("session-%s.%s", req.SessionID, ext)
+	}
+	return fmt.Sprintf("sessions-%s-%s.%s", req.From.Format(time.DateOnly), req.To.Format(time.DateOnly), ext)
+}
+
+func sendFileChunks(stream grpc.ServerStreamingServer[pbw.FileChunk], fileName, contentType string, data []byte) error {
+	for current := 0; current < len(data); current += exportChunkSize {
+		end := min(current+exportChunkSize, len(data))
+		chunk := &pbw.FileChunk{Content: data[current:end]}
+		if current == 0 {
+			chunk.IsFirstChunk = true
+			chunk.FileName = fileName
+			chunk.ContentType = contentType
+		}
+		if err := stream.Send(chunk); err != nil {
+			return status.Errorf(codes.Internal, "failed to send export: %v", err)
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"session_id", "session_name", "activity", "start_time", "end_time", "duration_seconds",
+	"calories_burned", "distance_m", "elevation_gain_m", "avg_heart_rate", "max_heart_rate",
+	"recorded_at", "lat", "lon", "elevation_m", "heart_rate", "cadence", "sample_distance_m",
+}
+
+// sessionsCSV writes a row per sample with the session's columns repeated,
+// and a single row for a session without samples.
+func sessionsCSV(sessions []ExportedSession) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		wo := s.Workout
+		session := []string{
+			s.ID, wo.Name, s.ActivityName,
+			wo.Start.UTC().Format(time.RFC3339), wo.End.UTC().Format(time.RFC3339),
+			strconv.Itoa(int(wo.Duration().Seconds())),
+			strconv.Itoa(wo.Calories), csvFloat(wo.DistanceM), csvFloat(wo.ElevationGainM),
+			csvInt(wo.AvgHeartRate), csvInt(wo.MaxHeartRate),
+		}
+		if len(wo.Points) == 0 {
+			if err := w.Write(append(session, "", "", "", "", "", "", "")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, p := range wo.Points {
+			row := append(session[:len(session):len(session)], p.Time.UTC().Format(time.RFC3339))
+			if p.HasPosition {
+				row = append(row, strconv.FormatFloat(p.Lat, 'f', 7, 64), strconv.FormatFloat(p.Lon, 'f', 7, 64))
+			} else {
+				row = append(row, "", "")
+			}
+			if p.HasElevation {
+				row = append(row, strconv.FormatFloat(math.Round(p.ElevationM*10)/10, 'f', -1, 64))
+			} else {
+				row = append(row, "")
+			}
+			row = append(row, csvInt(p.HeartRate), csvInt(p.Cadence), csvFloat(p.DistanceM))
+			if err := w.Write(row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvInt leaves unrecorded readings blank rather than zero.
+func csvInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+func csvFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+func (a *RepositoryActivity) ExportSessions(ctx context.Context, userID string, req *ExportRequest) ([]ExportedSession, error) {
+	query := `
+		SELECT es.id, COALESCE(es.activity_id::text, ''), COALESCE(a.name, ''), COALESCE(es.session_name, ''),
+		       COALESCE(es.start_time, es.created_at), es.end_time,
+		       COALESCE(es.duration_hours, 0) * 3600 + COALESCE(es.duration_minutes, 0) * 60 + COALESCE(es.duration_seconds, 0),
+		       COALESCE(es.calories_burned, 0)::int, COALESCE(es.distance_m, 0)::float8,
+		       COALESCE(es.elevation_gain_m, 0)::float8,
+		       COALESCE(es.avg_heart_rate, 0), COALESCE(es.max_heart_rate, 0)
+		FROM exercise_session es
+		LEFT JOIN activity a ON a.id = es.activity_id
+		WHERE es.user_id = $1 AND `
+	args := []any{userID}
+	if req.SessionID != "" {
+		query += `es.id = $2`
+		args = append(args, req.SessionID)
+	} else {
+		query += `es.start_time >= $2 AND es.start_time < $3
+		ORDER BY es.start_time
+		LIMIT $4`
+		// one more than allowed, to tell a full range from a cut-off one
+		args = append(args, req.From.UTC(), req.To.UTC(), maxExportSessions+1)
+	}
+
+	rows, err := a.pgpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []ExportedSession
+	byID := map[string]*workoutfile.Activity{}
+	var ids []string
+	for rows.Next() {
+		var s ExportedSession
+		var end *time.Time
+		var seconds int
+		w := &workoutfile.Activity{}
+		if err = rows.Scan(&s.ID, &s.ActivityID, &s.ActivityName, &w.Name, &w.Start, &end, &seconds,
+			&w.Calories, &w.DistanceM, &w.ElevationGainM, &w.AvgHeartRate, &w.MaxHeartRate); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		// tracker sessions were saved without an end time
+		w.End = w.Start.Add(time.Duration(seconds) * time.Second)
+		if end != nil && end.After(w.Start) {
+			w.End = *end
+		}
+		w.Sport = workoutfile.NormaliseSport(s.ActivityName)
+		s.Workout = w
+		sessions = append(sessions, s)
+		byID[s.ID] = w
+		ids = append(ids, s.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	if len(sessions) > maxExportSessions {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"the range holds more than %d sessions; export a shorter one", maxExportSessions)
+	}
+
+	if err = a.loadExportLaps(ctx, ids, byID); err != nil {
+		return nil, err
+	}
+	if err = a.loadExportSamples(ctx, ids, byID); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (a *RepositoryActivity) loadExportLaps(ctx context.Context, ids []string, byID map[string]*workoutfile.Activity) error {
+	rows, err := a.pgpool.Query(ctx, `
+		SELECT session_id, start_time, elapsed_ms, distance_m::float8,
+		       COALESCE(calories, 0), COALESCE(avg_heart_rate, 0), COALESCE(max_heart_rate, 0)
+		FROM exercise_session_laps
+		WHERE session_id = ANY($1)
+		ORDER BY session_id, lap_number`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query laps: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID string
+		var elapsedMs int64
+		var l workoutfile.Lap
+		if err = rows.Scan(&sessionID, &l.Start, &elapsedMs, &l.DistanceM, &l.Calories, &l.AvgHeartRate, &l.MaxHeartRate); err != nil {
+			return fmt.Errorf("failed to scan lap: %w", err)
+		}
+		l.Elapsed = time.Duration(elapsedMs) * time.Millisecond
+		w := byID[sessionID]
+		w.Laps = append(w.Laps, l)
+	}
+	return rows.Err()
+}
+
+// sessionSamplesSQL is the track of the sessions in $1: the one saved with
+// an import, or for sessions recorded live, the samples their tracker
+// collected.
+const sessionSamplesSQL = `(
+		  SELECT session_id, seq, recorded_at, lat, lon, elevation_m, heart_rate, cadence, distance_m
+		  FROM exercise_session_samples
+		  WHERE session_id = ANY($1)
+		  UNION ALL
+		  SELECT t.exercise_session_id, s.seq, s.recorded_at AT TIME ZONE 'UTC', NULL, NULL, NULL,
+		         s.heart_rate, s.cadence, s.distance_m
+		  FROM activity_tracker_samples s
+		  JOIN activity_trackers t ON t.id = s.tracker_id
+		  WHERE t.exercise_session_id = ANY($1)
+		    AND NOT EXISTS (SELECT 1 FROM exercise_session_samples x WHERE x.session_id = t.exercise_session_id)
+		) samples`
+
+func (a *RepositoryActivity) loadExportSamples(ctx context.Context, ids []string, byID map[string]*workoutfile.Activity) error {
+	rows, err := a.pgpool.Query(ctx, `
+		SELECT session_id, recorded_at, lat, lon, elevation_m::float8, heart_rate, cadence, distance_m::float8
+		FROM `+sessionSamplesSQL+`
+		ORDER BY session_id, seq`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query samples: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID string
+		var p workoutfile.Point
+		var lat, lon, elevation, distance *float64
+		var heartRate, cadence *int
+		if err = rows.Scan(&sessionID, &p.Time, &lat, &lon, &elevation, &heartRate, &cadence, &distance); err != nil {
+			return fmt.Errorf("failed to scan sample: %w", err)
+		}
+		if lat != nil && lon != nil {
+			p.Lat, p.Lon, p.HasPosition = *lat, *lon, true
+		}
+		if elevation != nil {
+			p.ElevationM, p.HasElevation = *elevation, true
+		}
+		if heartRate != nil {
+			p.HeartRate = *heartRate
+		}
+		if cadence != nil {
+			p.Cadence = *cadence
+		}
+		if distance != nil {
+			p.DistanceM = *distance
+		}
+		w := byID[sessionID]
+		w.Points = append(w.Points, p)
+		// sessions recorded live only know their distance from the samples
+		if w.DistanceM < p.DistanceM {
+			w.DistanceM = p.DistanceM
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,113 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	pbw "github.com/FACorreiaa/fitme-protos/modules/workout/generated"
+	"google.golang.org/grpc"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/workoutfile"
+)
+
+func TestSessionsCSV(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	sessions := []ExportedSession{
+		{ID: "s1", ActivityName: "Running, general", Workout: &workoutfile.Activity{
+			Name: "Morning Run", Start: start, End: start.Add(time.Minute), Calories: 12, DistanceM: 180.04,
+			Points: []workoutfile.Point{
+				{Time: start, Lat: 38.7223, Lon: -9.1393, HasPosition: true, ElevationM: 0, HasElevation: true, HeartRate: 120},
+				{Time: start.Add(time.Minute), HeartRate: 140, DistanceM: 180},
+			},
+		}},
+		{ID: "s2", ActivityName: "Yoga", Workout: &workoutfile.Activity{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)}},
+	}
+
+	data, err := sessionsCSV(sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"s1", "Morning Run", "Running, general", "2024-05-01T07:00:00Z", "2024-05-01T07:01:00Z", "60", "12", "180", "", "", "",
+			"2024-05-01T07:00:00Z", "38.7223000", "-9.1393000", "0", "120", "", ""},
+		{"s1", "Morning Run", "Running, general", "2024-05-01T07:00:00Z", "2024-05-01T07:01:00Z", "60", "12", "180", "", "", "",
+			"2024-05-01T07:01:00Z", "", "", "", "140", "", "180"},
+		{"s2", "", "Yoga", "2024-05-01T08:00:00Z", "2024-05-01T09:00:00Z", "3600", "0", "", "", "", "",
+			"", "", "", "", "", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d:\n%s", len(rows), len(want), data)
+	}
+	for i := range want {
+		if len(rows[i]) != len(want[i]) {
+			t.Fatalf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("row %d %s = %q, want %q", i, csvHeader[j], rows[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestExportRequestValidate(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		req  ExportRequest
+		ok   bool
+	}{
+		{"session", ExportRequest{SessionID: "5b0c1e44-8f5e-4a51-9d0b-2a3f4f1f1b11"}, true},
+		{"bad session id", ExportRequest{SessionID: "42"}, false},
+		{"range", ExportRequest{From: from, To: from.AddDate(0, 1, 0)}, true},
+		{"reversed range", ExportRequest{From: from, To: from.AddDate(0, -1, 0)}, false},
+		{"two years", ExportRequest{From: from, To: from.AddDate(2, 0, 0)}, false},
+		{"nothing", ExportRequest{}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.req.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v", tt.name, err)
+		}
+	}
+
+	for format, ok := range map[string]bool{"gpx": true, "TCX": true, "csv": true, "fit": false, "kml": false} {
+		if _, err := parseExportFormat(format); (err == nil) != ok {
+			t.Errorf("parseExportFormat(%q) = %v", format, err)
+		}
+	}
+}
+
+type chunkRecorder struct {
+	grpc.ServerStream
+	chunks []*pbw.FileChunk
+}
+
+func (c *chunkRecorder) Send(chunk *pbw.FileChunk) error {
+	c.chunks = append(c.chunks, chunk)
+	return nil
+}
+
+func TestSendFileChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2*exportChunkSize+10)
+	stream := &chunkRecorder{}
+	if err := sendFileChunks(stream, "sessions.csv", "text/csv", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.chunks) != 3 || len(stream.chunks[2].Content) != 10 {
+		t.Fatalf("got %d chunks", len(stream.chunks))
+	}
+	first := stream.chunks[0]
+	if !first.IsFirstChunk || first.FileName != "sessions.csv" || first.ContentType != "text/csv" {
+		t.Errorf("first chunk = %+v", first)
+	}
+	if stream.chunks[1].IsFirstChunk || stream.chunks[1].FileName != "" {
+		t.Errorf("second chunk carries metadata: %+v", stream.chunks[1])
+	}
+}
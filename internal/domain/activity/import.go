@@ -0,0 +1,393 @@
+package activity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
+	pbw "github.com/FACorreiaa/fitme-protos/modules/workout/generated"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/workoutfile"
+)
+
+// Uploads above this are refused before they're parsed. A day-long FIT
+// recording at one second is a few megabytes.
+const maxImportBytes = 25 << 20
+
+// ImportWorkout files the session under this catalog activity instead of
+// the one matching the file's sport.
+const activityIDHeader = "x-activity-id"
+
+// importSource marks imported rows in exercise_session.source.
+const importSource = "IMPORT"
+
+// sportActivities are the catalog entries each file sport falls back to.
+var sportActivities = map[string]string{
+	"running":  "Running, general",
+	"cycling":  "Cycling, general",
+	"swimming": "Swimming, general",
+	"walking":  "Walking, general",
+	"hiking":   "Hiking, general",
+	"generic":  "Exercise, general",
+}
+
+// CalorieSource says where an imported session's burn came from.
+type CalorieSource string
+
+const (
+	CaloriesFromFile      CalorieSource = "FILE"
+	CaloriesFromHeartRate CalorieSource = "HEART_RATE"
+	CaloriesFromMET       CalorieSource = "MET"
+)
+
+// ImportResult answers a workout upload.
+type ImportResult struct {
+	SessionID       string             `json:"session_id"`
+	ActivityID      string             `json:"activity_id"`
+	SessionName     string             `json:"session_name"`
+	Format          workoutfile.Format `json:"format"`
+	Sport           string             `json:"sport"`
+	StartTime       time.Time          `json:"start_time"`
+	EndTime         time.Time          `json:"end_time"`
+	DurationSeconds int                `json:"duration_seconds"`
+	DistanceM       float64            `json:"distance_m"`
+	ElevationGainM  float64            `json:"elevation_gain_m"`
+	AvgHeartRate    int                `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate    int                `json:"max_heart_rate,omitempty"`
+	CaloriesBurned  int                `json:"calories_burned"`
+	CalorieSource   CalorieSource      `json:"calorie_source"`
+	Laps            int                `json:"laps"`
+	Points          int                `json:"points"`
+}
+
+// CatalogActivity is the catalog entry an import is filed under.
+type CatalogActivity struct {
+	ID   string
+	Name string
+	MET  float64
+}
+
+// ImportedSession is a parsed workout file ready to be saved.
+type ImportedSession struct {
+	UserID      string
+	Activity    CatalogActivity
+	SessionName string
+	Hash        string
+	Calories    int
+	Workout     *workoutfile.Activity
+}
+
+// WorkoutFileRepository is implemented by RepositoryActivity.
+type WorkoutFileRepository interface {
+	// ImportActivity finds activityID in the catalog, or the entry for
+	// sport when activityID is empty.
+	ImportActivity(ctx context.Context, userID, activityID, sport string) (CatalogActivity, error)
+	// ImportSession saves the session with its laps and track points and
+	// returns its id.
+	ImportSession(ctx context.Context, s *ImportedSession) (string, error)
+	// ExportSessions loads the user's sessions with their laps and samples,
+	// oldest first.
+	ExportSessions(ctx context.Context, userID string, req *ExportRequest) ([]ExportedSession, error)
+}
+
+func errAlreadyImported(sessionID string) error {
+	return status.Errorf(codes.AlreadyExists, "workout already imported as session %s", sessionID)
+}
+
+// ImportWorkout takes a GPX, TCX or FIT file streamed in chunks, the name
+// on the first, and saves it as an exercise session. The same file, or the
+// same workout exported in another format, is refused as already imported.
+func (a *ServiceActivity) ImportWorkout(stream grpc.ClientStreamingServer[pbw.FileChunk, ImportResult]) error {
+	ctx := stream.Context()
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/ImportWorkout")
+	defer span.End()
+
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
+
+	var activityID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(activityIDHeader); len(v) > 0 {
+			activityID = v[0]
+		}
+	}
+	if activityID != "" {
+		if _, err := uuid.Parse(activityID); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid %s header", activityIDHeader)
+		}
+	}
+
+	name, data, err := receiveWorkoutFile(stream)
+	if err != nil {
+		return err
+	}
+	workout, err := workoutfile.Parse("", name, data)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	span.SetAttributes(
+		attribute.String("import.format", string(workout.Format)),
+		attribute.Int("import.bytes", len(data)),
+		attribute.Int("import.points", len(workout.Points)),
+	)
+
+	catalog, err := a.files.ImportActivity(ctx, userID, activityID, workout.Sport)
+	if err != nil {
+		return err
+	}
+	profile, err := a.trackers.Profile(ctx, userID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	kcal, source := importCalories(workout, catalog.MET, profile)
+
+	sum := sha256.Sum256(data)
+	imported := &ImportedSession{
+		UserID:      userID,
+		Activity:    catalog,
+		SessionName: workout.Name,
+		Hash:        hex.EncodeToString(sum[:]),
+		Calories:    kcal,
+		Workout:     workout,
+	}
+	if imported.SessionName == "" {
+		imported.SessionName = catalog.Name
+	}
+	sessionID, err := a.files.ImportSession(ctx, imported)
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return stream.SendAndClose(&ImportResult{
+		SessionID:       sessionID,
+		ActivityID:      catalog.ID,
+		SessionName:     imported.SessionName,
+		Format:          workout.Format,
+		Sport:           workout.Sport,
+		StartTime:       workout.Start,
+		EndTime:         workout.End,
+		DurationSeconds: int(workout.Duration().Seconds()),
+		DistanceM:       workout.DistanceM,
+		ElevationGainM:  workout.ElevationGainM,
+		AvgHeartRate:    workout.AvgHeartRate,
+		MaxHeartRate:    workout.MaxHeartRate,
+		CaloriesBurned:  kcal,
+		CalorieSource:   source,
+		Laps:            len(workout.Laps),
+		Points:          len(workout.Points),
+	})
+}
+
+// receiveWorkoutFile reads the chunks up to the end of the stream.
+func receiveWorkoutFile(stream grpc.ClientStreamingServer[pbw.FileChunk, ImportResult]) (string, []byte, error) {
+	var name string
+	var data []byte
+	for first := true; ; first = false {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if first {
+			if !chunk.IsFirstChunk {
+				return "", nil, status.Error(codes.InvalidArgument, "the first chunk must be marked as first")
+			}
+			name = chunk.FileName
+		}
+		if len(data)+len(chunk.Content) > maxImportBytes {
+			return "", nil, status.Errorf(codes.ResourceExhausted, "workout files are limited to %d MiB", maxImportBytes>>20)
+		}
+		data = append(data, chunk.Content...)
+	}
+	if len(data) == 0 {
+		return "", nil, status.Error(codes.InvalidArgument, "workout file is empty")
+	}
+	return name, data, nil
+}
+
+// importCalories trusts the device's total first, then a heart rate
+// estimate, then the activity's METs.
+func importCalories(w *workoutfile.Activity, met float64, p Profile) (int, CalorieSource) {
+	if w.Calories > 0 {
+		return w.Calories, CaloriesFromFile
+	}
+	samples := make([]Sample, 0, len(w.Points))
+	for i, pt := range w.Points {
+		samples = append(samples, Sample{Seq: int64(i + 1), RecordedAt: pt.Time, HeartRate: pt.HeartRate})
+	}
+	if kcal, ok := heartRateCalories(samples, p, w.End); ok {
+		return int(kcal), CaloriesFromHeartRate
+	}
+	return int(metCalories(met, p.WeightKg, w.Duration())), CaloriesFromMET
+}
+
+func (a *RepositoryActivity) ImportActivity(ctx context.Context, userID, activityID, sport string) (CatalogActivity, error) {
+	query := `
+		SELECT a.id, COALESCE(a.name, ''), COALESCE(a.met, 0)::float8
+		FROM activity a
+		WHERE a.id = $2 AND ` + visibleActivity
+	args := []any{userID, activityID}
+	if activityID == "" {
+		query = `
+			SELECT id, COALESCE(name, ''), COALESCE(met, 0)::float8
+			FROM activity
+			WHERE name = $1 AND user_id IS NULL AND deleted_at IS NULL
+			ORDER BY created_at
+			LIMIT 1`
+		name, ok := sportActivities[sport]
+		if !ok {
+			name = sportActivities["generic"]
+		}
+		args = []any{name}
+	}
+
+	var c CatalogActivity
+	err := a.pgpool.QueryRow(ctx, query, args...).Scan(&c.ID, &c.Name, &c.MET)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CatalogActivity{}, status.Error(codes.NotFound, "activity not found")
+	}
+	if err != nil {
+		return CatalogActivity{}, status.Errorf(codes.Internal, "failed to fetch activity: %v", err)
+	}
+	return c, nil
+}
+
+// ImportSession checks for an earlier import of the same file, or of a
+// session with the same start and end give or take a second, before saving.
+// The unique index on the hash settles two uploads racing each other.
+func (a *RepositoryActivity) ImportSession(ctx context.Context, s *ImportedSession) (string, error) {
+	w := s.Workout
+	var sessionID string
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var existing string
+		err := tx.QueryRow(ctx, `
+			SELECT id FROM exercise_session
+			WHERE user_id = $1
+			  AND (import_hash = $2
+			    OR (start_time BETWEEN $3::timestamp - interval '1 second' AND $3::timestamp + interval '1 second'
+			    AND end_time BETWEEN $4::timestamp - interval '1 second' AND $4::timestamp + interval '1 second'))
+			LIMIT 1`, s.UserID, s.Hash, w.Start, w.End).Scan(&existing)
+		switch {
+		case err == nil:
+			return errAlreadyImported(existing)
+		case !errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("failed to check for earlier imports: %w", err)
+		}
+
+		seconds := int(w.Duration().Seconds())
+		sessionID, err = insertSession(ctx, tx, &pba.XExerciseSession{
+			UserId:          s.UserID,
+			ActivityId:      s.Activity.ID,
+			SessionName:     s.SessionName,
+			StartTime:       timestamppb.New(w.Start),
+			EndTime:         timestamppb.New(w.End),
+			DurationHours:   uint32(seconds / 3600),
+			DurationMinutes: uint32((seconds % 3600) / 60),
+			DurationSeconds: uint32(seconds % 60),
+			CaloriesBurned:  uint32(s.Calories),
+			CreatedAt:       timestamppb.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE exercise_session
+			SET distance_m = $2, elevation_gain_m = $3,
+			    avg_heart_rate = NULLIF($4, 0), max_heart_rate = NULLIF($5, 0),
+			    source = $6, import_format = $7, import_hash = $8
+			WHERE id = $1`,
+			sessionID, w.DistanceM, w.ElevationGainM, w.AvgHeartRate, w.MaxHeartRate,
+			importSource, string(w.Format), s.Hash)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return status.Error(codes.AlreadyExists, "workout file already imported")
+			}
+			return fmt.Errorf("failed to save import details: %w", err)
+		}
+
+		if err = copyLaps(ctx, tx, sessionID, w.Laps); err != nil {
+			return err
+		}
+		return copyPoints(ctx, tx, sessionID, w.Points)
+	})
+	return sessionID, err
+}
+
+func copyLaps(ctx context.Context, tx pgx.Tx, sessionID string, laps []workoutfile.Lap) error {
+	if len(laps) == 0 {
+		return nil
+	}
+	rows := make([][]any, 0, len(laps))
+	for i, l := range laps {
+		rows = append(rows, []any{
+			sessionID, i + 1, l.Start, l.Elapsed.Milliseconds(), l.DistanceM,
+			nullIfZero(l.Calories), nullIfZero(l.AvgHeartRate), nullIfZero(l.MaxHeartRate),
+		})
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"exercise_session_laps"},
+		[]string{"session_id", "lap_number", "start_time", "elapsed_ms", "distance_m", "calories", "avg_heart_rate", "max_heart_rate"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to save laps: %w", err)
+	}
+	return nil
+}
+
+func copyPoints(ctx context.Context, tx pgx.Tx, sessionID string, points []workoutfile.Point) error {
+	rows := make([][]any, 0, len(points))
+	for i, p := range points {
+		var lat, lon, elevation, distance any
+		if p.HasPosition {
+			lat, lon = p.Lat, p.Lon
+		}
+		if p.HasElevation {
+			elevation = p.ElevationM
+		}
+		if p.DistanceM > 0 {
+			distance = p.DistanceM
+		}
+		rows = append(rows, []any{
+			sessionID, i + 1, p.Time, lat, lon, elevation,
+			nullIfZero(p.HeartRate), nullIfZero(p.Cadence), distance,
+		})
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"exercise_session_samples"},
+		[]string{"session_id", "seq", "recorded_at", "lat", "lon", "elevation_m", "heart_rate", "cadence", "distance_m"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to save track points: %w", err)
+	}
+	return nil
+}
+
+func nullIfZero(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
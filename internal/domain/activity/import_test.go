@@ -0,0 +1,46 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/workoutfile"
+)
+
+func TestImportCalories(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	track := func(hr int) *workoutfile.Activity {
+		w := &workoutfile.Activity{Start: start, End: start.Add(10 * time.Minute)}
+		for i := 0; i <= 20; i++ {
+			w.Points = append(w.Points, workoutfile.Point{Time: start.Add(time.Duration(i) * 30 * time.Second), HeartRate: hr})
+		}
+		return w
+	}
+	male := Profile{WeightKg: 80, Age: 35, Gender: "MALE"}
+
+	withTotal := track(150)
+	withTotal.Calories = 95
+
+	tests := []struct {
+		name    string
+		workout *workoutfile.Activity
+		profile Profile
+		want    int
+		source  CalorieSource
+	}{
+		{"device total", withTotal, male, 95, CaloriesFromFile},
+		// 14.94 kcal/min, see TestHeartRateCalories
+		{"heart rate", track(150), male, 149, CaloriesFromHeartRate},
+		// 8 METs * 80 kg for a sixth of an hour
+		{"no heart rate", track(0), male, 106, CaloriesFromMET},
+		{"no gender", track(150), Profile{WeightKg: 80, Age: 35}, 106, CaloriesFromMET},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source := importCalories(tt.workout, 8, tt.profile)
+			if got != tt.want || source != tt.source {
+				t.Errorf("importCalories = %d, %s, want %d, %s", got, source, tt.want, tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalKind is whether a planned step is effort or recovery.
+type IntervalKind string
+
+const (
+	IntervalWork IntervalKind = "WORK"
+	IntervalRest IntervalKind = "REST"
+)
+
+const (
+	maxIntervalRepeats = 100
+	maxIntervalSteps   = 10
+	minIntervalStep    = time.Second
+	maxIntervalStep    = 2 * time.Hour
+	maxIntervalPlan    = 6 * time.Hour
+)
+
+// IntervalStep is one timed part of a round.
+type IntervalStep struct {
+	Kind     IntervalKind  `json:"kind"`
+	Duration time.Duration `json:"duration"`
+}
+
+// IntervalPlan is a round of steps repeated Repeats times, written as
+// 8x(30s on/90s off). Steps are Go durations followed by on or work for
+// effort and off or rest for recovery.
+type IntervalPlan struct {
+	Repeats int            `json:"repeats"`
+	Steps   []IntervalStep `json:"steps"`
+}
+
+// ParseIntervalPlan reads a plan such as 8x(30s on/90s off) or
+// 5×(3m work/1m rest). A single round may leave out the repeat count.
+func ParseIntervalPlan(s string) (*IntervalPlan, error) {
+	spec := strings.ToLower(strings.TrimSpace(s))
+	if spec == "" {
+		return nil, errors.New("interval plan is empty")
+	}
+
+	p := &IntervalPlan{Repeats: 1}
+	if i := strings.IndexAny(spec, "x×"); i > 0 && !strings.ContainsAny(spec[:i], "(/") {
+		n, err := strconv.Atoi(strings.TrimSpace(spec[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid repeat count in interval plan %q", s)
+		}
+		p.Repeats = n
+		spec = strings.TrimSpace(strings.TrimPrefix(spec[i:], "x"))
+		spec = strings.TrimSpace(strings.TrimPrefix(spec, "×"))
+	}
+	if strings.HasPrefix(spec, "(") && strings.HasSuffix(spec, ")") {
+		spec = spec[1 : len(spec)-1]
+	}
+
+	for _, part := range strings.Split(spec, "/") {
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("interval step %q needs a duration and on or off", strings.TrimSpace(part))
+		}
+		d, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q in interval plan", fields[0])
+		}
+		step := IntervalStep{Duration: d}
+		switch fields[1] {
+		case "on", "work":
+			step.Kind = IntervalWork
+		case "off", "rest":
+			step.Kind = IntervalRest
+		default:
+			return nil, fmt.Errorf("interval step %q must be on or off", strings.TrimSpace(part))
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *IntervalPlan) validate() error {
+	switch {
+	case p.Repeats < 1 || p.Repeats > maxIntervalRepeats:
+		return fmt.Errorf("interval plans repeat between 1 and %d times", maxIntervalRepeats)
+	case len(p.Steps) == 0 || len(p.Steps) > maxIntervalSteps:
+		return fmt.Errorf("interval rounds have between 1 and %d steps", maxIntervalSteps)
+	}
+	for _, s := range p.Steps {
+		if s.Duration < minIntervalStep || s.Duration > maxIntervalStep {
+			return fmt.Errorf("interval steps last between %s and %s", minIntervalStep, maxIntervalStep)
+		}
+	}
+	if p.Total() > maxIntervalPlan {
+		return fmt.Errorf("interval plans last at most %s", formatStep(maxIntervalPlan))
+	}
+	return nil
+}
+
+// String writes p the way ParseIntervalPlan reads it; it is what trackers
+// store.
+func (p *IntervalPlan) String() string {
+	steps := make([]string, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		kind := "on"
+		if s.Kind == IntervalRest {
+			kind = "off"
+		}
+		steps = append(steps, formatStep(s.Duration)+" "+kind)
+	}
+	return fmt.Sprintf("%dx(%s)", p.Repeats, strings.Join(steps, "/"))
+}
+
+// formatStep drops the zero units time.Duration prints, so two minutes is
+// 2m rather than 2m0s.
+func formatStep(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// Total is the active time the plan takes.
+func (p *IntervalPlan) Total() time.Duration {
+	var round time.Duration
+	for _, s := range p.Steps {
+		round += s.Duration
+	}
+	return round * time.Duration(p.Repeats)
+}
+
+// IntervalState is where a plan stands after some active time. Step counts
+// across rounds from 1; Done is set once the last step has run out.
+type IntervalState struct {
+	Round            int          `json:"round"`
+	Rounds           int          `json:"rounds"`
+	Step             int          `json:"step"`
+	Steps            int          `json:"steps"`
+	Kind             IntervalKind `json:"kind,omitempty"`
+	RemainingSeconds int          `json:"remaining_seconds"`
+	Done             bool         `json:"done,omitempty"`
+}
+
+// At is the plan's state after active training time.
+func (p *IntervalPlan) At(active time.Duration) IntervalState {
+	state := IntervalState{Rounds: p.Repeats, Steps: p.Repeats * len(p.Steps)}
+	var elapsed time.Duration
+	for round := 0; round < p.Repeats; round++ {
+		for i, s := range p.Steps {
+			elapsed += s.Duration
+			if active < elapsed {
+				state.Round = round + 1
+				state.Step = round*len(p.Steps) + i + 1
+				state.Kind = s.Kind
+				state.RemainingSeconds = int((elapsed - active + time.Second - 1) / time.Second)
+				return state
+			}
+		}
+	}
+	state.Round, state.Step, state.Done = p.Repeats, state.Steps, true
+	return state
+}
+
+// boundaries are the active times each step after the first starts at,
+// with the kind of the step starting there; the last one is the end of the
+// plan, where no step starts.
+func (p *IntervalPlan) boundaries() ([]time.Duration, []IntervalKind) {
+	var at []time.Duration
+	var kinds []IntervalKind
+	var elapsed time.Duration
+	for round := 0; round < p.Repeats; round++ {
+		for i, s := range p.Steps {
+			elapsed += s.Duration
+			at = append(at, elapsed)
+			if next := i + 1; next < len(p.Steps) {
+				kinds = append(kinds, p.Steps[next].Kind)
+			} else if round+1 < p.Repeats {
+				kinds = append(kinds, p.Steps[0].Kind)
+			} else {
+				kinds = append(kinds, "")
+			}
+		}
+	}
+	return at, kinds
+}
+
+// announcement is what a client reads out when a step begins.
+func (s IntervalState) announcement(p *IntervalPlan) string {
+	if s.Done {
+		return "Intervals complete"
+	}
+	step := p.Steps[(s.Step-1)%len(p.Steps)]
+	kind := "Work"
+	if step.Kind == IntervalRest {
+		kind = "Rest"
+	}
+	return fmt.Sprintf("Round %d of %d: %s for %s", s.Round, s.Rounds, kind, formatStep(step.Duration))
+}
@@ -0,0 +1,69 @@
+package activity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIntervalPlan(t *testing.T) {
+	tests := []struct {
+		in, want string
+		total    time.Duration
+		wantErr  bool
+	}{
+		{in: "8x(30s on/90s off)", want: "8x(30s on/1m30s off)", total: 16 * time.Minute},
+		{in: "5×(3m work / 1m rest)", want: "5x(3m on/1m off)", total: 20 * time.Minute},
+		{in: "20m on", want: "1x(20m on)", total: 20 * time.Minute},
+		{in: "4 x (1h0m0s on/5m off)", want: "4x(1h on/5m off)", total: 260 * time.Minute},
+		{in: "", wantErr: true},
+		{in: "8x(30s on/90s)", wantErr: true},
+		{in: "8x(30 on/90s off)", wantErr: true},
+		{in: "0x(30s on)", wantErr: true},
+		{in: "8x(30s sprint)", wantErr: true},
+		{in: "100x(5m on)", wantErr: true}, // over six hours
+	}
+	for _, tt := range tests {
+		p, err := ParseIntervalPlan(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseIntervalPlan(%q) = %v, want an error", tt.in, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIntervalPlan(%q): %v", tt.in, err)
+			continue
+		}
+		if p.String() != tt.want || p.Total() != tt.total {
+			t.Errorf("ParseIntervalPlan(%q) = %s lasting %s, want %s lasting %s", tt.in, p, p.Total(), tt.want, tt.total)
+		}
+		if again, err := ParseIntervalPlan(p.String()); err != nil || !reflect.DeepEqual(again, p) {
+			t.Errorf("%s does not parse back: %v, %v", p, again, err)
+		}
+	}
+}
+
+func TestIntervalPlanAt(t *testing.T) {
+	p, err := ParseIntervalPlan("3x(30s on/90s off)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		active time.Duration
+		want   IntervalState
+	}{
+		{0, IntervalState{Round: 1, Rounds: 3, Step: 1, Steps: 6, Kind: IntervalWork, RemainingSeconds: 30}},
+		{45 * time.Second, IntervalState{Round: 1, Rounds: 3, Step: 2, Steps: 6, Kind: IntervalRest, RemainingSeconds: 75}},
+		{2*time.Minute + 500*time.Millisecond, IntervalState{Round: 2, Rounds: 3, Step: 3, Steps: 6, Kind: IntervalWork, RemainingSeconds: 30}},
+		{6 * time.Minute, IntervalState{Round: 3, Rounds: 3, Step: 6, Steps: 6, Done: true}},
+	}
+	for _, tt := range tests {
+		if got := p.At(tt.active); got != tt.want {
+			t.Errorf("At(%s) = %+v, want %+v", tt.active, got, tt.want)
+		}
+	}
+	if got := p.At(45 * time.Second).announcement(p); got != "Round 1 of 3: Rest for 1m30s" {
+		t.Errorf("announcement = %q", got)
+	}
+}
@@ -0,0 +1,314 @@
+package activity
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// intervalPlanHeader carries the plan a unary tracker start should follow.
+const intervalPlanHeader = "x-interval-plan"
+
+// SessionLap is one lap of a stored session. Laps recorded live end at a
+// lap mark or at a planned step change, and carry the step's kind; imported
+// laps are the device's. Duration is active time, pauses left out.
+type SessionLap struct {
+	Number          int          `json:"number"`
+	Kind            IntervalKind `json:"kind,omitempty"`
+	Start           time.Time    `json:"start"`
+	DurationSeconds int          `json:"duration_seconds"`
+	Calories        int          `json:"calories"`
+	DistanceM       float64      `json:"distance_m,omitempty"`
+	AvgHeartRate    int          `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate    int          `json:"max_heart_rate,omitempty"`
+}
+
+// plan is the interval plan the tracker follows, nil when there is none.
+// Stored plans were written by IntervalPlan.String, so they parse.
+func (t Tracker) plan() *IntervalPlan {
+	if t.Plan == "" {
+		return nil
+	}
+	p, err := ParseIntervalPlan(t.Plan)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// wallTime is when the tracker had been active for active, pauses counted
+// in.
+func (t Tracker) wallTime(active time.Duration) time.Time {
+	at := t.StartedAt.Add(active)
+	for _, p := range t.Pauses {
+		if !p.PausedAt.Before(at) {
+			break
+		}
+		if p.ResumedAt != nil {
+			at = at.Add(p.ResumedAt.Sub(p.PausedAt))
+		}
+	}
+	return at
+}
+
+// splitLaps breaks the tracker's time up to end into laps at every lap
+// mark and every step change of its plan. A workout that was never split is
+// one lap, and has no breakdown.
+func splitLaps(t Tracker, samples []Sample, end time.Time) []SessionLap {
+	total := t.Active(end)
+	type cut struct {
+		active time.Duration
+		kind   IntervalKind
+		step   bool
+	}
+	var cuts []cut
+	plan := t.plan()
+	if plan != nil {
+		at, kinds := plan.boundaries()
+		for i, d := range at {
+			if d < total {
+				cuts = append(cuts, cut{active: d, kind: kinds[i], step: true})
+			}
+		}
+	}
+	// a mark at end closes the last lap rather than opening an empty one
+	for _, mark := range t.Laps {
+		if d := t.Active(mark); d > 0 && d <= total {
+			cuts = append(cuts, cut{active: d})
+		}
+	}
+	if len(cuts) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(cuts, func(a, b cut) int { return cmp.Compare(a.active, b.active) })
+
+	firstKind := IntervalKind("")
+	if plan != nil {
+		firstKind = plan.Steps[0].Kind
+	}
+	laps := make([]SessionLap, 0, len(cuts)+1)
+	from, kind := time.Duration(0), firstKind
+	for _, c := range append(cuts, cut{active: total}) {
+		if c.active <= from {
+			// a lap mark on a step change
+			if c.step {
+				kind = c.kind
+			}
+			continue
+		}
+		laps = append(laps, lapBetween(t, samples, len(laps)+1, kind, from, c.active))
+		from = c.active
+		if c.step {
+			kind = c.kind
+		}
+	}
+	return laps
+}
+
+// lapBetween sums the lap between two active times: MET calories, the
+// distance covered and the heart rate readings taken during it.
+func lapBetween(t Tracker, samples []Sample, number int, kind IntervalKind, from, to time.Duration) SessionLap {
+	start, end := t.wallTime(from), t.wallTime(to)
+	lap := SessionLap{
+		Number:          number,
+		Kind:            kind,
+		Start:           start.UTC(),
+		DurationSeconds: int(math.Round((to - from).Seconds())),
+		Calories:        int(math.Round(metCalories(t.MET, t.WeightKg, to-from))),
+	}
+
+	var startDistance, endDistance float64
+	hrSum, hrCount := 0, 0
+	for _, s := range samples {
+		if s.RecordedAt.After(end) {
+			break
+		}
+		if s.DistanceM > 0 {
+			if !s.RecordedAt.After(start) {
+				startDistance = s.DistanceM
+			}
+			endDistance = s.DistanceM
+		}
+		if s.RecordedAt.Before(start) || !s.RecordedAt.Before(end) || s.HeartRate <= 0 {
+			continue
+		}
+		hrSum += s.HeartRate
+		hrCount++
+		lap.MaxHeartRate = max(lap.MaxHeartRate, s.HeartRate)
+	}
+	if endDistance > startDistance {
+		lap.DistanceM = round1(endDistance - startDistance)
+	}
+	if hrCount > 0 {
+		lap.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrCount)))
+	}
+	return lap
+}
+
+// markLap ends the current lap of a live tracker and returns it.
+func (a *ServiceActivity) markLap(ctx context.Context, live *liveSession) (*SessionLap, error) {
+	if live.tracker.Status != TrackerRunning {
+		return nil, status.Error(codes.FailedPrecondition, "activity tracker is not running")
+	}
+	t, err := a.trackers.MarkLap(ctx, live.userID, live.tracker.ID)
+	if err != nil {
+		return nil, err
+	}
+	live.tracker = t
+	mark := t.Laps[len(t.Laps)-1]
+	laps := splitLaps(*t, live.samples, mark)
+	if len(laps) == 0 {
+		return nil, nil
+	}
+	return &laps[len(laps)-1], nil
+}
+
+// SessionLaps is the per-lap breakdown of one of the user's sessions,
+// empty when it was never split.
+func (a *ServiceActivity) SessionLaps(ctx context.Context, userID, sessionID string) ([]SessionLap, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/SessionLaps")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	if sessionID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Session ID is required")
+	}
+	laps, err := a.trackers.SessionLaps(ctx, userID, sessionID)
+	if err != nil {
+		return nil, trackerError(err)
+	}
+	span.SetAttributes(attribute.Int("session.laps", len(laps)))
+	return laps, nil
+}
+
+// MarkLap records a lap mark on a running tracker.
+func (a *RepositoryActivity) MarkLap(ctx context.Context, userID, trackerID string) (*Tracker, error) {
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if t, err = loadUserTracker(ctx, tx, userID, trackerID); err != nil {
+			return err
+		}
+		if t.Status != TrackerRunning {
+			return status.Error(codes.FailedPrecondition, "activity tracker is not running")
+		}
+
+		now := time.Now().UTC()
+		if _, err = tx.Exec(ctx, `
+			INSERT INTO activity_tracker_laps (tracker_id, lap_number, marked_at)
+			VALUES ($1, $2, $3)`, t.ID, len(t.Laps)+1, now); err != nil {
+			return fmt.Errorf("failed to record lap: %w", err)
+		}
+		if err = setTrackerStatus(ctx, tx, t, TrackerRunning, now); err != nil {
+			return err
+		}
+		t.Laps = append(t.Laps, now)
+		return nil
+	})
+	return t, err
+}
+
+func loadLapMarks(ctx context.Context, tx pgx.Tx, t *Tracker) error {
+	rows, err := tx.Query(ctx, `
+		SELECT marked_at
+		FROM activity_tracker_laps
+		WHERE tracker_id = $1
+		ORDER BY lap_number`, t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch tracker laps: %w", err)
+	}
+	defer rows.Close()
+
+	t.Laps = make([]time.Time, 0)
+	for rows.Next() {
+		var at time.Time
+		if err = rows.Scan(&at); err != nil {
+			return fmt.Errorf("failed to scan tracker lap: %w", err)
+		}
+		t.Laps = append(t.Laps, at)
+	}
+	return rows.Err()
+}
+
+// saveTrackerLaps stores the breakdown of a tracker being saved as
+// sessionID, when it was split at all.
+func saveTrackerLaps(ctx context.Context, tx pgx.Tx, t *Tracker, sessionID string, end time.Time) error {
+	if len(t.Laps) == 0 && t.Plan == "" {
+		return nil
+	}
+	samples, err := querySamples(ctx, tx, t.ID)
+	if err != nil {
+		return err
+	}
+	laps := splitLaps(*t, samples, end)
+	if len(laps) == 0 {
+		return nil
+	}
+
+	rows := make([][]any, 0, len(laps))
+	for _, l := range laps {
+		var kind any
+		if l.Kind != "" {
+			kind = string(l.Kind)
+		}
+		rows = append(rows, []any{
+			sessionID, l.Number, l.Start, int64(l.DurationSeconds) * 1000, l.DistanceM,
+			l.Calories, nullIfZero(l.AvgHeartRate), nullIfZero(l.MaxHeartRate), kind,
+		})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"exercise_session_laps"},
+		[]string{"session_id", "lap_number", "start_time", "elapsed_ms", "distance_m", "calories", "avg_heart_rate", "max_heart_rate", "kind"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to save laps: %w", err)
+	}
+	return nil
+}
+
+func (a *RepositoryActivity) SessionLaps(ctx context.Context, userID, sessionID string) ([]SessionLap, error) {
+	var owner string
+	err := a.pgpool.QueryRow(ctx, `SELECT user_id::text FROM exercise_session WHERE id = $1`, sessionID).Scan(&owner)
+	if err != nil || owner != userID {
+		if err == nil || errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	rows, err := a.pgpool.Query(ctx, `
+		SELECT lap_number, COALESCE(kind, ''), start_time, elapsed_ms, distance_m::float8,
+		       COALESCE(calories, 0), COALESCE(avg_heart_rate, 0), COALESCE(max_heart_rate, 0)
+		FROM exercise_session_laps
+		WHERE session_id = $1
+		ORDER BY lap_number`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch laps: %w", err)
+	}
+	defer rows.Close()
+
+	laps := make([]SessionLap, 0)
+	for rows.Next() {
+		var l SessionLap
+		var elapsedMs int64
+		if err = rows.Scan(&l.Number, &l.Kind, &l.Start, &elapsedMs, &l.DistanceM,
+			&l.Calories, &l.AvgHeartRate, &l.MaxHeartRate); err != nil {
+			return nil, fmt.Errorf("failed to scan lap: %w", err)
+		}
+		l.DurationSeconds = int(math.Round(float64(elapsedMs) / 1000))
+		laps = append(laps, l)
+	}
+	return laps, rows.Err()
+}
@@ -0,0 +1,62 @@
+package activity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitLaps(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	resumed := at(100)
+	tracker := Tracker{
+		MET: 10, WeightKg: 72, StartedAt: start,
+		// a 40s pause in the first rest
+		Pauses: []Pause{{PausedAt: at(60), ResumedAt: &resumed}},
+		Plan:   "2x(30s on/60s off)",
+	}
+	var samples []Sample
+	for s := 0; s <= 240; s += 10 {
+		samples = append(samples, Sample{Seq: int64(s/10 + 1), RecordedAt: at(s), HeartRate: 120 + s/10, DistanceM: float64(s) * 3})
+	}
+
+	laps := splitLaps(tracker, samples, at(240))
+	want := []struct {
+		kind     IntervalKind
+		start    time.Time
+		duration int
+	}{
+		{IntervalWork, at(0), 30},
+		{IntervalRest, at(30), 60},
+		{IntervalWork, at(130), 30},
+		{IntervalRest, at(160), 60},
+		{"", at(220), 20}, // after the plan
+	}
+	if len(laps) != len(want) {
+		t.Fatalf("got %d laps: %+v", len(laps), laps)
+	}
+	for i, w := range want {
+		l := laps[i]
+		if l.Number != i+1 || l.Kind != w.kind || !l.Start.Equal(w.start) || l.DurationSeconds != w.duration {
+			t.Errorf("lap %d = %+v, want %s from %s for %ds", i+1, l, w.kind, w.start.Format(time.TimeOnly), w.duration)
+		}
+	}
+	// 10 METs at 72 kg is 12 kcal a minute
+	if laps[1].Calories != 12 || laps[0].Calories != 6 {
+		t.Errorf("lap calories = %d, %d", laps[0].Calories, laps[1].Calories)
+	}
+	if laps[0].DistanceM != 90 || laps[0].AvgHeartRate != 121 || laps[0].MaxHeartRate != 122 {
+		t.Errorf("first lap = %+v", laps[0])
+	}
+
+	// a lap mark inside the second work step splits it
+	tracker.Laps = []time.Time{at(145)}
+	laps = splitLaps(tracker, samples, at(240))
+	if len(laps) != 6 || laps[2].DurationSeconds != 15 || laps[3].Kind != IntervalWork || laps[3].DurationSeconds != 15 {
+		t.Errorf("split laps = %+v", laps)
+	}
+
+	if laps := splitLaps(Tracker{StartedAt: start}, samples, at(240)); laps != nil {
+		t.Errorf("unsplit workout has laps %+v", laps)
+	}
+}
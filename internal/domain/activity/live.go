@@ -0,0 +1,509 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/gender"
+)
+
+// LiveCommandType is what a client asks of a live tracking stream.
+type LiveCommandType string
+
+const (
+	LiveStart  LiveCommandType = "START"
+	LivePause  LiveCommandType = "PAUSE"
+	LiveResume LiveCommandType = "RESUME"
+	LiveStop   LiveCommandType = "STOP"
+	// LiveAttach picks up an active tracker after a reconnect; without a
+	// tracker id it takes the user's active one.
+	LiveAttach LiveCommandType = "ATTACH"
+	LiveSample LiveCommandType = "SAMPLE"
+	// LiveLap ends the current lap.
+	LiveLap LiveCommandType = "LAP"
+)
+
+const (
+	liveTickInterval = time.Second
+	// a heart rate reading counts for at most this long when the next one
+	// is late, so a dropped sensor doesn't pile up zone time
+	maxSampleGap = 30 * time.Second
+	// 220 minus age for a 30 year old, when the age is unknown
+	defaultMaxHeartRate     = 190
+	defaultRestingHeartRate = 60
+	zoneCount               = 5
+)
+
+// LiveCommand is one message from the client. Plan is an interval plan
+// for START to follow, such as 8x(30s on/90s off).
+type LiveCommand struct {
+	Type       LiveCommandType `json:"type"`
+	ActivityID string          `json:"activity_id,omitempty"`
+	Plan       string          `json:"plan,omitempty"`
+	TrackerID  string          `json:"tracker_id,omitempty"`
+	Sample     *Sample         `json:"sample,omitempty"`
+}
+
+// Sample is a sensor reading. Seq is assigned by the client and only ever
+// grows, so samples resent after a reconnect are recorded once. Distance is
+// cumulative since the start.
+type Sample struct {
+	Seq        int64     `json:"seq"`
+	RecordedAt time.Time `json:"recorded_at"`
+	HeartRate  int       `json:"heart_rate,omitempty"`
+	DistanceM  float64   `json:"distance_m,omitempty"`
+	Cadence    int       `json:"cadence,omitempty"`
+}
+
+func (s Sample) validate() error {
+	switch {
+	case s.Seq <= 0:
+		return errors.New("sample seq must be positive")
+	case s.HeartRate < 0 || s.HeartRate > 250:
+		return errors.New("heart rate must be between 0 and 250 bpm")
+	case s.DistanceM < 0:
+		return errors.New("distance cannot be negative")
+	case s.Cadence < 0 || s.Cadence > 300:
+		return errors.New("cadence must be between 0 and 300")
+	}
+	return nil
+}
+
+// LiveUpdate is what the server streams back: after every command and once
+// a second while the tracker runs. LastSeq is the last sample recorded, so a
+// reconnecting client knows what to resend. Session is set once stopped.
+// Error reports a rejected command; the stream stays open. Interval follows
+// the plan, if any, and Announcement is set when a step begins, for the
+// client to read out. LastLap is the lap a LAP command ended.
+type LiveUpdate struct {
+	TrackerID      string        `json:"tracker_id,omitempty"`
+	Status         TrackerStatus `json:"status,omitempty"`
+	ElapsedSeconds int           `json:"elapsed_seconds"`
+	Calories       int           `json:"calories"`
+	// HeartRateCalories is the heart rate based estimate, when there are
+	// readings to base it on.
+	HeartRateCalories int             `json:"heart_rate_calories,omitempty"`
+	HeartRate         int             `json:"heart_rate,omitempty"`
+	Zone              int             `json:"zone,omitempty"`
+	ZoneSeconds       [zoneCount]int  `json:"zone_seconds"`
+	DistanceM         float64         `json:"distance_m,omitempty"`
+	Cadence           int             `json:"cadence,omitempty"`
+	LastSeq           int64           `json:"last_seq"`
+	Lap               int             `json:"lap,omitempty"`
+	LastLap           *SessionLap     `json:"last_lap,omitempty"`
+	Interval          *IntervalState  `json:"interval,omitempty"`
+	Announcement      string          `json:"announcement,omitempty"`
+	Session           *SessionSummary `json:"session,omitempty"`
+	Error             string          `json:"error,omitempty"`
+	Command           LiveCommandType `json:"command,omitempty"`
+	SentAt            time.Time       `json:"sent_at"`
+}
+
+// SessionSummary is the exercise session a stopped tracker was saved as,
+// with the MET based burn that was saved, the heart rate estimate and the
+// laps, if it was split.
+type SessionSummary struct {
+	ID                string       `json:"id"`
+	DurationSeconds   int          `json:"duration_seconds"`
+	CaloriesBurned    int          `json:"calories_burned"`
+	HeartRateCalories int          `json:"heart_rate_calories,omitempty"`
+	Laps              []SessionLap `json:"laps,omitempty"`
+}
+
+// heartRateZone places bpm in one of five zones at 50, 60, 70, 80 and 90%
+// of the maximum; 0 is below zone 1.
+func heartRateZone(bpm, maxHR int) int {
+	if bpm <= 0 || maxHR <= 0 {
+		return 0
+	}
+	pct := float64(bpm) / float64(maxHR)
+	zone := int(pct*10) - 4
+	if zone < 0 {
+		return 0
+	}
+	if zone > zoneCount {
+		return zoneCount
+	}
+	return zone
+}
+
+// zoneSeconds adds up the time spent in each zone.
+func zoneSeconds(samples []Sample, maxHR int, now time.Time) [zoneCount]int {
+	var zones [zoneCount]int
+	sampleHolds(samples, now, func(s Sample, held time.Duration) {
+		if zone := heartRateZone(s.HeartRate, maxHR); zone > 0 {
+			zones[zone-1] += int(held.Seconds())
+		}
+	})
+	return zones
+}
+
+// liveSession is one stream's view of its tracker. announced is the plan
+// step last announced, so each is announced once per stream; lastLap is
+// the lap the LAP command being answered ended.
+type liveSession struct {
+	userID    string
+	profile   Profile
+	tracker   *Tracker
+	plan      *IntervalPlan
+	samples   []Sample
+	lastSeq   int64
+	announced int
+	lastLap   *SessionLap
+}
+
+func (l *liveSession) attach(t *Tracker, samples []Sample) {
+	l.tracker = t
+	l.plan = t.plan()
+	l.samples = samples
+	l.announced = 0
+	l.lastSeq = 0
+	if n := len(samples); n > 0 {
+		l.lastSeq = samples[n-1].Seq
+	}
+}
+
+func (l *liveSession) update(now time.Time) *LiveUpdate {
+	u := &LiveUpdate{LastSeq: l.lastSeq, SentAt: now}
+	if l.tracker == nil {
+		return u
+	}
+	session := l.tracker.Session(now)
+	u.TrackerID = l.tracker.ID
+	u.Status = l.tracker.Status
+	u.ElapsedSeconds = int(l.tracker.Active(now).Seconds())
+	u.Calories = int(session.CaloriesBurned)
+	u.ZoneSeconds = zoneSeconds(l.samples, l.profile.MaxHeartRate(), now)
+	if kcal, ok := heartRateCalories(l.samples, l.profile, now); ok {
+		u.HeartRateCalories = int(kcal)
+	}
+	if n := len(l.samples); n > 0 {
+		last := l.samples[n-1]
+		u.HeartRate = last.HeartRate
+		u.Zone = heartRateZone(last.HeartRate, l.profile.MaxHeartRate())
+		u.DistanceM = last.DistanceM
+		u.Cadence = last.Cadence
+	}
+	if len(l.tracker.Laps) > 0 {
+		u.Lap = len(l.tracker.Laps) + 1
+	}
+	if l.plan != nil {
+		state := l.plan.At(l.tracker.Active(now))
+		u.Interval = &state
+		// a finished plan counts as one step past the last
+		step := state.Step
+		if state.Done {
+			step++
+		}
+		if step != l.announced {
+			u.Announcement = state.announcement(l.plan)
+			l.announced = step
+		}
+	}
+	return u
+}
+
+// TrackLive runs a live workout over a bidirectional stream: the client
+// sends commands and samples, the server answers every command and streams
+// progress while the tracker runs. The tracker outlives the stream, so a
+// client that drops reconnects and sends ATTACH.
+func (a *ServiceActivity) TrackLive(stream grpc.BidiStreamingServer[LiveCommand, LiveUpdate]) error {
+	ctx := stream.Context()
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/TrackLive")
+	defer span.End()
+
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
+	profile, err := a.trackers.Profile(ctx, userID)
+	if err != nil {
+		return trackerError(err)
+	}
+	live := &liveSession{userID: userID, profile: profile}
+
+	commands := make(chan *LiveCommand)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			cmd, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case commands <- cmd:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(liveTickInterval)
+	defer ticker.Stop()
+
+	received := 0
+	defer func() { span.SetAttributes(attribute.Int("live.commands", received)) }()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case cmd := <-commands:
+			received++
+			update, err := a.handleLive(ctx, live, cmd)
+			if err != nil {
+				return err
+			}
+			if update != nil {
+				if err = stream.Send(update); err != nil {
+					return err
+				}
+			}
+		case now := <-ticker.C:
+			if live.tracker == nil || live.tracker.Status != TrackerRunning {
+				continue
+			}
+			if err := stream.Send(live.update(now)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleLive applies one command. Rejected commands come back as an update
+// carrying the error; only failures on our side end the stream. Samples are
+// not answered, the ticker reports them.
+func (a *ServiceActivity) handleLive(ctx context.Context, live *liveSession, cmd *LiveCommand) (*LiveUpdate, error) {
+	err := a.applyLive(ctx, live, cmd)
+	if err != nil {
+		if s, ok := status.FromError(err); ok && s.Code() != codes.Internal {
+			u := live.update(time.Now())
+			u.Command = cmd.Type
+			u.Error = s.Message()
+			return u, nil
+		}
+		return nil, trackerError(err)
+	}
+	if cmd.Type == LiveSample {
+		return nil, nil
+	}
+
+	u := live.update(time.Now())
+	u.Command = cmd.Type
+	if cmd.Type == LiveLap {
+		u.LastLap = live.lastLap
+		live.lastLap = nil
+	}
+	if cmd.Type == LiveStop && live.tracker != nil {
+		u.Session = live.summary()
+		live.tracker = nil
+	}
+	return u, nil
+}
+
+func (l *liveSession) summary() *SessionSummary {
+	if l.tracker.SessionID == "" {
+		return nil
+	}
+	end := time.Now()
+	if l.tracker.EndedAt != nil {
+		end = *l.tracker.EndedAt
+	}
+	s := l.tracker.Session(end)
+	summary := &SessionSummary{
+		ID:              l.tracker.SessionID,
+		DurationSeconds: int(l.tracker.Active(end).Seconds()),
+		CaloriesBurned:  int(s.CaloriesBurned),
+	}
+	if kcal, ok := heartRateCalories(l.samples, l.profile, end); ok {
+		summary.HeartRateCalories = int(kcal)
+	}
+	summary.Laps = splitLaps(*l.tracker, l.samples, end)
+	return summary
+}
+
+func (a *ServiceActivity) applyLive(ctx context.Context, live *liveSession, cmd *LiveCommand) error {
+	if cmd == nil {
+		return status.Error(codes.InvalidArgument, "empty command")
+	}
+	if cmd.Type != LiveStart && cmd.Type != LiveAttach && live.tracker == nil {
+		return status.Error(codes.FailedPrecondition, "start or attach to a tracker first")
+	}
+
+	var err error
+	switch cmd.Type {
+	case LiveStart:
+		if cmd.ActivityID == "" {
+			return status.Error(codes.InvalidArgument, "Activity ID is required")
+		}
+		var plan *IntervalPlan
+		if cmd.Plan != "" {
+			if plan, err = ParseIntervalPlan(cmd.Plan); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		t, err := a.trackers.StartTracker(ctx, live.userID, cmd.ActivityID, plan)
+		if err != nil {
+			return err
+		}
+		live.attach(t, nil)
+	case LiveAttach:
+		var t *Tracker
+		if cmd.TrackerID == "" {
+			t, err = a.trackers.ActiveTracker(ctx, live.userID)
+		} else {
+			t, err = a.trackers.Tracker(ctx, cmd.TrackerID)
+		}
+		if err != nil {
+			return err
+		}
+		if t.UserID != live.userID {
+			return errTrackerNotFound
+		}
+		samples, err := a.trackers.Samples(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		live.attach(t, samples)
+	case LivePause:
+		live.tracker, err = a.trackers.PauseTracker(ctx, live.userID, live.tracker.ID)
+	case LiveResume:
+		live.tracker, err = a.trackers.ResumeTracker(ctx, live.userID, live.tracker.ID)
+	case LiveStop:
+		live.tracker, _, err = a.trackers.StopTracker(ctx, live.userID, live.tracker.ID)
+	case LiveSample:
+		return a.recordLiveSample(ctx, live, cmd.Sample)
+	case LiveLap:
+		live.lastLap, err = a.markLap(ctx, live)
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown command %q", cmd.Type)
+	}
+	return err
+}
+
+func (a *ServiceActivity) recordLiveSample(ctx context.Context, live *liveSession, s *Sample) error {
+	if s == nil {
+		return status.Error(codes.InvalidArgument, "sample is required")
+	}
+	if live.tracker.Status != TrackerRunning {
+		return status.Error(codes.FailedPrecondition, "activity tracker is not running")
+	}
+	if s.RecordedAt.IsZero() {
+		s.RecordedAt = time.Now().UTC()
+	}
+	if err := s.validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	// already recorded before a reconnect
+	if s.Seq <= live.lastSeq {
+		return nil
+	}
+	if err := a.trackers.RecordSample(ctx, live.tracker.ID, *s); err != nil {
+		return fmt.Errorf("failed to record sample: %w", err)
+	}
+	live.samples = append(live.samples, *s)
+	live.lastSeq = s.Seq
+	return nil
+}
+
+func (a *RepositoryActivity) Tracker(ctx context.Context, trackerID string) (*Tracker, error) {
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		t, err = loadTracker(ctx, tx, trackerID)
+		return err
+	})
+	return t, err
+}
+
+// RecordSample stores a reading once, however often it is resent, and
+// counts as a sign of life for the reaper.
+func (a *RepositoryActivity) RecordSample(ctx context.Context, trackerID string, s Sample) error {
+	return a.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO activity_tracker_samples (tracker_id, seq, recorded_at, heart_rate, distance_m, cadence)
+			VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0::float8), NULLIF($6, 0))
+			ON CONFLICT (tracker_id, seq) DO NOTHING`,
+			trackerID, s.Seq, s.RecordedAt, s.HeartRate, s.DistanceM, s.Cadence)
+		if err != nil {
+			return fmt.Errorf("failed to insert sample: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE activity_trackers SET last_seen_at = now()
+			WHERE id = $1 AND status IN ($2, $3)`, trackerID, TrackerRunning, TrackerPaused)
+		if err != nil {
+			return fmt.Errorf("failed to touch tracker: %w", err)
+		}
+		return nil
+	})
+}
+
+func (a *RepositoryActivity) Samples(ctx context.Context, trackerID string) ([]Sample, error) {
+	return querySamples(ctx, a.pgpool, trackerID)
+}
+
+// rowsQuerier is a pool or a transaction.
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func querySamples(ctx context.Context, q rowsQuerier, trackerID string) ([]Sample, error) {
+	rows, err := q.Query(ctx, `
+		SELECT seq, recorded_at, COALESCE(heart_rate, 0), COALESCE(distance_m, 0)::float8, COALESCE(cadence, 0)
+		FROM activity_tracker_samples
+		WHERE tracker_id = $1
+		ORDER BY seq`, trackerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]Sample, 0)
+	for rows.Next() {
+		var s Sample
+		if err = rows.Scan(&s.Seq, &s.RecordedAt, &s.HeartRate, &s.DistanceM, &s.Cadence); err != nil {
+			return nil, fmt.Errorf("failed to scan sample: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// Profile reads the latest weigh-in, falling back to the bio data, the age
+// on the current macro distribution, the gender and the heart rate settings.
+func (a *RepositoryActivity) Profile(ctx context.Context, userID string) (Profile, error) {
+	var p Profile
+	err := a.pgpool.QueryRow(ctx, `
+		SELECT
+		  COALESCE(`+latestWeightSQL+`, 0),
+		  COALESCE((SELECT age FROM user_macro_distribution WHERE user_id = $1
+		            ORDER BY is_current DESC, created_at DESC LIMIT 1), 0),
+		  COALESCE(
+		    (SELECT gender::text FROM user_personal_data WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1),
+		    (SELECT gender FROM user_macro_distribution WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1),
+		    ''),
+		  COALESCE((SELECT max_heart_rate FROM user_heart_rate_settings WHERE user_id = $1), 0),
+		  COALESCE((SELECT resting_heart_rate FROM user_heart_rate_settings WHERE user_id = $1), 0)`, userID).Scan(
+		&p.WeightKg, &p.Age, &p.Gender, &p.MaxHR, &p.RestingHR)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+	p.Gender = gender.Normalize(p.Gender)
+	return p, nil
+}
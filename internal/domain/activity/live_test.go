@@ -0,0 +1,309 @@
+package activity
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
+)
+
+func TestHeartRateZone(t *testing.T) {
+	tests := []struct {
+		bpm, want int
+	}{
+		{0, 0}, {90, 0}, {95, 1}, {120, 2}, {140, 3}, {155, 4}, {175, 5}, {210, 5},
+	}
+	for _, tt := range tests {
+		if got := heartRateZone(tt.bpm, 190); got != tt.want {
+			t.Errorf("heartRateZone(%d) = %d, want %d", tt.bpm, got, tt.want)
+		}
+	}
+}
+
+func TestZoneSeconds(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	samples := []Sample{
+		{Seq: 1, RecordedAt: at(0), HeartRate: 120},   // zone 2 for 10s
+		{Seq: 2, RecordedAt: at(10), HeartRate: 150},  // zone 3, gap capped at 30s
+		{Seq: 3, RecordedAt: at(100), HeartRate: 0},   // no reading
+		{Seq: 4, RecordedAt: at(105), HeartRate: 180}, // zone 5 until now
+	}
+	got := zoneSeconds(samples, 190, at(120))
+	want := [zoneCount]int{0, 10, 30, 0, 15}
+	if got != want {
+		t.Errorf("zoneSeconds = %v, want %v", got, want)
+	}
+}
+
+// fakeTrackers keeps one user's trackers in memory.
+type fakeTrackers struct {
+	trackers map[string]*Tracker
+	samples  map[string][]Sample
+}
+
+func newFakeTrackers() *fakeTrackers {
+	return &fakeTrackers{trackers: map[string]*Tracker{}, samples: map[string][]Sample{}}
+}
+
+func (f *fakeTrackers) StartTracker(_ context.Context, userID, activityID string, plan *IntervalPlan) (*Tracker, error) {
+	for _, t := range f.trackers {
+		if t.UserID == userID && (t.Status == TrackerRunning || t.Status == TrackerPaused) {
+			return nil, errTrackerStarted
+		}
+	}
+	t := &Tracker{ID: "t1", UserID: userID, ActivityID: activityID, MET: 10, WeightKg: 60,
+		Status: TrackerRunning, StartedAt: time.Now().Add(-time.Minute)}
+	if plan != nil {
+		t.Plan = plan.String()
+	}
+	f.trackers[t.ID] = t
+	return t, nil
+}
+
+func (f *fakeTrackers) ActiveTracker(_ context.Context, userID string) (*Tracker, error) {
+	for _, t := range f.trackers {
+		if t.UserID == userID && (t.Status == TrackerRunning || t.Status == TrackerPaused) {
+			return t, nil
+		}
+	}
+	return nil, errTrackerNotFound
+}
+
+func (f *fakeTrackers) Tracker(_ context.Context, trackerID string) (*Tracker, error) {
+	if t, ok := f.trackers[trackerID]; ok {
+		return t, nil
+	}
+	return nil, errTrackerNotFound
+}
+
+// own is the user's tracker trackerID, nil when it is someone else's.
+func (f *fakeTrackers) own(userID, trackerID string) *Tracker {
+	if t, ok := f.trackers[trackerID]; ok && t.UserID == userID {
+		return t
+	}
+	return nil
+}
+
+func (f *fakeTrackers) PauseTracker(_ context.Context, userID, trackerID string) (*Tracker, error) {
+	t := f.own(userID, trackerID)
+	if t == nil {
+		return nil, errTrackerNotFound
+	}
+	if t.Status == TrackerPaused {
+		return nil, errTrackerPaused
+	}
+	t.Status = TrackerPaused
+	t.Pauses = append(t.Pauses, Pause{PausedAt: time.Now()})
+	return t, nil
+}
+
+func (f *fakeTrackers) ResumeTracker(_ context.Context, userID, trackerID string) (*Tracker, error) {
+	t := f.own(userID, trackerID)
+	if t == nil {
+		return nil, errTrackerNotFound
+	}
+	if t.Status != TrackerPaused {
+		return nil, errTrackerRunning
+	}
+	now := time.Now()
+	t.Pauses[len(t.Pauses)-1].ResumedAt = &now
+	t.Status = TrackerRunning
+	return t, nil
+}
+
+func (f *fakeTrackers) MarkLap(_ context.Context, userID, trackerID string) (*Tracker, error) {
+	t := f.own(userID, trackerID)
+	if t == nil {
+		return nil, errTrackerNotFound
+	}
+	t.Laps = append(t.Laps, time.Now())
+	return t, nil
+}
+
+func (f *fakeTrackers) StopTracker(_ context.Context, userID, trackerID string) (*Tracker, *pba.XExerciseSession, error) {
+	t := f.own(userID, trackerID)
+	if t == nil {
+		return nil, nil, errTrackerNotFound
+	}
+	now := time.Now()
+	t.Status, t.EndedAt, t.SessionID = TrackerStopped, &now, "s1"
+	return t, t.Session(now), nil
+}
+
+func (f *fakeTrackers) ReapTrackers(context.Context) (int, error) { return 0, nil }
+
+func (f *fakeTrackers) RecordSample(_ context.Context, trackerID string, s Sample) error {
+	f.samples[trackerID] = append(f.samples[trackerID], s)
+	return nil
+}
+
+func (f *fakeTrackers) Samples(_ context.Context, trackerID string) ([]Sample, error) {
+	return f.samples[trackerID], nil
+}
+
+func (f *fakeTrackers) Profile(context.Context, string) (Profile, error) {
+	return Profile{WeightKg: 60, Age: 30, Gender: "FEMALE"}, nil
+}
+
+func (f *fakeTrackers) SessionLaps(context.Context, string, string) ([]SessionLap, error) {
+	return nil, nil
+}
+
+func TestHandleLive(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeTrackers()
+	a := &ServiceActivity{trackers: repo}
+	sample := func(seq int64, hr int) *LiveCommand {
+		return &LiveCommand{Type: LiveSample, Sample: &Sample{Seq: seq, HeartRate: hr}}
+	}
+
+	first := &liveSession{userID: "u1", profile: Profile{Age: 30}}
+	steps := []struct {
+		live    *liveSession
+		cmd     *LiveCommand
+		wantErr string
+		check   func(t *testing.T, u *LiveUpdate)
+	}{
+		{first, &LiveCommand{Type: LivePause}, "start or attach to a tracker first", nil},
+		{first, &LiveCommand{Type: LiveStart, ActivityID: "a1"}, "", func(t *testing.T, u *LiveUpdate) {
+			if u.TrackerID != "t1" || u.Status != TrackerRunning || u.ElapsedSeconds < 60 || u.Calories < 10 {
+				t.Errorf("start update = %+v", u)
+			}
+		}},
+		{first, sample(1, 150), "", nil},
+		{first, sample(2, 155), "", nil},
+		{first, &LiveCommand{Type: LiveStart, ActivityID: "a1"}, "activity tracker already started", nil},
+		// the stream drops; a new one attaches and resends sample 2
+		{&liveSession{userID: "u1", profile: Profile{Age: 30}}, &LiveCommand{Type: LiveAttach}, "", func(t *testing.T, u *LiveUpdate) {
+			if u.LastSeq != 2 || u.HeartRate != 155 || u.Zone != 4 {
+				t.Errorf("attach update = %+v", u)
+			}
+		}},
+		{nil, sample(2, 155), "", nil},
+		{nil, sample(3, 160), "", nil},
+		{nil, &LiveCommand{Type: LiveLap}, "", func(t *testing.T, u *LiveUpdate) {
+			if u.Lap != 2 || u.LastLap == nil || u.LastLap.Number != 1 || u.LastLap.DurationSeconds < 60 {
+				t.Errorf("lap update = %+v", u)
+			}
+		}},
+		{nil, &LiveCommand{Type: LivePause}, "", func(t *testing.T, u *LiveUpdate) {
+			if u.Status != TrackerPaused || u.LastSeq != 3 {
+				t.Errorf("pause update = %+v", u)
+			}
+		}},
+		{nil, sample(4, 120), "activity tracker is not running", nil},
+		{nil, &LiveCommand{Type: LiveLap}, "activity tracker is not running", nil},
+		{nil, &LiveCommand{Type: LiveStop}, "", func(t *testing.T, u *LiveUpdate) {
+			if u.Session == nil || u.Session.ID != "s1" || len(u.Session.Laps) != 2 {
+				t.Errorf("stop update = %+v", u)
+			}
+		}},
+	}
+
+	live := first
+	for i, step := range steps {
+		if step.live != nil {
+			live = step.live
+		}
+		u, err := a.handleLive(ctx, live, step.cmd)
+		if err != nil {
+			t.Fatalf("step %d: stream ended: %v", i, err)
+		}
+		if step.wantErr != "" {
+			if u == nil || u.Error != step.wantErr {
+				t.Fatalf("step %d: got %+v, want error %q", i, u, step.wantErr)
+			}
+			continue
+		}
+		if u != nil && u.Error != "" {
+			t.Fatalf("step %d: unexpected error %q", i, u.Error)
+		}
+		if step.check != nil {
+			step.check(t, u)
+		}
+	}
+
+	if got := len(repo.samples["t1"]); got != 3 {
+		t.Errorf("recorded %d samples, want 3", got)
+	}
+}
+
+func TestTrackerCommandsNeedTheOwner(t *testing.T) {
+	repo := newFakeTrackers()
+	a := &ServiceActivity{trackers: repo}
+	if _, err := repo.StartTracker(context.Background(), "u1", "a1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	other := context.WithValue(context.Background(), "userID", "u2")
+	if _, err := a.PauseActivityTracker(other, &pba.PauseActivityTrackerReq{SessionId: "t1"}); err == nil {
+		t.Error("another user paused the tracker")
+	}
+	if _, err := a.StopActivityTracker(other, &pba.StopActivityTrackerReq{SessionId: "t1"}); err == nil {
+		t.Error("another user stopped the tracker")
+	}
+	if repo.trackers["t1"].Status != TrackerRunning {
+		t.Errorf("status = %s, want RUNNING", repo.trackers["t1"].Status)
+	}
+
+	owner := context.WithValue(context.Background(), "userID", "u1")
+	if _, err := a.PauseActivityTracker(owner, &pba.PauseActivityTrackerReq{SessionId: "t1"}); err != nil {
+		t.Errorf("owner pause: %v", err)
+	}
+}
+
+func TestHeartRateCalories(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	samples := make([]Sample, 0)
+	for i := 0; i < 20; i++ {
+		samples = append(samples, Sample{Seq: int64(i + 1), RecordedAt: start.Add(time.Duration(i) * 30 * time.Second), HeartRate: 150})
+	}
+	end := start.Add(10 * time.Minute)
+
+	tests := []struct {
+		name    string
+		profile Profile
+		want    float64
+		ok      bool
+	}{
+		// (-55.0969 + 0.6309*150 + 0.1988*80 + 0.2017*35) / 4.184 = 14.94 kcal/min
+		{"male", Profile{WeightKg: 80, Age: 35, Gender: "MALE"}, 149.4, true},
+		// (-20.4022 + 0.4472*150 - 0.1263*60 + 0.074*35) / 4.184 = 9.96 kcal/min
+		{"female by enum number", Profile{WeightKg: 60, Age: 35, Gender: "2"}, 99.6, true},
+		{"unknown gender", Profile{WeightKg: 80, Age: 35}, 0, false},
+		{"no weight", Profile{Age: 35, Gender: "MALE"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := heartRateCalories(samples, tt.profile, end)
+			if ok != tt.ok || math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("heartRateCalories = %.1f, %v, want %.1f, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := heartRateCalories([]Sample{{Seq: 1, RecordedAt: start}}, Profile{WeightKg: 80, Age: 35, Gender: "MALE"}, end); ok {
+		t.Error("estimate without heart rate readings")
+	}
+}
+
+func TestMetCalories(t *testing.T) {
+	tests := []struct {
+		met, weight float64
+		d           time.Duration
+		want        float64
+	}{
+		{8, 80, 45 * time.Minute, 480},
+		{8, 50, 45 * time.Minute, 300},
+		{8, 0, time.Hour, 560}, // reference weight
+		{0, 80, time.Hour, 0},
+	}
+	for _, tt := range tests {
+		if got := metCalories(tt.met, tt.weight, tt.d); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("metCalories(%v, %v, %v) = %v, want %v", tt.met, tt.weight, tt.d, got, tt.want)
+		}
+	}
+}
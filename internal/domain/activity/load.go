@@ -0,0 +1,446 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/gender"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+// Training load is Banister's TRIMP: minutes at a fraction of heart rate
+// reserve, weighted exponentially so the hard minutes count for more. Acute
+// (ATL) and chronic (CTL) load are exponentially weighted daily averages over
+// 7 and 42 days, and form (TSB) is chronic minus acute.
+const (
+	atlDays = 7
+	ctlDays = 42
+	// the chronic average takes about three time constants to settle
+	loadWarmupDays  = 3 * ctlDays
+	defaultLoadDays = 28
+	maxLoadDays     = 365
+
+	// Sessions without heart rate are read through ACSM's %HRR ≈ %VO2R,
+	// taking an untrained VO2max of 12 METs.
+	assumedMaxMET = 12.0
+
+	overreachingTSB = -30.0
+	loadSpikeRatio  = 1.5
+	// below this chronic load, a spike ratio says more about the short
+	// history than about the training
+	minChronicLoad = 15.0
+
+	minMaxHeartRate     = 100
+	maxMaxHeartRate     = 230
+	minRestingHeartRate = 25
+	maxRestingHeartRate = 120
+)
+
+// GetUserExerciseSessionStats reports the current load in these headers
+// when the request sets trainingLoadHeader to true. Computing it reads the
+// last four months of sessions, which the plain stats don't need.
+const (
+	trainingLoadHeader    = "x-training-load"
+	trainingATLHeader     = "x-training-atl"
+	trainingCTLHeader     = "x-training-ctl"
+	trainingTSBHeader     = "x-training-tsb"
+	trainingWarningHeader = "x-training-warning"
+)
+
+// LoadSource says what a session's load was computed from.
+type LoadSource string
+
+const (
+	LoadFromHeartRate LoadSource = "HEART_RATE"
+	LoadFromMET       LoadSource = "MET"
+)
+
+// TrainingWarning flags a load pattern that tends to end in injury or
+// illness.
+type TrainingWarning string
+
+const (
+	// WarningOverreaching is a deep negative form: far more recent load
+	// than the user is adapted to.
+	WarningOverreaching TrainingWarning = "OVERREACHING"
+	// WarningLoadSpike is a week's load well above the six week average.
+	WarningLoadSpike TrainingWarning = "LOAD_SPIKE"
+)
+
+// HeartRateSettings are the maximum and resting rates in use, and whether
+// each was configured or derived.
+type HeartRateSettings struct {
+	MaxHeartRate      int  `json:"max_heart_rate"`
+	RestingHeartRate  int  `json:"resting_heart_rate"`
+	MaxConfigured     bool `json:"max_configured"`
+	RestingConfigured bool `json:"resting_configured"`
+}
+
+func heartRateSettings(p Profile) HeartRateSettings {
+	return HeartRateSettings{
+		MaxHeartRate:      p.MaxHeartRate(),
+		RestingHeartRate:  p.RestingHeartRate(),
+		MaxConfigured:     p.MaxHR > 0,
+		RestingConfigured: p.RestingHR > 0,
+	}
+}
+
+// SessionLoad is one session's intensity.
+type SessionLoad struct {
+	SessionID       string         `json:"session_id"`
+	StartTime       time.Time      `json:"start_time"`
+	DurationSeconds int            `json:"duration_seconds"`
+	ZoneSeconds     [zoneCount]int `json:"zone_seconds"`
+	TRIMP           float64        `json:"trimp"`
+	Source          LoadSource     `json:"source"`
+}
+
+// DailyLoad is a day's summed TRIMP and the averages at its end.
+type DailyLoad struct {
+	Date string  `json:"date"`
+	Load float64 `json:"load"`
+	ATL  float64 `json:"atl"`
+	CTL  float64 `json:"ctl"`
+	TSB  float64 `json:"tsb"`
+}
+
+// TrainingLoad is the intensity report for the days up to AsOf.
+type TrainingLoad struct {
+	AsOf     time.Time         `json:"as_of"`
+	Settings HeartRateSettings `json:"settings"`
+	Sessions []SessionLoad     `json:"sessions"`
+	Days     []DailyLoad       `json:"days"`
+	ATL      float64           `json:"atl"`
+	CTL      float64           `json:"ctl"`
+	TSB      float64           `json:"tsb"`
+	// Ratio is ATL over CTL, the acute:chronic workload ratio.
+	Ratio          float64         `json:"ratio"`
+	Warning        TrainingWarning `json:"warning,omitempty"`
+	WarningMessage string          `json:"warning_message,omitempty"`
+}
+
+// LoadSession is a saved session with what its load is computed from.
+type LoadSession struct {
+	ID       string
+	Start    time.Time
+	Duration time.Duration
+	MET      float64
+	Samples  []Sample
+}
+
+// StatsRepository is implemented by RepositoryActivity.
+type StatsRepository interface {
+	// LoadSessions returns the user's sessions starting in [from, to), with
+	// their heart rate samples.
+	LoadSessions(ctx context.Context, userID string, from, to time.Time) ([]LoadSession, error)
+	// SetHeartRateSettings stores the user's rates; zero clears one.
+	SetHeartRateSettings(ctx context.Context, userID string, maxHR, restingHR int) error
+	// SessionBuckets sums the sessions q covers per bucket.
+	SessionBuckets(ctx context.Context, q *BucketQuery) ([]AnalyticsBucket, error)
+}
+
+// trimpWeight is Banister's exponent, which differs between men and women.
+func trimpWeight(g string) float64 {
+	switch gender.Normalize(g) {
+	case gender.Male:
+		return 1.92
+	case gender.Female:
+		return 1.67
+	}
+	return (1.92 + 1.67) / 2
+}
+
+func trimp(minutes, reserve, weight float64) float64 {
+	reserve = math.Max(0, math.Min(1, reserve))
+	return minutes * reserve * 0.64 * math.Exp(weight*reserve)
+}
+
+// sessionLoad scores a session from its heart rate samples, or its METs when
+// there are none.
+func sessionLoad(s LoadSession, p Profile) SessionLoad {
+	maxHR, restHR := float64(p.MaxHeartRate()), float64(p.RestingHeartRate())
+	weight := trimpWeight(p.Gender)
+	end := s.Start.Add(s.Duration)
+	load := SessionLoad{SessionID: s.ID, StartTime: s.Start, DurationSeconds: int(s.Duration.Seconds())}
+
+	var score float64
+	measured := false
+	sampleHolds(s.Samples, end, func(sample Sample, held time.Duration) {
+		if sample.HeartRate <= 0 {
+			return
+		}
+		measured = true
+		score += trimp(held.Minutes(), (float64(sample.HeartRate)-restHR)/(maxHR-restHR), weight)
+	})
+
+	if measured {
+		load.Source = LoadFromHeartRate
+		load.ZoneSeconds = zoneSeconds(s.Samples, p.MaxHeartRate(), end)
+	} else {
+		load.Source = LoadFromMET
+		score = trimp(s.Duration.Minutes(), (s.MET-1)/(assumedMaxMET-1), weight)
+	}
+	load.TRIMP = round1(score)
+	return load
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// dailyLoads sums session loads per calendar day in loc and runs the
+// averages from the first day to the last, both inclusive.
+func dailyLoads(sessions []SessionLoad, first, last time.Time, loc *time.Location) []DailyLoad {
+	byDay := map[string]float64{}
+	for _, s := range sessions {
+		byDay[s.StartTime.In(loc).Format(time.DateOnly)] += s.TRIMP
+	}
+
+	atlDecay := 1 - math.Exp(-1.0/atlDays)
+	ctlDecay := 1 - math.Exp(-1.0/ctlDays)
+	var atl, ctl float64
+	var days []DailyLoad
+	for day := first.In(loc); !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		load := byDay[date]
+		atl += (load - atl) * atlDecay
+		ctl += (load - ctl) * ctlDecay
+		days = append(days, DailyLoad{Date: date, Load: round1(load), ATL: round1(atl), CTL: round1(ctl), TSB: round1(ctl - atl)})
+	}
+	return days
+}
+
+func trainingWarning(atl, ctl float64) (TrainingWarning, string) {
+	switch tsb := ctl - atl; {
+	case tsb <= overreachingTSB:
+		return WarningOverreaching, fmt.Sprintf("form is %.0f: recent load is far above what you're adapted to, plan some easy days", tsb)
+	case ctl >= minChronicLoad && atl/ctl >= loadSpikeRatio:
+		return WarningLoadSpike, fmt.Sprintf("this week's load is %.1f times your six week average, ramp up more gradually", atl/ctl)
+	}
+	return "", ""
+}
+
+// startOfDay is midnight at the start of t's day in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// TrainingLoad reports the days up to asOf: per-session TRIMP and time in
+// zone, and the acute and chronic load with a warning when the pattern
+// looks risky.
+func (a *ServiceActivity) TrainingLoad(ctx context.Context, userID string, asOf time.Time, days int) (*TrainingLoad, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/TrainingLoad")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	if days == 0 {
+		days = defaultLoadDays
+	}
+	if days < 1 || days > maxLoadDays {
+		return nil, status.Errorf(codes.InvalidArgument, "days must be between 1 and %d", maxLoadDays)
+	}
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	loc, err := a.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	last := startOfDay(asOf, loc)
+	from := last.AddDate(0, 0, 1-days)
+	warmup := from.AddDate(0, 0, -loadWarmupDays)
+
+	profile, err := a.trackers.Profile(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	sessions, err := a.stats.LoadSessions(ctx, userID, warmup, last.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	report := &TrainingLoad{AsOf: asOf, Settings: heartRateSettings(profile)}
+	loads := make([]SessionLoad, 0, len(sessions))
+	for _, s := range sessions {
+		load := sessionLoad(s, profile)
+		loads = append(loads, load)
+		if !s.Start.Before(from) {
+			report.Sessions = append(report.Sessions, load)
+		}
+	}
+
+	all := dailyLoads(loads, warmup, last, loc)
+	report.Days = all[len(all)-days:]
+	today := all[len(all)-1]
+	report.ATL, report.CTL, report.TSB = today.ATL, today.CTL, today.TSB
+	if report.CTL > 0 {
+		report.Ratio = math.Round(report.ATL/report.CTL*100) / 100
+	}
+	report.Warning, report.WarningMessage = trainingWarning(today.ATL, today.CTL)
+
+	span.SetAttributes(
+		attribute.Int("load.sessions", len(report.Sessions)),
+		attribute.String("load.warning", string(report.Warning)),
+	)
+	return report, nil
+}
+
+// HeartRateSettings returns the rates zones and load are computed with.
+func (a *ServiceActivity) HeartRateSettings(ctx context.Context, userID string) (HeartRateSettings, error) {
+	profile, err := a.trackers.Profile(ctx, userID)
+	if err != nil {
+		return HeartRateSettings{}, status.Error(codes.Internal, err.Error())
+	}
+	return heartRateSettings(profile), nil
+}
+
+// SetHeartRateSettings stores the user's maximum and resting rates. Zero
+// clears a rate back to its default.
+func (a *ServiceActivity) SetHeartRateSettings(ctx context.Context, userID string, maxHR, restingHR int) (HeartRateSettings, error) {
+	if userID == "" {
+		return HeartRateSettings{}, status.Error(codes.InvalidArgument, "user is required")
+	}
+	if maxHR != 0 && (maxHR < minMaxHeartRate || maxHR > maxMaxHeartRate) {
+		return HeartRateSettings{}, status.Errorf(codes.InvalidArgument,
+			"max heart rate must be between %d and %d", minMaxHeartRate, maxMaxHeartRate)
+	}
+	if restingHR != 0 && (restingHR < minRestingHeartRate || restingHR > maxRestingHeartRate) {
+		return HeartRateSettings{}, status.Errorf(codes.InvalidArgument,
+			"resting heart rate must be between %d and %d", minRestingHeartRate, maxRestingHeartRate)
+	}
+
+	profile, err := a.trackers.Profile(ctx, userID)
+	if err != nil {
+		return HeartRateSettings{}, status.Error(codes.Internal, err.Error())
+	}
+	profile.MaxHR, profile.RestingHR = maxHR, restingHR
+	if profile.RestingHeartRate() >= profile.MaxHeartRate() {
+		return HeartRateSettings{}, status.Errorf(codes.InvalidArgument,
+			"resting heart rate must be below the max of %d", profile.MaxHeartRate())
+	}
+
+	if err = a.stats.SetHeartRateSettings(ctx, userID, maxHR, restingHR); err != nil {
+		return HeartRateSettings{}, status.Error(codes.Internal, err.Error())
+	}
+	return heartRateSettings(profile), nil
+}
+
+// setTrainingLoadHeaders adds the current load to a stats response when the
+// client asked for it. The stats are still useful without it, so failures
+// are only logged.
+func (a *ServiceActivity) setTrainingLoadHeaders(ctx context.Context, userID string) {
+	if !wantsTrainingLoad(ctx) {
+		return
+	}
+	report, err := a.TrainingLoad(ctx, userID, time.Now(), 1)
+	if err != nil {
+		logger.Log.Warn("failed to compute training load", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		trainingATLHeader, strconv.FormatFloat(report.ATL, 'f', 1, 64),
+		trainingCTLHeader, strconv.FormatFloat(report.CTL, 'f', 1, 64),
+		trainingTSBHeader, strconv.FormatFloat(report.TSB, 'f', 1, 64),
+		trainingWarningHeader, string(report.Warning),
+	))
+}
+
+func wantsTrainingLoad(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	v := md.Get(trainingLoadHeader)
+	if len(v) == 0 {
+		return false
+	}
+	want, _ := strconv.ParseBool(v[0])
+	return want
+}
+
+func (a *RepositoryActivity) LoadSessions(ctx context.Context, userID string, from, to time.Time) ([]LoadSession, error) {
+	rows, err := a.pgpool.Query(ctx, `
+		SELECT es.id, es.start_time,
+		       COALESCE(es.duration_hours, 0) * 3600 + COALESCE(es.duration_minutes, 0) * 60 + COALESCE(es.duration_seconds, 0),
+		       COALESCE(a.met, 0)::float8
+		FROM exercise_session es
+		LEFT JOIN activity a ON a.id = es.activity_id
+		WHERE es.user_id = $1 AND es.start_time >= $2 AND es.start_time < $3
+		ORDER BY es.start_time`, userID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []LoadSession
+	index := map[string]int{}
+	var ids []string
+	for rows.Next() {
+		var s LoadSession
+		var seconds int
+		if err = rows.Scan(&s.ID, &s.Start, &seconds, &s.MET); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		s.Duration = time.Duration(seconds) * time.Second
+		index[s.ID] = len(sessions)
+		ids = append(ids, s.ID)
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	samples, err := a.pgpool.Query(ctx, `
+		SELECT session_id, seq, recorded_at, heart_rate
+		FROM `+sessionSamplesSQL+`
+		WHERE heart_rate IS NOT NULL
+		ORDER BY session_id, seq`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query samples: %w", err)
+	}
+	defer samples.Close()
+
+	for samples.Next() {
+		var sessionID string
+		var s Sample
+		if err = samples.Scan(&sessionID, &s.Seq, &s.RecordedAt, &s.HeartRate); err != nil {
+			return nil, fmt.Errorf("failed to scan sample: %w", err)
+		}
+		i := index[sessionID]
+		sessions[i].Samples = append(sessions[i].Samples, s)
+	}
+	return sessions, samples.Err()
+}
+
+func (a *RepositoryActivity) SetHeartRateSettings(ctx context.Context, userID string, maxHR, restingHR int) error {
+	_, err := a.pgpool.Exec(ctx, `
+		INSERT INTO user_heart_rate_settings (user_id, max_heart_rate, resting_heart_rate)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0))
+		ON CONFLICT (user_id) DO UPDATE
+		SET max_heart_rate = EXCLUDED.max_heart_rate,
+		    resting_heart_rate = EXCLUDED.resting_heart_rate,
+		    updated_at = now()`, userID, maxHR, restingHR)
+	if err != nil {
+		return fmt.Errorf("failed to save heart rate settings: %w", err)
+	}
+	return nil
+}
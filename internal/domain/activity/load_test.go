@@ -0,0 +1,110 @@
+package activity
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSessionLoad(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	male := Profile{Age: 30, Gender: "MALE"} // 190 max, 60 resting
+
+	steady := LoadSession{ID: "hr", Start: start, Duration: 30 * time.Minute, MET: 8}
+	for i := 0; i < 60; i++ {
+		// 151 bpm is 70% of the reserve and 79% of the max
+		steady.Samples = append(steady.Samples, Sample{Seq: int64(i + 1), RecordedAt: start.Add(time.Duration(i) * 30 * time.Second), HeartRate: 151})
+	}
+
+	tests := []struct {
+		name    string
+		session LoadSession
+		profile Profile
+		want    float64
+		source  LoadSource
+	}{
+		// 30 * 0.7 * 0.64 * e^(1.92 * 0.7)
+		{"heart rate", steady, male, 51.5, LoadFromHeartRate},
+		{"configured rates", steady, Profile{Gender: "MALE", MaxHR: 200, RestingHR: 49}, 30 * 0.64 * 0.6755 * math.Exp(1.92*0.6755), LoadFromHeartRate},
+		// an hour at 8 METs is 7/11 of the reserve
+		{"METs", LoadSession{ID: "met", Start: start, Duration: time.Hour, MET: 8}, male, 82.9, LoadFromMET},
+		{"rest", LoadSession{ID: "rest", Start: start, Duration: time.Hour, MET: 1}, male, 0, LoadFromMET},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sessionLoad(tt.session, tt.profile)
+			if math.Abs(got.TRIMP-tt.want) > 0.1 || got.Source != tt.source {
+				t.Errorf("sessionLoad = %v from %s, want %.1f from %s", got.TRIMP, got.Source, tt.want, tt.source)
+			}
+		})
+	}
+
+	if zones := sessionLoad(steady, male).ZoneSeconds; zones != [zoneCount]int{0, 0, 1800, 0, 0} {
+		t.Errorf("zone seconds = %v", zones)
+	}
+}
+
+func TestDailyLoads(t *testing.T) {
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sessions []SessionLoad
+	for d := 0; d < 200; d++ {
+		sessions = append(sessions, SessionLoad{StartTime: first.AddDate(0, 0, d).Add(7 * time.Hour), TRIMP: 50})
+	}
+
+	days := dailyLoads(sessions, first, first.AddDate(0, 0, 199), time.UTC)
+	if len(days) != 200 {
+		t.Fatalf("got %d days", len(days))
+	}
+	last := days[len(days)-1]
+	if last.Date != "2024-07-18" || last.ATL != 50 || math.Abs(last.CTL-49.6) > 0.05 {
+		t.Errorf("steady training ends at %+v", last)
+	}
+	if w, _ := trainingWarning(last.ATL, last.CTL); w != "" {
+		t.Errorf("steady training warns %s", w)
+	}
+
+	// a week of doubled load on the same base
+	for d := 200; d < 207; d++ {
+		sessions = append(sessions, SessionLoad{StartTime: first.AddDate(0, 0, d), TRIMP: 200})
+	}
+	days = dailyLoads(sessions, first, first.AddDate(0, 0, 206), time.UTC)
+	last = days[len(days)-1]
+	if last.TSB > overreachingTSB {
+		t.Errorf("spike week ends at %+v", last)
+	}
+	if w, _ := trainingWarning(last.ATL, last.CTL); w != WarningOverreaching {
+		t.Errorf("spike week warns %q", w)
+	}
+}
+
+func TestTrainingWarning(t *testing.T) {
+	tests := []struct {
+		atl, ctl float64
+		want     TrainingWarning
+	}{
+		{50, 50, ""},
+		{40, 20, WarningLoadSpike},
+		{12, 6, ""}, // too little history to call a spike
+		{95, 60, WarningOverreaching},
+	}
+	for _, tt := range tests {
+		if got, _ := trainingWarning(tt.atl, tt.ctl); got != tt.want {
+			t.Errorf("trainingWarning(%v, %v) = %q, want %q", tt.atl, tt.ctl, got, tt.want)
+		}
+	}
+}
+
+func TestWantsTrainingLoad(t *testing.T) {
+	for header, want := range map[string]bool{"": false, "true": true, "1": true, "false": false, "yes please": false} {
+		ctx := context.Background()
+		if header != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(trainingLoadHeader, header))
+		}
+		if got := wantsTrainingLoad(ctx); got != want {
+			t.Errorf("wantsTrainingLoad(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 )
 
 type RepositoryActivity struct {
@@ -53,14 +55,39 @@ func nullTimeToTimestamppb(nt sql.NullTime) *timestamppb.Timestamp {
 	return nil
 }
 
+var activitiesPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"name":              {Expr: "COALESCE(name, '')", Type: pagination.Text},
+		"calories_per_hour": {Expr: "COALESCE(calories_per_hour, 0)::numeric", Type: pagination.Numeric},
+		"created_at":        {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "name",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"name":                  {Expr: "name", Op: pagination.Contains},
+		"min_calories_per_hour": {Expr: "calories_per_hour", Type: pagination.Numeric, Op: pagination.Gte},
+		"max_calories_per_hour": {Expr: "calories_per_hour", Type: pagination.Numeric, Op: pagination.Lte},
+	},
+}
+
 func (a *RepositoryActivity) GetActivity(ctx context.Context, req *pba.GetActivityReq) (*pba.GetActivityRes, error) {
 	activities := make([]*pba.XActivity, 0)
+
+	// the global catalog and the caller's own activities
+	userID, _ := ctx.Value("userID").(string)
+	page, err := activitiesPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `SELECT id, user_id, name,
 					duration_minutes, total_calories, calories_per_hour,
-					created_at, updated_at
-			FROM activity`
+					created_at, updated_at, ` + page.CursorColumns() + `
+			FROM activity a
+			WHERE ` + visibleActivity + page.Where() + page.OrderLimit()
 
-	rows, err := a.pgpool.Query(ctx, query)
+	rows, err := a.pgpool.Query(ctx, query, append([]any{userID}, page.Args()...)...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, status.Error(codes.NotFound, "activity not found")
@@ -69,6 +96,7 @@ func (a *RepositoryActivity) GetActivity(ctx context.Context, req *pba.GetActivi
 	}
 	defer rows.Close()
 
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -80,8 +108,8 @@ func (a *RepositoryActivity) GetActivity(ctx context.Context, req *pba.GetActivi
 		a := pba.XActivity{}
 
 		err := rows.Scan(
-			&ac.ID, &ac.ID, &ac.Name, &ac.DurationMinutes, &ac.TotalCalories, &ac.CaloriesPerHour,
-			&ac.CreatedAt, &ac.UpdatedAt,
+			&ac.ID, &ac.UserID, &ac.Name, &ac.DurationMinutes, &ac.TotalCalories, &ac.CaloriesPerHour,
+			&ac.CreatedAt, &ac.UpdatedAt, &pager.SortValue, &pager.ID,
 		)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
@@ -89,6 +117,9 @@ func (a *RepositoryActivity) GetActivity(ctx context.Context, req *pba.GetActivi
 			}
 			return nil, status.Error(codes.Internal, "Internal server error")
 		}
+		if !pager.Next() {
+			break
+		}
 
 		createdAt := timestamppb.New(ac.CreatedAt)
 		var updatedAt sql.NullTime
@@ -124,6 +155,8 @@ func (a *RepositoryActivity) GetActivity(ctx context.Context, req *pba.GetActivi
 		return nil, status.Error(codes.NotFound, "activity not found")
 	}
 
+	pager.SetHeader(ctx)
+
 	return &pba.GetActivityRes{
 		Success:  true,
 		Message:  "Activities retrieved successfully",
@@ -143,13 +176,18 @@ func (a *RepositoryActivity) GetActivitiesByName(ctx context.Context, req *pba.G
 		return nil, status.Error(codes.InvalidArgument, "activity ID is required")
 	}
 
+	// the closest match by trigram similarity, so typos still find it
+	userID, _ := ctx.Value("userID").(string)
 	query := `SELECT 	id, user_id, name, duration_minutes,
        					total_calories, calories_per_hour, created_at,
        					updated_at
-			   FROM activity
-			   WHERE name LIKE '%' || $1 || '%'`
+			   FROM activity a
+			   WHERE ` + visibleActivity + `
+			     AND (a.name % $2 OR $2 <% a.name)
+			   ORDER BY GREATEST(similarity(a.name, $2), word_similarity($2, a.name)) DESC, a.name
+			   LIMIT 1`
 
-	err := a.pgpool.QueryRow(ctx, query, nameReq).Scan(
+	err := a.pgpool.QueryRow(ctx, query, userID, nameReq).Scan(
 		&ac.ID, &ac.UserID, &ac.Name, &ac.DurationMinutes, &ac.TotalCalories, &ac.CaloriesPerHour,
 		&ac.CreatedAt, &ac.UpdatedAt,
 	)
@@ -197,13 +235,14 @@ func (a *RepositoryActivity) GetActivitiesByID(ctx context.Context, req *pba.Get
 		return nil, status.Error(codes.InvalidArgument, "activity ID is required")
 	}
 
+	userID, _ := ctx.Value("userID").(string)
 	query := `SELECT 	id, user_id, name, duration_minutes,
        					total_calories, calories_per_hour, created_at,
        					updated_at
-			   FROM activity
-			   WHERE id = $1`
+			   FROM activity a
+			   WHERE a.id = $2 AND ` + visibleActivity
 
-	err := a.pgpool.QueryRow(ctx, query, activityID).Scan(
+	err := a.pgpool.QueryRow(ctx, query, userID, activityID).Scan(
 		&ac.ID, &ac.UserID, &ac.Name, &ac.DurationMinutes, &ac.TotalCalories, &ac.CaloriesPerHour,
 		&ac.CreatedAt, &ac.UpdatedAt,
 	)
@@ -286,6 +325,30 @@ func (a *RepositoryActivity) GetActivitiesByID(ctx context.Context, req *pba.Get
 //}
 
 func (a *RepositoryActivity) SaveSession(ctx context.Context, req *pba.XExerciseSession) error {
+	tx, err := a.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if _, err = insertSession(ctx, tx, req); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertSession saves a finished session, records its WorkoutCompleted
+// event and refreshes the user's aggregates in tx.
+func insertSession(ctx context.Context, tx pgx.Tx, req *pba.XExerciseSession) (string, error) {
 	query := `
 		INSERT INTO exercise_session
 		    (user_id, activity_id, session_name, start_time,
@@ -301,17 +364,33 @@ func (a *RepositoryActivity) SaveSession(ctx context.Context, req *pba.XExercise
 	createdAt := req.CreatedAt.AsTime()
 
 	// Execute the query and get the inserted session ID
-	err := a.pgpool.QueryRow(ctx, query,
+	err := tx.QueryRow(ctx, query,
 		req.UserId, req.ActivityId, req.SessionName, startTime, endTime,
 		req.DurationHours, req.DurationMinutes, req.DurationSeconds, req.CaloriesBurned, createdAt,
 	).Scan(&sessionID)
 
 	if err != nil {
 		log.Printf("Query execution error: %v", err) // Log detailed error
-		return fmt.Errorf("failed to execute query: %w", err)
+		return "", fmt.Errorf("failed to execute query: %w", err)
 	}
 
-	return nil
+	err = events.RecordNew(ctx, tx, events.WorkoutCompleted, req.UserId, sessionID.String(), events.WorkoutCompletedPayload{
+		SessionID:       sessionID.String(),
+		ActivityID:      req.ActivityId,
+		SessionName:     req.SessionName,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		DurationSeconds: int(req.DurationHours)*3600 + int(req.DurationMinutes)*60 + int(req.DurationSeconds),
+		CaloriesBurned:  int(req.CaloriesBurned),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = refreshSessionAggregates(ctx, tx, req.UserId, req.ActivityId); err != nil {
+		return "", err
+	}
+
+	return sessionID.String(), nil
 }
 
 func (a *RepositoryActivity) GetUserExerciseSession(ctx context.Context, req *pba.GetUserExerciseSessionReq) (*pba.GetUserExerciseSessionRes, error) {
@@ -611,11 +690,25 @@ func (a *RepositoryActivity) DeleteExerciseSession(ctx context.Context, req *pba
 		return nil, status.Error(codes.InvalidArgument, "public_id is required")
 	}
 
-	query := `DELETE FROM exercise_session WHERE id = $1`
+	query := `DELETE FROM exercise_session WHERE id = $1
+		RETURNING COALESCE(user_id::text, ''), COALESCE(activity_id::text, '')`
 
-	_, err := a.pgpool.Exec(ctx, query, req.PublicId)
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var userID, activityID string
+		err := tx.QueryRow(ctx, query, req.PublicId).Scan(&userID, &activityID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return fmt.Errorf("failed to delete exercise session: %w", err)
+		}
+		if userID == "" {
+			return nil
+		}
+		return refreshSessionAggregates(ctx, tx, userID, activityID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete exercise session: %w", err)
+		return nil, err
 	}
 
 	return &pba.NilRes{}, nil
@@ -624,9 +717,14 @@ func (a *RepositoryActivity) DeleteExerciseSession(ctx context.Context, req *pba
 func (a *RepositoryActivity) DeleteAllExercisesSession(ctx context.Context, req *pba.DeleteAllExercisesSessionReq) (*pba.NilRes, error) {
 	query := `DELETE FROM exercise_session WHERE user_id = $1`
 
-	_, err := a.pgpool.Exec(ctx, query, req.PublicId)
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, req.PublicId); err != nil {
+			return fmt.Errorf("failed to delete all exercise sessions for user: %w", err)
+		}
+		return refreshSessionAggregates(ctx, tx, req.PublicId)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete all exercise sessions for user: %w", err)
+		return nil, err
 	}
 
 	return &pba.NilRes{}, nil
@@ -3,7 +3,7 @@ package activity
 import (
 	"context"
 	"errors"
-	"sync"
+	"strconv"
 	"time"
 
 	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
@@ -11,30 +11,42 @@ import (
 	"github.com/jackc/pgx/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/preferences"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
+	"github.com/FACorreiaa/fitme-grpc/logger"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware/grpcrequest"
 )
 
-var mu sync.Mutex
-
 type ServiceActivity struct {
 	pba.UnimplementedActivityServer
-	ctx              context.Context
-	repo             domain.RepositoryActivity
-	exerciseSessions map[string]*pba.XExerciseSession
-	pausedTimers     map[string]time.Time
+	ctx       context.Context
+	repo      domain.RepositoryActivity
+	trackers  TrackerRepository
+	files     WorkoutFileRepository
+	stats     StatsRepository
+	sessions  SessionRepository
+	catalog   CatalogRepository
+	timezones *preferences.Timezones
 }
 
-func NewCalculatorService(ctx context.Context, repo domain.RepositoryActivity) *ServiceActivity {
+func NewCalculatorService(ctx context.Context, repo domain.RepositoryActivity, trackers TrackerRepository, files WorkoutFileRepository, stats StatsRepository, sessions SessionRepository, catalog CatalogRepository, timezones *preferences.Timezones) *ServiceActivity {
 	return &ServiceActivity{
-		ctx:              ctx,
-		repo:             repo,
-		exerciseSessions: make(map[string]*pba.XExerciseSession),
-		pausedTimers:     make(map[string]time.Time),
+		ctx:       ctx,
+		repo:      repo,
+		trackers:  trackers,
+		files:     files,
+		stats:     stats,
+		sessions:  sessions,
+		catalog:   catalog,
+		timezones: timezones,
 	}
 }
 
@@ -57,11 +69,17 @@ func (a *ServiceActivity) GetActivity(ctx context.Context, req *pba.GetActivityR
 	if req.PublicId == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "req PublicId is required")
 	}
+	if _, err := uuid.Parse(req.PublicId); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid PublicId")
+	}
 
 	activityResponse, err := a.repo.GetActivity(ctx, req)
 
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	response := &pba.GetActivityRes{}
@@ -117,6 +135,9 @@ func (a *ServiceActivity) GetActivitiesByID(ctx context.Context, req *pba.GetAct
 	if req.PublicId == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "req PublicId is required")
 	}
+	if _, err := uuid.Parse(req.PublicId); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid PublicId")
+	}
 
 	activity, err := a.repo.GetActivitiesByID(ctx, req)
 
@@ -361,6 +382,7 @@ func (a *ServiceActivity) GetUserExerciseSessionStats(ctx context.Context, req *
 		attribute.String("request.id", req.Request.RequestId),
 		attribute.String("request.details", req.String()),
 	)
+	a.setTrainingLoadHeaders(ctx, userID)
 
 	return &pba.GetUserExerciseSessionStatsRes{
 		Success:       true,
@@ -378,6 +400,8 @@ func (a *ServiceActivity) GetUserExerciseSessionStats(ctx context.Context, req *
 //	return nil, nil
 //}
 
+// StartActivityTracker follows the interval plan in the x-interval-plan
+// header, when one is sent.
 func (a *ServiceActivity) StartActivityTracker(ctx context.Context, req *pba.StartActivityTrackerReq) (*pba.StartActivityTrackerRes, error) {
 	tracer := otel.Tracer("FitSphere")
 	ctx, span := tracer.Start(ctx, "Activity/StartActivityTracker")
@@ -393,30 +417,22 @@ func (a *ServiceActivity) StartActivityTracker(ctx context.Context, req *pba.Sta
 		return nil, status.Error(codes.InvalidArgument, "User ID is required")
 	}
 
-	mu.Lock()
-	_, found := a.exerciseSessions[userID]
-	mu.Unlock()
-	if found {
-		return nil, status.Error(codes.FailedPrecondition, "activity tracker already started")
+	var plan *IntervalPlan
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(intervalPlanHeader); len(v) > 0 && v[0] != "" {
+			p, err := ParseIntervalPlan(v[0])
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			plan = p
+		}
 	}
 
-	activityRes, err := a.repo.GetActivitiesByID(ctx, &pba.GetActivityIDReq{PublicId: activityID})
+	tracker, err := a.trackers.StartTracker(ctx, userID, activityID, plan)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to retrieve activity: %v", err)
-	}
-
-	currentTime := time.Now()
-	exerciseSession := &pba.XExerciseSession{
-		ExerciseSessionId: uuid.NewString(),
-		UserId:            userID,
-		ActivityId:        activityRes.Activity.ActivityId,
-		SessionName:       activityRes.Activity.Name,
-		StartTime:         timestamppb.New(time.Now()),
-		CreatedAt:         timestamppb.New(currentTime),
+		return nil, trackerError(err)
 	}
 
-	a.exerciseSessions[exerciseSession.ExerciseSessionId] = exerciseSession
-
 	span.SetAttributes(
 		attribute.String("request.id", req.ActivityId),
 		attribute.String("request.details", req.String()),
@@ -425,13 +441,11 @@ func (a *ServiceActivity) StartActivityTracker(ctx context.Context, req *pba.Sta
 	return &pba.StartActivityTrackerRes{
 		Success:         true,
 		Message:         "Activity tracker started",
-		ExerciseSession: exerciseSession,
+		ExerciseSession: tracker.Session(time.Now()),
 	}, nil
 }
 
 func (a *ServiceActivity) PauseActivityTracker(ctx context.Context, req *pba.PauseActivityTrackerReq) (*pba.PauseActivityTrackerRes, error) {
-	// this is the user session! change after
-
 	tracer := otel.Tracer("FitSphere")
 	ctx, span := tracer.Start(ctx, "Activity/PauseActivityTracker")
 	defer span.End()
@@ -441,10 +455,14 @@ func (a *ServiceActivity) PauseActivityTracker(ctx context.Context, req *pba.Pau
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "Session ID is required")
 	}
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
 
-	mu.Lock()
-	a.pausedTimers[sessionID] = time.Now()
-	mu.Unlock()
+	if _, err := a.trackers.PauseTracker(ctx, userID, sessionID); err != nil {
+		return nil, trackerError(err)
+	}
 	return &pba.PauseActivityTrackerRes{
 		Success: true,
 		Message: "Activity tracker paused",
@@ -460,41 +478,20 @@ func (a *ServiceActivity) ResumeActivityTracker(ctx context.Context, req *pba.Re
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "Session ID is required")
 	}
-
-	mu.Lock()
-	session, found := a.exerciseSessions[sessionID]
-	mu.Unlock()
-
-	if !found {
-		return nil, status.Error(codes.FailedPrecondition, "activity tracker session not found")
-	}
-
-	if sessionID != session.ExerciseSessionId {
-		return nil, status.Error(codes.FailedPrecondition, "activity tracker session not found")
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	//session, _ := a.exerciseSessions[sessionID]
-	//if !found {
-	//	return nil, status.Error(codes.FailedPrecondition, "activity tracker session not found")
-	//}
-
-	pausedTime, found := a.pausedTimers[sessionID]
-	if !found {
-		return nil, status.Error(codes.FailedPrecondition, "activity tracker was not paused")
+	tracker, err := a.trackers.ResumeTracker(ctx, userID, sessionID)
+	if err != nil {
+		return nil, trackerError(err)
 	}
 
-	startTime := session.StartTime.AsTime()
-
-	pausedDuration := time.Since(pausedTime)
-	adjustedStartTime := startTime.Add(pausedDuration)
-
-	session.StartTime = timestamppb.New(adjustedStartTime)
-	delete(a.pausedTimers, sessionID)
-
 	return &pba.ResumeActivityTrackerRes{
 		Success:         true,
 		Message:         "Activity tracker resumed successfully",
-		ExerciseSession: session, // Return the updated session with adjusted start time
+		ExerciseSession: tracker.Session(time.Now()), // start time shifted by the time spent paused
 	}, nil
 }
 
@@ -503,39 +500,21 @@ func (a *ServiceActivity) StopActivityTracker(ctx context.Context, req *pba.Stop
 	ctx, span := tracer.Start(ctx, "Activity/StopActivityTracker")
 	defer span.End()
 
-	// review protos.
 	sessionID := req.SessionId
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "Session ID is required")
 	}
-	mu.Lock()
-	session, found := a.exerciseSessions[sessionID]
-	mu.Unlock()
-	if !found {
-		return nil, status.Error(codes.FailedPrecondition, "activity tracker session not found")
-	}
-
-	activityRes, err := a.repo.GetActivitiesByID(ctx, &pba.GetActivityIDReq{PublicId: session.ActivityId})
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Error getting activity: %v", err)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	startUpTime := session.StartTime.AsTime()
-
-	totalDurationSeconds := int(time.Since(startUpTime).Seconds())
-	session.DurationHours = uint32(totalDurationSeconds / 3600)
-	session.DurationMinutes = uint32((totalDurationSeconds % 3600) / 60)
-	session.DurationSeconds = uint32(totalDurationSeconds % 60)
-
-	caloriesPerSecond := activityRes.Activity.CaloriesPerHour / 3600
-	session.CaloriesBurned = uint32(caloriesPerSecond * float32(totalDurationSeconds))
-	//session.EndTime = timestamppb.New(time.Now()) // change protobuf generation
-	session.EndTime = timestamppb.New(time.Now())
-	err = a.repo.SaveSession(ctx, session)
+	tracker, session, err := a.trackers.StopTracker(ctx, userID, sessionID)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Error saving exercise session to DB: %v", err)
+		return nil, trackerError(err)
 	}
-	delete(a.exerciseSessions, sessionID)
+	span.SetAttributes(attribute.Int("tracker.pauses", len(tracker.Pauses)))
+	a.setHeartRateCaloriesHeader(ctx, tracker)
 
 	return &pba.StopActivityTrackerRes{
 		Success:         true,
@@ -545,6 +524,49 @@ func (a *ServiceActivity) StopActivityTracker(ctx context.Context, req *pba.Stop
 
 }
 
+// ActiveTracker returns the user's running or paused tracker with its pause
+// history, so a client can pick a workout back up after reconnecting.
+func (a *ServiceActivity) ActiveTracker(ctx context.Context, userID string) (*Tracker, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "User ID is required")
+	}
+	tracker, err := a.trackers.ActiveTracker(ctx, userID)
+	if err != nil {
+		return nil, trackerError(err)
+	}
+	return tracker, nil
+}
+
+// setHeartRateCaloriesHeader offers the heart rate based burn next to the
+// saved MET figure when the workout was streamed with heart rate readings.
+// It never fails the call.
+func (a *ServiceActivity) setHeartRateCaloriesHeader(ctx context.Context, tracker *Tracker) {
+	if tracker.EndedAt == nil {
+		return
+	}
+	samples, err := a.trackers.Samples(ctx, tracker.ID)
+	if err != nil || len(samples) == 0 {
+		return
+	}
+	profile, err := a.trackers.Profile(ctx, tracker.UserID)
+	if err != nil {
+		logger.Log.Warn("failed to fetch profile", zap.String("user_id", tracker.UserID), zap.Error(err))
+		return
+	}
+	if kcal, ok := heartRateCalories(samples, profile, *tracker.EndedAt); ok {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(heartRateCaloriesHeader, strconv.Itoa(int(kcal))))
+	}
+}
+
+// trackerError passes the repository's status errors through and hides
+// everything else behind Internal.
+func trackerError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "activity tracker: %v", err)
+}
+
 func (a *ServiceActivity) DeleteExerciseSession(ctx context.Context, req *pba.DeleteExerciseSessionReq) (*pba.NilRes, error) {
 	tracer := otel.Tracer("FitSphere")
 	ctx, span := tracer.Start(ctx, "Activity/DeleteExerciseSession")
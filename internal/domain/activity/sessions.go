@@ -0,0 +1,385 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	manualSource = "MANUAL"
+	// a session may be logged while the clock on the phone runs ahead
+	maxClockSkew       = 5 * time.Minute
+	maxSessionDuration = 24 * time.Hour
+	maxSessionCalories = 20000
+)
+
+// SessionInput is a session entered by hand. The end comes from EndTime or,
+// when that is zero, from DurationSeconds; if both are given they must
+// agree. CaloriesBurned zero means estimate it from the activity's METs and
+// the user's weight.
+type SessionInput struct {
+	ActivityID      string    `json:"activity_id"`
+	SessionName     string    `json:"session_name,omitempty"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time,omitempty"`
+	DurationSeconds int       `json:"duration_seconds,omitempty"`
+	CaloriesBurned  int       `json:"calories_burned,omitempty"`
+}
+
+// span validates in and returns the time it covers, in UTC.
+func (in *SessionInput) span(now time.Time) (start, end time.Time, err error) {
+	switch {
+	case in.ActivityID == "":
+		return start, end, errors.New("activity_id is required")
+	case uuid.Validate(in.ActivityID) != nil:
+		return start, end, errors.New("invalid activity_id")
+	case in.StartTime.IsZero():
+		return start, end, errors.New("start_time is required")
+	case in.EndTime.IsZero() && in.DurationSeconds <= 0:
+		return start, end, errors.New("end_time or duration_seconds is required")
+	case in.DurationSeconds < 0:
+		return start, end, errors.New("duration cannot be negative")
+	case in.CaloriesBurned < 0 || in.CaloriesBurned > maxSessionCalories:
+		return start, end, fmt.Errorf("calories must be between 0 and %d", maxSessionCalories)
+	}
+
+	start = in.StartTime.UTC()
+	end = in.EndTime.UTC()
+	if in.EndTime.IsZero() {
+		end = start.Add(time.Duration(in.DurationSeconds) * time.Second)
+	} else if in.DurationSeconds > 0 {
+		if diff := end.Sub(start) - time.Duration(in.DurationSeconds)*time.Second; diff.Abs() > time.Second {
+			return start, end, errors.New("end_time and duration_seconds disagree")
+		}
+	}
+
+	switch d := end.Sub(start); {
+	case d < time.Second:
+		return start, end, errors.New("end_time must be after start_time")
+	case d > maxSessionDuration:
+		return start, end, fmt.Errorf("sessions last at most %s", formatStep(maxSessionDuration))
+	case end.After(now.Add(maxClockSkew)):
+		return start, end, errors.New("sessions can't end in the future")
+	}
+	return start, end, nil
+}
+
+// SessionRepository is implemented by RepositoryActivity. Both methods
+// refuse sessions overlapping another of the user's, or the tracker the
+// user has running, and recalculate the user's aggregates.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, userID string, in *SessionInput, start, end time.Time) (*ExerciseSession, error)
+	UpdateSession(ctx context.Context, userID, sessionID string, in *SessionInput, start, end time.Time) (*ExerciseSession, error)
+}
+
+// CreateSession logs a session the tracker didn't record, such as a swim
+// from yesterday.
+func (a *ServiceActivity) CreateSession(ctx context.Context, userID string, in *SessionInput) (*ExerciseSession, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/CreateSession")
+	defer span.End()
+
+	if err := requireIDs(userID); err != nil {
+		return nil, err
+	}
+	start, end, err := in.span(time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s, err := a.sessions.CreateSession(ctx, userID, in, start, end)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	span.SetAttributes(attribute.String("session.id", s.ID))
+	return s, nil
+}
+
+// UpdateSession replaces the activity, times, name and calories of one of
+// the user's sessions, such as one left running after the workout ended.
+// Laps that start after the new end are dropped.
+func (a *ServiceActivity) UpdateSession(ctx context.Context, userID, sessionID string, in *SessionInput) (*ExerciseSession, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "Activity/UpdateSession")
+	defer span.End()
+
+	if err := requireIDs(userID); err != nil {
+		return nil, err
+	}
+	if err := requireID("Session ID", sessionID); err != nil {
+		return nil, err
+	}
+	start, end, err := in.span(time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s, err := a.sessions.UpdateSession(ctx, userID, sessionID, in, start, end)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	span.SetAttributes(attribute.String("session.id", s.ID))
+	return s, nil
+}
+
+func sessionError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "exercise session: %v", err)
+}
+
+// sessionSeconds is the stored duration of es as an SQL expression.
+const sessionSeconds = `(COALESCE(es.duration_hours, 0) * 3600 + COALESCE(es.duration_minutes, 0) * 60 + COALESCE(es.duration_seconds, 0))`
+
+// lockUserSessions serialises session writes for one user until tx ends,
+// so two overlapping sessions can't pass the overlap check together.
+func lockUserSessions(ctx context.Context, tx pgx.Tx, userID string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('exercise_session:' || $1::text))`, userID); err != nil {
+		return fmt.Errorf("failed to lock sessions: %w", err)
+	}
+	return nil
+}
+
+// checkOverlap fails when [start, end) overlaps one of the user's sessions
+// other than exceptID, or the user's active tracker.
+func checkOverlap(ctx context.Context, tx pgx.Tx, userID, exceptID string, start, end time.Time) error {
+	var name string
+	var otherStart, otherEnd time.Time
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(es.session_name, ''), es.start_time, o.end_time
+		FROM exercise_session es
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(es.end_time, es.start_time + make_interval(secs => `+sessionSeconds+`)) AS end_time
+		) o
+		WHERE es.user_id = $1 AND es.id::text <> $4
+		  AND es.start_time < $3 AND o.end_time > $2
+		ORDER BY es.start_time
+		LIMIT 1`, userID, start, end, exceptID).Scan(&name, &otherStart, &otherEnd)
+	switch {
+	case err == nil:
+		return status.Errorf(codes.FailedPrecondition, "overlaps the session %q from %s to %s",
+			name, otherStart.Format(time.RFC3339), otherEnd.Format(time.RFC3339))
+	case !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("failed to check overlapping sessions: %w", err)
+	}
+
+	var trackerStart time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT started_at FROM activity_trackers
+		WHERE user_id = $1 AND status IN ($2, $3) AND started_at < $4`,
+		userID, TrackerRunning, TrackerPaused, end).Scan(&trackerStart)
+	switch {
+	case err == nil:
+		return status.Errorf(codes.FailedPrecondition, "overlaps the workout being tracked since %s",
+			trackerStart.UTC().Format(time.RFC3339))
+	case !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("failed to check active tracker: %w", err)
+	}
+	return nil
+}
+
+// manualCalories is in's calories, or the MET estimate for the activity at
+// the user's latest weight.
+func manualCalories(ctx context.Context, tx pgx.Tx, userID string, in *SessionInput, d time.Duration) (name string, kcal int, err error) {
+	var met, weightKg float64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(a.name, ''), COALESCE(a.met, 0)::float8, COALESCE(`+latestWeightSQL+`, 0)
+		FROM activity a
+		WHERE a.id = $2 AND (a.user_id IS NULL OR a.user_id = $1) AND a.deleted_at IS NULL`, userID, in.ActivityID).Scan(&name, &met, &weightKg)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", 0, status.Error(codes.NotFound, "activity not found")
+		}
+		return "", 0, fmt.Errorf("failed to fetch activity: %w", err)
+	}
+	if in.SessionName != "" {
+		name = in.SessionName
+	}
+	if in.CaloriesBurned > 0 {
+		return name, in.CaloriesBurned, nil
+	}
+	return name, int(math.Round(metCalories(met, weightKg, d))), nil
+}
+
+func (a *RepositoryActivity) CreateSession(ctx context.Context, userID string, in *SessionInput, start, end time.Time) (*ExerciseSession, error) {
+	var s *ExerciseSession
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		if err := lockUserSessions(ctx, tx, userID); err != nil {
+			return err
+		}
+		if err := checkOverlap(ctx, tx, userID, "", start, end); err != nil {
+			return err
+		}
+		name, kcal, err := manualCalories(ctx, tx, userID, in, end.Sub(start))
+		if err != nil {
+			return err
+		}
+
+		seconds := int(end.Sub(start).Seconds())
+		now := time.Now().UTC()
+		sessionID, err := insertSession(ctx, tx, &pba.XExerciseSession{
+			UserId:          userID,
+			ActivityId:      in.ActivityID,
+			SessionName:     name,
+			StartTime:       timestamppb.New(start),
+			EndTime:         timestamppb.New(end),
+			DurationHours:   uint32(seconds / 3600),
+			DurationMinutes: uint32((seconds % 3600) / 60),
+			DurationSeconds: uint32(seconds % 60),
+			CaloriesBurned:  uint32(kcal),
+			CreatedAt:       timestamppb.New(now),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, `UPDATE exercise_session SET source = $2 WHERE id = $1`, sessionID, manualSource); err != nil {
+			return fmt.Errorf("failed to mark manual session: %w", err)
+		}
+		s = &ExerciseSession{
+			ID: sessionID, UserID: userID, ActivityID: in.ActivityID, SessionName: name,
+			StartTime: start, EndTime: end, CaloriesBurned: kcal, CreatedAt: now,
+		}
+		s.DurationHours, s.DurationMinutes, s.DurationSeconds = seconds/3600, (seconds%3600)/60, seconds%60
+		return nil
+	})
+	return s, err
+}
+
+func (a *RepositoryActivity) UpdateSession(ctx context.Context, userID, sessionID string, in *SessionInput, start, end time.Time) (*ExerciseSession, error) {
+	var s *ExerciseSession
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		if err := lockUserSessions(ctx, tx, userID); err != nil {
+			return err
+		}
+		var owner, previousActivity string
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE(user_id::text, ''), COALESCE(activity_id::text, '')
+			FROM exercise_session
+			WHERE id = $1
+			FOR UPDATE`, sessionID).Scan(&owner, &previousActivity)
+		if err != nil || owner != userID {
+			if err == nil || errors.Is(err, pgx.ErrNoRows) {
+				return status.Error(codes.NotFound, "session not found")
+			}
+			return fmt.Errorf("failed to fetch session: %w", err)
+		}
+		if err = checkOverlap(ctx, tx, userID, sessionID, start, end); err != nil {
+			return err
+		}
+		name, kcal, err := manualCalories(ctx, tx, userID, in, end.Sub(start))
+		if err != nil {
+			return err
+		}
+
+		seconds := int(end.Sub(start).Seconds())
+		s = &ExerciseSession{
+			ID: sessionID, UserID: userID, ActivityID: in.ActivityID, SessionName: name,
+			StartTime: start, EndTime: end, CaloriesBurned: kcal,
+			DurationHours: seconds / 3600, DurationMinutes: (seconds % 3600) / 60, DurationSeconds: seconds % 60,
+		}
+		err = tx.QueryRow(ctx, `
+			UPDATE exercise_session
+			SET activity_id = $2, session_name = $3, start_time = $4, end_time = $5,
+			    duration_hours = $6, duration_minutes = $7, duration_seconds = $8,
+			    calories_burned = $9, updated_at = now()
+			WHERE id = $1
+			RETURNING created_at, updated_at`,
+			sessionID, in.ActivityID, name, start, end,
+			s.DurationHours, s.DurationMinutes, s.DurationSeconds, kcal).Scan(&s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+		if _, err = tx.Exec(ctx, `
+			DELETE FROM exercise_session_laps WHERE session_id = $1 AND start_time >= $2`, sessionID, end); err != nil {
+			return fmt.Errorf("failed to trim laps: %w", err)
+		}
+		return refreshSessionAggregates(ctx, tx, userID, previousActivity, in.ActivityID)
+	})
+	return s, err
+}
+
+// refreshSessionAggregates recomputes total_exercise_session and the
+// exercise_stats rows of activityIDs from the user's sessions, in the same
+// transaction as the write that changed them. Rows left with no sessions
+// are removed. No activity ids refreshes every activity of the user.
+func refreshSessionAggregates(ctx context.Context, tx pgx.Tx, userID string, activityIDs ...string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO total_exercise_session
+		    (user_id, total_duration_hours, total_duration_minutes, total_duration_seconds,
+		     total_calories_burned, session_name, updated_at)
+		SELECT $1, t.seconds / 3600, (t.seconds % 3600) / 60, t.seconds % 60, t.calories,
+		       (SELECT session_name FROM exercise_session WHERE user_id = $1 ORDER BY start_time DESC NULLS LAST LIMIT 1),
+		       now()
+		FROM (
+			SELECT COALESCE(SUM(`+sessionSeconds+`), 0)::int AS seconds,
+			       COALESCE(SUM(es.calories_burned), 0)::int AS calories
+			FROM exercise_session es
+			WHERE es.user_id = $1
+			HAVING COUNT(*) > 0
+		) t
+		ON CONFLICT (user_id) DO UPDATE SET
+			total_duration_hours = EXCLUDED.total_duration_hours,
+			total_duration_minutes = EXCLUDED.total_duration_minutes,
+			total_duration_seconds = EXCLUDED.total_duration_seconds,
+			total_calories_burned = EXCLUDED.total_calories_burned,
+			session_name = EXCLUDED.session_name,
+			updated_at = EXCLUDED.updated_at`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh total exercise session: %w", err)
+	}
+	if _, err = tx.Exec(ctx, `
+		DELETE FROM total_exercise_session
+		WHERE user_id = $1 AND NOT EXISTS (SELECT 1 FROM exercise_session WHERE user_id = $1)`, userID); err != nil {
+		return fmt.Errorf("failed to refresh total exercise session: %w", err)
+	}
+
+	ids := make([]string, 0, len(activityIDs))
+	for _, id := range activityIDs {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	all := len(activityIDs) == 0
+	if !all && len(ids) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO exercise_stats
+		    (user_id, activity_id, session_name, number_of_times, total_duration_seconds, total_calories_burned, updated_at)
+		SELECT es.user_id, es.activity_id, MAX(es.session_name), COUNT(*),
+		       SUM(`+sessionSeconds+`)::int, COALESCE(SUM(es.calories_burned), 0)::int, now()
+		FROM exercise_session es
+		JOIN activity a ON a.id = es.activity_id
+		WHERE es.user_id = $1 AND ($2 OR es.activity_id::text = ANY($3))
+		GROUP BY es.user_id, es.activity_id
+		ON CONFLICT (user_id, activity_id) DO UPDATE SET
+			session_name = EXCLUDED.session_name,
+			number_of_times = EXCLUDED.number_of_times,
+			total_duration_seconds = EXCLUDED.total_duration_seconds,
+			total_calories_burned = EXCLUDED.total_calories_burned,
+			updated_at = EXCLUDED.updated_at`, userID, all, ids)
+	if err != nil {
+		return fmt.Errorf("failed to refresh exercise stats: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM exercise_stats st
+		WHERE st.user_id = $1 AND ($2 OR st.activity_id::text = ANY($3))
+		  AND NOT EXISTS (
+			SELECT 1 FROM exercise_session es
+			WHERE es.user_id = st.user_id AND es.activity_id = st.activity_id)`, userID, all, ids)
+	if err != nil {
+		return fmt.Errorf("failed to refresh exercise stats: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package activity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionInputSpan(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	lisbon := time.FixedZone("WEST", 3600)
+	yesterday := time.Date(2024, 5, 1, 19, 0, 0, 0, lisbon)
+	const activityID = "0d9e6a57-1c2b-4f3e-8a90-b6c7d8e9f012"
+
+	tests := []struct {
+		name      string
+		in        SessionInput
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   string
+	}{
+		{"duration", SessionInput{ActivityID: activityID, StartTime: yesterday, DurationSeconds: 45 * 60},
+			time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 18, 45, 0, 0, time.UTC), ""},
+		{"end", SessionInput{ActivityID: activityID, StartTime: yesterday, EndTime: yesterday.Add(time.Hour), DurationSeconds: 3600},
+			time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 19, 0, 0, 0, time.UTC), ""},
+		{"no activity", SessionInput{StartTime: yesterday, DurationSeconds: 60}, time.Time{}, time.Time{}, "activity_id is required"},
+		{"bad activity", SessionInput{ActivityID: "a1", StartTime: yesterday, DurationSeconds: 60}, time.Time{}, time.Time{}, "invalid activity_id"},
+		{"no end", SessionInput{ActivityID: activityID, StartTime: yesterday}, time.Time{}, time.Time{}, "end_time or duration_seconds is required"},
+		{"disagree", SessionInput{ActivityID: activityID, StartTime: yesterday, EndTime: yesterday.Add(time.Hour), DurationSeconds: 60},
+			time.Time{}, time.Time{}, "end_time and duration_seconds disagree"},
+		{"backwards", SessionInput{ActivityID: activityID, StartTime: yesterday, EndTime: yesterday.Add(-time.Hour)},
+			time.Time{}, time.Time{}, "end_time must be after start_time"},
+		{"too long", SessionInput{ActivityID: activityID, StartTime: yesterday.Add(-48 * time.Hour), DurationSeconds: 25 * 3600},
+			time.Time{}, time.Time{}, "sessions last at most 24h"},
+		{"future", SessionInput{ActivityID: activityID, StartTime: now, DurationSeconds: 3600}, time.Time{}, time.Time{}, "sessions can't end in the future"},
+		{"calories", SessionInput{ActivityID: activityID, StartTime: yesterday, DurationSeconds: 60, CaloriesBurned: -5},
+			time.Time{}, time.Time{}, "calories must be between 0 and 20000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := tt.in.span(now)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("span() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) || start.Location() != time.UTC {
+				t.Errorf("span() = %s, %s, %v", start, end, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+// TrackerStatus is where a live tracker is in its life. RUNNING and PAUSED
+// trackers are active; a user has at most one.
+type TrackerStatus string
+
+const (
+	TrackerRunning TrackerStatus = "RUNNING"
+	TrackerPaused  TrackerStatus = "PAUSED"
+	TrackerStopped TrackerStatus = "STOPPED"
+	// TrackerAbandoned trackers were closed by the reaper.
+	TrackerAbandoned TrackerStatus = "ABANDONED"
+)
+
+const (
+	// A tracker nobody touched for this long is assumed forgotten. Running
+	// trackers get longer, since a workout needs no calls between start
+	// and stop.
+	runningTrackerTimeout = 6 * time.Hour
+	pausedTrackerTimeout  = 2 * time.Hour
+	reapBatchSize         = 100
+
+	kindReapTrackers = "activity.reap_trackers"
+)
+
+// Pause is one pause of a tracker; ResumedAt is nil while it lasts.
+type Pause struct {
+	PausedAt  time.Time  `json:"paused_at"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+}
+
+// Tracker is a live workout. LastSeenAt moves on every start, pause, resume
+// and lap, and is what the reaper measures abandonment from. MET comes from
+// the activity and WeightKg is the user's latest weigh-in, zero if none.
+// Plan is the interval plan it follows, and Laps the times laps were marked.
+type Tracker struct {
+	ID          string        `json:"id"`
+	UserID      string        `json:"user_id"`
+	ActivityID  string        `json:"activity_id"`
+	SessionName string        `json:"session_name"`
+	MET         float64       `json:"met"`
+	WeightKg    float64       `json:"weight_kg"`
+	Status      TrackerStatus `json:"status"`
+	StartedAt   time.Time     `json:"started_at"`
+	LastSeenAt  time.Time     `json:"last_seen_at"`
+	EndedAt     *time.Time    `json:"ended_at,omitempty"`
+	SessionID   string        `json:"session_id,omitempty"`
+	Pauses      []Pause       `json:"pauses"`
+	Plan        string        `json:"plan,omitempty"`
+	Laps        []time.Time   `json:"laps"`
+}
+
+// Active is the time spent training between StartedAt and end, pauses left
+// out.
+func (t Tracker) Active(end time.Time) time.Duration {
+	if end.Before(t.StartedAt) {
+		return 0
+	}
+	active := end.Sub(t.StartedAt)
+	for _, p := range t.Pauses {
+		if !p.PausedAt.Before(end) {
+			continue
+		}
+		resumed := end
+		if p.ResumedAt != nil && p.ResumedAt.Before(end) {
+			resumed = *p.ResumedAt
+		}
+		active -= resumed.Sub(p.PausedAt)
+	}
+	if active < 0 {
+		return 0
+	}
+	return active
+}
+
+// Session describes the tracker as of at. StartTime is shifted forward by
+// the time spent paused, so at minus StartTime is always the active time;
+// clients have relied on that since trackers lived in memory.
+func (t Tracker) Session(at time.Time) *pba.XExerciseSession {
+	active := t.Active(at)
+	seconds := int(active.Seconds())
+	return &pba.XExerciseSession{
+		ExerciseSessionId: t.ID,
+		UserId:            t.UserID,
+		ActivityId:        t.ActivityID,
+		SessionName:       t.SessionName,
+		StartTime:         timestamppb.New(at.Add(-active)),
+		DurationHours:     uint32(seconds / 3600),
+		DurationMinutes:   uint32((seconds % 3600) / 60),
+		DurationSeconds:   uint32(seconds % 60),
+		CaloriesBurned:    uint32(metCalories(t.MET, t.WeightKg, active)),
+		CreatedAt:         timestamppb.New(t.StartedAt),
+	}
+}
+
+func (t Tracker) expired(now time.Time) bool {
+	switch t.Status {
+	case TrackerRunning:
+		return now.Sub(t.LastSeenAt) > runningTrackerTimeout
+	case TrackerPaused:
+		return now.Sub(t.LastSeenAt) > pausedTrackerTimeout
+	}
+	return false
+}
+
+// TrackerRepository is implemented by RepositoryActivity. State lives in
+// Postgres so trackers survive restarts and work across replicas.
+type TrackerRepository interface {
+	StartTracker(ctx context.Context, userID, activityID string, plan *IntervalPlan) (*Tracker, error)
+	ActiveTracker(ctx context.Context, userID string) (*Tracker, error)
+	Tracker(ctx context.Context, trackerID string) (*Tracker, error)
+	PauseTracker(ctx context.Context, userID, trackerID string) (*Tracker, error)
+	ResumeTracker(ctx context.Context, userID, trackerID string) (*Tracker, error)
+	MarkLap(ctx context.Context, userID, trackerID string) (*Tracker, error)
+	StopTracker(ctx context.Context, userID, trackerID string) (*Tracker, *pba.XExerciseSession, error)
+	ReapTrackers(ctx context.Context) (int, error)
+	RecordSample(ctx context.Context, trackerID string, s Sample) error
+	Samples(ctx context.Context, trackerID string) ([]Sample, error)
+	Profile(ctx context.Context, userID string) (Profile, error)
+	SessionLaps(ctx context.Context, userID, sessionID string) ([]SessionLap, error)
+}
+
+var (
+	errTrackerNotFound = status.Error(codes.FailedPrecondition, "activity tracker session not found")
+	errTrackerStarted  = status.Error(codes.FailedPrecondition, "activity tracker already started")
+	errTrackerPaused   = status.Error(codes.FailedPrecondition, "activity tracker already paused")
+	errTrackerRunning  = status.Error(codes.FailedPrecondition, "activity tracker was not paused")
+)
+
+// latestWeightSQL is the user's latest weight in kg for the user in $1, or
+// NULL; trackerColumns swaps in the tracker's user.
+const latestWeightSQL = `COALESCE(
+	(SELECT weight_value::float8 FROM weight_measure WHERE user_id = $1 AND weight_value > 0 ORDER BY created_at DESC LIMIT 1),
+	(SELECT weight::float8 FROM user_bio_data WHERE user_id = $1 AND weight > 0 ORDER BY created_at DESC LIMIT 1))`
+
+var trackerColumns = `t.id, t.user_id, t.activity_id, t.session_name, COALESCE(a.met, 0)::float8,
+	COALESCE(` + strings.ReplaceAll(latestWeightSQL, "$1", "t.user_id") + `, 0),
+	t.status, t.started_at, t.last_seen_at, t.ended_at, COALESCE(t.exercise_session_id::text, ''),
+	COALESCE(t.interval_plan, '')`
+
+func scanTracker(row pgx.Row) (*Tracker, error) {
+	var t Tracker
+	err := row.Scan(&t.ID, &t.UserID, &t.ActivityID, &t.SessionName, &t.MET, &t.WeightKg,
+		&t.Status, &t.StartedAt, &t.LastSeenAt, &t.EndedAt, &t.SessionID, &t.Plan)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// loadTracker reads a tracker, its pauses and its laps, locking the
+// tracker row.
+func loadTracker(ctx context.Context, tx pgx.Tx, trackerID string) (*Tracker, error) {
+	return queryTracker(ctx, tx, `t.id = $1`, trackerID)
+}
+
+// loadUserTracker is loadTracker for changes a user asks for: another
+// user's tracker is not found.
+func loadUserTracker(ctx context.Context, tx pgx.Tx, userID, trackerID string) (*Tracker, error) {
+	return queryTracker(ctx, tx, `t.id = $1 AND t.user_id = $2`, trackerID, userID)
+}
+
+func queryTracker(ctx context.Context, tx pgx.Tx, where string, args ...any) (*Tracker, error) {
+	t, err := scanTracker(tx.QueryRow(ctx, `
+		SELECT `+trackerColumns+`
+		FROM activity_trackers t
+		LEFT JOIN activity a ON a.id = t.activity_id
+		WHERE `+where+`
+		FOR UPDATE OF t`, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errTrackerNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch tracker: %w", err)
+	}
+	if err = loadPauses(ctx, tx, t); err != nil {
+		return nil, err
+	}
+	if err = loadLapMarks(ctx, tx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func loadPauses(ctx context.Context, tx pgx.Tx, t *Tracker) error {
+	rows, err := tx.Query(ctx, `
+		SELECT paused_at, resumed_at
+		FROM activity_tracker_pauses
+		WHERE tracker_id = $1
+		ORDER BY paused_at`, t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch tracker pauses: %w", err)
+	}
+	defer rows.Close()
+
+	t.Pauses = make([]Pause, 0)
+	for rows.Next() {
+		var p Pause
+		if err = rows.Scan(&p.PausedAt, &p.ResumedAt); err != nil {
+			return fmt.Errorf("failed to scan tracker pause: %w", err)
+		}
+		t.Pauses = append(t.Pauses, p)
+	}
+	return rows.Err()
+}
+
+// inTx runs fn in a transaction, committing when it returns nil.
+func (a *RepositoryActivity) inTx(ctx context.Context, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := a.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// StartTracker relies on the one-active-tracker-per-user index, so two
+// replicas racing to start the same user's workout can't both win. plan may
+// be nil.
+func (a *RepositoryActivity) StartTracker(ctx context.Context, userID, activityID string, plan *IntervalPlan) (*Tracker, error) {
+	spec := ""
+	if plan != nil {
+		spec = plan.String()
+	}
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var trackerID string
+		err := tx.QueryRow(ctx, `
+			INSERT INTO activity_trackers (user_id, activity_id, session_name, status, interval_plan)
+			SELECT $1, a.id, COALESCE(a.name, ''), $3, NULLIF($4, '')
+			FROM activity a
+			WHERE a.id = $2 AND (a.user_id IS NULL OR a.user_id = $1) AND a.deleted_at IS NULL
+			RETURNING id`, userID, activityID, TrackerRunning, spec).Scan(&trackerID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				return status.Error(codes.NotFound, "activity not found")
+			case errors.As(err, &pgErr) && pgErr.Code == "23505":
+				return errTrackerStarted
+			}
+			return fmt.Errorf("failed to start tracker: %w", err)
+		}
+		t, err = loadTracker(ctx, tx, trackerID)
+		return err
+	})
+	return t, err
+}
+
+func (a *RepositoryActivity) ActiveTracker(ctx context.Context, userID string) (*Tracker, error) {
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var trackerID string
+		err := tx.QueryRow(ctx, `
+			SELECT id FROM activity_trackers
+			WHERE user_id = $1 AND status IN ($2, $3)`, userID, TrackerRunning, TrackerPaused).Scan(&trackerID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errTrackerNotFound
+			}
+			return fmt.Errorf("failed to fetch active tracker: %w", err)
+		}
+		t, err = loadTracker(ctx, tx, trackerID)
+		return err
+	})
+	return t, err
+}
+
+func (a *RepositoryActivity) PauseTracker(ctx context.Context, userID, trackerID string) (*Tracker, error) {
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if t, err = loadUserTracker(ctx, tx, userID, trackerID); err != nil {
+			return err
+		}
+		switch t.Status {
+		case TrackerPaused:
+			return errTrackerPaused
+		case TrackerRunning:
+		default:
+			return errTrackerNotFound
+		}
+
+		now := time.Now().UTC()
+		if _, err = tx.Exec(ctx, `
+			INSERT INTO activity_tracker_pauses (tracker_id, paused_at) VALUES ($1, $2)`, t.ID, now); err != nil {
+			return fmt.Errorf("failed to record pause: %w", err)
+		}
+		if err = setTrackerStatus(ctx, tx, t, TrackerPaused, now); err != nil {
+			return err
+		}
+		t.Pauses = append(t.Pauses, Pause{PausedAt: now})
+		return nil
+	})
+	return t, err
+}
+
+func (a *RepositoryActivity) ResumeTracker(ctx context.Context, userID, trackerID string) (*Tracker, error) {
+	var t *Tracker
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if t, err = loadUserTracker(ctx, tx, userID, trackerID); err != nil {
+			return err
+		}
+		switch t.Status {
+		case TrackerRunning:
+			return errTrackerRunning
+		case TrackerPaused:
+		default:
+			return errTrackerNotFound
+		}
+
+		now := time.Now().UTC()
+		if err = closePause(ctx, tx, t, now); err != nil {
+			return err
+		}
+		return setTrackerStatus(ctx, tx, t, TrackerRunning, now)
+	})
+	return t, err
+}
+
+// StopTracker closes the tracker and saves the exercise session in one
+// transaction, so a retried stop can't save the workout twice.
+func (a *RepositoryActivity) StopTracker(ctx context.Context, userID, trackerID string) (*Tracker, *pba.XExerciseSession, error) {
+	var t *Tracker
+	var session *pba.XExerciseSession
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if t, err = loadUserTracker(ctx, tx, userID, trackerID); err != nil {
+			return err
+		}
+		if t.Status != TrackerRunning && t.Status != TrackerPaused {
+			return errTrackerNotFound
+		}
+		session, err = closeTracker(ctx, tx, t, TrackerStopped, time.Now().UTC())
+		return err
+	})
+	return t, session, err
+}
+
+// ReapTrackers closes trackers left running or paused for too long. Only
+// the time up to the last interaction is saved: after that nobody knows
+// whether the user was still training.
+func (a *RepositoryActivity) ReapTrackers(ctx context.Context) (int, error) {
+	reaped := 0
+	err := a.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT id FROM activity_trackers
+			WHERE (status = $1 AND last_seen_at < now() - make_interval(secs => $3))
+			   OR (status = $2 AND last_seen_at < now() - make_interval(secs => $4))
+			ORDER BY last_seen_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED`,
+			TrackerRunning, TrackerPaused, runningTrackerTimeout.Seconds(), pausedTrackerTimeout.Seconds(), reapBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to find abandoned trackers: %w", err)
+		}
+		ids := make([]string, 0)
+		for rows.Next() {
+			var id string
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan abandoned tracker: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("failed to find abandoned trackers: %w", err)
+		}
+
+		now := time.Now().UTC()
+		for _, id := range ids {
+			t, err := loadTracker(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			if !t.expired(now) {
+				continue
+			}
+			if _, err = closeTracker(ctx, tx, t, TrackerAbandoned, t.LastSeenAt); err != nil {
+				return err
+			}
+			reaped++
+		}
+		return nil
+	})
+	return reaped, err
+}
+
+func setTrackerStatus(ctx context.Context, tx pgx.Tx, t *Tracker, s TrackerStatus, now time.Time) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE activity_trackers
+		SET status = $2, last_seen_at = $3, updated_at = $3
+		WHERE id = $1`, t.ID, s, now)
+	if err != nil {
+		return fmt.Errorf("failed to update tracker: %w", err)
+	}
+	t.Status = s
+	t.LastSeenAt = now
+	return nil
+}
+
+func closePause(ctx context.Context, tx pgx.Tx, t *Tracker, at time.Time) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE activity_tracker_pauses
+		SET resumed_at = $2
+		WHERE tracker_id = $1 AND resumed_at IS NULL`, t.ID, at)
+	if err != nil {
+		return fmt.Errorf("failed to close pause: %w", err)
+	}
+	for i := range t.Pauses {
+		if t.Pauses[i].ResumedAt == nil {
+			t.Pauses[i].ResumedAt = &at
+		}
+	}
+	return nil
+}
+
+// closeTracker ends t at end with status s. The workout is saved as an
+// exercise session, with its laps, unless no active time was tracked.
+func closeTracker(ctx context.Context, tx pgx.Tx, t *Tracker, s TrackerStatus, end time.Time) (*pba.XExerciseSession, error) {
+	if err := closePause(ctx, tx, t, end); err != nil {
+		return nil, err
+	}
+
+	session := t.Session(end)
+	session.StartTime = timestamppb.New(t.StartedAt)
+	session.EndTime = timestamppb.New(end)
+	session.CreatedAt = timestamppb.New(time.Now().UTC())
+	if t.Active(end) >= time.Second {
+		sessionID, err := insertSession(ctx, tx, session)
+		if err != nil {
+			return nil, err
+		}
+		session.ExerciseSessionId = sessionID
+		t.SessionID = sessionID
+		if err = saveTrackerLaps(ctx, tx, t, sessionID, end); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE activity_trackers
+		SET status = $2, ended_at = $3, exercise_session_id = NULLIF($4, '')::uuid, updated_at = now()
+		WHERE id = $1`, t.ID, s, end, t.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close tracker: %w", err)
+	}
+	t.Status = s
+	t.EndedAt = &end
+	return session, nil
+}
+
+// RegisterReaper schedules the job that closes abandoned trackers.
+func (a *ServiceActivity) RegisterReaper(ctx context.Context, q *jobs.Queue) error {
+	q.Register(kindReapTrackers, func(ctx context.Context, job jobs.Job) error {
+		n, err := a.trackers.ReapTrackers(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			logger.Log.Info("closed abandoned activity trackers", zap.Int("count", n))
+		}
+		return nil
+	})
+	return q.Schedule(ctx, "reap-activity-trackers", "*/10 * * * *", kindReapTrackers, struct{}{})
+}
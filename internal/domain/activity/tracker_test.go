@@ -0,0 +1,80 @@
+package activity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerActive(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	resumed := func(minutes int) *time.Time {
+		t := at(minutes)
+		return &t
+	}
+
+	tests := []struct {
+		name   string
+		pauses []Pause
+		end    time.Time
+		want   time.Duration
+	}{
+		{"no pauses", nil, at(45), 45 * time.Minute},
+		{"closed pause", []Pause{{PausedAt: at(10), ResumedAt: resumed(15)}}, at(45), 40 * time.Minute},
+		{"still paused", []Pause{{PausedAt: at(10), ResumedAt: resumed(15)}, {PausedAt: at(30)}}, at(45), 25 * time.Minute},
+		{"pause after end ignored", []Pause{{PausedAt: at(50), ResumedAt: resumed(55)}}, at(45), 45 * time.Minute},
+		{"end inside a pause", []Pause{{PausedAt: at(10), ResumedAt: resumed(20)}}, at(15), 10 * time.Minute},
+		{"end before start", nil, at(-5), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := Tracker{StartedAt: start, Pauses: tt.pauses}
+			if got := tracker.Active(tt.end); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackerSession(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	resumed := start.Add(20 * time.Minute)
+	tracker := Tracker{
+		ID: "t1", StartedAt: start, MET: 10, WeightKg: 60,
+		Pauses: []Pause{{PausedAt: start.Add(10 * time.Minute), ResumedAt: &resumed}},
+	}
+
+	end := start.Add(70*time.Minute + 30*time.Second)
+	s := tracker.Session(end)
+	if s.DurationHours != 1 || s.DurationMinutes != 0 || s.DurationSeconds != 30 {
+		t.Errorf("duration = %dh%dm%ds, want 1h0m30s", s.DurationHours, s.DurationMinutes, s.DurationSeconds)
+	}
+	if s.CaloriesBurned != 605 {
+		t.Errorf("calories = %d, want 605", s.CaloriesBurned)
+	}
+	if got := end.Sub(s.StartTime.AsTime()); got != tracker.Active(end) {
+		t.Errorf("shifted start gives %v elapsed, want %v", got, tracker.Active(end))
+	}
+}
+
+func TestTrackerExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		status   TrackerStatus
+		lastSeen time.Duration
+		want     bool
+	}{
+		{TrackerRunning, 5 * time.Hour, false},
+		{TrackerRunning, 7 * time.Hour, true},
+		{TrackerPaused, time.Hour, false},
+		{TrackerPaused, 3 * time.Hour, true},
+		{TrackerStopped, 48 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		tracker := Tracker{Status: tt.status, LastSeenAt: now.Add(-tt.lastSeen)}
+		if got := tracker.expired(now); got != tt.want {
+			t.Errorf("%s idle %v: expired = %v, want %v", tt.status, tt.lastSeen, got, tt.want)
+		}
+	}
+}
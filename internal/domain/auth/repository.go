@@ -18,6 +18,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 )
 
 type Repository struct {
@@ -192,14 +193,35 @@ func (r *Repository) ChangeEmail(ctx context.Context, req *pb.ChangeEmailRequest
 	return &pb.ChangeEmailResponse{Message: "Email changed successfully"}, nil
 }
 
+var usersPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"username":   {Expr: "username", Type: pagination.Text},
+		"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "username",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"role":     {Expr: "role::text", Type: pagination.Text, Op: pagination.Eq},
+		"username": {Expr: "username", Op: pagination.Contains},
+	},
+}
+
 func (r *Repository) GetAllUsers(ctx context.Context) (*pb.GetAllUsersResponse, error) {
-	rows, err := r.pgpool.Query(ctx, `SELECT id, username, email, role, created_at, updated_at FROM "users"`)
+	page, err := usersPage.Build(pagination.FromContext(ctx), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pgpool.Query(ctx, `SELECT id, username, email, role, created_at, updated_at, `+page.CursorColumns()+`
+		FROM "users" WHERE TRUE`+page.Where()+page.OrderLimit(), page.Args()...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var users []*pb.User
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -211,10 +233,13 @@ func (r *Repository) GetAllUsers(ctx context.Context) (*pb.GetAllUsersResponse,
 		var createdAt time.Time
 		var updatedAt *time.Time
 
-		err := rows.Scan(&id, &username, &email, &roleStr, &createdAt, &updatedAt)
+		err := rows.Scan(&id, &username, &email, &roleStr, &createdAt, &updatedAt, &pager.SortValue, &pager.ID)
 		if err != nil {
 			return nil, err
 		}
+		if !pager.Next() {
+			break
+		}
 
 		var role pb.User_Role
 		switch roleStr {
@@ -246,6 +271,8 @@ func (r *Repository) GetAllUsers(ctx context.Context) (*pb.GetAllUsersResponse,
 		return nil, err
 	}
 
+	pager.SetHeader(ctx)
+
 	return &pb.GetAllUsersResponse{Users: users}, nil
 }
 
@@ -0,0 +1,243 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+// Adaptive expenditure: instead of trusting the activity multiplier, infer
+// what the user actually burns from what they ate and how their weight moved.
+//
+//	TDEE = average intake - (trend change in kg * kcalPerKg) / days
+//
+// Daily scale readings are noisy (water, glycogen, sodium), so the weight
+// change is taken from an exponentially smoothed trend, not raw weigh-ins.
+
+const (
+	kcalPerKg = 7700.0
+	// trendSmoothing is the weight given to each new weigh-in.
+	trendSmoothing = 0.1
+
+	minIntakeDays = 7
+	minWeighIns   = 3
+)
+
+// expenditureWindows are the rolling windows, in days, that are evaluated.
+var expenditureWindows = []int{14, 28, 56}
+
+type Confidence string
+
+const (
+	ConfidenceNone   Confidence = "NONE"
+	ConfidenceLow    Confidence = "LOW"
+	ConfidenceMedium Confidence = "MEDIUM"
+	ConfidenceHigh   Confidence = "HIGH"
+)
+
+func (c Confidence) rank() int {
+	switch c {
+	case ConfidenceHigh:
+		return 3
+	case ConfidenceMedium:
+		return 2
+	case ConfidenceLow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// weight is how much the adaptive estimate counts against the formula TDEE.
+func (c Confidence) weight() float64 {
+	switch c {
+	case ConfidenceHigh:
+		return 0.9
+	case ConfidenceMedium:
+		return 0.6
+	case ConfidenceLow:
+		return 0.3
+	default:
+		return 0
+	}
+}
+
+// WeightSample is the average weight (kg) logged on a day.
+type WeightSample struct {
+	Day    time.Time
+	Weight float64
+}
+
+// IntakeSample is the total energy (kcal) logged on a day.
+type IntakeSample struct {
+	Day      time.Time
+	Calories float64
+}
+
+type TrendPoint struct {
+	Day time.Time `json:"day"`
+	// Weight is the raw reading, zero on days without a weigh-in.
+	Weight float64 `json:"weight"`
+	Trend  float64 `json:"trend"`
+}
+
+type WindowEstimate struct {
+	Days          int        `json:"days"`
+	IntakeDays    int        `json:"intake_days"`
+	WeighIns      int        `json:"weigh_ins"`
+	AverageIntake float64    `json:"average_intake"`
+	TrendChange   float64    `json:"trend_change"`
+	TDEE          float64    `json:"tdee"`
+	Confidence    Confidence `json:"confidence"`
+}
+
+type ExpenditureEstimate struct {
+	Trend   []TrendPoint     `json:"trend"`
+	Windows []WindowEstimate `json:"windows"`
+	// TDEE and Confidence come from the most reliable window.
+	TDEE       float64    `json:"tdee"`
+	Confidence Confidence `json:"confidence"`
+	// FormulaTDEE is the activity multiplier estimate stored on the current
+	// macro distribution; ProposedTDEE blends it with TDEE by confidence.
+	FormulaTDEE  float64 `json:"formula_tdee"`
+	ProposedTDEE float64 `json:"proposed_tdee"`
+	MacroID      string  `json:"macro_id"`
+	CurrentGoal  uint16  `json:"current_goal"`
+	ProposedGoal uint16  `json:"proposed_goal"`
+}
+
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// weightTrend smooths weigh-ins into a daily trend from the first sample up to
+// and including end. Days without a reading carry the trend forward.
+func weightTrend(samples []WeightSample, end time.Time) []TrendPoint {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	byDay := make(map[time.Time]float64, len(samples))
+	for _, s := range samples {
+		byDay[day(s.Day)] = s.Weight
+	}
+	sorted := append([]WeightSample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Day.Before(sorted[j].Day) })
+
+	start := day(sorted[0].Day)
+	end = day(end)
+	trend := sorted[0].Weight
+
+	points := make([]TrendPoint, 0, int(end.Sub(start).Hours()/24)+1)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		w, ok := byDay[d]
+		if ok {
+			trend += trendSmoothing * (w - trend)
+		}
+		points = append(points, TrendPoint{Day: d, Weight: w, Trend: round2(trend)})
+	}
+	return points
+}
+
+// estimateWindow derives TDEE over the days ending at end.
+func estimateWindow(trend []TrendPoint, intake map[time.Time]float64, end time.Time, days int) WindowEstimate {
+	est := WindowEstimate{Days: days, Confidence: ConfidenceNone}
+	if len(trend) == 0 {
+		return est
+	}
+
+	end = day(end)
+	start := end.AddDate(0, 0, -days)
+	first := trend[0].Day
+	if start.Before(first) {
+		return est
+	}
+
+	var startTrend, endTrend float64
+	for _, p := range trend {
+		if p.Day.Equal(start) {
+			startTrend = p.Trend
+		}
+		if p.Day.After(start) && !p.Day.After(end) {
+			endTrend = p.Trend
+			if p.Weight > 0 {
+				est.WeighIns++
+			}
+		}
+	}
+
+	var total float64
+	for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if kcal, ok := intake[d]; ok && kcal > 0 {
+			total += kcal
+			est.IntakeDays++
+		}
+	}
+
+	if est.IntakeDays < minIntakeDays || est.WeighIns < minWeighIns {
+		return est
+	}
+
+	est.AverageIntake = math.Round(total / float64(est.IntakeDays))
+	est.TrendChange = round2(endTrend - startTrend)
+	est.TDEE = math.Round(est.AverageIntake - (endTrend-startTrend)*kcalPerKg/float64(days))
+
+	coverage := float64(est.IntakeDays) / float64(days)
+	switch {
+	case coverage >= 0.85 && est.WeighIns*2 >= days && days >= 28:
+		est.Confidence = ConfidenceHigh
+	case coverage >= 0.6 && est.WeighIns*4 >= days:
+		est.Confidence = ConfidenceMedium
+	default:
+		est.Confidence = ConfidenceLow
+	}
+
+	return est
+}
+
+// EstimateExpenditure runs every rolling window ending at end and picks the
+// most confident one, preferring longer windows on ties.
+func EstimateExpenditure(weights []WeightSample, intake []IntakeSample, end time.Time) ExpenditureEstimate {
+	trend := weightTrend(weights, end)
+
+	intakeByDay := make(map[time.Time]float64, len(intake))
+	for _, s := range intake {
+		intakeByDay[day(s.Day)] += s.Calories
+	}
+
+	result := ExpenditureEstimate{Trend: trend, Confidence: ConfidenceNone}
+	for _, days := range expenditureWindows {
+		w := estimateWindow(trend, intakeByDay, end, days)
+		result.Windows = append(result.Windows, w)
+		if w.Confidence.rank() >= result.Confidence.rank() && w.Confidence != ConfidenceNone {
+			result.TDEE = w.TDEE
+			result.Confidence = w.Confidence
+		}
+	}
+
+	return result
+}
+
+// propose blends the adaptive TDEE with the formula TDEE and derives the
+// calorie goal for the macro distribution's objective.
+func (e *ExpenditureEstimate) propose(formulaTDEE float64, objective pb.Objective) {
+	e.FormulaTDEE = formulaTDEE
+	w := e.Confidence.weight()
+	e.ProposedTDEE = math.Round(w*e.TDEE + (1-w)*formulaTDEE)
+
+	goals := calculateGoals(e.ProposedTDEE)
+	switch objective {
+	case pb.Objective_CUTTING:
+		e.ProposedGoal = goals.Cutting
+	case pb.Objective_BULKING:
+		e.ProposedGoal = goals.Bulking
+	default:
+		e.ProposedGoal = goals.Maintenance
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
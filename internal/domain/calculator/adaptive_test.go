@@ -0,0 +1,68 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+func dailyLogs(days int, end time.Time, weight func(i int) float64, kcal float64) ([]WeightSample, []IntakeSample) {
+	var weights []WeightSample
+	var intake []IntakeSample
+	for i := 0; i < days; i++ {
+		d := end.AddDate(0, 0, i-days+1)
+		weights = append(weights, WeightSample{Day: d, Weight: weight(i)})
+		intake = append(intake, IntakeSample{Day: d, Calories: kcal})
+	}
+	return weights, intake
+}
+
+func TestEstimateExpenditureStableWeight(t *testing.T) {
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	weights, intake := dailyLogs(90, end, func(int) float64 { return 80 }, 2500)
+
+	est := EstimateExpenditure(weights, intake, end)
+	if est.Confidence != ConfidenceHigh {
+		t.Fatalf("confidence = %s, want HIGH", est.Confidence)
+	}
+	if est.TDEE != 2500 {
+		t.Errorf("TDEE = %v, want 2500", est.TDEE)
+	}
+}
+
+func TestEstimateExpenditureLosingWeight(t *testing.T) {
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	// 0.5 kg per week on 2000 kcal means a deficit of 550 kcal per day
+	weights, intake := dailyLogs(90, end, func(i int) float64 { return 90 - 0.5*float64(i)/7 }, 2000)
+
+	est := EstimateExpenditure(weights, intake, end)
+	if math.Abs(est.TDEE-2550) > 25 {
+		t.Errorf("TDEE = %v, want about 2550", est.TDEE)
+	}
+
+	est.propose(2300, pb.Objective_CUTTING)
+	want := math.Round(0.9*est.TDEE + 0.1*2300)
+	if est.ProposedTDEE != want {
+		t.Errorf("proposed TDEE = %v, want %v", est.ProposedTDEE, want)
+	}
+	if est.ProposedGoal != uint16(want-caloricDeficit) {
+		t.Errorf("proposed goal = %v, want %v", est.ProposedGoal, want-caloricDeficit)
+	}
+}
+
+func TestEstimateExpenditureNeedsData(t *testing.T) {
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	weights, intake := dailyLogs(5, end, func(int) float64 { return 80 }, 2500)
+
+	est := EstimateExpenditure(weights, intake, end)
+	if est.Confidence != ConfidenceNone {
+		t.Errorf("confidence = %s, want NONE", est.Confidence)
+	}
+
+	est.propose(2300, pb.Objective_MAINTENANCE)
+	if est.ProposedTDEE != 2300 || est.ProposedGoal != 2300 {
+		t.Errorf("proposal = %v/%v, want the formula TDEE unchanged", est.ProposedTDEE, est.ProposedGoal)
+	}
+}
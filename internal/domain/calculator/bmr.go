@@ -0,0 +1,183 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// BMRFormula selects the equation used to estimate basal metabolic rate.
+type BMRFormula string
+
+const (
+	MifflinStJeor  BMRFormula = "mifflin_st_jeor"
+	HarrisBenedict BMRFormula = "harris_benedict"
+	KatchMcArdle   BMRFormula = "katch_mcardle"
+	Cunningham     BMRFormula = "cunningham"
+)
+
+// BMRFormulas lists every supported formula in comparison order.
+var BMRFormulas = []BMRFormula{MifflinStJeor, HarrisBenedict, KatchMcArdle, Cunningham}
+
+// The calculator request messages have no formula fields yet, so clients
+// pass them as metadata, the same way list RPCs pass paging options.
+const (
+	bmrFormulaHeader     = "x-bmr-formula"
+	bodyFatPercentHeader = "x-body-fat-percent"
+	bmrComparisonHeader  = "x-bmr-comparison"
+)
+
+const (
+	minBodyFatPercent = 2.0
+	maxBodyFatPercent = 70.0
+)
+
+var errBodyFatRequired = errors.New("body fat percentage is required for lean mass formulas")
+
+// ParseBMRFormula accepts the formula names above; empty means Mifflin-St Jeor.
+func ParseBMRFormula(s string) (BMRFormula, error) {
+	if s == "" {
+		return MifflinStJeor, nil
+	}
+	formula := BMRFormula(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range BMRFormulas {
+		if formula == known {
+			return formula, nil
+		}
+	}
+	return "", fmt.Errorf("unknown BMR formula %q", s)
+}
+
+// UsesLeanMass reports whether the formula needs a body fat percentage.
+func (f BMRFormula) UsesLeanMass() bool {
+	return f == KatchMcArdle || f == Cunningham
+}
+
+// BMREstimate is one formula's result, used to compare formulas side by side.
+type BMREstimate struct {
+	Formula BMRFormula `json:"formula"`
+	BMR     uint16     `json:"bmr"`
+	TDEE    uint16     `json:"tdee"`
+}
+
+func isMale(gender string) (bool, error) {
+	switch {
+	case strings.EqualFold(gender, m):
+		return true, nil
+	case strings.EqualFold(gender, f):
+		return false, nil
+	default:
+		return false, errors.New("gender must be 'male' or 'female'")
+	}
+}
+
+// CalculateBMRWith estimates BMR with the given formula. bodyFatPercent is
+// only read by the lean mass formulas (Katch-McArdle and Cunningham).
+func CalculateBMRWith(formula BMRFormula, userData UserData, bodyFatPercent float64) (float64, error) {
+	if formula == MifflinStJeor {
+		return CalculateBMR(userData)
+	}
+	if userData.Weight <= 0 || userData.Height <= 0 || userData.Age <= 0 {
+		return 0, errors.New("weight, height, and age must be positive values")
+	}
+
+	weight := userData.Weight
+	height := userData.Height
+	age := float64(userData.Age)
+
+	switch formula {
+	case HarrisBenedict:
+		// revised equations, Roza and Shizgal (1984)
+		male, err := isMale(userData.Gender)
+		if err != nil {
+			return 0, err
+		}
+		if male {
+			return math.Round(88.362 + 13.397*weight + 4.799*height - 5.677*age), nil
+		}
+		return math.Round(447.593 + 9.247*weight + 3.098*height - 4.330*age), nil
+	case KatchMcArdle, Cunningham:
+		if bodyFatPercent <= 0 {
+			return 0, errBodyFatRequired
+		}
+		if bodyFatPercent < minBodyFatPercent || bodyFatPercent > maxBodyFatPercent {
+			return 0, fmt.Errorf("body fat percentage must be between %.0f and %.0f", minBodyFatPercent, maxBodyFatPercent)
+		}
+		leanMass := weight * (1 - bodyFatPercent/100)
+		if formula == KatchMcArdle {
+			return math.Round(370 + 21.6*leanMass), nil
+		}
+		return math.Round(500 + 22*leanMass), nil
+	default:
+		return 0, fmt.Errorf("unknown BMR formula %q", formula)
+	}
+}
+
+// compareBMR runs every formula that can be computed from the inputs. Lean
+// mass formulas are skipped when no body fat percentage is known.
+func compareBMR(userData UserData, bodyFatPercent float64, activity ActivityValues) ([]BMREstimate, error) {
+	estimates := make([]BMREstimate, 0, len(BMRFormulas))
+	for _, formula := range BMRFormulas {
+		if formula.UsesLeanMass() && bodyFatPercent <= 0 {
+			continue
+		}
+		bmr, err := CalculateBMRWith(formula, userData, bodyFatPercent)
+		if err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, BMREstimate{
+			Formula: formula,
+			BMR:     uint16(bmr),
+			TDEE:    uint16(calculateTDEE(bmr, activity)),
+		})
+	}
+	return estimates, nil
+}
+
+// bmrOptionsFromContext reads the formula and body fat headers.
+func bmrOptionsFromContext(ctx context.Context) (BMRFormula, float64, error) {
+	var rawFormula, rawBodyFat string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(bmrFormulaHeader); len(v) > 0 {
+			rawFormula = v[0]
+		}
+		if v := md.Get(bodyFatPercentHeader); len(v) > 0 {
+			rawBodyFat = v[0]
+		}
+	}
+
+	formula, err := ParseBMRFormula(rawFormula)
+	if err != nil {
+		return "", 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var bodyFat float64
+	if rawBodyFat != "" {
+		bodyFat, err = strconv.ParseFloat(rawBodyFat, 64)
+		if err != nil || bodyFat < minBodyFatPercent || bodyFat > maxBodyFatPercent {
+			return "", 0, status.Errorf(codes.InvalidArgument, "body fat percentage must be between %.0f and %.0f", minBodyFatPercent, maxBodyFatPercent)
+		}
+	}
+	if formula.UsesLeanMass() && bodyFat == 0 {
+		return "", 0, status.Error(codes.InvalidArgument, errBodyFatRequired.Error())
+	}
+
+	return formula, bodyFat, nil
+}
+
+// setBMRComparisonHeader sends "formula=bmr/tdee" pairs back to the caller.
+func setBMRComparisonHeader(ctx context.Context, estimates []BMREstimate) {
+	pairs := make([]string, 0, len(estimates))
+	for _, e := range estimates {
+		pairs = append(pairs, fmt.Sprintf("%s=%d/%d", e.Formula, e.BMR, e.TDEE))
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(bmrComparisonHeader, strings.Join(pairs, ",")))
+}
@@ -0,0 +1,149 @@
+package calculator
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+func TestCalculateBMRWith(t *testing.T) {
+	male := UserData{Age: 30, Height: 180, Weight: 80, Gender: "male"}
+	female := UserData{Age: 30, Height: 165, Weight: 60, Gender: "Female"}
+
+	tests := []struct {
+		name    string
+		formula BMRFormula
+		user    UserData
+		bodyFat float64
+		want    float64
+	}{
+		{"mifflin male", MifflinStJeor, male, 0, 1780},
+		{"mifflin female", MifflinStJeor, female, 0, 1320},
+		{"harris benedict male", HarrisBenedict, male, 0, 1854},
+		{"harris benedict female", HarrisBenedict, female, 0, 1384},
+		{"katch mcardle", KatchMcArdle, male, 15, 1839},
+		{"cunningham", Cunningham, male, 15, 1996},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalculateBMRWith(tt.formula, tt.user, tt.bodyFat)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BMR = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeanMassFormulasNeedBodyFat(t *testing.T) {
+	user := UserData{Age: 30, Height: 180, Weight: 80, Gender: "male"}
+	if _, err := CalculateBMRWith(KatchMcArdle, user, 0); err == nil {
+		t.Error("expected an error without body fat")
+	}
+
+	estimates, err := compareBMR(user, 0, sedentaryActivityValue)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(estimates) != 2 {
+		t.Errorf("got %d estimates without body fat, want 2", len(estimates))
+	}
+}
+
+func TestParseBMRFormula(t *testing.T) {
+	if f, err := ParseBMRFormula(""); err != nil || f != MifflinStJeor {
+		t.Errorf("empty = %q, %v", f, err)
+	}
+	if f, err := ParseBMRFormula("Katch_McArdle"); err != nil || f != KatchMcArdle {
+		t.Errorf("mixed case = %q, %v", f, err)
+	}
+	if _, err := ParseBMRFormula("bogus"); err == nil {
+		t.Error("expected an error for an unknown formula")
+	}
+}
+
+func TestFormulasInBothSystems(t *testing.T) {
+	metric := UserParams{Age: 30, Height: 180, Weight: 80, Gender: "male", System: "METRIC"}
+	imperial := UserParams{Age: 30, Height: 180 / 2.54, Weight: 80 / 0.45359237, Gender: "male", System: "IMPERIAL"}
+	feetInches := UserParams{Age: 30, HeightInput: `5'10.87"`, Weight: 80 / 0.45359237, Gender: "male", System: "Imperial"}
+
+	tests := []struct {
+		formula BMRFormula
+		bodyFat float64
+		want    float64
+	}{
+		{MifflinStJeor, 0, 1780},
+		{HarrisBenedict, 0, 1854},
+		{KatchMcArdle, 15, 1839},
+		{Cunningham, 15, 1996},
+	}
+
+	for _, tt := range tests {
+		for name, params := range map[string]UserParams{"metric": metric, "imperial": imperial, "feet and inches": feetInches} {
+			t.Run(string(tt.formula)+" "+name, func(t *testing.T) {
+				userData, err := validateUserInput(context.Background(), params)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got, err := CalculateBMRWith(tt.formula, userData, tt.bodyFat)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("BMR = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestValidateUserInputUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  UserParams
+		height  float64
+		weight  float64
+		wantErr bool
+	}{
+		{"metric", UserParams{Age: 30, Height: 175, Weight: 70, System: "METRIC"}, 175, 70, false},
+		{"imperial", UserParams{Age: 30, Height: 69, Weight: 154, System: "IMPERIAL"}, 175.3, 69.85, false},
+		{"feet and inches", UserParams{Age: 30, HeightInput: "5ft 9in", Weight: 154, System: "IMPERIAL"}, 175.3, 69.85, false},
+		{"centimetres with imperial weight", UserParams{Age: 30, HeightInput: "175cm", Weight: 154, System: "IMPERIAL"}, 175, 69.85, false},
+		{"weight above the height limit", UserParams{Age: 30, Height: 180, Weight: 300, System: "METRIC"}, 180, 300, false},
+		{"pounds entered as metric", UserParams{Age: 30, Height: 180, Weight: 600, System: "METRIC"}, 0, 0, true},
+		{"inches entered as metric", UserParams{Age: 30, Height: 70, Weight: 80, System: "METRIC"}, 70, 80, false},
+		{"unknown system", UserParams{Age: 30, Height: 180, Weight: 80, System: "CUBITS"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateUserInput(context.Background(), tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Height != tt.height || got.Weight != tt.weight {
+				t.Errorf("height/weight = %v/%v, want %v/%v", got.Height, got.Weight, tt.height, tt.weight)
+			}
+		})
+	}
+}
+
+func TestDisplayMacro(t *testing.T) {
+	stored := &pb.UserMacroDistribution{Height: 180, Weight: 80, System: pb.System_IMPERIAL}
+	got := displayMacro(stored)
+	if got.Height != 71 || got.Weight != 176.4 {
+		t.Errorf("imperial display = %v in / %v lb, want 71 / 176.4", got.Height, got.Weight)
+	}
+
+	metric := displayMacro(&pb.UserMacroDistribution{Height: 180, Weight: 80, System: pb.System_METRIC})
+	if metric.Height != 180 || metric.Weight != 80 {
+		t.Errorf("metric display = %v / %v, want unchanged", metric.Height, metric.Weight)
+	}
+}
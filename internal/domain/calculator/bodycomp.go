@@ -0,0 +1,210 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+)
+
+// Body composition metrics derived from tape and scale measurements. All
+// inputs are metric: kg for weight, cm for height and circumferences.
+
+type BMICategory string
+
+const (
+	BMIUnderweight BMICategory = "UNDERWEIGHT"
+	BMINormal      BMICategory = "NORMAL"
+	BMIOverweight  BMICategory = "OVERWEIGHT"
+	BMIObese       BMICategory = "OBESE"
+)
+
+type WaistToHeightCategory string
+
+const (
+	WaistToHeightLow       WaistToHeightCategory = "LOW"
+	WaistToHeightHealthy   WaistToHeightCategory = "HEALTHY"
+	WaistToHeightIncreased WaistToHeightCategory = "INCREASED_RISK"
+	WaistToHeightHigh      WaistToHeightCategory = "HIGH_RISK"
+)
+
+// BodyFatSource records where the body fat figure came from.
+type BodyFatSource string
+
+const (
+	BodyFatNavy     BodyFatSource = "US_NAVY"
+	BodyFatSupplied BodyFatSource = "SUPPLIED"
+)
+
+// BodyInputs are the measurements a calculation starts from. Zero means
+// unknown; missing values are filled from the latest stored measurements.
+type BodyInputs struct {
+	Gender         string  `json:"gender"`
+	Weight         float64 `json:"weight"`
+	Height         float64 `json:"height"`
+	Waist          float64 `json:"waist"`
+	Neck           float64 `json:"neck"`
+	Hip            float64 `json:"hip"`
+	BodyFatPercent float64 `json:"body_fat_percent"`
+}
+
+// merge fills the zero fields of b from stored.
+func (b BodyInputs) merge(stored BodyInputs) BodyInputs {
+	if b.Gender == "" {
+		b.Gender = stored.Gender
+	}
+	if b.Weight == 0 {
+		b.Weight = stored.Weight
+	}
+	if b.Height == 0 {
+		b.Height = stored.Height
+	}
+	if b.Waist == 0 {
+		b.Waist = stored.Waist
+	}
+	if b.Neck == 0 {
+		b.Neck = stored.Neck
+	}
+	if b.Hip == 0 {
+		b.Hip = stored.Hip
+	}
+	return b
+}
+
+// BodyComposition holds every metric the inputs allow. Metrics that could not
+// be derived are zero and named in Missing.
+type BodyComposition struct {
+	ID     string     `json:"id"`
+	Inputs BodyInputs `json:"inputs"`
+
+	BMI         float64     `json:"bmi"`
+	BMICategory BMICategory `json:"bmi_category"`
+
+	BodyFatPercent float64       `json:"body_fat_percent"`
+	BodyFatSource  BodyFatSource `json:"body_fat_source"`
+	LeanMass       float64       `json:"lean_mass"`
+	FatMass        float64       `json:"fat_mass"`
+	FFMI           float64       `json:"ffmi"`
+	// NormalizedFFMI adjusts FFMI to a height of 1.8 m.
+	NormalizedFFMI float64 `json:"normalized_ffmi"`
+
+	WaistToHeight         float64               `json:"waist_to_height"`
+	WaistToHeightCategory WaistToHeightCategory `json:"waist_to_height_category"`
+
+	Missing []string `json:"missing"`
+}
+
+// NavyBodyFat estimates body fat with the US Navy circumference method. Women
+// also need a hip measurement.
+func NavyBodyFat(gender string, height, waist, neck, hip float64) (float64, error) {
+	if height <= 0 || waist <= 0 || neck <= 0 {
+		return 0, fmt.Errorf("height, waist and neck are required")
+	}
+
+	male, err := isMale(gender)
+	if err != nil {
+		return 0, err
+	}
+
+	var density float64
+	if male {
+		if waist <= neck {
+			return 0, fmt.Errorf("waist must be larger than neck")
+		}
+		density = 1.0324 - 0.19077*math.Log10(waist-neck) + 0.15456*math.Log10(height)
+	} else {
+		if hip <= 0 {
+			return 0, fmt.Errorf("hip is required for women")
+		}
+		if waist+hip <= neck {
+			return 0, fmt.Errorf("waist and hip must be larger than neck")
+		}
+		density = 1.29579 - 0.35004*math.Log10(waist+hip-neck) + 0.22100*math.Log10(height)
+	}
+
+	bf := 495/density - 450
+	if bf < 2 || bf > 70 {
+		return 0, fmt.Errorf("measurements give an implausible body fat of %.1f%%", bf)
+	}
+	return round1(bf), nil
+}
+
+func BMI(weight, height float64) (float64, BMICategory) {
+	m := height / 100
+	bmi := round1(weight / (m * m))
+	switch {
+	case bmi < 18.5:
+		return bmi, BMIUnderweight
+	case bmi < 25:
+		return bmi, BMINormal
+	case bmi < 30:
+		return bmi, BMIOverweight
+	default:
+		return bmi, BMIObese
+	}
+}
+
+func WaistToHeight(waist, height float64) (float64, WaistToHeightCategory) {
+	ratio := round2(waist / height)
+	switch {
+	case ratio < 0.4:
+		return ratio, WaistToHeightLow
+	case ratio < 0.5:
+		return ratio, WaistToHeightHealthy
+	case ratio < 0.6:
+		return ratio, WaistToHeightIncreased
+	default:
+		return ratio, WaistToHeightHigh
+	}
+}
+
+// FFMI returns the fat-free mass index and its height-normalized value.
+func FFMI(leanMass, height float64) (float64, float64) {
+	m := height / 100
+	ffmi := leanMass / (m * m)
+	return round1(ffmi), round1(ffmi + 6.1*(1.8-m))
+}
+
+// ComposeBody derives every metric the inputs allow. A Navy estimate wins
+// over a supplied body fat percentage.
+func ComposeBody(in BodyInputs) BodyComposition {
+	bc := BodyComposition{Inputs: in}
+	missing := func(name string) { bc.Missing = append(bc.Missing, name) }
+
+	if in.Weight > 0 && in.Height > 0 {
+		bc.BMI, bc.BMICategory = BMI(in.Weight, in.Height)
+	} else {
+		missing("bmi")
+	}
+
+	if in.Waist > 0 && in.Height > 0 {
+		bc.WaistToHeight, bc.WaistToHeightCategory = WaistToHeight(in.Waist, in.Height)
+	} else {
+		missing("waist_to_height")
+	}
+
+	if bf, err := NavyBodyFat(in.Gender, in.Height, in.Waist, in.Neck, in.Hip); err == nil {
+		bc.BodyFatPercent, bc.BodyFatSource = bf, BodyFatNavy
+	} else if in.BodyFatPercent > 0 {
+		bc.BodyFatPercent, bc.BodyFatSource = in.BodyFatPercent, BodyFatSupplied
+	} else {
+		missing("body_fat")
+	}
+
+	if bc.BodyFatPercent > 0 && in.Weight > 0 {
+		bc.FatMass = round1(in.Weight * bc.BodyFatPercent / 100)
+		bc.LeanMass = round1(in.Weight - bc.FatMass)
+		if in.Height > 0 {
+			bc.FFMI, bc.NormalizedFFMI = FFMI(bc.LeanMass, in.Height)
+		} else {
+			missing("ffmi")
+		}
+	} else {
+		missing("lean_mass")
+		missing("ffmi")
+	}
+
+	return bc
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,95 @@
+package calculator
+
+import "testing"
+
+func TestNavyBodyFat(t *testing.T) {
+	tests := []struct {
+		name    string
+		gender  string
+		height  float64
+		waist   float64
+		neck    float64
+		hip     float64
+		want    float64
+		wantErr bool
+	}{
+		{"male", "MALE", 180, 85, 38, 0, 16.1, false},
+		{"female", "female", 165, 72, 32, 98, 27.4, false},
+		{"female without hip", "female", 165, 72, 32, 0, 0, true},
+		{"neck wider than waist", "male", 180, 35, 38, 0, 0, true},
+		{"missing neck", "male", 180, 85, 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NavyBodyFat(tt.gender, tt.height, tt.waist, tt.neck, tt.hip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("body fat = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBMICategories(t *testing.T) {
+	tests := []struct {
+		weight float64
+		want   BMICategory
+	}{
+		{55, BMIUnderweight},
+		{70, BMINormal},
+		{90, BMIOverweight},
+		{110, BMIObese},
+	}
+	for _, tt := range tests {
+		if _, got := BMI(tt.weight, 180); got != tt.want {
+			t.Errorf("BMI(%v, 180) category = %s, want %s", tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestComposeBody(t *testing.T) {
+	bc := ComposeBody(BodyInputs{Gender: "male", Weight: 80, Height: 180, Waist: 85, Neck: 38})
+
+	if bc.BMI != 24.7 || bc.BMICategory != BMINormal {
+		t.Errorf("BMI = %v %s", bc.BMI, bc.BMICategory)
+	}
+	if bc.BodyFatSource != BodyFatNavy || bc.BodyFatPercent != 16.1 {
+		t.Errorf("body fat = %v from %s", bc.BodyFatPercent, bc.BodyFatSource)
+	}
+	if bc.FatMass != 12.9 || bc.LeanMass != 67.1 {
+		t.Errorf("fat/lean = %v/%v, want 12.9/67.1", bc.FatMass, bc.LeanMass)
+	}
+	if bc.FFMI != 20.7 || bc.NormalizedFFMI != 20.7 {
+		t.Errorf("FFMI = %v normalized %v, want 20.7", bc.FFMI, bc.NormalizedFFMI)
+	}
+	if bc.WaistToHeight != 0.47 || bc.WaistToHeightCategory != WaistToHeightHealthy {
+		t.Errorf("waist to height = %v %s", bc.WaistToHeight, bc.WaistToHeightCategory)
+	}
+	if len(bc.Missing) != 0 {
+		t.Errorf("missing = %v", bc.Missing)
+	}
+}
+
+func TestComposeBodyPartial(t *testing.T) {
+	bc := ComposeBody(BodyInputs{Weight: 80, Height: 180, BodyFatPercent: 20})
+	if bc.BodyFatSource != BodyFatSupplied || bc.LeanMass != 64 {
+		t.Errorf("body fat %v from %s, lean %v", bc.BodyFatPercent, bc.BodyFatSource, bc.LeanMass)
+	}
+	if len(bc.Missing) != 1 || bc.Missing[0] != "waist_to_height" {
+		t.Errorf("missing = %v, want [waist_to_height]", bc.Missing)
+	}
+
+	bc = ComposeBody(BodyInputs{Height: 180, Waist: 85})
+	want := []string{"bmi", "body_fat", "lean_mass", "ffmi"}
+	if len(bc.Missing) != len(want) {
+		t.Fatalf("missing = %v, want %v", bc.Missing, want)
+	}
+	for i := range want {
+		if bc.Missing[i] != want[i] {
+			t.Errorf("missing = %v, want %v", bc.Missing, want)
+		}
+	}
+}
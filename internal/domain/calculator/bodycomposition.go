@@ -0,0 +1,203 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
+)
+
+// BodyCompositionRepository is implemented by CalculatorRepository.
+type BodyCompositionRepository interface {
+	LatestBodyInputs(ctx context.Context, userID string) (BodyInputs, error)
+	SaveBodyComposition(ctx context.Context, userID string, bc *BodyComposition) error
+	BodyCompositionHistory(ctx context.Context, userID string) ([]BodyCompositionSnapshot, error)
+}
+
+// BodyCompositionSnapshot is a stored calculation, kept for trending.
+type BodyCompositionSnapshot struct {
+	BodyComposition
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LatestBodyInputs reads the most recent weigh-in, waist line and bio data.
+// Weight falls back to the bio data when nothing is in weight_measure, and
+// gender to the latest macro distribution when the profile has none.
+func (c *CalculatorRepository) LatestBodyInputs(ctx context.Context, userID string) (BodyInputs, error) {
+	var in BodyInputs
+	err := c.pgpool.QueryRow(ctx, `
+		SELECT
+		  COALESCE(
+		    (SELECT gender::text FROM user_personal_data WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1),
+		    (SELECT gender FROM user_macro_distribution WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1),
+		    ''),
+		  COALESCE(
+		    (SELECT weight_value::float8 FROM weight_measure WHERE user_id = $1 AND weight_value > 0 ORDER BY created_at DESC LIMIT 1),
+		    (SELECT weight::float8 FROM user_bio_data WHERE user_id = $1 AND weight > 0 ORDER BY created_at DESC LIMIT 1),
+		    0),
+		  COALESCE((SELECT height::float8 FROM user_bio_data WHERE user_id = $1 AND height > 0 ORDER BY created_at DESC LIMIT 1), 0),
+		  COALESCE((SELECT quantity::float8 FROM waist_line WHERE user_id = $1 AND quantity > 0 ORDER BY created_at DESC LIMIT 1), 0)`,
+		userID).Scan(&in.Gender, &in.Weight, &in.Height, &in.Waist)
+	if err != nil {
+		return BodyInputs{}, fmt.Errorf("failed to fetch latest measurements: %w", err)
+	}
+	return in, nil
+}
+
+func (c *CalculatorRepository) SaveBodyComposition(ctx context.Context, userID string, bc *BodyComposition) error {
+	in := bc.Inputs
+	err := c.pgpool.QueryRow(ctx, `
+		INSERT INTO body_composition_snapshots (
+		  user_id, gender, weight, height, waist, neck, hip,
+		  bmi, bmi_category, body_fat_percent, body_fat_source, lean_mass, fat_mass,
+		  ffmi, normalized_ffmi, waist_to_height, waist_to_height_category
+		)
+		VALUES ($1, $2, NULLIF($3::float8, 0), NULLIF($4::float8, 0), NULLIF($5::float8, 0), NULLIF($6::float8, 0), NULLIF($7::float8, 0),
+		        NULLIF($8::float8, 0), NULLIF($9, ''), NULLIF($10::float8, 0), NULLIF($11, ''), NULLIF($12::float8, 0), NULLIF($13::float8, 0),
+		        NULLIF($14::float8, 0), NULLIF($15::float8, 0), NULLIF($16::float8, 0), NULLIF($17, ''))
+		RETURNING id`,
+		userID, in.Gender, in.Weight, in.Height, in.Waist, in.Neck, in.Hip,
+		bc.BMI, string(bc.BMICategory), bc.BodyFatPercent, string(bc.BodyFatSource), bc.LeanMass, bc.FatMass,
+		bc.FFMI, bc.NormalizedFFMI, bc.WaistToHeight, string(bc.WaistToHeightCategory),
+	).Scan(&bc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store body composition: %w", err)
+	}
+	return nil
+}
+
+var bodyCompositionPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "-created_at",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"from": {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Gte},
+		"to":   {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Lte},
+	},
+}
+
+// BodyCompositionHistory lists the user's snapshots, paged with the usual
+// x-page-* and x-filter-from/to headers.
+func (c *CalculatorRepository) BodyCompositionHistory(ctx context.Context, userID string) ([]BodyCompositionSnapshot, error) {
+	page, err := bodyCompositionPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pgpool.Query(ctx, `
+		SELECT id, COALESCE(gender, ''), COALESCE(weight, 0)::float8, COALESCE(height, 0)::float8,
+		       COALESCE(waist, 0)::float8, COALESCE(neck, 0)::float8, COALESCE(hip, 0)::float8,
+		       COALESCE(bmi, 0)::float8, COALESCE(bmi_category, ''),
+		       COALESCE(body_fat_percent, 0)::float8, COALESCE(body_fat_source, ''),
+		       COALESCE(lean_mass, 0)::float8, COALESCE(fat_mass, 0)::float8,
+		       COALESCE(ffmi, 0)::float8, COALESCE(normalized_ffmi, 0)::float8,
+		       COALESCE(waist_to_height, 0)::float8, COALESCE(waist_to_height_category, ''),
+		       created_at, `+page.CursorColumns()+`
+		FROM body_composition_snapshots
+		WHERE user_id = $1`+page.Where()+page.OrderLimit(),
+		append([]any{userID}, page.Args()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch body composition history: %w", err)
+	}
+	defer rows.Close()
+
+	pager := page.Pager()
+	snapshots := make([]BodyCompositionSnapshot, 0)
+	for rows.Next() {
+		var s BodyCompositionSnapshot
+		in := &s.Inputs
+		err = rows.Scan(&s.ID, &in.Gender, &in.Weight, &in.Height, &in.Waist, &in.Neck, &in.Hip,
+			&s.BMI, &s.BMICategory, &s.BodyFatPercent, &s.BodyFatSource, &s.LeanMass, &s.FatMass,
+			&s.FFMI, &s.NormalizedFFMI, &s.WaistToHeight, &s.WaistToHeightCategory,
+			&s.CreatedAt, &pager.SortValue, &pager.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan body composition: %w", err)
+		}
+		if !pager.Next() {
+			break
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch body composition history: %w", err)
+	}
+
+	pager.SetHeader(ctx)
+	return snapshots, nil
+}
+
+// BodyCompositionService derives BMI, body fat, lean mass, FFMI and
+// waist-to-height from measurements.
+type BodyCompositionService struct {
+	ctx  context.Context
+	repo BodyCompositionRepository
+}
+
+func NewBodyCompositionService(ctx context.Context, repo BodyCompositionRepository) *BodyCompositionService {
+	return &BodyCompositionService{ctx: ctx, repo: repo}
+}
+
+// CalculateBodyComposition fills the inputs the caller left out from the
+// latest stored measurements, derives every metric it can and stores the
+// result as a snapshot.
+func (s *BodyCompositionService) CalculateBodyComposition(ctx context.Context, userID string, in BodyInputs) (*BodyComposition, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "CalculateBodyComposition")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	for name, v := range map[string]float64{"weight": in.Weight, "height": in.Height, "waist": in.Waist, "neck": in.Neck, "hip": in.Hip} {
+		if v < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "%s cannot be negative", name)
+		}
+	}
+	if in.BodyFatPercent < 0 || in.BodyFatPercent > 70 {
+		return nil, status.Error(codes.InvalidArgument, "body fat must be between 0 and 70 percent")
+	}
+
+	stored, err := s.repo.LatestBodyInputs(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	in = in.merge(stored)
+
+	bc := ComposeBody(in)
+	if bc.BMI == 0 && bc.BodyFatPercent == 0 && bc.WaistToHeight == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "not enough measurements; log a weight, height or waist line first")
+	}
+
+	if err = s.repo.SaveBodyComposition(ctx, userID, &bc); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	span.SetAttributes(
+		attribute.Float64("body.bmi", bc.BMI),
+		attribute.Float64("body.fat_percent", bc.BodyFatPercent),
+	)
+	return &bc, nil
+}
+
+func (s *BodyCompositionService) BodyCompositionHistory(ctx context.Context, userID string) ([]BodyCompositionSnapshot, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	snapshots, err := s.repo.BodyCompositionHistory(ctx, userID)
+	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return snapshots, nil
+}
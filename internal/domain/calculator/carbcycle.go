@@ -0,0 +1,273 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CarbCycleRepository is implemented by CalculatorRepository.
+type CarbCycleRepository interface {
+	CurrentMacro(ctx context.Context, userID string) (*CurrentMacro, error)
+	TrainingDays(ctx context.Context, userID string) (map[time.Weekday]bool, error)
+	CreateCarbCycle(ctx context.Context, cycle *CarbCycle) error
+	ActiveCarbCycle(ctx context.Context, userID string) (*CarbCycle, error)
+	DeactivateCarbCycle(ctx context.Context, userID, cycleID string) error
+}
+
+// TrainingDays returns the weekdays of the user's latest workout plan.
+func (c *CalculatorRepository) TrainingDays(ctx context.Context, userID string) (map[time.Weekday]bool, error) {
+	rows, err := c.pgpool.Query(ctx, `
+		SELECT wd.day
+		FROM workout_day wd
+		WHERE wd.workout_plan_id = (
+		  SELECT id FROM workout_plan WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1
+		)`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workout days: %w", err)
+	}
+	defer rows.Close()
+
+	training := make(map[time.Weekday]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan workout day: %w", err)
+		}
+		if d, ok := parseTrainingDay(name); ok {
+			training[d] = true
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch workout days: %w", err)
+	}
+	return training, nil
+}
+
+const carbCycleColumns = `id, user_id, start_date, high_day_increase::float8, refeed_every_weeks,
+	diet_break_every_weeks, diet_break_weeks, active, created_at, updated_at`
+
+func scanCarbCycle(row pgx.Row) (*CarbCycle, error) {
+	var cc CarbCycle
+	err := row.Scan(&cc.ID, &cc.UserID, &cc.StartDate, &cc.HighDayIncrease, &cc.RefeedEveryWeeks,
+		&cc.DietBreakEveryWeeks, &cc.DietBreakWeeks, &cc.Active, &cc.CreatedAt, &cc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cc, nil
+}
+
+// CreateCarbCycle stores the cycle and replaces any active one.
+func (c *CalculatorRepository) CreateCarbCycle(ctx context.Context, cycle *CarbCycle) error {
+	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		UPDATE carb_cycles SET active = false, updated_at = now()
+		WHERE user_id = $1 AND active`, cycle.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate carb cycle: %w", err)
+	}
+
+	stored, err := scanCarbCycle(tx.QueryRow(ctx, `
+		INSERT INTO carb_cycles (user_id, start_date, high_day_increase, refeed_every_weeks,
+		                         diet_break_every_weeks, diet_break_weeks)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+carbCycleColumns,
+		cycle.UserID, cycle.StartDate, cycle.HighDayIncrease, cycle.RefeedEveryWeeks,
+		cycle.DietBreakEveryWeeks, cycle.DietBreakWeeks))
+	if err != nil {
+		return fmt.Errorf("failed to store carb cycle: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	*cycle = *stored
+	return nil
+}
+
+func (c *CalculatorRepository) ActiveCarbCycle(ctx context.Context, userID string) (*CarbCycle, error) {
+	return scanCarbCycle(c.pgpool.QueryRow(ctx, `
+		SELECT `+carbCycleColumns+`
+		FROM carb_cycles
+		WHERE user_id = $1 AND active`, userID))
+}
+
+func (c *CalculatorRepository) DeactivateCarbCycle(ctx context.Context, userID, cycleID string) error {
+	tag, err := c.pgpool.Exec(ctx, `
+		UPDATE carb_cycles SET active = false, updated_at = now()
+		WHERE id = $1 AND user_id = $2 AND active`, cycleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate carb cycle: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "carb cycle not found")
+	}
+	return nil
+}
+
+// CalorieLimit is the user's calorie target on date: the carb cycle's day
+// when one is active, otherwise the current goal. Users without a
+// distribution marked current have no limit; unlike CurrentMacro this does
+// not fall back to their latest one.
+func (c *CalculatorRepository) CalorieLimit(ctx context.Context, userID string, date time.Time) (float64, error) {
+	var current bool
+	err := c.pgpool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_macro_distribution WHERE user_id = $1 AND is_current)`,
+		userID).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check current macro: %w", err)
+	}
+	if !current {
+		return 0, nil
+	}
+
+	target, err := dayTarget(ctx, c, userID, date)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return float64(target.Calories), nil
+}
+
+func dayTarget(ctx context.Context, repo CarbCycleRepository, userID string, date time.Time) (DayTarget, error) {
+	macro, err := repo.CurrentMacro(ctx, userID)
+	if err != nil {
+		return DayTarget{}, err
+	}
+
+	cycle, err := repo.ActiveCarbCycle(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			protein, fats, carbs := dayMacros(*macro, macro.Goal)
+			return DayTarget{Date: day(date), Kind: DayModerate, Calories: macro.Goal,
+				Protein: protein, Fats: fats, Carbs: carbs}, nil
+		}
+		return DayTarget{}, err
+	}
+
+	training, err := repo.TrainingDays(ctx, userID)
+	if err != nil {
+		return DayTarget{}, err
+	}
+	return cycle.Day(*macro, training, date), nil
+}
+
+// CarbCycleService builds weekly calorie and macro schedules around the
+// user's training days.
+type CarbCycleService struct {
+	ctx  context.Context
+	repo CarbCycleRepository
+}
+
+func NewCarbCycleService(ctx context.Context, repo CarbCycleRepository) *CarbCycleService {
+	return &CarbCycleService{ctx: ctx, repo: repo}
+}
+
+func (s *CarbCycleService) CreateCarbCycle(ctx context.Context, userID string, cycle CarbCycle) (*CarbCycle, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "CreateCarbCycle")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if cycle.StartDate.IsZero() {
+		cycle.StartDate = time.Now()
+	}
+	if err := cycle.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	macro, err := s.repo.CurrentMacro(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "create a macro distribution first")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to fetch current macro: %v", err)
+	}
+	if (cycle.RefeedEveryWeeks > 0 || cycle.DietBreakEveryWeeks > 0) && macro.Goal >= macro.TDEE {
+		return nil, status.Error(codes.FailedPrecondition, "refeeds and diet breaks need a calorie deficit")
+	}
+
+	cycle.UserID = userID
+	if err = s.repo.CreateCarbCycle(ctx, &cycle); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	span.SetAttributes(
+		attribute.Float64("cycle.high_day_increase", cycle.HighDayIncrease),
+		attribute.Int("cycle.refeed_every_weeks", cycle.RefeedEveryWeeks),
+		attribute.Int("cycle.diet_break_every_weeks", cycle.DietBreakEveryWeeks),
+	)
+	return &cycle, nil
+}
+
+func (s *CarbCycleService) GetCarbCycle(ctx context.Context, userID string) (*CarbCycle, error) {
+	cycle, err := s.repo.ActiveCarbCycle(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "no active carb cycle")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to fetch carb cycle: %v", err)
+	}
+	return cycle, nil
+}
+
+func (s *CarbCycleService) CancelCarbCycle(ctx context.Context, userID string) error {
+	cycle, err := s.GetCarbCycle(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeactivateCarbCycle(ctx, userID, cycle.ID)
+}
+
+// WeekSchedule returns the seven days of the cycle week containing date.
+func (s *CarbCycleService) WeekSchedule(ctx context.Context, userID string, date time.Time) ([]DayTarget, error) {
+	cycle, err := s.GetCarbCycle(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	macro, err := s.repo.CurrentMacro(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "create a macro distribution first")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to fetch current macro: %v", err)
+	}
+	training, err := s.repo.TrainingDays(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return cycle.Week(*macro, training, date), nil
+}
+
+// DayTarget returns the calorie and macro target for date, whether or not a
+// cycle is active.
+func (s *CarbCycleService) DayTarget(ctx context.Context, userID string, date time.Time) (*DayTarget, error) {
+	target, err := dayTarget(ctx, s.repo, userID, date)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "create a macro distribution first")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &target, nil
+}
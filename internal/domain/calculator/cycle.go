@@ -0,0 +1,261 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Carb cycling turns the single daily goal of a macro distribution into a
+// weekly schedule. Training days get more calories, all of it carbs, and rest
+// days pay for it, so every regular week still averages the goal. Protein and
+// fat stay at the distribution's grams every day.
+//
+// Weeks are seven-day blocks counted from the cycle's start date. A refeed
+// week moves one day, the last training day, up to maintenance and spreads the
+// cost over the other six. A diet break runs whole weeks at maintenance; those
+// are the only weeks allowed off the average.
+
+type DayKind string
+
+const (
+	DayModerate  DayKind = "MODERATE"
+	DayHighCarb  DayKind = "HIGH_CARB"
+	DayLowCarb   DayKind = "LOW_CARB"
+	DayRefeed    DayKind = "REFEED"
+	DayDietBreak DayKind = "DIET_BREAK"
+)
+
+const (
+	defaultHighDayIncrease = 0.15
+	maxHighDayIncrease     = 0.5
+	maxCycleWeeks          = 52
+)
+
+type CarbCycle struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	StartDate time.Time `json:"start_date"`
+	// HighDayIncrease is the share of the average day added on training days.
+	HighDayIncrease float64 `json:"high_day_increase"`
+	// RefeedEveryWeeks puts a refeed in every Nth week; zero disables refeeds.
+	RefeedEveryWeeks int `json:"refeed_every_weeks"`
+	// DietBreakEveryWeeks dieting weeks are followed by DietBreakWeeks at
+	// maintenance; zero disables diet breaks.
+	DietBreakEveryWeeks int       `json:"diet_break_every_weeks"`
+	DietBreakWeeks      int       `json:"diet_break_weeks"`
+	Active              bool      `json:"active"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// DayTarget is the calorie and macro target of one date.
+type DayTarget struct {
+	Date     time.Time `json:"date"`
+	Kind     DayKind   `json:"kind"`
+	Calories uint16    `json:"calories"`
+	Protein  uint16    `json:"protein"`
+	Fats     uint16    `json:"fats"`
+	Carbs    uint16    `json:"carbs"`
+}
+
+func (c *CarbCycle) Validate() error {
+	if c.HighDayIncrease == 0 {
+		c.HighDayIncrease = defaultHighDayIncrease
+	}
+	if c.HighDayIncrease < 0 || c.HighDayIncrease > maxHighDayIncrease {
+		return fmt.Errorf("high day increase must be between 0 and %.0f%%", maxHighDayIncrease*100)
+	}
+	if c.RefeedEveryWeeks < 0 || c.RefeedEveryWeeks > maxCycleWeeks {
+		return fmt.Errorf("refeeds must be between 0 and %d weeks apart", maxCycleWeeks)
+	}
+	if c.DietBreakEveryWeeks < 0 || c.DietBreakEveryWeeks > maxCycleWeeks {
+		return fmt.Errorf("diet breaks must be between 0 and %d weeks apart", maxCycleWeeks)
+	}
+	if c.DietBreakEveryWeeks > 0 && (c.DietBreakWeeks < 1 || c.DietBreakWeeks > 4) {
+		return fmt.Errorf("a diet break must last between 1 and 4 weeks")
+	}
+	if c.DietBreakEveryWeeks == 0 {
+		c.DietBreakWeeks = 0
+	}
+	c.StartDate = day(c.StartDate)
+	return nil
+}
+
+// week returns the index of the block containing date, -1 before the start.
+func (c CarbCycle) week(date time.Time) int {
+	days := int(math.Floor(day(date).Sub(c.StartDate).Hours() / 24))
+	if days < 0 {
+		return -1
+	}
+	return days / 7
+}
+
+func (c CarbCycle) dietBreak(week int) bool {
+	if c.DietBreakEveryWeeks == 0 || week < 0 {
+		return false
+	}
+	return week%(c.DietBreakEveryWeeks+c.DietBreakWeeks) >= c.DietBreakEveryWeeks
+}
+
+// refeed counts only dieting weeks, so breaks do not shift the refeed rhythm.
+func (c CarbCycle) refeed(week int) bool {
+	if c.RefeedEveryWeeks == 0 || week < 0 {
+		return false
+	}
+	dieting := week
+	if c.DietBreakEveryWeeks > 0 {
+		period := c.DietBreakEveryWeeks + c.DietBreakWeeks
+		dieting = week/period*c.DietBreakEveryWeeks + week%period
+	}
+	return dieting%c.RefeedEveryWeeks == c.RefeedEveryWeeks-1
+}
+
+// Week returns the seven days of the block containing date. Dates before the
+// start get the plain goal.
+func (c CarbCycle) Week(base CurrentMacro, training map[time.Weekday]bool, date time.Time) []DayTarget {
+	w := c.week(date)
+	start := c.StartDate.AddDate(0, 0, 7*w)
+	if w < 0 {
+		start = day(date)
+	}
+
+	days := make([]DayTarget, 7)
+	for i := range days {
+		days[i].Date = start.AddDate(0, 0, i)
+	}
+
+	goal := float64(base.Goal)
+	tdee := float64(base.TDEE)
+	deficit := tdee > goal
+
+	switch {
+	case w < 0:
+		for i := range days {
+			days[i].Kind = DayModerate
+			days[i].Calories = base.Goal
+		}
+	case deficit && c.dietBreak(w):
+		for i := range days {
+			days[i].Kind = DayDietBreak
+			days[i].Calories = base.TDEE
+		}
+	case deficit && c.refeed(w):
+		refeed := 6
+		for i := 6; i >= 0; i-- {
+			if training[days[i].Date.Weekday()] {
+				refeed = i
+				break
+			}
+		}
+		days[refeed].Kind = DayRefeed
+		days[refeed].Calories = base.TDEE
+
+		rest := make([]*DayTarget, 0, 6)
+		for i := range days {
+			if i != refeed {
+				rest = append(rest, &days[i])
+			}
+		}
+		c.split(rest, 7*goal-tdee, training)
+	default:
+		all := make([]*DayTarget, 7)
+		for i := range days {
+			all[i] = &days[i]
+		}
+		c.split(all, 7*goal, training)
+	}
+
+	for i := range days {
+		days[i].Protein, days[i].Fats, days[i].Carbs = dayMacros(base, days[i].Calories)
+	}
+	return days
+}
+
+// Day returns the target for a single date.
+func (c CarbCycle) Day(base CurrentMacro, training map[time.Weekday]bool, date time.Time) DayTarget {
+	target := day(date)
+	for _, d := range c.Week(base, training, target) {
+		if d.Date.Equal(target) {
+			return d
+		}
+	}
+	return DayTarget{Date: target, Kind: DayModerate, Calories: base.Goal}
+}
+
+// split shares budget between days, training days getting HighDayIncrease
+// more than the average. Rest days never drop below minDailyCalories; the
+// increase shrinks instead. Rounding is settled on the last day so the days
+// add up to budget exactly.
+func (c CarbCycle) split(days []*DayTarget, budget float64, training map[time.Weekday]bool) {
+	n := float64(len(days))
+	var t float64
+	for _, d := range days {
+		if training[d.Date.Weekday()] {
+			t++
+		}
+	}
+
+	avg := budget / n
+	high, low := avg, avg
+	if t > 0 && t < n && c.HighDayIncrease > 0 && avg > minDailyCalories {
+		high = avg * (1 + c.HighDayIncrease)
+		low = (budget - t*high) / (n - t)
+		if low < minDailyCalories {
+			low = minDailyCalories
+			high = (budget - (n-t)*low) / t
+		}
+		if high <= low {
+			high, low = avg, avg
+		}
+	}
+
+	var total float64
+	for i, d := range days {
+		switch {
+		case high == low:
+			d.Kind = DayModerate
+			d.Calories = uint16(math.Round(avg))
+		case training[d.Date.Weekday()]:
+			d.Kind = DayHighCarb
+			d.Calories = uint16(math.Round(high))
+		default:
+			d.Kind = DayLowCarb
+			d.Calories = uint16(math.Round(low))
+		}
+		if i == len(days)-1 {
+			d.Calories = uint16(math.Max(0, math.Round(budget-total)))
+		}
+		total += float64(d.Calories)
+	}
+}
+
+// dayMacros keeps protein and fat at the base grams and lets carbs take up
+// the rest. When there is not enough left for them, fat gives way.
+func dayMacros(base CurrentMacro, calories uint16) (protein, fats, carbs uint16) {
+	kcal := float64(calories)
+	p := float64(base.Protein) * float64(proteinGramValue)
+	f := float64(base.Fats) * float64(fatGramValue)
+	left := kcal - p - f
+	if left >= 0 {
+		return base.Protein, base.Fats, uint16(left / float64(carbGramValue))
+	}
+	fat := math.Max(0, (kcal-p)/float64(fatGramValue))
+	return base.Protein, uint16(fat), 0
+}
+
+// parseTrainingDay reads the free text of workout_day.day ("Monday",
+// "mon", "Tuesday - Pull"). Anything else is not tied to a weekday.
+func parseTrainingDay(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 {
+		return 0, false
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.HasPrefix(s, strings.ToLower(d.String())[:3]) {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,210 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	// a Monday
+	cycleStart = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	cutMacro   = CurrentMacro{TDEE: 2500, Goal: 2000, Protein: 160, Fats: 60, Carbs: 205}
+	pushPull   = map[time.Weekday]bool{time.Monday: true, time.Wednesday: true, time.Friday: true}
+)
+
+func weekTotal(days []DayTarget) int {
+	var total int
+	for _, d := range days {
+		total += int(d.Calories)
+	}
+	return total
+}
+
+func TestCarbCycleWeek(t *testing.T) {
+	cycle := CarbCycle{StartDate: cycleStart, HighDayIncrease: 0.15}
+	days := cycle.Week(cutMacro, pushPull, cycleStart.AddDate(0, 0, 3))
+
+	if days[0].Date != cycleStart {
+		t.Fatalf("week starts %v, want %v", days[0].Date, cycleStart)
+	}
+	if got := weekTotal(days); got != 14000 {
+		t.Errorf("week total = %d, want 14000", got)
+	}
+	for _, d := range days {
+		want := DayLowCarb
+		calories := uint16(1775)
+		if pushPull[d.Date.Weekday()] {
+			want, calories = DayHighCarb, 2300
+		}
+		if d.Kind != want || d.Calories != calories {
+			t.Errorf("%s = %s %d kcal, want %s %d", d.Date.Weekday(), d.Kind, d.Calories, want, calories)
+		}
+		if d.Protein != 160 || d.Fats != 60 {
+			t.Errorf("%s protein/fat = %d/%d, want 160/60", d.Date.Weekday(), d.Protein, d.Fats)
+		}
+	}
+	if days[0].Carbs != 280 {
+		t.Errorf("high day carbs = %d, want 280", days[0].Carbs)
+	}
+}
+
+func TestCarbCycleRefeedsAndDietBreaks(t *testing.T) {
+	cycle := CarbCycle{StartDate: cycleStart, HighDayIncrease: 0.15, RefeedEveryWeeks: 2, DietBreakEveryWeeks: 4, DietBreakWeeks: 1}
+
+	refeeds := map[int]bool{1: true, 3: true, 6: true, 8: true}
+	breaks := map[int]bool{4: true, 9: true}
+
+	for w := 0; w < 10; w++ {
+		days := cycle.Week(cutMacro, pushPull, cycleStart.AddDate(0, 0, 7*w))
+
+		kinds := make(map[DayKind]int)
+		for _, d := range days {
+			kinds[d.Kind]++
+		}
+
+		switch {
+		case breaks[w]:
+			if kinds[DayDietBreak] != 7 || weekTotal(days) != 7*2500 {
+				t.Errorf("week %d: want a diet break at maintenance, got %v", w, kinds)
+			}
+		case refeeds[w]:
+			if kinds[DayRefeed] != 1 {
+				t.Errorf("week %d: want one refeed, got %v", w, kinds)
+			}
+			// the last training day of the week
+			if days[4].Kind != DayRefeed || days[4].Calories != 2500 {
+				t.Errorf("week %d: Friday = %s %d kcal, want a 2500 kcal refeed", w, days[4].Kind, days[4].Calories)
+			}
+			if got := weekTotal(days); got != 14000 {
+				t.Errorf("week %d: total = %d, want 14000", w, got)
+			}
+		default:
+			if kinds[DayRefeed] != 0 || kinds[DayDietBreak] != 0 {
+				t.Errorf("week %d: want a regular week, got %v", w, kinds)
+			}
+			if got := weekTotal(days); got != 14000 {
+				t.Errorf("week %d: total = %d, want 14000", w, got)
+			}
+		}
+	}
+}
+
+func TestCarbCycleSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		macro    CurrentMacro
+		training map[time.Weekday]bool
+		increase float64
+		refeed   int
+		wantHigh uint16
+		wantLow  uint16
+	}{
+		{"no training days", cutMacro, nil, 0.15, 0, 2000, 2000},
+		{"training every day", cutMacro, map[time.Weekday]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true}, 0.15, 0, 2000, 2000},
+		{"rest days floored", CurrentMacro{TDEE: 1800, Goal: 1300, Protein: 120, Fats: 45}, pushPull, 0.5, 0, 1433, 1200},
+		{"no refeed in a surplus", CurrentMacro{TDEE: 2500, Goal: 2800, Protein: 160, Fats: 70}, pushPull, 0.1, 1, 3080, 2590},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cycle := CarbCycle{StartDate: cycleStart, HighDayIncrease: tt.increase, RefeedEveryWeeks: tt.refeed}
+			days := cycle.Week(tt.macro, tt.training, cycleStart)
+
+			if got, want := weekTotal(days), 7*int(tt.macro.Goal); got != want {
+				t.Errorf("week total = %d, want %d", got, want)
+			}
+			// Monday trains in every case that has training; Tuesday rests
+			if days[0].Calories != tt.wantHigh {
+				t.Errorf("Monday = %d kcal, want %d", days[0].Calories, tt.wantHigh)
+			}
+			if days[1].Calories != tt.wantLow {
+				t.Errorf("Tuesday = %d kcal, want %d", days[1].Calories, tt.wantLow)
+			}
+			for _, d := range days {
+				if d.Kind == DayRefeed {
+					t.Errorf("unexpected refeed on %s", d.Date.Weekday())
+				}
+			}
+		})
+	}
+}
+
+func TestCarbCycleDay(t *testing.T) {
+	cycle := CarbCycle{StartDate: cycleStart, HighDayIncrease: 0.15}
+
+	before := cycle.Day(cutMacro, pushPull, cycleStart.AddDate(0, 0, -1))
+	if before.Kind != DayModerate || before.Calories != 2000 {
+		t.Errorf("before start = %s %d kcal, want the plain goal", before.Kind, before.Calories)
+	}
+
+	wed := cycle.Day(cutMacro, pushPull, cycleStart.AddDate(0, 0, 16).Add(15*time.Hour))
+	if wed.Kind != DayHighCarb || wed.Calories != 2300 {
+		t.Errorf("Wednesday = %s %d kcal, want a 2300 kcal high carb day", wed.Kind, wed.Calories)
+	}
+}
+
+func TestCarbCycleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cycle   CarbCycle
+		wantErr bool
+	}{
+		{"defaults", CarbCycle{}, false},
+		{"refeeds and breaks", CarbCycle{RefeedEveryWeeks: 2, DietBreakEveryWeeks: 8, DietBreakWeeks: 2}, false},
+		{"increase too large", CarbCycle{HighDayIncrease: 0.8}, true},
+		{"negative refeed", CarbCycle{RefeedEveryWeeks: -1}, true},
+		{"break without length", CarbCycle{DietBreakEveryWeeks: 8}, true},
+		{"break too long", CarbCycle{DietBreakEveryWeeks: 8, DietBreakWeeks: 6}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cycle.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDayMacros(t *testing.T) {
+	tests := []struct {
+		name                string
+		calories            uint16
+		wantFats, wantCarbs uint16
+	}{
+		{"carbs take the rest", 2300, 60, 280},
+		{"no carbs left", 1180, 60, 0},
+		{"fat gives way", 1000, 40, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protein, fats, carbs := dayMacros(cutMacro, tt.calories)
+			if protein != 160 || fats != tt.wantFats || carbs != tt.wantCarbs {
+				t.Errorf("dayMacros(%d) = %d/%d/%d, want 160/%d/%d", tt.calories, protein, fats, carbs, tt.wantFats, tt.wantCarbs)
+			}
+		})
+	}
+}
+
+func TestParseTrainingDay(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Weekday
+		ok   bool
+	}{
+		{"Monday", time.Monday, true},
+		{"tue", time.Tuesday, true},
+		{" Saturday - Legs ", time.Saturday, true},
+		{"Day 1", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseTrainingDay(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseTrainingDay(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+)
+
+// trendWarmup is extra history loaded before the longest window so the
+// smoothed trend has settled by the time the window starts.
+const trendWarmup = 28
+
+// ExpenditureRepository is implemented by CalculatorRepository.
+type ExpenditureRepository interface {
+	DailyWeights(ctx context.Context, userID string, since time.Time) ([]WeightSample, error)
+	DailyIntake(ctx context.Context, userID string, since time.Time) ([]IntakeSample, error)
+	CurrentMacro(ctx context.Context, userID string) (*CurrentMacro, error)
+	UpdateMacroGoal(ctx context.Context, userID, macroID string, tdee, goal uint16, macros Macros) error
+}
+
+// CurrentMacro is the part of the active macro distribution the adaptive
+// engine reads.
+type CurrentMacro struct {
+	ID        string
+	TDEE      uint16
+	Goal      uint16
+	Objective pb.Objective
+	Protein   uint16
+	Fats      uint16
+	Carbs     uint16
+}
+
+func (c *CalculatorRepository) DailyWeights(ctx context.Context, userID string, since time.Time) ([]WeightSample, error) {
+	rows, err := c.pgpool.Query(ctx, `
+		SELECT date_trunc('day', created_at) AS day, AVG(weight_value)::float8
+		FROM weight_measure
+		WHERE user_id = $1 AND created_at >= $2 AND weight_value > 0
+		GROUP BY 1
+		ORDER BY 1`, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch weights: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]WeightSample, 0)
+	for rows.Next() {
+		var s WeightSample
+		if err = rows.Scan(&s.Day, &s.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan weight: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// DailyIntake sums logged calories per day. A food log's quantity is the
+// number of servings of its meal.
+func (c *CalculatorRepository) DailyIntake(ctx context.Context, userID string, since time.Time) ([]IntakeSample, error) {
+	rows, err := c.pgpool.Query(ctx, `
+		SELECT date_trunc('day', fl.log_date) AS day,
+		       SUM(fl.quantity * COALESCE(mc.calories, 0))::float8
+		FROM food_logs fl
+		LEFT JOIN (
+			SELECT meal_id, SUM(calories) AS calories
+			FROM meal_ingredients
+			GROUP BY meal_id
+		) mc ON mc.meal_id = fl.meal_id
+		WHERE fl.user_id = $1 AND fl.log_date >= $2
+		GROUP BY 1
+		ORDER BY 1`, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch food intake: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]IntakeSample, 0)
+	for rows.Next() {
+		var s IntakeSample
+		if err = rows.Scan(&s.Day, &s.Calories); err != nil {
+			return nil, fmt.Errorf("failed to scan food intake: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// CurrentMacro returns the active distribution, or the latest one if none is
+// marked current.
+func (c *CalculatorRepository) CurrentMacro(ctx context.Context, userID string) (*CurrentMacro, error) {
+	var macro CurrentMacro
+	var objective string
+	err := c.pgpool.QueryRow(ctx, `
+		SELECT id, tdee, goal, objective, protein, fats, carbs
+		FROM user_macro_distribution
+		WHERE user_id = $1
+		ORDER BY is_current DESC, created_at DESC
+		LIMIT 1`, userID).Scan(&macro.ID, &macro.TDEE, &macro.Goal, &objective, &macro.Protein, &macro.Fats, &macro.Carbs)
+	if err != nil {
+		return nil, err
+	}
+	macro.Objective = parseStoredObjective(objective)
+	return &macro, nil
+}
+
+// MacroByID returns one of the user's macro distributions.
+func (c *CalculatorRepository) MacroByID(ctx context.Context, userID, macroID string) (*CurrentMacro, error) {
+	var macro CurrentMacro
+	var objective string
+	err := c.pgpool.QueryRow(ctx, `
+		SELECT id, tdee, goal, objective, protein, fats, carbs
+		FROM user_macro_distribution
+		WHERE id = $1 AND user_id = $2`, macroID, userID).Scan(&macro.ID, &macro.TDEE, &macro.Goal, &objective, &macro.Protein, &macro.Fats, &macro.Carbs)
+	if err != nil {
+		return nil, err
+	}
+	macro.Objective = parseStoredObjective(objective)
+	return &macro, nil
+}
+
+// UpdateMacroGoal stores a new TDEE and goal on a macro distribution with
+// the macros rescaled to it.
+func (c *CalculatorRepository) UpdateMacroGoal(ctx context.Context, userID, macroID string, tdee, goal uint16, macros Macros) error {
+	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_macro_distribution
+		SET tdee = $3, goal = $4, protein = $5, fats = $6, carbs = $7
+		WHERE id = $1 AND user_id = $2`,
+		macroID, userID, tdee, goal, macros.Protein, macros.Fats, macros.Carbs)
+	if err != nil {
+		return fmt.Errorf("failed to update macro goal: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		err = status.Error(codes.NotFound, "macro not found")
+		return err
+	}
+
+	err = recordMacroChange(ctx, tx, userID, macroID, domain.MacroChange{
+		Source: string(ChangeAdaptive), Reason: "adaptive TDEE estimate",
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// parseStoredObjective reads the objective column, which holds either the
+// enum number or its name depending on how the row was written.
+func parseStoredObjective(s string) pb.Objective {
+	if n, err := strconv.Atoi(s); err == nil {
+		return pb.Objective(n)
+	}
+	if v, ok := pb.Objective_value[strings.ToUpper(s)]; ok {
+		return pb.Objective(v)
+	}
+	return pb.Objective_OBJECTIVE_UNSPECIFIED
+}
+
+// ExpenditureService estimates a user's real energy expenditure from their
+// weight and food logs.
+type ExpenditureService struct {
+	ctx  context.Context
+	repo ExpenditureRepository
+}
+
+func NewExpenditureService(ctx context.Context, repo ExpenditureRepository) *ExpenditureService {
+	return &ExpenditureService{ctx: ctx, repo: repo}
+}
+
+// EstimateExpenditure returns the weight trend, the per-window estimates and
+// a proposed goal for the user's current macro distribution.
+func (s *ExpenditureService) EstimateExpenditure(ctx context.Context, userID string) (*ExpenditureEstimate, error) {
+	estimate, _, err := s.estimate(ctx, userID)
+	return estimate, err
+}
+
+// estimate is EstimateExpenditure, also returning the macro distribution the
+// proposal is for.
+func (s *ExpenditureService) estimate(ctx context.Context, userID string) (*ExpenditureEstimate, *CurrentMacro, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "EstimateExpenditure")
+	defer span.End()
+
+	if userID == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	macro, err := s.repo.CurrentMacro(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, status.Error(codes.FailedPrecondition, "create a macro distribution first")
+		}
+		return nil, nil, status.Errorf(codes.Internal, "failed to fetch current macro: %v", err)
+	}
+
+	now := time.Now()
+	longest := expenditureWindows[len(expenditureWindows)-1]
+	since := day(now).AddDate(0, 0, -(longest + trendWarmup))
+
+	weights, err := s.repo.DailyWeights(ctx, userID, since)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	intake, err := s.repo.DailyIntake(ctx, userID, since)
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+
+	estimate := EstimateExpenditure(weights, intake, now)
+	estimate.MacroID = macro.ID
+	estimate.CurrentGoal = macro.Goal
+	estimate.propose(float64(macro.TDEE), macro.Objective)
+
+	span.SetAttributes(
+		attribute.String("expenditure.confidence", string(estimate.Confidence)),
+		attribute.Float64("expenditure.tdee", estimate.TDEE),
+	)
+
+	return &estimate, macro, nil
+}
+
+// ApplyProposedGoal stores the proposed TDEE and goal on the current macro
+// distribution, its macros rescaled to keep their calorie split. Nothing is
+// changed while confidence is NONE.
+func (s *ExpenditureService) ApplyProposedGoal(ctx context.Context, userID string) (*ExpenditureEstimate, error) {
+	estimate, macro, err := s.estimate(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if estimate.Confidence == ConfidenceNone {
+		return nil, status.Error(codes.FailedPrecondition, "not enough weight and food logs to adapt the goal")
+	}
+
+	err = s.repo.UpdateMacroGoal(ctx, userID, estimate.MacroID, uint16(estimate.ProposedTDEE), estimate.ProposedGoal,
+		macro.rescale(estimate.ProposedGoal))
+	if err != nil {
+		return nil, err
+	}
+	return estimate, nil
+}
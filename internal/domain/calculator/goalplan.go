@@ -0,0 +1,355 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+// GoalPlanRepository is implemented by CalculatorRepository.
+type GoalPlanRepository interface {
+	DailyWeights(ctx context.Context, userID string, since time.Time) ([]WeightSample, error)
+	CurrentMacro(ctx context.Context, userID string) (*CurrentMacro, error)
+	MacroByID(ctx context.Context, userID, macroID string) (*CurrentMacro, error)
+	CreateGoalPlan(ctx context.Context, plan *GoalPlan, macros Macros) error
+	ActiveGoalPlan(ctx context.Context, userID string) (*GoalPlan, error)
+	UpdateGoalPlan(ctx context.Context, plan *GoalPlan, macros Macros) error
+	DeactivateGoalPlan(ctx context.Context, userID, planID string) error
+}
+
+const goalPlanColumns = `id, user_id, macro_id, start_weight::float8, current_weight::float8, target_weight::float8,
+	target_date, weekly_rate::float8, daily_adjustment, tdee, daily_goal, safe, warnings, active, created_at, updated_at`
+
+func scanGoalPlan(row pgx.Row) (*GoalPlan, error) {
+	var p GoalPlan
+	var adjustment, tdee int
+	err := row.Scan(&p.ID, &p.UserID, &p.MacroID, &p.StartWeight, &p.CurrentWeight, &p.TargetWeight,
+		&p.TargetDate, &p.WeeklyRate, &adjustment, &tdee, &p.DailyGoal, &p.Safe, &p.Warnings, &p.Active,
+		&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.DailyAdjustment = float64(adjustment)
+	p.TDEE = float64(tdee)
+	return &p, nil
+}
+
+// CreateGoalPlan copies the user's current macro distribution with the plan's
+// goal and macros, makes the copy current and replaces any active plan.
+func (c *CalculatorRepository) CreateGoalPlan(ctx context.Context, plan *GoalPlan, macros Macros) error {
+	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_macro_distribution (
+		  user_id, age, height, weight, gender, system, activity, activity_description,
+		  objective, objective_description, calories_distribution, calories_distribution_description,
+		  protein, fats, carbs, bmr, tdee, goal, created_at, is_current, bmr_formula, body_fat_percent,
+		  distribution_mode, custom_protein_ratio, custom_fat_ratio, custom_carb_ratio, protein_g_per_kg, fat_g_per_kg
+		)
+		SELECT user_id, age, height, weight, gender, system, activity, activity_description,
+		       objective, objective_description, calories_distribution, calories_distribution_description,
+		       $3, $4, $5, bmr, tdee, $6, now(), false, bmr_formula, body_fat_percent,
+		       distribution_mode, custom_protein_ratio, custom_fat_ratio, custom_carb_ratio, protein_g_per_kg, fat_g_per_kg
+		FROM user_macro_distribution
+		WHERE id = $1 AND user_id = $2
+		RETURNING id`,
+		plan.MacroID, plan.UserID, macros.Protein, macros.Fats, macros.Carbs, plan.DailyGoal,
+	).Scan(&plan.MacroID)
+	if err != nil {
+		return fmt.Errorf("failed to copy macro distribution: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_macro_distribution SET is_current = (id = $2)
+		WHERE user_id = $1`, plan.UserID, plan.MacroID)
+	if err != nil {
+		return fmt.Errorf("failed to set current macro: %w", err)
+	}
+
+	err = recordMacroChange(ctx, tx, plan.UserID, plan.MacroID, domain.MacroChange{
+		Source: string(ChangeByUser), ChangedBy: plan.UserID, Reason: "goal plan created",
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE goal_plans SET active = false, updated_at = now()
+		WHERE user_id = $1 AND active`, plan.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to replace goal plan: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO goal_plans (
+		  user_id, macro_id, start_weight, current_weight, target_weight, target_date,
+		  weekly_rate, daily_adjustment, tdee, daily_goal, safe, warnings
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at`,
+		plan.UserID, plan.MacroID, plan.StartWeight, plan.CurrentWeight, plan.TargetWeight, plan.TargetDate,
+		plan.WeeklyRate, int(plan.DailyAdjustment), int(plan.TDEE), plan.DailyGoal, plan.Safe, nonNil(plan.Warnings),
+	).Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert goal plan: %w", err)
+	}
+	plan.Active = true
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (c *CalculatorRepository) ActiveGoalPlan(ctx context.Context, userID string) (*GoalPlan, error) {
+	return scanGoalPlan(c.pgpool.QueryRow(ctx, `
+		SELECT `+goalPlanColumns+`
+		FROM goal_plans
+		WHERE user_id = $1 AND active`, userID))
+}
+
+// UpdateGoalPlan stores a recomputed plan and moves its macro distribution to
+// the new goal.
+func (c *CalculatorRepository) UpdateGoalPlan(ctx context.Context, plan *GoalPlan, macros Macros) error {
+	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	err = tx.QueryRow(ctx, `
+		UPDATE goal_plans
+		SET current_weight = $3, weekly_rate = $4, daily_adjustment = $5, tdee = $6,
+		    daily_goal = $7, safe = $8, warnings = $9, updated_at = now()
+		WHERE id = $1 AND user_id = $2 AND active
+		RETURNING updated_at`,
+		plan.ID, plan.UserID, plan.CurrentWeight, plan.WeeklyRate, int(plan.DailyAdjustment), int(plan.TDEE),
+		plan.DailyGoal, plan.Safe, nonNil(plan.Warnings),
+	).Scan(&plan.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update goal plan: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_macro_distribution
+		SET goal = $3, protein = $4, fats = $5, carbs = $6
+		WHERE id = $1 AND user_id = $2`,
+		plan.MacroID, plan.UserID, plan.DailyGoal, macros.Protein, macros.Fats, macros.Carbs)
+	if err != nil {
+		return fmt.Errorf("failed to update plan macro: %w", err)
+	}
+
+	err = recordMacroChange(ctx, tx, plan.UserID, plan.MacroID, domain.MacroChange{
+		Source: string(ChangeAdaptive), Reason: "goal plan recomputed",
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (c *CalculatorRepository) DeactivateGoalPlan(ctx context.Context, userID, planID string) error {
+	tag, err := c.pgpool.Exec(ctx, `
+		UPDATE goal_plans SET active = false, updated_at = now()
+		WHERE id = $1 AND user_id = $2 AND active`, planID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate goal plan: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "goal plan not found")
+	}
+	return nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// GoalPlanService turns a target weight and date into a macro distribution
+// and keeps it on track as weights are logged.
+type GoalPlanService struct {
+	ctx  context.Context
+	repo GoalPlanRepository
+}
+
+func NewGoalPlanService(ctx context.Context, repo GoalPlanRepository) *GoalPlanService {
+	return &GoalPlanService{ctx: ctx, repo: repo}
+}
+
+// Register recomputes the active plan whenever a weight is logged.
+func (s *GoalPlanService) Register(bus *events.Bus) {
+	bus.Subscribe(events.WeightLogged, s.handleWeightLogged)
+}
+
+// currentWeight is today's smoothed trend, so a single heavy weigh-in does
+// not swing the plan.
+func (s *GoalPlanService) currentWeight(ctx context.Context, userID string, now time.Time) (float64, error) {
+	weights, err := s.repo.DailyWeights(ctx, userID, day(now).AddDate(0, 0, -trendWarmup))
+	if err != nil {
+		return 0, err
+	}
+	trend := weightTrend(weights, now)
+	if len(trend) == 0 {
+		return 0, nil
+	}
+	return trend[len(trend)-1].Trend, nil
+}
+
+func (s *GoalPlanService) CreateGoalPlan(ctx context.Context, userID string, targetWeight float64, targetDate time.Time) (*GoalPlan, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "CreateGoalPlan")
+	defer span.End()
+
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	now := time.Now()
+	if day(targetDate).Before(day(now).AddDate(0, 0, minPlanDays)) {
+		return nil, status.Errorf(codes.InvalidArgument, "target date must be at least %d days away", minPlanDays)
+	}
+	if targetWeight < minWeight || targetWeight > maxWeight {
+		return nil, status.Errorf(codes.InvalidArgument, "target weight must be between %d and %d kg", minWeight, maxWeight)
+	}
+
+	macro, err := s.repo.CurrentMacro(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "create a macro distribution first")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to fetch current macro: %v", err)
+	}
+
+	weight, err := s.currentWeight(ctx, userID, now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if weight == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "log a weight first")
+	}
+
+	plan := &GoalPlan{
+		UserID:        userID,
+		MacroID:       macro.ID,
+		StartWeight:   weight,
+		CurrentWeight: weight,
+		TargetWeight:  targetWeight,
+		TargetDate:    day(targetDate),
+		TDEE:          float64(macro.TDEE),
+	}
+	if err = planGoal(plan, now); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.repo.CreateGoalPlan(ctx, plan, macro.rescale(plan.DailyGoal)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	span.SetAttributes(
+		attribute.Float64("plan.weekly_rate", plan.WeeklyRate),
+		attribute.Bool("plan.safe", plan.Safe),
+	)
+	return plan, nil
+}
+
+// GetGoalPlan returns the active plan with a fresh projection.
+func (s *GoalPlanService) GetGoalPlan(ctx context.Context, userID string) (*GoalPlan, error) {
+	plan, err := s.repo.ActiveGoalPlan(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "no active goal plan")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to fetch goal plan: %v", err)
+	}
+	plan.Projection = project(plan.CurrentWeight, plan.DailyAdjustment, day(time.Now()), plan.TargetDate)
+	return plan, nil
+}
+
+func (s *GoalPlanService) CancelGoalPlan(ctx context.Context, userID string) error {
+	plan, err := s.GetGoalPlan(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeactivateGoalPlan(ctx, userID, plan.ID)
+}
+
+// RecomputeGoalPlan re-plans from the latest weight trend and the TDEE of the
+// plan's macro distribution, which the adaptive estimate may have moved.
+// Plans past their target date are closed.
+func (s *GoalPlanService) RecomputeGoalPlan(ctx context.Context, userID string) (*GoalPlan, error) {
+	plan, err := s.repo.ActiveGoalPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	macro, err := s.repo.MacroByID(ctx, userID, plan.MacroID)
+	if err != nil {
+		return nil, err
+	}
+	plan.TDEE = float64(macro.TDEE)
+
+	now := time.Now()
+	weight, err := s.currentWeight(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if weight > 0 {
+		plan.CurrentWeight = weight
+	}
+
+	if err = planGoal(plan, now); err != nil {
+		if errors.Is(err, errPlanExpired) {
+			plan.Active = false
+			return plan, s.repo.DeactivateGoalPlan(ctx, userID, plan.ID)
+		}
+		return nil, err
+	}
+
+	if err = s.repo.UpdateGoalPlan(ctx, plan, macro.rescale(plan.DailyGoal)); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// handleWeightLogged is idempotent: the plan is recomputed from stored
+// weights, so a redelivered event yields the same result.
+func (s *GoalPlanService) handleWeightLogged(ctx context.Context, evt events.Event) error {
+	_, err := s.RecomputeGoalPlan(ctx, evt.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		logger.Log.Error("failed to recompute goal plan", zap.String("user_id", evt.UserID), zap.Error(err))
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package calculator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeSource says who moved the user's active macro distribution.
+type ChangeSource string
+
+const (
+	ChangeByUser    ChangeSource = "USER"
+	ChangeByTrainer ChangeSource = "TRAINER"
+	// ChangeAdaptive covers the adaptive TDEE estimate and goal plan
+	// recomputes; nobody asked for those directly.
+	ChangeAdaptive ChangeSource = "ADAPTIVE"
+)
+
+// MacroSnapshot is what a macro distribution looked like at one point: the
+// inputs it was calculated from and the targets it produced.
+type MacroSnapshot struct {
+	MacroID              string  `json:"macro_id"`
+	Age                  int     `json:"age"`
+	Height               float64 `json:"height"`
+	Weight               float64 `json:"weight"`
+	Activity             string  `json:"activity"`
+	Objective            string  `json:"objective"`
+	CaloriesDistribution string  `json:"calories_distribution"`
+	BMRFormula           string  `json:"bmr_formula"`
+	BMR                  int     `json:"bmr"`
+	TDEE                 int     `json:"tdee"`
+	Goal                 int     `json:"goal"`
+	Protein              int     `json:"protein"`
+	Fats                 int     `json:"fats"`
+	Carbs                int     `json:"carbs"`
+}
+
+// FieldChange is one field that differs between two snapshots. Delta is set
+// for numeric fields.
+type FieldChange struct {
+	Field string   `json:"field"`
+	From  any      `json:"from"`
+	To    any      `json:"to"`
+	Delta *float64 `json:"delta,omitempty"`
+}
+
+// MacroChange is one entry in the history of the active distribution.
+// Changes is the diff against the entry before it.
+type MacroChange struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	Source    ChangeSource  `json:"source"`
+	ChangedBy string        `json:"changed_by,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Snapshot  MacroSnapshot `json:"snapshot"`
+	Changes   []FieldChange `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// MacroDiff compares two distributions.
+type MacroDiff struct {
+	From    MacroSnapshot `json:"from"`
+	To      MacroSnapshot `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// diffSnapshots lists the fields that differ between a and b, inputs first.
+// Height and weight are compared to a tenth to ignore float noise.
+func diffSnapshots(a, b MacroSnapshot) []FieldChange {
+	changes := make([]FieldChange, 0)
+	number := func(field string, from, to float64) {
+		if math.Round(from*10) == math.Round(to*10) {
+			return
+		}
+		delta := round1(to - from)
+		changes = append(changes, FieldChange{Field: field, From: from, To: to, Delta: &delta})
+	}
+	text := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	number("age", float64(a.Age), float64(b.Age))
+	number("height", a.Height, b.Height)
+	number("weight", a.Weight, b.Weight)
+	text("activity", a.Activity, b.Activity)
+	text("objective", a.Objective, b.Objective)
+	text("calories_distribution", a.CaloriesDistribution, b.CaloriesDistribution)
+	text("bmr_formula", a.BMRFormula, b.BMRFormula)
+	number("bmr", float64(a.BMR), float64(b.BMR))
+	number("tdee", float64(a.TDEE), float64(b.TDEE))
+	number("goal", float64(a.Goal), float64(b.Goal))
+	number("protein", float64(a.Protein), float64(b.Protein))
+	number("fats", float64(a.Fats), float64(b.Fats))
+	number("carbs", float64(a.Carbs), float64(b.Carbs))
+	return changes
+}
+
+// storedEnumName reads an enum column that holds either the number or the
+// name, like parseStoredObjective, and always returns the name.
+func storedEnumName(s string, names map[int32]string) string {
+	if n, err := strconv.Atoi(s); err == nil {
+		if name, ok := names[int32(n)]; ok {
+			return name
+		}
+	}
+	return strings.ToUpper(s)
+}
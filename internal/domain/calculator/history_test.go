@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"testing"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	base := MacroSnapshot{
+		MacroID: "a", Age: 30, Height: 180, Weight: 82.4, Activity: "MODERATELY_ACTIVE", Objective: "LOSE_WEIGHT",
+		CaloriesDistribution: "MODERATE_CALORIE", BMRFormula: "mifflin_st_jeor",
+		BMR: 1800, TDEE: 2790, Goal: 2290, Protein: 172, Fats: 64, Carbs: 258,
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *MacroSnapshot)
+		want   map[string]float64
+	}{
+		{"same distribution", func(s *MacroSnapshot) {}, map[string]float64{}},
+		{"float noise ignored", func(s *MacroSnapshot) { s.Weight = 82.40001 }, map[string]float64{}},
+		{"weight and goal", func(s *MacroSnapshot) {
+			s.Weight = 80.1
+			s.Goal = 2200
+		}, map[string]float64{"weight": -2.3, "goal": -90}},
+		{"objective", func(s *MacroSnapshot) { s.Objective = "MAINTAIN" }, map[string]float64{"objective": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base
+			tt.change(&next)
+			changes := diffSnapshots(base, next)
+			if len(changes) != len(tt.want) {
+				t.Fatalf("got %d changes %+v, want %d", len(changes), changes, len(tt.want))
+			}
+			for _, c := range changes {
+				delta, ok := tt.want[c.Field]
+				if !ok {
+					t.Errorf("unexpected change to %s", c.Field)
+					continue
+				}
+				if c.Delta == nil {
+					if delta != 0 {
+						t.Errorf("%s: missing delta", c.Field)
+					}
+					continue
+				}
+				if *c.Delta != delta {
+					t.Errorf("%s: delta = %v, want %v", c.Field, *c.Delta, delta)
+				}
+			}
+		})
+	}
+}
+
+func TestStoredEnumName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2", pb.Objective_name[2]},
+		{"lose_weight", "LOSE_WEIGHT"},
+		{"99", "99"},
+	}
+	for _, tt := range tests {
+		if got := storedEnumName(tt.in, pb.Objective_name); got != tt.want {
+			t.Errorf("storedEnumName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+// Daily water goal: 35 ml per kg of bodyweight, scaled up for the user's
+// activity level, plus what the day's training sweats out. Volumes are in ml.
+const (
+	waterMlPerKg             = 35.0
+	exerciseWaterMlPerMinute = 12.0 // roughly 350 ml per half hour
+	minWaterGoalMl           = 1500.0
+	maxWaterGoalMl           = 6000.0
+)
+
+var activityWaterFactor = map[pb.Activity]float64{
+	pb.Activity_SEDENTARY:   1.0,
+	pb.Activity_LIGHT:       1.05,
+	pb.Activity_MODERATE:    1.1,
+	pb.Activity_HEAVY:       1.15,
+	pb.Activity_EXTRA_HEAVY: 1.2,
+}
+
+// WaterGoal breaks a day's target down by where it comes from.
+type WaterGoal struct {
+	BaseMl     float64 `json:"base_ml"`
+	ActivityMl float64 `json:"activity_ml"`
+	ExerciseMl float64 `json:"exercise_ml"`
+	TotalMl    float64 `json:"total_ml"`
+}
+
+// DailyWaterGoal is the target for a day with exerciseMinutes of logged
+// training. An unknown activity level counts as sedentary. The total is kept
+// between 1.5 and 6 litres.
+func DailyWaterGoal(weightKg float64, activity pb.Activity, exerciseMinutes float64) (WaterGoal, error) {
+	if weightKg < minWeight || weightKg > maxWeight {
+		return WaterGoal{}, fmt.Errorf("weight must be between %d and %d kg", minWeight, maxWeight)
+	}
+	if exerciseMinutes < 0 {
+		return WaterGoal{}, fmt.Errorf("exercise minutes cannot be negative")
+	}
+
+	factor, ok := activityWaterFactor[activity]
+	if !ok {
+		factor = 1
+	}
+
+	goal := WaterGoal{BaseMl: math.Round(weightKg * waterMlPerKg)}
+	goal.ActivityMl = math.Round(goal.BaseMl * (factor - 1))
+	goal.ExerciseMl = math.Round(exerciseMinutes * exerciseWaterMlPerMinute)
+	goal.TotalMl = math.Min(maxWaterGoalMl, math.Max(minWaterGoalMl, goal.BaseMl+goal.ActivityMl+goal.ExerciseMl))
+	return goal, nil
+}
+
+// ParseStoredActivity reads the activity column of user_macro_distribution,
+// which holds either the enum number or its name.
+func ParseStoredActivity(s string) pb.Activity {
+	return pb.Activity(pb.Activity_value[storedEnumName(s, pb.Activity_name)])
+}
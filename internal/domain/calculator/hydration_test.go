@@ -0,0 +1,46 @@
+package calculator
+
+import (
+	"testing"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+)
+
+func TestDailyWaterGoal(t *testing.T) {
+	tests := []struct {
+		name     string
+		weight   float64
+		activity pb.Activity
+		minutes  float64
+		want     WaterGoal
+		wantErr  bool
+	}{
+		{"sedentary rest day", 80, pb.Activity_SEDENTARY, 0, WaterGoal{BaseMl: 2800, TotalMl: 2800}, false},
+		{"moderate with training", 80, pb.Activity_MODERATE, 60, WaterGoal{BaseMl: 2800, ActivityMl: 280, ExerciseMl: 720, TotalMl: 3800}, false},
+		{"unknown activity counts as sedentary", 70, pb.Activity_ACTIVITY_UNSPECIFIED, 30, WaterGoal{BaseMl: 2450, ExerciseMl: 360, TotalMl: 2810}, false},
+		{"floor", 40, pb.Activity_SEDENTARY, 0, WaterGoal{BaseMl: 1400, TotalMl: 1500}, false},
+		{"ceiling", 150, pb.Activity_EXTRA_HEAVY, 180, WaterGoal{BaseMl: 5250, ActivityMl: 1050, ExerciseMl: 2160, TotalMl: 6000}, false},
+		{"no weight", 0, pb.Activity_SEDENTARY, 0, WaterGoal{}, true},
+		{"negative exercise", 80, pb.Activity_SEDENTARY, -5, WaterGoal{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DailyWaterGoal(tt.weight, tt.activity, tt.minutes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DailyWaterGoal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DailyWaterGoal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStoredActivity(t *testing.T) {
+	for in, want := range map[string]pb.Activity{"3": pb.Activity_MODERATE, "HEAVY": pb.Activity_HEAVY, "": pb.Activity_ACTIVITY_UNSPECIFIED} {
+		if got := ParseStoredActivity(in); got != want {
+			t.Errorf("ParseStoredActivity(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package calculator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
+)
+
+// MacroHistoryRepository is implemented by CalculatorRepository.
+type MacroHistoryRepository interface {
+	MacroHistory(ctx context.Context, userID string) ([]MacroChange, error)
+	MacroSnapshot(ctx context.Context, userID, macroID string) (MacroSnapshot, error)
+	IsTrainerOf(ctx context.Context, trainerID, clientID string) (bool, error)
+}
+
+// querier is satisfied by both the pool and a transaction, so changes can be
+// recorded inside the statement that made them.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+const macroSnapshotColumns = `id, age, height::float8, weight::float8, activity, objective, calories_distribution,
+	bmr_formula, bmr, tdee, goal, protein, fats, carbs`
+
+func scanMacroSnapshot(row pgx.Row) (MacroSnapshot, error) {
+	var s MacroSnapshot
+	err := row.Scan(&s.MacroID, &s.Age, &s.Height, &s.Weight, &s.Activity, &s.Objective, &s.CaloriesDistribution,
+		&s.BMRFormula, &s.BMR, &s.TDEE, &s.Goal, &s.Protein, &s.Fats, &s.Carbs)
+	if err != nil {
+		return MacroSnapshot{}, err
+	}
+	s.Activity = storedEnumName(s.Activity, pb.Activity_name)
+	s.Objective = storedEnumName(s.Objective, pb.Objective_name)
+	s.CaloriesDistribution = storedEnumName(s.CaloriesDistribution, pb.CaloriesDistribution_name)
+	return s, nil
+}
+
+// recordMacroChange appends the macro to the user's history when it is the
+// current one and differs from the last entry. Call it after the change, in
+// the same transaction.
+func recordMacroChange(ctx context.Context, q querier, userID, macroID string, change domain.MacroChange) error {
+	snapshot, err := scanMacroSnapshot(q.QueryRow(ctx, `
+		SELECT `+macroSnapshotColumns+`
+		FROM user_macro_distribution
+		WHERE id = $1 AND user_id = $2 AND is_current`, macroID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to read macro snapshot: %w", err)
+	}
+
+	var changes []FieldChange
+	previous, err := scanMacroSnapshot(q.QueryRow(ctx, `
+		SELECT COALESCE(macro_id::text, ''), age, height, weight, activity, objective, calories_distribution,
+		       bmr_formula, bmr, tdee, goal, protein, fats, carbs
+		FROM macro_distribution_changes
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`, userID))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		changes = make([]FieldChange, 0)
+	case err != nil:
+		return fmt.Errorf("failed to read previous macro snapshot: %w", err)
+	default:
+		changes = diffSnapshots(previous, snapshot)
+		if len(changes) == 0 && previous.MacroID == snapshot.MacroID {
+			return nil
+		}
+	}
+
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode macro changes: %w", err)
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO macro_distribution_changes (
+		  user_id, macro_id, source, changed_by, reason,
+		  age, height, weight, activity, objective, calories_distribution, bmr_formula,
+		  bmr, tdee, goal, protein, fats, carbs, changes
+		)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, ''),
+		        $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		userID, snapshot.MacroID, change.Source, change.ChangedBy, change.Reason,
+		snapshot.Age, snapshot.Height, snapshot.Weight, snapshot.Activity, snapshot.Objective,
+		snapshot.CaloriesDistribution, snapshot.BMRFormula,
+		snapshot.BMR, snapshot.TDEE, snapshot.Goal, snapshot.Protein, snapshot.Fats, snapshot.Carbs, raw)
+	if err != nil {
+		return fmt.Errorf("failed to record macro change: %w", err)
+	}
+	return nil
+}
+
+func (c *CalculatorRepository) IsTrainerOf(ctx context.Context, trainerID, clientID string) (bool, error) {
+	var ok bool
+	err := c.pgpool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM trainer_clients WHERE trainer_id = $1 AND client_id = $2)`,
+		trainerID, clientID).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("failed to check trainer: %w", err)
+	}
+	return ok, nil
+}
+
+func (c *CalculatorRepository) MacroSnapshot(ctx context.Context, userID, macroID string) (MacroSnapshot, error) {
+	return scanMacroSnapshot(c.pgpool.QueryRow(ctx, `
+		SELECT `+macroSnapshotColumns+`
+		FROM user_macro_distribution
+		WHERE id = $1 AND user_id = $2`, macroID, userID))
+}
+
+var macroHistoryPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "-created_at",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"from":   {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Gte},
+		"to":     {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Lte},
+		"source": {Expr: "source", Type: pagination.Text, Op: pagination.Eq},
+	},
+}
+
+// MacroHistory lists the changes of the user's active distribution, paged
+// with the usual x-page-* headers and filtered by x-filter-from/to/source.
+func (c *CalculatorRepository) MacroHistory(ctx context.Context, userID string) ([]MacroChange, error) {
+	page, err := macroHistoryPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pgpool.Query(ctx, `
+		SELECT id, user_id, source, COALESCE(changed_by::text, ''), COALESCE(reason, ''),
+		       COALESCE(macro_id::text, ''), age, height, weight, activity, objective, calories_distribution,
+		       bmr_formula, bmr, tdee, goal, protein, fats, carbs, changes, created_at, `+page.CursorColumns()+`
+		FROM macro_distribution_changes
+		WHERE user_id = $1`+page.Where()+page.OrderLimit(),
+		append([]any{userID}, page.Args()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch macro history: %w", err)
+	}
+	defer rows.Close()
+
+	pager := page.Pager()
+	history := make([]MacroChange, 0)
+	for rows.Next() {
+		var m MacroChange
+		var raw []byte
+		s := &m.Snapshot
+		err = rows.Scan(&m.ID, &m.UserID, &m.Source, &m.ChangedBy, &m.Reason,
+			&s.MacroID, &s.Age, &s.Height, &s.Weight, &s.Activity, &s.Objective, &s.CaloriesDistribution,
+			&s.BMRFormula, &s.BMR, &s.TDEE, &s.Goal, &s.Protein, &s.Fats, &s.Carbs, &raw, &m.CreatedAt,
+			&pager.SortValue, &pager.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan macro change: %w", err)
+		}
+		if err = json.Unmarshal(raw, &m.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode macro changes: %w", err)
+		}
+		if !pager.Next() {
+			break
+		}
+		history = append(history, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch macro history: %w", err)
+	}
+
+	pager.SetHeader(ctx)
+	return history, nil
+}
+
+// MacroHistoryService shows how a user's targets evolved and why.
+type MacroHistoryService struct {
+	ctx  context.Context
+	repo MacroHistoryRepository
+}
+
+func NewMacroHistoryService(ctx context.Context, repo MacroHistoryRepository) *MacroHistoryService {
+	return &MacroHistoryService{ctx: ctx, repo: repo}
+}
+
+// authorize lets users read their own history and trainers their clients'.
+func (s *MacroHistoryService) authorize(ctx context.Context, userID string) error {
+	if userID == "" {
+		return status.Error(codes.InvalidArgument, "user id is required")
+	}
+	actor, _ := ctx.Value("userID").(string)
+	if actor == "" || actor == userID {
+		return nil
+	}
+	ok, err := s.repo.IsTrainerOf(ctx, actor, userID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !ok {
+		return status.Error(codes.PermissionDenied, "not a trainer of this user")
+	}
+	return nil
+}
+
+func (s *MacroHistoryService) MacroHistory(ctx context.Context, userID string) ([]MacroChange, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "MacroHistory")
+	defer span.End()
+
+	if err := s.authorize(ctx, userID); err != nil {
+		return nil, err
+	}
+	history, err := s.repo.MacroHistory(ctx, userID)
+	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	span.SetAttributes(attribute.Int("history.entries", len(history)))
+	return history, nil
+}
+
+// CompareMacros diffs any two of the user's distributions.
+func (s *MacroHistoryService) CompareMacros(ctx context.Context, userID, fromID, toID string) (*MacroDiff, error) {
+	if err := s.authorize(ctx, userID); err != nil {
+		return nil, err
+	}
+	if fromID == "" || toID == "" {
+		return nil, status.Error(codes.InvalidArgument, "both macro ids are required")
+	}
+
+	from, err := s.repo.MacroSnapshot(ctx, userID, fromID)
+	if err != nil {
+		return nil, macroLookupError(err)
+	}
+	to, err := s.repo.MacroSnapshot(ctx, userID, toID)
+	if err != nil {
+		return nil, macroLookupError(err)
+	}
+	return &MacroDiff{From: from, To: to, Changes: diffSnapshots(from, to)}, nil
+}
+
+func macroLookupError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status.Error(codes.NotFound, "macro not found")
+	}
+	return status.Errorf(codes.Internal, "failed to fetch macro: %v", err)
+}
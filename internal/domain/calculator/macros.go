@@ -0,0 +1,199 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+)
+
+// MacroMode selects how calories are split between protein, fat and carbs.
+type MacroMode string
+
+const (
+	// MacroModePreset uses the High/Moderate/Low macroRatios presets.
+	MacroModePreset MacroMode = "preset"
+	// MacroModePercent takes exact percentages of calories.
+	MacroModePercent MacroMode = "percent"
+	// MacroModePerKg takes protein and fat in grams per kg of bodyweight and
+	// gives the remaining calories to carbs.
+	MacroModePerKg MacroMode = "per_kg"
+)
+
+// Custom distributions are passed as metadata until the calculator messages
+// carry them:
+//
+//	x-macro-mode:   percent | per_kg
+//	x-macro-ratios: protein=30,fat=25,carbs=45   (percent of calories)
+//	x-macro-per-kg: protein=2.0,fat=0.8          (grams per kg)
+const (
+	macroModeHeader   = "x-macro-mode"
+	macroRatiosHeader = "x-macro-ratios"
+	macroPerKgHeader  = "x-macro-per-kg"
+)
+
+const (
+	ratioSumTolerance = 0.005
+	maxProteinPerKg   = 4.4
+	maxFatPerKg       = 2.5
+)
+
+// CustomMacros is a trainer-defined distribution. Ratios are fractions of
+// calories; in per_kg mode they are derived once the calorie target is known.
+type CustomMacros struct {
+	Mode         MacroMode
+	ProteinRatio float64
+	FatRatio     float64
+	CarbRatio    float64
+	ProteinPerKg float64
+	FatPerKg     float64
+}
+
+func (c CustomMacros) Validate() error {
+	switch c.Mode {
+	case MacroModePercent:
+		for name, r := range map[string]float64{"protein": c.ProteinRatio, "fat": c.FatRatio, "carbs": c.CarbRatio} {
+			if r < 0 || r > 1 {
+				return fmt.Errorf("%s share must be between 0 and 100 percent", name)
+			}
+		}
+		if sum := c.ProteinRatio + c.FatRatio + c.CarbRatio; math.Abs(sum-1) > ratioSumTolerance {
+			return fmt.Errorf("macro shares must sum to 100 percent, got %.1f", sum*100)
+		}
+	case MacroModePerKg:
+		if c.ProteinPerKg <= 0 || c.ProteinPerKg > maxProteinPerKg {
+			return fmt.Errorf("protein must be between 0 and %.1f g/kg", maxProteinPerKg)
+		}
+		if c.FatPerKg <= 0 || c.FatPerKg > maxFatPerKg {
+			return fmt.Errorf("fat must be between 0 and %.1f g/kg", maxFatPerKg)
+		}
+	default:
+		return fmt.Errorf("unknown macro mode %q", c.Mode)
+	}
+	return nil
+}
+
+// resolve returns the macros for calorieGoal and fills in the ratios for
+// per_kg mode, so the stored distribution always sums to 100%.
+func (c *CustomMacros) resolve(calorieGoal, weightKg float64) (Macros, error) {
+	if calorieGoal <= 0 {
+		return Macros{}, fmt.Errorf("calorie target must be positive")
+	}
+
+	if c.Mode == MacroModePerKg {
+		protein := c.ProteinPerKg * weightKg
+		fat := c.FatPerKg * weightKg
+		used := protein*float64(proteinGramValue) + fat*float64(fatGramValue)
+		if used > calorieGoal {
+			return Macros{}, fmt.Errorf("protein and fat targets need %.0f kcal, more than the %.0f kcal target", used, calorieGoal)
+		}
+		c.ProteinRatio = protein * float64(proteinGramValue) / calorieGoal
+		c.FatRatio = fat * float64(fatGramValue) / calorieGoal
+		c.CarbRatio = 1 - c.ProteinRatio - c.FatRatio
+	}
+
+	return Macros{
+		Protein: uint16(calculateMacroDistribution(c.ProteinRatio, calorieGoal, proteinGramValue)),
+		Fats:    uint16(calculateMacroDistribution(c.FatRatio, calorieGoal, fatGramValue)),
+		Carbs:   uint16(calculateMacroDistribution(c.CarbRatio, calorieGoal, carbGramValue)),
+	}, nil
+}
+
+// Description mirrors the wording of the preset descriptions.
+func (c CustomMacros) Description() CaloriesDistributionDescription {
+	if c.Mode == MacroModePerKg {
+		return CaloriesDistributionDescription(fmt.Sprintf(
+			"Custom diet. Protein %.1f g/kg, Fats %.1f g/kg, rest Carbs (%.2f, %.2f, %.2f)",
+			c.ProteinPerKg, c.FatPerKg, c.ProteinRatio, c.FatRatio, c.CarbRatio))
+	}
+	return CaloriesDistributionDescription(fmt.Sprintf(
+		"Custom diet. Protein %.2f, Fats %.2f, Carbs %.2f", c.ProteinRatio, c.FatRatio, c.CarbRatio))
+}
+
+func (c *CustomMacros) options() *domain.CustomMacroOptions {
+	if c == nil {
+		return nil
+	}
+	return &domain.CustomMacroOptions{
+		Mode:         string(c.Mode),
+		ProteinRatio: c.ProteinRatio,
+		FatRatio:     c.FatRatio,
+		CarbRatio:    c.CarbRatio,
+		ProteinPerKg: c.ProteinPerKg,
+		FatPerKg:     c.FatPerKg,
+	}
+}
+
+// parseMacroPairs reads "protein=30,fat=25,carbs=45".
+func parseMacroPairs(raw string) (map[string]float64, error) {
+	values := make(map[string]float64)
+	for _, part := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed macro value %q", part)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed macro value %q", part)
+		}
+		key := strings.ToLower(strings.TrimSpace(k))
+		if key == "carb" {
+			key = "carbs"
+		}
+		if key == "fats" {
+			key = "fat"
+		}
+		values[key] = n
+	}
+	return values, nil
+}
+
+// customMacrosFromContext returns nil when the preset distribution is used.
+func customMacrosFromContext(ctx context.Context) (*CustomMacros, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	get := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	mode := MacroMode(strings.ToLower(get(macroModeHeader)))
+	if mode == "" || mode == MacroModePreset {
+		return nil, nil
+	}
+
+	custom := &CustomMacros{Mode: mode}
+	switch mode {
+	case MacroModePercent:
+		values, err := parseMacroPairs(get(macroRatiosHeader))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		custom.ProteinRatio = values["protein"] / 100
+		custom.FatRatio = values["fat"] / 100
+		custom.CarbRatio = values["carbs"] / 100
+	case MacroModePerKg:
+		values, err := parseMacroPairs(get(macroPerKgHeader))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		custom.ProteinPerKg = values["protein"]
+		custom.FatPerKg = values["fat"]
+	}
+
+	if err := custom.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return custom, nil
+}
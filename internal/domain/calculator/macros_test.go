@@ -0,0 +1,67 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCustomMacrosValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  CustomMacros
+		wantErr bool
+	}{
+		{"percent sums to 100", CustomMacros{Mode: MacroModePercent, ProteinRatio: 0.3, FatRatio: 0.25, CarbRatio: 0.45}, false},
+		{"percent under 100", CustomMacros{Mode: MacroModePercent, ProteinRatio: 0.3, FatRatio: 0.25, CarbRatio: 0.4}, true},
+		{"percent negative share", CustomMacros{Mode: MacroModePercent, ProteinRatio: 0.6, FatRatio: 0.5, CarbRatio: -0.1}, true},
+		{"per kg", CustomMacros{Mode: MacroModePerKg, ProteinPerKg: 2, FatPerKg: 0.8}, false},
+		{"per kg missing fat", CustomMacros{Mode: MacroModePerKg, ProteinPerKg: 2}, true},
+		{"per kg protein too high", CustomMacros{Mode: MacroModePerKg, ProteinPerKg: 6, FatPerKg: 0.8}, true},
+		{"unknown mode", CustomMacros{Mode: "zone"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.custom.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCustomMacrosPerKgGivesRestToCarbs(t *testing.T) {
+	custom := CustomMacros{Mode: MacroModePerKg, ProteinPerKg: 2, FatPerKg: 0.8}
+	macros, err := custom.resolve(2500, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 160 g protein (640 kcal) + 64 g fat (576 kcal) leaves 1284 kcal of carbs
+	if macros.Protein != 160 || macros.Fats != 64 || macros.Carbs != 321 {
+		t.Errorf("macros = %+v, want 160/64/321", macros)
+	}
+	if sum := custom.ProteinRatio + custom.FatRatio + custom.CarbRatio; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("ratios sum to %v, want 1", sum)
+	}
+}
+
+func TestCustomMacrosPerKgExceedsTarget(t *testing.T) {
+	custom := CustomMacros{Mode: MacroModePerKg, ProteinPerKg: 3, FatPerKg: 2}
+	if _, err := custom.resolve(1500, 100); err == nil {
+		t.Error("expected an error when protein and fat exceed the calorie target")
+	}
+}
+
+func TestParseMacroPairs(t *testing.T) {
+	values, err := parseMacroPairs("protein=30, fats=25,carb=45")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values["protein"] != 30 || values["fat"] != 25 || values["carbs"] != 45 {
+		t.Errorf("values = %v", values)
+	}
+	if _, err := parseMacroPairs("protein:30"); err == nil {
+		t.Error("expected an error for a malformed pair")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	pb "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/units"
 )
 
 type UserMacroDistribution struct {
@@ -30,25 +32,39 @@ type UserMacroDistribution struct {
 }
 
 type UserInfo struct {
-	System        string `json:"system" db:"system"`
+	System string `json:"system" db:"system"`
+	// Units is System parsed; UserData is SI and converted with it on output.
+	Units         units.System
 	UserData      UserData
 	ActivityInfo  ActivityInfo
 	ObjectiveInfo ObjectiveInfo
-	BMR           uint16 `json:"bmr" db:"bmr"`
+	BMR           uint16     `json:"bmr" db:"bmr"`
+	BMRFormula    BMRFormula `json:"bmrFormula" db:"bmr_formula"`
+	BMRComparison []BMREstimate
 	TDEE          uint16 `json:"tdee" db:"tdee"`
 	MacrosInfo    MacrosInfo
 	Goal          uint16 `json:"dietGoal" db:"goal"`
 }
 
+// UserParams are the values as entered: Height is centimetres or inches and
+// Weight kilograms or pounds, depending on System.
 type UserParams struct {
-	Age          uint16 `json:"age" db:"age"`
-	Height       uint16 `json:"height" db:"height"`
-	Weight       uint16 `json:"weight" db:"weight"`
-	Gender       string `json:"gender" db:"gender"`
-	System       string `json:"system" db:"system"`
-	Activity     string `json:"activity" db:"activity"`
-	Objective    string `json:"objective" db:"objective"`
-	CaloriesDist string `json:"calories-distribution" db:"calorie_distribution"`
+	Age    uint16  `json:"age" db:"age"`
+	Height float64 `json:"height" db:"height"`
+	// HeightInput is a free-form height such as 5'11" and wins over Height.
+	HeightInput  string  `json:"height-input"`
+	Weight       float64 `json:"weight" db:"weight"`
+	Gender       string  `json:"gender" db:"gender"`
+	System       string  `json:"system" db:"system"`
+	Activity     string  `json:"activity" db:"activity"`
+	Objective    string  `json:"objective" db:"objective"`
+	CaloriesDist string  `json:"calories-distribution" db:"calorie_distribution"`
+	// BMRFormula defaults to Mifflin-St Jeor; BodyFatPercent is required by
+	// the lean mass formulas.
+	BMRFormula     BMRFormula `json:"bmr-formula" db:"bmr_formula"`
+	BodyFatPercent float64    `json:"body-fat-percent" db:"body_fat_percent"`
+	// CustomMacros overrides CaloriesDist when set.
+	CustomMacros *CustomMacros `json:"custom-macros"`
 }
 
 type Goals struct {
@@ -71,9 +87,10 @@ type SystemList struct {
 	System System `json:"metric"`
 }
 
+// UserData is in SI: Height in centimetres and Weight in kilograms.
 type UserData struct {
 	Age    uint16  `json:"age"`
-	Height uint16  `json:"height"`
+	Height float64 `json:"height"`
 	Weight float64 `json:"weight"`
 	Gender string  `json:"gender"`
 }
@@ -151,9 +168,9 @@ const (
 const (
 	minAge                                 = 0
 	maxAge                                 = 100
-	minHeight                              = 0
+	minHeight                              = 50
 	maxHeight                              = 250
-	minWeight                              = 0
+	minWeight                              = 20
 	maxWeight                              = 500
 	maleAgeFactor                          = 5
 	femaleAgeFactor                        = -161
@@ -0,0 +1,138 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Goal plans replace the fixed caloricDeficit/caloricPlus with the daily
+// adjustment needed to reach a target weight by a target date:
+//
+//	weekly rate      = (target - current) / weeks left
+//	daily adjustment = weekly rate * kcalPerKg / 7
+//
+// The rate is not capped: an aggressive plan is kept but flagged unsafe. The
+// daily goal never drops below minDailyCalories, and the projection follows
+// what that goal actually achieves.
+
+const (
+	maxLossPercentPerWeek = 1.0
+	maxGainPercentPerWeek = 0.5
+	minDailyCalories      = 1200.0
+	minPlanDays           = 7
+)
+
+var errPlanExpired = errors.New("target date has passed")
+
+type ProjectionPoint struct {
+	Day    time.Time `json:"day"`
+	Weight float64   `json:"weight"`
+}
+
+type GoalPlan struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	MacroID       string    `json:"macro_id"`
+	StartWeight   float64   `json:"start_weight"`
+	CurrentWeight float64   `json:"current_weight"`
+	TargetWeight  float64   `json:"target_weight"`
+	TargetDate    time.Time `json:"target_date"`
+	// WeeklyRate is in kg per week, negative when losing.
+	WeeklyRate float64 `json:"weekly_rate"`
+	// DailyAdjustment is the kcal added to (or taken from) TDEE.
+	DailyAdjustment float64           `json:"daily_adjustment"`
+	TDEE            float64           `json:"tdee"`
+	DailyGoal       uint16            `json:"daily_goal"`
+	Safe            bool              `json:"safe"`
+	Warnings        []string          `json:"warnings"`
+	Projection      []ProjectionPoint `json:"projection"`
+	Active          bool              `json:"active"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// planGoal fills in the rate, daily goal, warnings and projection of plan
+// from its current weight and TDEE as of now.
+func planGoal(plan *GoalPlan, now time.Time) error {
+	if plan.CurrentWeight <= 0 || plan.TargetWeight <= 0 {
+		return fmt.Errorf("weights must be positive")
+	}
+	if plan.TDEE <= 0 {
+		return fmt.Errorf("TDEE must be positive")
+	}
+
+	today := day(now)
+	days := int(day(plan.TargetDate).Sub(today).Hours() / 24)
+	if days <= 0 {
+		return errPlanExpired
+	}
+	// close to the deadline the remaining change is spread over a week rather
+	// than demanded in a day or two
+	days = max(days, minPlanDays)
+
+	change := plan.TargetWeight - plan.CurrentWeight
+	plan.WeeklyRate = round2(change / float64(days) * 7)
+	plan.Warnings = nil
+	plan.Safe = true
+
+	ratePercent := plan.WeeklyRate / plan.CurrentWeight * 100
+	switch {
+	case ratePercent < -maxLossPercentPerWeek:
+		plan.Safe = false
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"losing %.2f kg per week is more than %.1f%% of bodyweight; consider a later target date",
+			-plan.WeeklyRate, maxLossPercentPerWeek))
+	case ratePercent > maxGainPercentPerWeek:
+		plan.Safe = false
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"gaining %.2f kg per week is more than %.1f%% of bodyweight; most of it will be fat",
+			plan.WeeklyRate, maxGainPercentPerWeek))
+	}
+
+	goal := plan.TDEE + plan.WeeklyRate*kcalPerKg/7
+	if goal < minDailyCalories {
+		plan.Safe = false
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"the plan needs %.0f kcal a day; the goal is held at %.0f kcal so the target date will be missed",
+			goal, minDailyCalories))
+		goal = minDailyCalories
+	}
+	goal = math.Round(goal)
+	plan.DailyGoal = uint16(goal)
+	plan.DailyAdjustment = goal - plan.TDEE
+
+	plan.Projection = project(plan.CurrentWeight, plan.DailyAdjustment, today, day(plan.TargetDate))
+	return nil
+}
+
+// project returns weekly points from start to end, always including end, for
+// a steady daily energy surplus (or deficit) of adjustment kcal.
+func project(weight, adjustment float64, start, end time.Time) []ProjectionPoint {
+	perDay := adjustment / kcalPerKg
+	var points []ProjectionPoint
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 7) {
+		days := d.Sub(start).Hours() / 24
+		points = append(points, ProjectionPoint{Day: d, Weight: round2(weight + perDay*days)})
+	}
+	days := end.Sub(start).Hours() / 24
+	return append(points, ProjectionPoint{Day: end, Weight: round2(weight + perDay*days)})
+}
+
+// rescale keeps the calorie split of the current macro distribution at a new
+// daily goal.
+func (m CurrentMacro) rescale(goal uint16) Macros {
+	p := float64(m.Protein) * float64(proteinGramValue)
+	f := float64(m.Fats) * float64(fatGramValue)
+	c := float64(m.Carbs) * float64(carbGramValue)
+	total := p + f + c
+	if total == 0 {
+		return calculateMacroNutrients(float64(goal), ModerateCarbRatios)
+	}
+	return Macros{
+		Protein: uint16(calculateMacroDistribution(p/total, float64(goal), proteinGramValue)),
+		Fats:    uint16(calculateMacroDistribution(f/total, float64(goal), fatGramValue)),
+		Carbs:   uint16(calculateMacroDistribution(c/total, float64(goal), carbGramValue)),
+	}
+}
@@ -0,0 +1,86 @@
+package calculator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanGoal(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		current   float64
+		target    float64
+		days      int
+		tdee      float64
+		wantRate  float64
+		wantGoal  uint16
+		wantSafe  bool
+		wantWarns int
+	}{
+		{"steady cut", 80, 75, 70, 2500, -0.5, 1950, true, 0},
+		{"aggressive cut", 80, 76, 28, 2500, -1, 1400, false, 1},
+		{"cut below floor", 60, 50, 35, 1800, -2, 1200, false, 2},
+		{"lean bulk", 70, 72, 70, 2600, 0.2, 2820, true, 0},
+		{"fast bulk", 70, 76, 42, 2600, 1, 3700, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &GoalPlan{
+				CurrentWeight: tt.current,
+				TargetWeight:  tt.target,
+				TargetDate:    now.AddDate(0, 0, tt.days),
+				TDEE:          tt.tdee,
+			}
+			if err := planGoal(plan, now); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if plan.WeeklyRate != tt.wantRate {
+				t.Errorf("weekly rate = %v, want %v", plan.WeeklyRate, tt.wantRate)
+			}
+			if plan.DailyGoal != tt.wantGoal {
+				t.Errorf("daily goal = %v, want %v", plan.DailyGoal, tt.wantGoal)
+			}
+			if plan.Safe != tt.wantSafe || len(plan.Warnings) != tt.wantWarns {
+				t.Errorf("safe = %v with %d warnings %v, want %v with %d", plan.Safe, len(plan.Warnings), plan.Warnings, tt.wantSafe, tt.wantWarns)
+			}
+		})
+	}
+}
+
+func TestPlanGoalProjection(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	plan := &GoalPlan{CurrentWeight: 80, TargetWeight: 75, TargetDate: now.AddDate(0, 0, 70), TDEE: 2500}
+	if err := planGoal(plan, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plan.Projection) != 11 {
+		t.Fatalf("got %d projection points, want 11", len(plan.Projection))
+	}
+	if first := plan.Projection[0]; first.Weight != 80 || !first.Day.Equal(now) {
+		t.Errorf("first point = %+v", first)
+	}
+	if last := plan.Projection[10]; last.Weight != 75 {
+		t.Errorf("last point = %+v, want 75 kg", last)
+	}
+}
+
+func TestPlanGoalExpired(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	plan := &GoalPlan{CurrentWeight: 80, TargetWeight: 75, TargetDate: now, TDEE: 2500}
+	if err := planGoal(plan, now); !errors.Is(err, errPlanExpired) {
+		t.Errorf("err = %v, want errPlanExpired", err)
+	}
+}
+
+func TestRescaleKeepsSplit(t *testing.T) {
+	macro := CurrentMacro{Protein: 150, Fats: 67, Carbs: 250}
+	got := macro.rescale(1800)
+	if got.Protein != 123 || got.Fats != 55 || got.Carbs != 204 {
+		t.Errorf("rescaled = %+v", got)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
 )
 
@@ -124,7 +125,7 @@ func (c *CalculatorRepository) GetUserMacros(ctx context.Context, req *pbc.GetUs
 	return &pbc.GetUserMacroResponse{UserMacro: &macroDistribution}, nil
 }
 
-func (c *CalculatorRepository) CreateUserMacro(ctx context.Context, req *pbc.CreateUserMacroRequest) (*pbc.UserMacroDistribution, error) {
+func (c *CalculatorRepository) CreateUserMacro(ctx context.Context, req *pbc.CreateUserMacroRequest, opts domain.UserMacroOptions) (*pbc.UserMacroDistribution, error) {
 	// Start a transaction if you want to ensure atomic update
 	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
@@ -153,14 +154,26 @@ func (c *CalculatorRepository) CreateUserMacro(ctx context.Context, req *pbc.Cre
     INSERT INTO user_macro_distribution (
       user_id, age, height, weight, gender, system, activity, activity_description,
       objective, objective_description, calories_distribution, calories_distribution_description,
-      protein, fats, carbs, bmr, tdee, goal, created_at, is_current
+      protein, fats, carbs, bmr, tdee, goal, created_at, is_current, bmr_formula, body_fat_percent,
+      distribution_mode, custom_protein_ratio, custom_fat_ratio, custom_carb_ratio, protein_g_per_kg, fat_g_per_kg
     )
-    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,now(),$19)
+    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,now(),$19,COALESCE(NULLIF($20, ''), 'mifflin_st_jeor'),$21,
+      $22,$23,$24,$25,$26,$27)
     RETURNING
       id, user_id, age, height, weight, gender, system, activity, activity_description,
       objective, objective_description, calories_distribution, calories_distribution_description,
       protein, fats, carbs, bmr, tdee, goal, created_at, is_current`
 
+	mode := "preset"
+	var proteinRatio, fatRatio, carbRatio, proteinPerKg, fatPerKg *float64
+	if c := opts.CustomMacros; c != nil {
+		mode = c.Mode
+		proteinRatio, fatRatio, carbRatio = &c.ProteinRatio, &c.FatRatio, &c.CarbRatio
+		if c.Mode == "per_kg" {
+			proteinPerKg, fatPerKg = &c.ProteinPerKg, &c.FatPerKg
+		}
+	}
+
 	var macro pbc.UserMacroDistribution
 	var createdAt time.Time
 	var isCurrent bool
@@ -170,6 +183,8 @@ func (c *CalculatorRepository) CreateUserMacro(ctx context.Context, req *pbc.Cre
 		userMacro.ActivityDescription, userMacro.Objective, userMacro.ObjectiveDescription,
 		userMacro.CaloriesDistribution, userMacro.CaloriesDistributionDescription,
 		userMacro.Protein, userMacro.Fats, userMacro.Carbs, userMacro.Bmr, userMacro.Tdee, userMacro.Goal, req.IsCurrent,
+		opts.BMRFormula, opts.BodyFatPercent,
+		mode, proteinRatio, fatRatio, carbRatio, proteinPerKg, fatPerKg,
 	)
 
 	err = row.Scan(
@@ -187,6 +202,10 @@ func (c *CalculatorRepository) CreateUserMacro(ctx context.Context, req *pbc.Cre
 	macro.CreatedAt = timestamppb.New(createdAt)
 	req.IsCurrent = isCurrent
 
+	if err = recordMacroChange(ctx, tx, macro.UserId, macro.Id, opts.Change); err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -215,7 +234,7 @@ func (c *CalculatorRepository) DeleteUserMacro(ctx context.Context, req *pbc.Del
 	return &pbc.DeleteUserMacroResponse{}, nil
 }
 
-func (c *CalculatorRepository) SetActiveUserMacro(ctx context.Context, userID, macroID string) (*pbc.UserMacroDistribution, error) {
+func (c *CalculatorRepository) SetActiveUserMacro(ctx context.Context, userID, macroID string, change domain.MacroChange) (*pbc.UserMacroDistribution, error) {
 	tx, err := c.pgpool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to start transaction: %w", err)
@@ -262,6 +281,10 @@ func (c *CalculatorRepository) SetActiveUserMacro(ctx context.Context, userID, m
 	}
 	macro.CreatedAt = timestamppb.New(createdAt)
 
+	if err = recordMacroChange(ctx, tx, userID, macroID, change); err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -14,10 +14,12 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/units"
 )
 
 type CalculatorService struct {
@@ -132,39 +134,27 @@ func ValidateWeight(value, minValue, maxValue float64, fieldName string) (float6
 //	return height, nil
 //}
 
-func convertWeight(weight float64, system System) float64 {
-	if system == Metric {
-		return weight
-	}
-	return float64(weight) / 0.453592 // 1 lb = 0.453592 kg
-}
-func convertHeight(height uint16, system System) float64 {
-	if system == Metric {
-		return float64(height)
-	}
-	return float64(height) / 2.54 // 1 in = 2.54 cm
-}
-func CalculateBMR(userData UserData, system System) (float64, error) {
+// CalculateBMR is Mifflin-St Jeor. userData is SI, so there is a single
+// metric equation; imperial inputs are converted at the API boundary.
+func CalculateBMR(userData UserData) (float64, error) {
 	if userData.Weight <= 0 || userData.Height <= 0 || userData.Age <= 0 {
 		return 0, errors.New("weight, height, and age must be positive values")
 	}
 
 	var ageFactor float64
-	weight := convertWeight(userData.Weight, system)
-	height := convertHeight(userData.Height, system)
-	if userData.Gender == m {
+	weight := userData.Weight
+	height := userData.Height
+	male, err := isMale(userData.Gender)
+	if err != nil {
+		return 0, err
+	}
+	if male {
 		ageFactor = maleAgeFactor
-	} else if userData.Gender == f {
-		ageFactor = femaleAgeFactor
 	} else {
-		return 0, errors.New("gender must be 'male' or 'female'")
+		ageFactor = femaleAgeFactor
 	}
 
-	if system == Metric {
-		return math.Round((10*weight + 6.25*height - 5.0*(float64(userData.Age))) + ageFactor), nil
-	} else {
-		return math.Round((4.536*weight + 15.88*height - 5.0*(float64(userData.Age))) + ageFactor), nil
-	}
+	return math.Round((10*weight + 6.25*height - 5.0*(float64(userData.Age))) + ageFactor), nil
 }
 
 func calculateTDEE(bmr float64, activityValue ActivityValues) float64 {
@@ -231,7 +221,11 @@ func calculateUserPersonalMacros(ctx context.Context, params UserParams) (UserIn
 		return UserInfo{}, fmt.Errorf("invalid objective: %s", params.Objective)
 	}
 
-	bmr, err := CalculateBMR(userData, System(params.System))
+	formula, err := ParseBMRFormula(string(params.BMRFormula))
+	if err != nil {
+		return UserInfo{}, err
+	}
+	bmr, err := CalculateBMRWith(formula, userData, params.BodyFatPercent)
 	if err != nil {
 		return UserInfo{}, err
 	}
@@ -258,9 +252,23 @@ func calculateUserPersonalMacros(ctx context.Context, params UserParams) (UserIn
 	tdee := calculateTDEE(bmr, v)
 	goal := getGoal(tdee, Objective(params.Objective))
 
+	comparison, err := compareBMR(userData, params.BodyFatPercent, v)
+	if err != nil {
+		return UserInfo{}, err
+	}
+
 	macros := calculateMacroNutrients(tdee, CaloriesDistribution(params.CaloriesDist))
+	if params.CustomMacros != nil {
+		macros, err = params.CustomMacros.resolve(tdee, userData.Weight)
+		if err != nil {
+			return UserInfo{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+		d.CaloriesDistributionDescription = params.CustomMacros.Description()
+	}
+	system, _ := units.ParseSystem(params.System)
 	return UserInfo{
 		System: params.System,
+		Units:  system,
 		UserData: UserData{
 			Age:    userData.Age,
 			Height: userData.Height,
@@ -275,8 +283,10 @@ func calculateUserPersonalMacros(ctx context.Context, params UserParams) (UserIn
 			Objective:   o.Objective,
 			Description: o.Description,
 		},
-		BMR:  uint16(bmr),
-		TDEE: uint16(tdee),
+		BMR:           uint16(bmr),
+		BMRFormula:    formula,
+		BMRComparison: comparison,
+		TDEE:          uint16(tdee),
 		MacrosInfo: MacrosInfo{
 			CaloriesInfo: CaloriesInfo{
 				CaloriesDistribution:            d.CaloriesDistribution,
@@ -298,11 +308,22 @@ func validateUserInput(ctx context.Context, params UserParams) (UserData, error)
 		if err != nil {
 			return UserData{}, err
 		}
-		validHeight, err := ValidateValues(params.Height, minHeight, maxHeight, "height")
+		system, err := units.ParseSystem(params.System)
 		if err != nil {
 			return UserData{}, err
 		}
-		validWeight, err := ValidateWeight(float64(params.Weight), minHeight, maxHeight, "weight")
+		height := system.Length(params.Height)
+		if params.HeightInput != "" {
+			height, err = units.ParseLength(params.HeightInput, system)
+			if err != nil {
+				return UserData{}, err
+			}
+		}
+		validHeight, err := ValidateWeight(round1(height.Centimetres()), minHeight, maxHeight, "height (cm)")
+		if err != nil {
+			return UserData{}, err
+		}
+		validWeight, err := ValidateWeight(round2(system.Mass(params.Weight).Kilograms()), minWeight, maxWeight, "weight (kg)")
 		if err != nil {
 			return UserData{}, err
 		}
@@ -332,18 +353,31 @@ func (s *CalculatorService) CreateUserMacro(ctx context.Context, req *pb.CreateU
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	formula, bodyFat, err := bmrOptionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	customMacros, err := customMacrosFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	params := UserParams{
-		Age:      uint16(req.UserMacro.Age),
-		Height:   uint16(req.UserMacro.Height),
-		Weight:   uint16(req.UserMacro.Weight),
-		Gender:   strings.ToLower(parsedGender.String()),
-		System:   req.UserMacro.System.String(),
-		Activity: req.UserMacro.Activity.String(),
+		Age:         uint16(req.UserMacro.Age),
+		Height:      float64(req.UserMacro.Height),
+		HeightInput: heightInputFromContext(ctx),
+		Weight:      req.UserMacro.Weight,
+		Gender:      strings.ToLower(parsedGender.String()),
+		System:      req.UserMacro.System.String(),
+		Activity:    req.UserMacro.Activity.String(),
 		//ActivityDesc:     req.UserMacro.ActivityDescription,
 		Objective: req.UserMacro.Objective.String(),
 		//ObjectiveDesc:    req.UserMacro.ObjectiveDescription,
 		CaloriesDist: string(req.UserMacro.CaloriesDistribution),
 		//CaloriesDistDesc: req.UserMacro.CaloriesDistributionDescription,
+		BMRFormula:     formula,
+		BodyFatPercent: bodyFat,
+		CustomMacros:   customMacros,
 	}
 
 	// Perform the offline calculations
@@ -370,7 +404,7 @@ func (s *CalculatorService) CreateUserMacro(ctx context.Context, req *pb.CreateU
 		Id:                              req.UserMacro.Id,
 		UserId:                          req.UserMacro.UserId,
 		Age:                             uint32(userInfo.UserData.Age),
-		Height:                          uint32(userInfo.UserData.Height),
+		Height:                          uint32(math.Round(userInfo.UserData.Height)),
 		Weight:                          userInfo.UserData.Weight,
 		Gender:                          req.UserMacro.Gender,
 		System:                          system,
@@ -389,11 +423,26 @@ func (s *CalculatorService) CreateUserMacro(ctx context.Context, req *pb.CreateU
 		CreatedAt:                       createdAt,
 	}
 
+	change, err := s.macroChange(ctx, req.UserMacro.UserId)
+	if err != nil {
+		return nil, err
+	}
+
 	req = &pb.CreateUserMacroRequest{
 		UserMacro: macroDistribution,
+		IsCurrent: req.IsCurrent,
+	}
+
+	opts := domain.UserMacroOptions{
+		BMRFormula:   string(userInfo.BMRFormula),
+		CustomMacros: customMacros.options(),
+		Change:       change,
+	}
+	if bodyFat > 0 {
+		opts.BodyFatPercent = &bodyFat
 	}
 
-	savedMacro, err := s.repo.CreateUserMacro(ctx, req)
+	savedMacro, err := s.repo.CreateUserMacro(ctx, req, opts)
 	if err != nil {
 		span.RecordError(err)
 		span.SetAttributes(attribute.String("error.type", fmt.Sprintf("%T", err)))
@@ -401,9 +450,11 @@ func (s *CalculatorService) CreateUserMacro(ctx context.Context, req *pb.CreateU
 	}
 
 	response := &pb.CreateUserMacroResponse{
-		UserMacro: savedMacro,
+		UserMacro: displayMacro(savedMacro),
 	}
 
+	setBMRComparisonHeader(ctx, userInfo.BMRComparison)
+
 	span.SetAttributes(
 		attribute.String("request.id", req.UserMacro.Id),
 		attribute.String("request.details", req.UserMacro.Id),
@@ -425,6 +476,7 @@ func (s *CalculatorService) GetUsersMacros(ctx context.Context, req *pb.GetAllUs
 	response := &pb.GetAllUserMacrosResponse{}
 	createdAt := timestamppb.New(time.Now())
 	for _, macro := range userMacrosResponse.UserMacros {
+		macro = displayMacro(macro)
 		response.UserMacros = append(response.UserMacros, &pb.UserMacroDistribution{
 			Id:                              macro.Id,
 			UserId:                          macro.UserId,
@@ -458,9 +510,6 @@ func (s *CalculatorService) GetUsersMacros(ctx context.Context, req *pb.GetAllUs
 // GetUserMacros implements the GetUserMacro gRPC method
 func (s *CalculatorService) GetUserMacros(ctx context.Context, req *pb.GetUserMacroRequest) (*pb.GetUserMacroResponse, error) {
 	macro, err := s.repo.GetUserMacros(ctx, req)
-	createdAt := timestamppb.New(time.Now())
-
-	macro.UserMacro.CreatedAt = createdAt
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Error(codes.NotFound, "macro not found")
@@ -468,6 +517,8 @@ func (s *CalculatorService) GetUserMacros(ctx context.Context, req *pb.GetUserMa
 		return nil, status.Errorf(codes.Internal, "failed to retrieve user macro: %v", err)
 	}
 
+	macro.UserMacro = displayMacro(macro.UserMacro)
+	macro.UserMacro.CreatedAt = timestamppb.New(time.Now())
 	return macro, nil
 }
 
@@ -477,15 +528,28 @@ func (s *CalculatorService) CreateOfflineUserMacro(ctx context.Context, req *pb.
 		return nil, status.Error(codes.InvalidArgument, "user macro cannot be nil")
 	}
 
+	formula, bodyFat, err := bmrOptionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	customMacros, err := customMacrosFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	params := UserParams{
-		Age:          uint16(req.UserMacro.Age),
-		Height:       uint16(req.UserMacro.Height),
-		Weight:       uint16(req.UserMacro.Weight),
-		Gender:       req.UserMacro.Gender,
-		System:       req.UserMacro.System,
-		Activity:     req.UserMacro.Activity,
-		Objective:    req.UserMacro.Objective,
-		CaloriesDist: string(req.UserMacro.CaloriesDistribution),
+		Age:            uint16(req.UserMacro.Age),
+		Height:         float64(req.UserMacro.Height),
+		HeightInput:    heightInputFromContext(ctx),
+		Weight:         float64(req.UserMacro.Weight),
+		Gender:         req.UserMacro.Gender,
+		System:         req.UserMacro.System,
+		Activity:       req.UserMacro.Activity,
+		Objective:      req.UserMacro.Objective,
+		CaloriesDist:   string(req.UserMacro.CaloriesDistribution),
+		BMRFormula:     formula,
+		BodyFatPercent: bodyFat,
+		CustomMacros:   customMacros,
 	}
 
 	// Perform the offline calculations
@@ -494,12 +558,14 @@ func (s *CalculatorService) CreateOfflineUserMacro(ctx context.Context, req *pb.
 		return nil, fmt.Errorf("failed to calculate user info: %v", err)
 	}
 
+	setBMRComparisonHeader(ctx, userInfo.BMRComparison)
+
 	// Creating response
 	response := &pb.CreateOfflineUserMacroResponse{
 		UserMacro: &pb.OfflineUserMacroDistribution{
 			Age:                             uint32(userInfo.UserData.Age),
-			Height:                          uint32(userInfo.UserData.Height),
-			Weight:                          uint32(userInfo.UserData.Weight),
+			Height:                          uint32(math.Round(userInfo.Units.LengthValue(units.Centimetres(userInfo.UserData.Height)))),
+			Weight:                          uint32(math.Round(userInfo.Units.MassValue(units.Kilograms(userInfo.UserData.Weight)))),
 			Gender:                          req.UserMacro.Gender,
 			System:                          userInfo.System,
 			Activity:                        userInfo.ActivityInfo.Activity,
@@ -521,6 +587,22 @@ func (s *CalculatorService) CreateOfflineUserMacro(ctx context.Context, req *pb.
 	return response, nil
 }
 
+// CompareBMR returns the estimate of every formula for the same inputs, so a
+// client can show them side by side before picking one. Lean mass formulas
+// are included only when params.BodyFatPercent is set.
+func (s *CalculatorService) CompareBMR(ctx context.Context, params UserParams) ([]BMREstimate, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "CompareBMR")
+	defer span.End()
+
+	userInfo, err := calculateUserPersonalMacros(ctx, params)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return userInfo.BMRComparison, nil
+}
+
 func (s *CalculatorService) DeleteUserMacro(ctx context.Context, req *pb.DeleteUserMacroRequest) (*pb.DeleteUserMacroResponse, error) {
 	_, err := s.repo.DeleteUserMacro(ctx, req)
 	if err != nil {
@@ -531,14 +613,39 @@ func (s *CalculatorService) DeleteUserMacro(ctx context.Context, req *pb.DeleteU
 }
 
 func StringToSystemEnum(s string) (pb.System, error) {
-	switch s {
-	case "METRIC":
-		return pb.System_METRIC, nil
-	case "IMPERIAL":
-		return pb.System_IMPERIAL, nil
-	default:
+	system, err := units.ParseSystem(s)
+	if err != nil {
 		return pb.System_SYSTEM_UNSPECIFIED, fmt.Errorf("invalid System: %s", s)
 	}
+	if system == units.Imperial {
+		return pb.System_IMPERIAL, nil
+	}
+	return pb.System_METRIC, nil
+}
+
+// displayMacro converts a stored (SI) distribution to the system it was
+// entered in. Height is whole centimetres or inches; weight keeps one decimal.
+func displayMacro(m *pb.UserMacroDistribution) *pb.UserMacroDistribution {
+	if m == nil || m.System != pb.System_IMPERIAL {
+		return m
+	}
+	m.Height = uint32(math.Round(units.Centimetres(float64(m.Height)).Inches()))
+	m.Weight = round1(units.Kilograms(m.Weight).Pounds())
+	return m
+}
+
+// heightHeader carries heights the uint32 height field cannot, such as 5'11"
+// or 180.5cm.
+const heightHeader = "x-height"
+
+// heightInputFromContext reads a free-form height such as 5'11".
+func heightInputFromContext(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(heightHeader); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
 }
 
 func StringToObjectiveEnum(s string) (pb.Objective, error) {
@@ -587,7 +694,13 @@ func (s *CalculatorService) SetActiveUserMacro(ctx context.Context, req *pb.SetA
 		return nil, err
 	}
 
-	currentMacro, err := s.repo.SetActiveUserMacro(ctx, req.GetUserId(), req.GetMacroId())
+	change, err := s.macroChange(ctx, req.GetUserId())
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	currentMacro, err := s.repo.SetActiveUserMacro(ctx, req.GetUserId(), req.GetMacroId(), change)
 	if err != nil {
 		span.RecordError(err)
 		span.SetAttributes(attribute.String("error.type", fmt.Sprintf("%T", err)))
@@ -604,6 +717,23 @@ func (s *CalculatorService) SetActiveUserMacro(ctx context.Context, req *pb.SetA
 	}, nil
 }
 
+// macroChange attributes a change to userID's distributions to the caller,
+// who is either that user or one of their trainers.
+func (s *CalculatorService) macroChange(ctx context.Context, userID string) (domain.MacroChange, error) {
+	actor, _ := ctx.Value("userID").(string)
+	if actor == "" || actor == userID {
+		return domain.MacroChange{Source: string(ChangeByUser), ChangedBy: userID}, nil
+	}
+	ok, err := s.repo.IsTrainerOf(ctx, actor, userID)
+	if err != nil {
+		return domain.MacroChange{}, status.Error(codes.Internal, err.Error())
+	}
+	if !ok {
+		return domain.MacroChange{}, status.Error(codes.PermissionDenied, "not a trainer of this user")
+	}
+	return domain.MacroChange{Source: string(ChangeByTrainer), ChangedBy: actor}, nil
+}
+
 func parseCaloriesDistribution(s string) pb.CaloriesDistribution {
 	switch s {
 	case "CD_UNSPECIFIED":
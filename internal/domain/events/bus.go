@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Bus fans events out to in-process subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+	wildcard []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Type][]Handler)}
+}
+
+// Subscribe registers h for a single event type.
+func (b *Bus) Subscribe(eventType Type, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+// SubscribeAll registers h for every event type.
+func (b *Bus) SubscribeAll(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.wildcard = append(b.wildcard, h)
+}
+
+// Publish runs every matching handler. All handlers are attempted even if one
+// fails; the joined error makes the dispatcher retry the whole event.
+func (b *Bus) Publish(ctx context.Context, evt Event) error {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers[evt.Type])+len(b.wildcard))
+	handlers = append(handlers, b.handlers[evt.Type]...)
+	handlers = append(handlers, b.wildcard...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, evt); err != nil {
+			errs = append(errs, fmt.Errorf("%s handler: %w", evt.Type, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
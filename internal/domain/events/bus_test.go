@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	var calls []string
+	handler := func(name string, err error) Handler {
+		return func(context.Context, Event) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	bus.Subscribe(WorkoutCompleted, handler("points", errors.New("points down")))
+	bus.Subscribe(WorkoutCompleted, handler("feed", nil))
+	bus.Subscribe(WeightLogged, handler("goal plan", nil))
+	bus.SubscribeAll(handler("webhooks", errors.New("webhooks down")))
+
+	err := bus.Publish(context.Background(), Event{Type: WorkoutCompleted})
+	if got := strings.Join(calls, ","); got != "points,feed,webhooks" {
+		t.Errorf("handlers run = %s, want points,feed,webhooks", got)
+	}
+	if err == nil || !strings.Contains(err.Error(), "points down") || !strings.Contains(err.Error(), "webhooks down") {
+		t.Errorf("Publish() error = %v, want both handler errors", err)
+	}
+
+	calls = nil
+	if err = bus.Publish(context.Background(), Event{Type: FoodLogged}); err == nil {
+		t.Error("Publish() = nil, want the wildcard handler's error")
+	}
+	if got := strings.Join(calls, ","); got != "webhooks" {
+		t.Errorf("handlers run = %s, want webhooks", got)
+	}
+}
+
+func TestBusPublishWithoutSubscribers(t *testing.T) {
+	if err := NewBus().Publish(context.Background(), Event{Type: WeightLogged}); err != nil {
+		t.Errorf("Publish() = %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:   time.Second,
+		2:   4 * time.Second,
+		10:  100 * time.Second,
+		17:  289 * time.Second,
+		18:  maxRetryBackoff,
+		100: maxRetryBackoff,
+	} {
+		if got := retryBackoff(attempts); got != want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 50
+	maxRetryBackoff     = 5 * time.Minute
+)
+
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// RedisStream is the stream every event is mirrored to. Empty disables it.
+	RedisStream string
+}
+
+// Dispatcher polls the outbox and delivers pending events to the bus and,
+// optionally, a Redis stream. Delivery is at-least-once: a row is only marked
+// dispatched after every subscriber succeeded.
+type Dispatcher struct {
+	pgpool *pgxpool.Pool
+	redis  *redis.Client
+	bus    *Bus
+	cfg    DispatcherConfig
+}
+
+func NewDispatcher(db *pgxpool.Pool, redis *redis.Client, bus *Bus, cfg DispatcherConfig) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &Dispatcher{pgpool: db, redis: redis, bus: bus, cfg: cfg}
+}
+
+// Run polls until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// drain whole batches before waiting for the next tick
+		for {
+			n, err := d.dispatchBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Log.Error("outbox dispatch failed", zap.Error(err))
+				}
+				break
+			}
+			if n < d.cfg.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	tx, err := d.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	query := `
+		SELECT id, event_type, COALESCE(aggregate_id::text, ''), COALESCE(user_id::text, ''),
+		       payload, attempts, created_at
+		FROM outbox_events
+		WHERE dispatched_at IS NULL AND available_at <= now()
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, query, d.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch pending events: %w", err)
+	}
+
+	var pending []Event
+	for rows.Next() {
+		var evt Event
+		if err = rows.Scan(&evt.ID, &evt.Type, &evt.AggregateID, &evt.UserID,
+			&evt.Payload, &evt.Attempts, &evt.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan event: %w", err)
+		}
+		pending = append(pending, evt)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	for _, evt := range pending {
+		if deliverErr := d.deliver(ctx, evt); deliverErr != nil {
+			logger.Log.Warn("event delivery failed",
+				zap.String("event_id", evt.ID),
+				zap.String("event_type", string(evt.Type)),
+				zap.Int("attempts", evt.Attempts+1),
+				zap.Error(deliverErr))
+
+			_, err = tx.Exec(ctx, `
+				UPDATE outbox_events
+				SET attempts = attempts + 1, last_error = $2, available_at = now() + make_interval(secs => $3)
+				WHERE id = $1`, evt.ID, deliverErr.Error(), retryBackoff(evt.Attempts+1).Seconds())
+		} else {
+			_, err = tx.Exec(ctx, `UPDATE outbox_events SET dispatched_at = now() WHERE id = $1`, evt.ID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to update event %s: %w", evt.ID, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(pending), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, evt Event) error {
+	if err := d.bus.Publish(ctx, evt); err != nil {
+		return err
+	}
+
+	if d.redis == nil || d.cfg.RedisStream == "" {
+		return nil
+	}
+
+	return d.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: d.cfg.RedisStream,
+		Values: map[string]interface{}{
+			"id":           evt.ID,
+			"event_type":   string(evt.Type),
+			"aggregate_id": evt.AggregateID,
+			"user_id":      evt.UserID,
+			"payload":      string(evt.Payload),
+			"created_at":   evt.CreatedAt.Format(time.RFC3339Nano),
+		},
+	}).Err()
+}
+
+// retryBackoff grows quadratically with the attempt count, capped at five minutes.
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(attempts*attempts) * time.Second
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Type string
+
+const (
+	WorkoutCompleted  Type = "WorkoutCompleted"
+	WeightLogged      Type = "WeightLogged"
+	FoodLogged        Type = "FoodLogged"
+	FriendRequestSent Type = "FriendRequestSent"
+)
+
+// Event is a domain event as stored in the outbox and handed to subscribers.
+type Event struct {
+	ID          string          `json:"id" db:"id"`
+	Type        Type            `json:"event_type" db:"event_type"`
+	AggregateID string          `json:"aggregate_id" db:"aggregate_id"`
+	UserID      string          `json:"user_id" db:"user_id"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Decode unmarshals the event payload into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+type WorkoutCompletedPayload struct {
+	SessionID       string    `json:"session_id"`
+	ActivityID      string    `json:"activity_id"`
+	SessionName     string    `json:"session_name"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	DurationSeconds int       `json:"duration_seconds"`
+	CaloriesBurned  int       `json:"calories_burned"`
+}
+
+type WeightLoggedPayload struct {
+	WeightID    string    `json:"weight_id"`
+	WeightValue float64   `json:"weight_value"`
+	LoggedAt    time.Time `json:"logged_at"`
+}
+
+type FoodLoggedPayload struct {
+	FoodLogID string    `json:"food_log_id"`
+	MealID    string    `json:"meal_id"`
+	Quantity  float64   `json:"quantity"`
+	LogDate   time.Time `json:"log_date"`
+}
+
+type FriendRequestSentPayload struct {
+	RequestID  string `json:"request_id"`
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+}
+
+// Handler reacts to a delivered event. Delivery is at-least-once, so handlers
+// must be idempotent.
+type Handler func(ctx context.Context, evt Event) error
+
+// Execer is satisfied by pgx.Tx, *pgxpool.Pool and *pgx.Conn so events can be
+// recorded in whatever transaction the repository already holds.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// NewEvent builds an event with a JSON encoded payload.
+func NewEvent(eventType Type, userID, aggregateID string, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	return Event{
+		Type:        eventType,
+		AggregateID: aggregateID,
+		UserID:      userID,
+		Payload:     data,
+		CreatedAt:   time.Now(),
+	}, nil
+}
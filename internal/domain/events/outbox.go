@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// Record writes evt into the outbox using tx. Call it inside the same
+// transaction as the state change so the event is persisted if and only if
+// the change commits.
+func Record(ctx context.Context, tx Execer, evt Event) error {
+	query := `
+		INSERT INTO outbox_events (event_type, aggregate_id, user_id, payload, created_at)
+		VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5)`
+
+	_, err := tx.Exec(ctx, query, string(evt.Type), evt.AggregateID, evt.UserID, evt.Payload, evt.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record %s event: %w", evt.Type, err)
+	}
+
+	return nil
+}
+
+// RecordNew is a shorthand for NewEvent followed by Record.
+func RecordNew(ctx context.Context, tx Execer, eventType Type, userID, aggregateID string, payload any) error {
+	evt, err := NewEvent(eventType, userID, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+
+	return Record(ctx, tx, evt)
+}
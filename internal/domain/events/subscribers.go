@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const workoutCompletedPoints = 10
+
+// RegisterDefaultSubscribers wires the in-process reactions to domain events.
+func RegisterDefaultSubscribers(bus *Bus, db *pgxpool.Pool) {
+	bus.Subscribe(WorkoutCompleted, awardWorkoutPoints(db))
+	bus.Subscribe(FriendRequestSent, notifyFriendRequest(db))
+}
+
+func awardWorkoutPoints(db *pgxpool.Pool) Handler {
+	return once(db, "award_workout_points", func(ctx context.Context, tx pgx.Tx, evt Event) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_points (user_id, total_points, updated_at)
+			VALUES ($1, $2, now())
+			ON CONFLICT (user_id)
+			DO UPDATE SET total_points = user_points.total_points + EXCLUDED.total_points, updated_at = now()`,
+			evt.UserID, workoutCompletedPoints)
+		return err
+	})
+}
+
+func notifyFriendRequest(db *pgxpool.Pool) Handler {
+	return once(db, "notify_friend_request", func(ctx context.Context, tx pgx.Tx, evt Event) error {
+		var payload FriendRequestSentPayload
+		if err := evt.Decode(&payload); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO notifications (user_id, type, message)
+			VALUES ($1, 'FRIEND_REQUEST', 'You have a new friend request')`, payload.ToUserID)
+		return err
+	})
+}
+
+// txStarter is satisfied by *pgxpool.Pool.
+type txStarter interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// once runs fn in a transaction guarded by processed_events so a redelivered
+// event is only applied a single time per handler.
+func once(db txStarter, name string, fn func(ctx context.Context, tx pgx.Tx, evt Event) error) Handler {
+	return func(ctx context.Context, evt Event) (err error) {
+		tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to start transaction: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback(ctx)
+			}
+		}()
+
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO processed_events (event_id, handler)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`, evt.ID, name)
+		if err != nil {
+			return fmt.Errorf("failed to mark event processed: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return tx.Commit(ctx)
+		}
+
+		if err = fn(ctx, tx, evt); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// processedEvents stands in for the processed_events table: marks only
+// stick when the transaction commits.
+type processedEvents struct {
+	marked map[string]bool
+}
+
+func (p *processedEvents) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	return &processedTx{table: p}, nil
+}
+
+type processedTx struct {
+	pgx.Tx
+	table   *processedEvents
+	pending []string
+}
+
+func (tx *processedTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if !strings.Contains(sql, "processed_events") {
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	}
+	key := args[0].(string) + "/" + args[1].(string)
+	if tx.table.marked[key] {
+		return pgconn.NewCommandTag("INSERT 0 0"), nil
+	}
+	tx.pending = append(tx.pending, key)
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (tx *processedTx) Commit(context.Context) error {
+	for _, key := range tx.pending {
+		tx.table.marked[key] = true
+	}
+	return nil
+}
+
+func (tx *processedTx) Rollback(context.Context) error { return nil }
+
+func TestOnce(t *testing.T) {
+	table := &processedEvents{marked: make(map[string]bool)}
+	runs := 0
+	fail := true
+	h := once(table, "award_workout_points", func(context.Context, pgx.Tx, Event) error {
+		runs++
+		if fail {
+			return errors.New("user_points is locked")
+		}
+		return nil
+	})
+	evt := Event{ID: "e1", Type: WorkoutCompleted}
+
+	// a failed run is rolled back, so the retry applies the event
+	if err := h(context.Background(), evt); err == nil {
+		t.Fatal("failing handler returned nil")
+	}
+	fail = false
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Fatalf("handler ran %d times, want 2", runs)
+	}
+
+	// redelivery after success is a no-op
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("redelivered event ran the handler again: %d runs", runs)
+	}
+
+	// another handler still sees the event
+	other := once(table, "notify_friend_request", func(context.Context, pgx.Tx, Event) error {
+		runs++
+		return nil
+	})
+	if err := other(context.Background(), evt); err != nil || runs != 3 {
+		t.Errorf("second handler: err = %v, runs = %d, want 3", err, runs)
+	}
+}
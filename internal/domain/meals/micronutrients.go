@@ -0,0 +1,224 @@
+package meals
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/gender"
+)
+
+const weekDays = 7
+
+// MicronutrientRepository is implemented by FoodLogRepository.
+type MicronutrientRepository interface {
+	NutrientProfile(ctx context.Context, userID string) (NutrientProfile, error)
+	NutrientOverrides(ctx context.Context, userID string) (map[string]float64, error)
+	SetNutrientOverride(ctx context.Context, userID, nutrient string, amount float64) error
+	DeleteNutrientOverride(ctx context.Context, userID, nutrient string) (bool, error)
+	NutrientIntake(ctx context.Context, userID string, from, to time.Time) (map[string]float64, error)
+}
+
+// NutrientProfile takes age and gender from the current macro distribution,
+// falling back to the personal data for gender. A user with neither gets a
+// zero profile, which ReferenceTargets reads as an adult.
+func (f *FoodLogRepository) NutrientProfile(ctx context.Context, userID string) (NutrientProfile, error) {
+	var p NutrientProfile
+	err := f.pgpool.QueryRow(ctx, `
+		WITH macro AS (
+			SELECT age, gender
+			FROM user_macro_distribution
+			WHERE user_id = $1
+			ORDER BY is_current DESC, created_at DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT age FROM macro), 0),
+		       COALESCE((SELECT gender FROM macro),
+		                (SELECT gender::text FROM user_personal_data WHERE user_id = $1 LIMIT 1), '')`,
+		userID).Scan(&p.Age, &p.Gender)
+	if err != nil {
+		return NutrientProfile{}, fmt.Errorf("failed to fetch nutrient profile: %w", err)
+	}
+	p.Gender = gender.Normalize(p.Gender)
+	return p, nil
+}
+
+func (f *FoodLogRepository) NutrientOverrides(ctx context.Context, userID string) (map[string]float64, error) {
+	rows, err := f.pgpool.Query(ctx, `
+		SELECT nutrient, amount::float8
+		FROM micronutrient_overrides
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nutrient overrides: %w", err)
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]float64)
+	for rows.Next() {
+		var nutrient string
+		var amount float64
+		if err = rows.Scan(&nutrient, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan nutrient override: %w", err)
+		}
+		overrides[nutrient] = amount
+	}
+	return overrides, rows.Err()
+}
+
+func (f *FoodLogRepository) SetNutrientOverride(ctx context.Context, userID, nutrient string, amount float64) error {
+	_, err := f.pgpool.Exec(ctx, `
+		INSERT INTO micronutrient_overrides (user_id, nutrient, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, nutrient)
+		DO UPDATE SET amount = EXCLUDED.amount, updated_at = now()`, userID, nutrient, amount)
+	if err != nil {
+		return fmt.Errorf("failed to save nutrient override: %w", err)
+	}
+	return nil
+}
+
+func (f *FoodLogRepository) DeleteNutrientOverride(ctx context.Context, userID, nutrient string) (bool, error) {
+	tag, err := f.pgpool.Exec(ctx, `
+		DELETE FROM micronutrient_overrides
+		WHERE user_id = $1 AND nutrient = $2`, userID, nutrient)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete nutrient override: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// NutrientIntake sums the micronutrients of the meals logged in [from, to).
+// A food log's quantity is the number of servings of its meal.
+func (f *FoodLogRepository) NutrientIntake(ctx context.Context, userID string, from, to time.Time) (map[string]float64, error) {
+	var fiber, sugar, sodium, potassium, cholesterol float64
+	err := f.pgpool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(fl.quantity * mi.fiber), 0)::float8,
+		       COALESCE(SUM(fl.quantity * mi.sugar), 0)::float8,
+		       COALESCE(SUM(fl.quantity * mi.sodium), 0)::float8,
+		       COALESCE(SUM(fl.quantity * mi.potassium), 0)::float8,
+		       COALESCE(SUM(fl.quantity * mi.cholesterol), 0)::float8
+		FROM food_logs fl
+		JOIN (
+			SELECT meal_id, SUM(fiber) AS fiber, SUM(sugar) AS sugar, SUM(sodium) AS sodium,
+			       SUM(potassium) AS potassium, SUM(cholesterol) AS cholesterol
+			FROM meal_ingredients
+			GROUP BY meal_id
+		) mi ON mi.meal_id = fl.meal_id
+		WHERE fl.user_id = $1 AND fl.log_date >= $2 AND fl.log_date < $3`, userID, from, to).
+		Scan(&fiber, &sugar, &sodium, &potassium, &cholesterol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nutrient intake: %w", err)
+	}
+	return map[string]float64{
+		Fiber:       fiber,
+		Sugar:       sugar,
+		Sodium:      sodium,
+		Potassium:   potassium,
+		Cholesterol: cholesterol,
+	}, nil
+}
+
+// MicronutrientService compares logged fiber, sugar, sodium, potassium and
+// cholesterol with reference intakes for the user's age and gender, or with
+// the user's own targets.
+type MicronutrientService struct {
+	ctx  context.Context
+	repo MicronutrientRepository
+}
+
+func NewMicronutrientService(ctx context.Context, repo MicronutrientRepository) *MicronutrientService {
+	return &MicronutrientService{ctx: ctx, repo: repo}
+}
+
+// Targets returns the user's daily targets, overrides applied.
+func (s *MicronutrientService) Targets(ctx context.Context, userID string) ([]NutrientTarget, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	profile, err := s.repo.NutrientProfile(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	overrides, err := s.repo.NutrientOverrides(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return applyOverrides(ReferenceTargets(profile), overrides), nil
+}
+
+// SetOverride replaces the reference target for one nutrient, for example a
+// lower sodium limit, and returns the resulting targets.
+func (s *MicronutrientService) SetOverride(ctx context.Context, userID, nutrient string, amount float64) ([]NutrientTarget, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if err := validateOverride(nutrient, amount); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.repo.SetNutrientOverride(ctx, userID, nutrient, amount); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.Targets(ctx, userID)
+}
+
+// ClearOverride goes back to the reference target for one nutrient.
+func (s *MicronutrientService) ClearOverride(ctx context.Context, userID, nutrient string) ([]NutrientTarget, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	ok, err := s.repo.DeleteNutrientOverride(ctx, userID, nutrient)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no override for this nutrient")
+	}
+	return s.Targets(ctx, userID)
+}
+
+// DailyReport evaluates the intake logged on date.
+func (s *MicronutrientService) DailyReport(ctx context.Context, userID string, date time.Time) (*NutrientReport, error) {
+	return s.report(ctx, userID, date, 1)
+}
+
+// WeeklyReport evaluates the seven days ending on date against a week's worth
+// of targets, so a salty day can be balanced by lighter ones.
+func (s *MicronutrientService) WeeklyReport(ctx context.Context, userID string, date time.Time) (*NutrientReport, error) {
+	return s.report(ctx, userID, date, weekDays)
+}
+
+func (s *MicronutrientService) report(ctx context.Context, userID string, date time.Time, days int) (*NutrientReport, error) {
+	tracer := otel.Tracer("FitSphere")
+	ctx, span := tracer.Start(ctx, "MicronutrientReport")
+	defer span.End()
+
+	targets, err := s.Targets(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	to := date.UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, 1-days)
+
+	intake, err := s.repo.NutrientIntake(ctx, userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	report := &NutrientReport{From: from, To: to, Days: days, Nutrients: evaluateIntake(targets, intake, days)}
+	flagged := 0
+	for _, n := range report.Nutrients {
+		if n.Status != IntakeOK {
+			flagged++
+		}
+	}
+	span.SetAttributes(attribute.Int("report.days", days), attribute.Int("report.flagged", flagged))
+	return report, nil
+}
@@ -0,0 +1,204 @@
+package meals
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/gender"
+)
+
+// Micronutrients tracked against reference intakes. Ingredients store fiber
+// and sugar in grams, the rest in milligrams, and so do the targets.
+const (
+	Fiber       = "fiber"
+	Sugar       = "sugar"
+	Sodium      = "sodium"
+	Potassium   = "potassium"
+	Cholesterol = "cholesterol"
+)
+
+// NutrientKind says which side of the target is a problem: a MINIMUM is an
+// intake to reach, a LIMIT one to stay under.
+type NutrientKind string
+
+const (
+	NutrientMinimum NutrientKind = "MINIMUM"
+	NutrientLimit   NutrientKind = "LIMIT"
+)
+
+// IntakeStatus is how a nutrient's intake compares to its target.
+type IntakeStatus string
+
+const (
+	IntakeOK      IntakeStatus = "OK"
+	IntakeDeficit IntakeStatus = "DEFICIT"
+	IntakeExcess  IntakeStatus = "EXCESS"
+)
+
+const (
+	// OverrideSource marks a target the user set themselves.
+	OverrideSource = "OVERRIDE"
+	// a minimum within 90% of its target still counts as met
+	deficitThreshold = 0.9
+	// adults, when the profile doesn't say otherwise
+	defaultAge = 30
+	// overrides are stored as NUMERIC(8, 2)
+	maxOverride = 100000
+)
+
+//go:embed data/reference_intakes.json
+var referenceIntakesJSON []byte
+
+type intakeBand struct {
+	Gender string  `json:"gender"`
+	MinAge int     `json:"min_age"`
+	MaxAge int     `json:"max_age"`
+	Amount float64 `json:"amount"`
+}
+
+type referenceIntake struct {
+	Unit   string       `json:"unit"`
+	Kind   NutrientKind `json:"kind"`
+	Source string       `json:"source"`
+	Bands  []intakeBand `json:"bands"`
+}
+
+var referenceIntakes = mustLoadReferenceIntakes(referenceIntakesJSON)
+
+func mustLoadReferenceIntakes(raw []byte) map[string]referenceIntake {
+	var table map[string]referenceIntake
+	if err := json.Unmarshal(raw, &table); err != nil {
+		panic(fmt.Errorf("invalid reference intake table: %w", err))
+	}
+	return table
+}
+
+// Nutrients lists the tracked micronutrients in a stable order.
+func Nutrients() []string {
+	names := make([]string, 0, len(referenceIntakes))
+	for name := range referenceIntakes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NutrientProfile is what the reference intakes depend on.
+type NutrientProfile struct {
+	Age    int    `json:"age"`
+	Gender string `json:"gender"`
+}
+
+// NutrientTarget is one nutrient's daily target. Source names the reference
+// it came from, or OVERRIDE.
+type NutrientTarget struct {
+	Nutrient string       `json:"nutrient"`
+	Unit     string       `json:"unit"`
+	Kind     NutrientKind `json:"kind"`
+	Amount   float64      `json:"amount"`
+	Source   string       `json:"source"`
+}
+
+// NutrientResult is one nutrient's intake over a period against the target
+// for that period. Gap is the amount missing for a deficit or over the limit
+// for an excess.
+type NutrientResult struct {
+	NutrientTarget
+	Intake  float64      `json:"intake"`
+	Percent float64      `json:"percent"`
+	Status  IntakeStatus `json:"status"`
+	Gap     float64      `json:"gap"`
+}
+
+// NutrientReport covers Days days ending on To.
+type NutrientReport struct {
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	Days      int              `json:"days"`
+	Nutrients []NutrientResult `json:"nutrients"`
+}
+
+func (b intakeBand) matches(p NutrientProfile) bool {
+	if p.Age < b.MinAge || p.Age > b.MaxAge {
+		return false
+	}
+	return b.Gender == "" || b.Gender == p.Gender
+}
+
+// ReferenceTargets looks the profile up in the reference table. Without a
+// known age the adult bands apply; without a known gender the female bands
+// do, as they are the lower minimums.
+func ReferenceTargets(p NutrientProfile) []NutrientTarget {
+	if p.Age <= 0 {
+		p.Age = defaultAge
+	}
+	p.Gender = gender.Normalize(p.Gender)
+	if p.Gender == "" {
+		p.Gender = gender.Female
+	}
+
+	targets := make([]NutrientTarget, 0, len(referenceIntakes))
+	for _, name := range Nutrients() {
+		ref := referenceIntakes[name]
+		for _, band := range ref.Bands {
+			if band.matches(p) {
+				targets = append(targets, NutrientTarget{
+					Nutrient: name, Unit: ref.Unit, Kind: ref.Kind, Amount: band.Amount, Source: ref.Source,
+				})
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// applyOverrides replaces reference amounts with the user's own.
+func applyOverrides(targets []NutrientTarget, overrides map[string]float64) []NutrientTarget {
+	out := make([]NutrientTarget, len(targets))
+	for i, t := range targets {
+		if amount, ok := overrides[t.Nutrient]; ok {
+			t.Amount = amount
+			t.Source = OverrideSource
+		}
+		out[i] = t
+	}
+	return out
+}
+
+func validateOverride(nutrient string, amount float64) error {
+	if _, ok := referenceIntakes[nutrient]; !ok {
+		return fmt.Errorf("unknown nutrient %q, expected one of %s", nutrient, strings.Join(Nutrients(), ", "))
+	}
+	if !(amount > 0 && amount < maxOverride) {
+		return fmt.Errorf("target must be between 0 and %d", maxOverride)
+	}
+	return nil
+}
+
+// evaluateIntake compares intake summed over days against days times the
+// daily targets.
+func evaluateIntake(targets []NutrientTarget, intake map[string]float64, days int) []NutrientResult {
+	results := make([]NutrientResult, 0, len(targets))
+	for _, t := range targets {
+		t.Amount = t.Amount * float64(days)
+		r := NutrientResult{NutrientTarget: t, Intake: math.Round(intake[t.Nutrient]*10) / 10, Status: IntakeOK}
+		if t.Amount > 0 {
+			r.Percent = math.Round(r.Intake/t.Amount*1000) / 10
+		}
+		switch {
+		case t.Kind == NutrientMinimum && r.Intake < t.Amount*deficitThreshold:
+			r.Status = IntakeDeficit
+			r.Gap = math.Round((t.Amount-r.Intake)*10) / 10
+		case t.Kind == NutrientLimit && r.Intake > t.Amount:
+			r.Status = IntakeExcess
+			r.Gap = math.Round((r.Intake-t.Amount)*10) / 10
+		}
+		results = append(results, r)
+	}
+	return results
+}
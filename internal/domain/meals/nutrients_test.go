@@ -0,0 +1,97 @@
+package meals
+
+import "testing"
+
+func targetAmounts(targets []NutrientTarget) map[string]float64 {
+	amounts := make(map[string]float64, len(targets))
+	for _, t := range targets {
+		amounts[t.Nutrient] = t.Amount
+	}
+	return amounts
+}
+
+func TestReferenceTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile NutrientProfile
+		want    map[string]float64
+	}{
+		{"adult male", NutrientProfile{Age: 35, Gender: "MALE"},
+			map[string]float64{Fiber: 38, Potassium: 3400, Sodium: 2300, Sugar: 50, Cholesterol: 300}},
+		{"older woman by enum number", NutrientProfile{Age: 62, Gender: "2"},
+			map[string]float64{Fiber: 21, Potassium: 2600, Sodium: 2300, Sugar: 50, Cholesterol: 300}},
+		{"child", NutrientProfile{Age: 6, Gender: "MALE"},
+			map[string]float64{Fiber: 25, Potassium: 2300, Sodium: 1500, Sugar: 30, Cholesterol: 300}},
+		{"unknown profile", NutrientProfile{},
+			map[string]float64{Fiber: 25, Potassium: 2600, Sodium: 2300, Sugar: 50, Cholesterol: 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := targetAmounts(ReferenceTargets(tt.profile))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d targets %v, want %d", len(got), got, len(tt.want))
+			}
+			for nutrient, amount := range tt.want {
+				if got[nutrient] != amount {
+					t.Errorf("%s = %v, want %v", nutrient, got[nutrient], amount)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	targets := applyOverrides(ReferenceTargets(NutrientProfile{Age: 40, Gender: "MALE"}), map[string]float64{Sodium: 1500})
+	for _, target := range targets {
+		if target.Nutrient == Sodium {
+			if target.Amount != 1500 || target.Source != OverrideSource {
+				t.Errorf("sodium = %+v, want the 1500 mg override", target)
+			}
+		} else if target.Source == OverrideSource {
+			t.Errorf("%s marked as override", target.Nutrient)
+		}
+	}
+
+	if err := validateOverride("vitamin_c", 90); err == nil {
+		t.Error("unknown nutrient accepted")
+	}
+	if err := validateOverride(Sodium, 0); err == nil {
+		t.Error("zero target accepted")
+	}
+}
+
+func TestEvaluateIntake(t *testing.T) {
+	targets := ReferenceTargets(NutrientProfile{Age: 40, Gender: "MALE"})
+
+	tests := []struct {
+		name   string
+		intake map[string]float64
+		days   int
+		want   map[string]IntakeStatus
+		gaps   map[string]float64
+	}{
+		{"daily deficit and excess", map[string]float64{Fiber: 20, Potassium: 3200, Sodium: 2600, Sugar: 40, Cholesterol: 300}, 1,
+			map[string]IntakeStatus{Fiber: IntakeDeficit, Potassium: IntakeOK, Sodium: IntakeExcess, Sugar: IntakeOK, Cholesterol: IntakeOK},
+			map[string]float64{Fiber: 18, Sodium: 300}},
+		{"week evens out a salty day", map[string]float64{Fiber: 266, Potassium: 23800, Sodium: 15000, Sugar: 300, Cholesterol: 1500}, 7,
+			map[string]IntakeStatus{Fiber: IntakeOK, Potassium: IntakeOK, Sodium: IntakeOK, Sugar: IntakeOK, Cholesterol: IntakeOK},
+			map[string]float64{}},
+		{"nothing logged", map[string]float64{}, 1,
+			map[string]IntakeStatus{Fiber: IntakeDeficit, Potassium: IntakeDeficit, Sodium: IntakeOK, Sugar: IntakeOK, Cholesterol: IntakeOK},
+			map[string]float64{Fiber: 38, Potassium: 3400}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, r := range evaluateIntake(targets, tt.intake, tt.days) {
+				if r.Status != tt.want[r.Nutrient] {
+					t.Errorf("%s status = %s, want %s", r.Nutrient, r.Status, tt.want[r.Nutrient])
+				}
+				if r.Gap != tt.gaps[r.Nutrient] {
+					t.Errorf("%s gap = %v, want %v", r.Nutrient, r.Gap, tt.gaps[r.Nutrient])
+				}
+			}
+		})
+	}
+}
@@ -21,6 +21,9 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 )
 
 func nullTimeToTimestamppb(nt sql.NullTime) *timestamppb.Timestamp {
@@ -39,6 +42,13 @@ type MealPlanRepository struct {
 	pgpool         *pgxpool.Pool
 	redis          *redis.Client
 	sessionManager *auth.SessionManager
+	limits         CalorieLimits
+}
+
+// CalorieLimits reports a user's calorie target on a day. It is implemented
+// by calculator.CalculatorRepository, which knows about carb cycles.
+type CalorieLimits interface {
+	CalorieLimit(ctx context.Context, userID string, date time.Time) (float64, error)
 }
 
 type DietPreferenceRepository struct {
@@ -107,11 +117,11 @@ func NewMealReminderRepository(db *pgxpool.Pool, redis *redis.Client, sessionMan
 	return &MealReminderRepository{pgpool: db, redis: redis, sessionManager: sessionManager}
 }
 
-func NewMealPlanRepository(db *pgxpool.Pool, redis *redis.Client, sessionManager *auth.SessionManager) *MealPlanRepository {
+func NewMealPlanRepository(db *pgxpool.Pool, redis *redis.Client, sessionManager *auth.SessionManager, limits CalorieLimits) *MealPlanRepository {
 	if db == nil {
 		panic(errors.New("db is nil"))
 	}
-	return &MealPlanRepository{pgpool: db, redis: redis, sessionManager: sessionManager}
+	return &MealPlanRepository{pgpool: db, redis: redis, sessionManager: sessionManager, limits: limits}
 }
 
 func (i *IngredientRepository) GetIngredient(ctx context.Context, req *pbml.GetIngredientReq) (*pbml.GetIngredientRes, error) {
@@ -131,8 +141,8 @@ func (i *IngredientRepository) GetIngredient(ctx context.Context, req *pbml.GetI
 	err = tx.QueryRow(ctx, `
 		SELECT id, name, calories, protein, carbohydrates_total, fat_total
 		FROM ingredients
-		WHERE id = $1 AND (user_id = $2 OR user_id IS NULL) -- Restrict to user's or global ingredients
-	`, req.IngredientId, req.UserId).Scan(
+		WHERE id = $1 AND (user_id = $2 OR (user_id IS NULL AND gym_id IS NULL) OR gym_id = $3::uuid) -- Restrict to user's, gym or global ingredients
+	`, req.IngredientId, req.UserId, tenant.GymArg(ctx)).Scan(
 		&ingredient.ID, &ingredient.Name, &ingredient.Calories,
 		&ingredient.Protein, &ingredient.CarbohydratesTotal, &ingredient.FatTotal,
 	)
@@ -177,15 +187,37 @@ func (i *IngredientRepository) GetIngredient(ctx context.Context, req *pbml.GetI
 	}, nil
 }
 
+var ingredientsPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"name":       {Expr: "COALESCE(name, '')", Type: pagination.Text},
+		"calories":   {Expr: "COALESCE(calories, 0)::numeric", Type: pagination.Numeric},
+		"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "name",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"name":         {Expr: "name", Op: pagination.Contains},
+		"min_calories": {Expr: "calories", Type: pagination.Numeric, Op: pagination.Gte},
+		"max_calories": {Expr: "calories", Type: pagination.Numeric, Op: pagination.Lte},
+	},
+}
+
 func (i *IngredientRepository) GetIngredients(ctx context.Context, req *pbml.GetIngredientsReq) (*pbml.GetIngredientsRes, error) {
 	ingredients := make([]*pbml.XIngredient, 0)
+
+	page, err := ingredientsPage.Build(pagination.FromContext(ctx), 2)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, name, calories, protein, carbohydrates_total, fat_total
+		SELECT id, name, calories, protein, carbohydrates_total, fat_total, ` + page.CursorColumns() + `
 		FROM ingredients
-		WHERE (user_id = $1 OR user_id IS NULL) -- Restrict to user's or global ingredients
-	`
+		WHERE (user_id = $1 OR (user_id IS NULL AND gym_id IS NULL) OR gym_id = $2::uuid) -- Restrict to user's, gym or global ingredients
+	` + page.Where() + page.OrderLimit()
 
-	rows, err := i.pgpool.Query(ctx, query, req.UserId)
+	rows, err := i.pgpool.Query(ctx, query, append([]any{req.UserId, tenant.GymArg(ctx)}, page.Args()...)...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("no ingredients found: %w", err)
@@ -194,6 +226,7 @@ func (i *IngredientRepository) GetIngredients(ctx context.Context, req *pbml.Get
 	}
 	defer rows.Close()
 
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -204,13 +237,17 @@ func (i *IngredientRepository) GetIngredients(ctx context.Context, req *pbml.Get
 		ingredientProto := pbml.XIngredient{}
 		ingredient := &Ingredient{}
 
-		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Calories, &ingredient.Protein, &ingredient.CarbohydratesTotal, &ingredient.FatTotal)
+		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Calories, &ingredient.Protein, &ingredient.CarbohydratesTotal, &ingredient.FatTotal,
+			&pager.SortValue, &pager.ID)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, fmt.Errorf("no ingredients found: %w", err)
 			}
 			return nil, status.Errorf(codes.Internal, "failed to scan row: %v", err)
 		}
+		if !pager.Next() {
+			break
+		}
 
 		createdAt := timestamppb.New(ingredient.CreatedAt)
 		var updatedAt sql.NullTime
@@ -233,6 +270,8 @@ func (i *IngredientRepository) GetIngredients(ctx context.Context, req *pbml.Get
 		ingredients = append(ingredients, &ingredientProto)
 	}
 
+	pager.SetHeader(ctx)
+
 	return &pbml.GetIngredientsRes{
 		Success:     true,
 		Message:     "Ingredients retrieved successfully",
@@ -259,9 +298,9 @@ func (i *IngredientRepository) CreateIngredient(ctx context.Context, req *pbml.C
 	query := `
 		INSERT INTO ingredients (name, calories, serving_size,
 			protein, fat_total, fat_saturated, carbohydrates_total, fiber, sugar, sodium, potassium, cholesterol,
-			created_at, user_id
+			created_at, user_id, gym_id
 		)
-		Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id
 	`
 
@@ -282,7 +321,8 @@ func (i *IngredientRepository) CreateIngredient(ctx context.Context, req *pbml.C
 		req.Potassium,
 		req.Cholesterol,
 		currentTime,
-		req.UserId).Scan(&ingredientID)
+		req.UserId,
+		tenant.OwnerGym(ctx)).Scan(&ingredientID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create ingredient: %v", err)
 	}
@@ -438,6 +478,9 @@ func (i *IngredientRepository) UpdateIngredient(ctx context.Context, req *pbml.U
 	query += strings.Join(setClauses, ", ")
 	query += fmt.Sprintf(" WHERE id = $%d AND user_id = $%d", argIndex, argIndex+1)
 	args = append(args, req.IngredientId, req.UserId)
+	owned, gymID := tenant.Owned(ctx, "gym_id", argIndex+2)
+	query += owned
+	args = append(args, gymID)
 
 	_, err := i.pgpool.Exec(ctx, query, args...)
 	if err != nil {
@@ -448,12 +491,12 @@ func (i *IngredientRepository) UpdateIngredient(ctx context.Context, req *pbml.U
 }
 
 func (i *IngredientRepository) DeleteIngredient(ctx context.Context, req *pbml.DeleteIngredientReq) (*pbml.NilRes, error) {
+	owned, gymID := tenant.Owned(ctx, "gym_id", 3)
 	query := `
 		DELETE FROM ingredients
-		WHERE id = $1 AND user_id = $2
-	`
+		WHERE id = $1 AND user_id = $2` + owned
 
-	_, err := i.pgpool.Exec(ctx, query, req.IngredientId, req.UserId)
+	_, err := i.pgpool.Exec(ctx, query, req.IngredientId, req.UserId, gymID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete exercise: %w", err)
 	}
@@ -523,8 +566,8 @@ func (m *MealPlanRepository) CreateMeal(ctx context.Context, req *pbml.CreateMea
 			ingredientRow := tx.QueryRow(ctx, `
 			SELECT calories, protein, carbohydrates_total, fat_total, fat_saturated, fiber, sugar, sodium, potassium, cholesterol
 			FROM ingredients
-			WHERE id = $1
-		`, ingredientUUID)
+			WHERE id = $1 AND (user_id = $2 OR (user_id IS NULL AND gym_id IS NULL) OR gym_id = $3::uuid)
+		`, ingredientUUID, req.UserId, tenant.GymArg(ctx))
 
 			var calories, protein, carbohydratesTotal, fatTotal, fatSaturated, fiber, sugar, sodium, potassium, cholesterol float64
 			if err = ingredientRow.Scan(&calories, &protein, &carbohydratesTotal,
@@ -1756,12 +1799,33 @@ func (m *MealPlanRepository) GetMealPlan(ctx context.Context, req *pbml.GetMealP
 	}, nil
 }
 
+var mealPlansPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"name":       {Expr: "COALESCE(mp.name, '')", Type: pagination.Text},
+		"rating":     {Expr: "COALESCE(mp.rating, 0)", Type: pagination.Int},
+		"created_at": {Expr: "mp.created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "-created_at",
+	IDExpr:      "mp.id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"name":      {Expr: "mp.name", Op: pagination.Contains},
+		"objective": {Expr: "mp.objective::text", Type: pagination.Text, Op: pagination.Eq},
+		"activity":  {Expr: "mp.activity::text", Type: pagination.Text, Op: pagination.Eq},
+	},
+}
+
 func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMealPlansReq) (*pbml.GetMealPlansRes, error) {
 	// Check if UserID is valid
 	if req.UserId == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "user ID cannot be empty")
 	}
 
+	page, err := mealPlansPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
 	//query := `
 	//			SELECT
 	//				mp.id AS id,
@@ -1822,7 +1886,8 @@ func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMeal
                     ELSE NULL
                 END
             ) FILTER (WHERE m.id IS NOT NULL), '[]'::jsonb
-        ) AS meals
+        ) AS meals,
+        ` + page.CursorColumns() + `
     FROM meal_plans mp
     LEFT JOIN meals m ON mp.id = m.meal_plan_id
     LEFT JOIN (
@@ -1841,13 +1906,13 @@ func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMeal
         JOIN meals m ON mi.meal_id = m.id
         GROUP BY mi.meal_id
     ) t ON t.meal_id = m.id
-    WHERE mp.user_id = $1
+    WHERE mp.user_id = $1` + page.Where() + `
     GROUP BY mp.id, mp.user_id, mp.name, mp.description,
              mp.notes, mp.rating, mp.created_at, mp.updated_at,
              mp.objective, mp.activity, mp.gender, mp.quantity_unit
-`
+` + page.OrderLimit()
 
-	rows, err := m.pgpool.Query(ctx, query, req.UserId)
+	rows, err := m.pgpool.Query(ctx, query, append([]any{req.UserId}, page.Args()...)...)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch meal plans: %v", err)
 	}
@@ -1855,6 +1920,7 @@ func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMeal
 
 	mealPlansProto := make([]*pbml.XMealPlan, 0)
 
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -1890,10 +1956,14 @@ func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMeal
 			&mealPlan.CreatedAt,
 			&mealPlan.UpdatedAt,
 			&rawMeals,
+			&pager.SortValue, &pager.ID,
 		); err != nil {
 			log.Printf("Scan Error: %v", err)
 			return nil, status.Errorf(codes.Internal, "failed to parse row: %v", err)
 		}
+		if !pager.Next() {
+			break
+		}
 
 		// Parse meals for the meal plan
 		var meals []map[string]interface{}
@@ -2008,6 +2078,8 @@ func (m *MealPlanRepository) GetMealPlans(ctx context.Context, req *pbml.GetMeal
 		totalMealPlanNutrients.CarbohydratesTotal += mp.TotalMealNutrients.CarbohydratesTotal
 	}
 
+	pager.SetHeader(ctx)
+
 	// Construct final response
 	return &pbml.GetMealPlansRes{
 		Success:                true,
@@ -2320,24 +2392,57 @@ func (m *MealPlanRepository) DeleteMealPlan(ctx context.Context, req *pbml.Delet
 	return &pbml.NilRes{}, nil
 }
 
-func (m *MealPlanRepository) GetUserCalorieLimit(ctx context.Context, userId string) (float64, error) {
-	var limit float64
+// GetUserCalorieLimit returns the calorie target for date, which follows the
+// user's carb cycle when one is active.
+func (m *MealPlanRepository) GetUserCalorieLimit(ctx context.Context, userId string, date time.Time) (float64, error) {
+	return m.limits.CalorieLimit(ctx, userId, date)
+}
+
+func (f *FoodLogRepository) LogFood(ctx context.Context, req *pbml.LogFoodReq) (*pbml.LogFoodRes, error) {
+	if req.UserId == "" || req.MealId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id and meal id are required")
+	}
+	if req.Quantity <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity must be positive")
+	}
 
-	// Query the user’s current macro
-	err := m.pgpool.QueryRow(ctx, `
-     SELECT goal
-     FROM user_macro_distribution
-     WHERE user_id = $1
-     AND is_current = TRUE
-     LIMIT 1
-   `, userId).Scan(&limit)
+	logDate := time.Now()
+	if req.LogDate != nil {
+		logDate = req.LogDate.AsTime()
+	}
 
+	tx, err := f.pgpool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// The user has no current macro. Return 0 or some default.
-			return 0, nil
+		return nil, status.Errorf(codes.Internal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
 		}
-		return 0, err
+	}()
+
+	var foodLogID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO food_logs (user_id, meal_id, quantity, log_date, created_at)
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING id`, req.UserId, req.MealId, req.Quantity, logDate).Scan(&foodLogID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to insert food log: %v", err)
+	}
+
+	err = events.RecordNew(ctx, tx, events.FoodLogged, req.UserId, foodLogID, events.FoodLoggedPayload{
+		FoodLogID: foodLogID,
+		MealID:    req.MealId,
+		Quantity:  req.Quantity,
+		LogDate:   logDate,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to record food log event: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to commit transaction: %v", err)
 	}
-	return limit, nil
+
+	return &pbml.LogFoodRes{Success: true, Message: "food logged"}, nil
 }
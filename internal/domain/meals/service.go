@@ -4,18 +4,38 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	pbml "github.com/FACorreiaa/fitme-protos/modules/meal/generated"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware/grpcrequest"
 )
 
+// planDateHeader names the day a meal plan is for, as YYYY-MM-DD. The calorie
+// limit it is checked against can change from day to day with carb cycling.
+const planDateHeader = "x-plan-date"
+
+func planDateFromContext(ctx context.Context) (time.Time, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(planDateHeader); len(v) > 0 && v[0] != "" {
+			date, err := time.Parse(time.DateOnly, v[0])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid %s %q, want YYYY-MM-DD", planDateHeader, v[0])
+			}
+			return date, nil
+		}
+	}
+	return time.Now(), nil
+}
+
 type MealServices interface {
 	GetMealPlanService() MealPlanService
 	GetDietPreferenceService() DietPreferenceService
@@ -87,6 +107,10 @@ type FoodLogService struct {
 	repo domain.FoodLogRepository
 }
 
+func (f FoodLogService) LogFood(ctx context.Context, req *pbml.LogFoodReq) (*pbml.LogFoodRes, error) {
+	return f.repo.LogFood(ctx, req)
+}
+
 func (f FoodLogService) GetMeal(ctx context.Context, req *pbml.GetMealReq) (*pbml.GetMealRes, error) {
 	//TODO implement me
 	panic("implement me")
@@ -522,6 +546,9 @@ func (i *IngredientService) GetIngredients(ctx context.Context, req *pbml.GetIng
 
 	ingredients, err := i.repo.GetIngredients(ctx, req)
 	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
 		return &pbml.GetIngredientsRes{
 			Success: false,
 			Message: "Ingredients fetch failed",
@@ -1146,7 +1173,12 @@ func (m *MealPlanService) CreateMealPlan(ctx context.Context, req *pbml.CreateMe
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in context")
 	}
 
-	activeMacro, err := m.repo.GetUserCalorieLimit(ctx, req.MealPlan.UserId)
+	planDate, err := planDateFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	activeMacro, err := m.repo.GetUserCalorieLimit(ctx, req.MealPlan.UserId, planDate)
 	if err != nil {
 		span.RecordError(err)
 		return nil, status.Errorf(codes.Internal, "failed to get active macro: %v", err)
@@ -1214,6 +1246,9 @@ func (m *MealPlanService) GetMealPlans(ctx context.Context, req *pbml.GetMealPla
 
 	mps, err := m.repo.GetMealPlans(ctx, req)
 	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
 		return &pbml.GetMealPlansRes{
 			Success: false,
 			Message: "Failed to fetch meal plans",
@@ -0,0 +1,140 @@
+package measurements
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/calculator"
+	"github.com/FACorreiaa/fitme-grpc/internal/units"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const (
+	defaultHydrationDays = 7
+	maxHydrationDays     = 90
+	// streaks longer than a year are reported as a year
+	streakLookbackDays = 365
+
+	// The water RPCs report today's target, intake and streak in these
+	// response headers, in the request's unit system.
+	waterTargetHeader = "x-water-target"
+	waterTodayHeader  = "x-water-today"
+	waterStreakHeader = "x-water-streak"
+)
+
+// HydrationDay is one day's intake against its target.
+type HydrationDay struct {
+	Date     time.Time            `json:"date"`
+	Target   calculator.WaterGoal `json:"target"`
+	IntakeMl float64              `json:"intake_ml"`
+	Percent  float64              `json:"percent"`
+	Met      bool                 `json:"met"`
+}
+
+// HydrationProgress lists days oldest first. Streak counts the consecutive
+// days the target was met, up to today or, while today is still open, up to
+// yesterday.
+type HydrationProgress struct {
+	Days   []HydrationDay `json:"days"`
+	Streak int            `json:"streak"`
+}
+
+func hydrationDays(log *domain.HydrationLog, from, to time.Time) ([]HydrationDay, error) {
+	activity := calculator.ParseStoredActivity(log.Activity)
+	days := make([]HydrationDay, 0)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		goal, err := calculator.DailyWaterGoal(log.WeightKg, activity, log.ExerciseMinutes[d])
+		if err != nil {
+			return nil, err
+		}
+		intake := log.IntakeMl[d]
+		days = append(days, HydrationDay{
+			Date:     d,
+			Target:   goal,
+			IntakeMl: intake,
+			Percent:  math.Round(intake/goal.TotalMl*1000) / 10,
+			Met:      intake >= goal.TotalMl,
+		})
+	}
+	return days, nil
+}
+
+// hydrationStreak expects days oldest first, ending today.
+func hydrationStreak(days []HydrationDay) int {
+	i := len(days) - 1
+	if i >= 0 && !days[i].Met {
+		i--
+	}
+	streak := 0
+	for ; i >= 0 && days[i].Met; i-- {
+		streak++
+	}
+	return streak
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// HydrationProgress returns the last n days of intake against the daily
+// target. The water RPCs carry today's figures in headers.
+func (s ServiceMeasurement) HydrationProgress(ctx context.Context, userID string, n int) (*HydrationProgress, error) {
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if n == 0 {
+		n = defaultHydrationDays
+	}
+	if n < 1 || n > maxHydrationDays {
+		return nil, status.Errorf(codes.InvalidArgument, "days must be between 1 and %d", maxHydrationDays)
+	}
+
+	end := today()
+	since := end.AddDate(0, 0, -streakLookbackDays)
+	log, err := s.repo.HydrationLog(ctx, userID, since)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if log.WeightKg == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "log a weight first")
+	}
+
+	days, err := hydrationDays(log, since, end)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &HydrationProgress{Days: days[len(days)-n:], Streak: hydrationStreak(days)}, nil
+}
+
+// setHydrationHeaders reports today's progress on a water RPC. It never
+// fails the call: a user without a weight simply gets no headers.
+func (s ServiceMeasurement) setHydrationHeaders(ctx context.Context, userID string) {
+	progress, err := s.HydrationProgress(ctx, userID, 1)
+	if err != nil {
+		if status.Code(err) != codes.FailedPrecondition {
+			logger.Log.Warn("failed to compute hydration progress", zap.String("user_id", userID), zap.Error(err))
+		}
+		return
+	}
+
+	day := progress.Days[0]
+	system := units.FromContext(ctx)
+	volume := func(ml float64) string {
+		return fmt.Sprintf("%d %s", shownVolume(ctx, ml), system.VolumeUnit())
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		waterTargetHeader, volume(day.Target.TotalMl),
+		waterTodayHeader, volume(day.IntakeMl),
+		waterStreakHeader, strconv.Itoa(progress.Streak),
+	))
+}
@@ -0,0 +1,71 @@
+package measurements
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+)
+
+func TestHydrationDays(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	log := &domain.HydrationLog{
+		WeightKg: 80,
+		Activity: "SEDENTARY",
+		IntakeMl: map[time.Time]float64{
+			from:                  2800,
+			from.AddDate(0, 0, 1): 1400,
+			from.AddDate(0, 0, 2): 3000,
+		},
+		ExerciseMinutes: map[time.Time]float64{from.AddDate(0, 0, 2): 30},
+	}
+
+	days, err := hydrationDays(log, from, from.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []struct {
+		target  float64
+		percent float64
+		met     bool
+	}{
+		{2800, 100, true},
+		{2800, 50, false},
+		{3160, 94.9, false},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("got %d days, want %d", len(days), len(want))
+	}
+	for i, w := range want {
+		d := days[i]
+		if d.Target.TotalMl != w.target || d.Percent != w.percent || d.Met != w.met {
+			t.Errorf("day %d = %v ml %.1f%% met=%v, want %v ml %.1f%% met=%v", i, d.Target.TotalMl, d.Percent, d.Met, w.target, w.percent, w.met)
+		}
+	}
+}
+
+func TestHydrationStreak(t *testing.T) {
+	tests := []struct {
+		name string
+		met  []bool
+		want int
+	}{
+		{"no days", nil, 0},
+		{"met today", []bool{false, true, true, true}, 3},
+		{"today still open", []bool{true, true, false}, 2},
+		{"missed yesterday", []bool{true, false, false}, 0},
+		{"every day", []bool{true, true, true}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := make([]HydrationDay, len(tt.met))
+			for i, m := range tt.met {
+				days[i].Met = m
+			}
+			if got := hydrationStreak(days); got != tt.want {
+				t.Errorf("hydrationStreak() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -16,7 +17,11 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
+	"github.com/FACorreiaa/fitme-grpc/internal/units"
 )
 
 type RepositoryMeasurement struct {
@@ -30,6 +35,46 @@ func NewRepositoryMeasurement(db *pgxpool.Pool, redis *redis.Client, sessionMana
 	return &RepositoryMeasurement{pgpool: db, redis: redis, sessionManager: sessionManager}
 }
 
+// Readings are stored in kg, cm and ml. The request's unit system, set by
+// the service, applies on the way in and out.
+
+func storedWeight(ctx context.Context, v float64) float64 {
+	return round2(units.FromContext(ctx).Mass(v).Kilograms())
+}
+
+func shownWeight(ctx context.Context, kg float64) int32 {
+	return int32(math.Round(units.FromContext(ctx).MassValue(units.Kilograms(kg))))
+}
+
+func storedLength(ctx context.Context, v float64) float64 {
+	return round2(units.FromContext(ctx).Length(v).Centimetres())
+}
+
+func shownLength(ctx context.Context, cm float64) int32 {
+	return int32(math.Round(units.FromContext(ctx).LengthValue(units.Centimetres(cm))))
+}
+
+func storedVolume(ctx context.Context, v float64) float64 {
+	return round2(units.FromContext(ctx).Volume(v).Millilitres())
+}
+
+func shownVolume(ctx context.Context, ml float64) int32 {
+	return int32(math.Round(units.FromContext(ctx).VolumeValue(units.Millilitres(ml))))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// parseReading reads an update value in the request's units.
+func parseReading(field, raw string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || v < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s: %q", field, raw)
+	}
+	return v, nil
+}
+
 // WEIGHTS
 
 func (r *RepositoryMeasurement) CreateWeight(ctx context.Context, req *pbm.CreateWeightReq) (*pbm.XWeight, error) {
@@ -49,11 +94,35 @@ func (r *RepositoryMeasurement) CreateWeight(ctx context.Context, req *pbm.Creat
 		updatedAt = sql.NullTime{Valid: false}
 	}
 
-	err := r.pgpool.QueryRow(ctx, query, req.UserId, req.Weight.WeightValue, currentTime, updatedAt).Scan(&weightID)
+	tx, err := r.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	kg := storedWeight(ctx, float64(req.Weight.WeightValue))
+	err = tx.QueryRow(ctx, query, req.UserId, kg, currentTime, updatedAt).Scan(&weightID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to insert weight: %v", err)
 	}
 
+	err = events.RecordNew(ctx, tx, events.WeightLogged, req.UserId, weightID, events.WeightLoggedPayload{
+		WeightID:    weightID,
+		WeightValue: kg,
+		LoggedAt:    currentTime,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to record weight event: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to commit transaction: %v", err)
+	}
+
 	weightProto := &pbm.XWeight{
 		WeightId:    weightID,
 		UserId:      req.UserId,
@@ -65,17 +134,48 @@ func (r *RepositoryMeasurement) CreateWeight(ctx context.Context, req *pbm.Creat
 	return weightProto, nil
 }
 
-func (r *RepositoryMeasurement) GetWeights(ctx context.Context) ([]*pbm.XWeight, error) {
+// measurementsPage is shared by the weight, water and waist line lists; each
+// table stores its reading in a different column.
+func measurementsPage(valueColumn string) pagination.Spec {
+	return pagination.Spec{
+		Sorts: map[string]pagination.SortKey{
+			"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+			"value":      {Expr: "COALESCE(" + valueColumn + ", 0)", Type: pagination.Numeric},
+		},
+		DefaultSort: "-created_at",
+		IDExpr:      "id",
+		IDType:      pagination.UUID,
+		Filters: map[string]pagination.Filter{
+			"from": {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Gte},
+			"to":   {Expr: "created_at", Type: pagination.Timestamp, Op: pagination.Lte},
+		},
+	}
+}
+
+var (
+	weightsPage    = measurementsPage("weight_value")
+	waterPage      = measurementsPage("quantity")
+	waistLinesPage = measurementsPage("quantity")
+)
+
+func (r *RepositoryMeasurement) GetWeights(ctx context.Context, userID string) ([]*pbm.XWeight, error) {
 	weightsProto := make([]*pbm.XWeight, 0)
-	// test without WHERE user_id = $1
+	page, err := weightsPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, user_id, weight_value, created_at, updated_at FROM weight_measure`
+		SELECT id, user_id, weight_value, created_at, updated_at, ` + page.CursorColumns() + ` FROM weight_measure
+		WHERE user_id = $1` + page.Where() + page.OrderLimit()
 
-	rows, err := r.pgpool.Query(ctx, query)
+	rows, err := r.pgpool.Query(ctx, query, append([]any{userID}, page.Args()...)...)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 	}
 	defer rows.Close()
+
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -88,10 +188,15 @@ func (r *RepositoryMeasurement) GetWeights(ctx context.Context) ([]*pbm.XWeight,
 		var createdAt time.Time
 		var updatedAt sql.NullTime
 
-		err = rows.Scan(&weightProto.WeightId, &weightProto.UserId, &weightProto.WeightValue, &createdAt, &updatedAt)
+		var kg float64
+		err = rows.Scan(&weightProto.WeightId, &weightProto.UserId, &kg, &createdAt, &updatedAt, &pager.SortValue, &pager.ID)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 		}
+		weightProto.WeightValue = shownWeight(ctx, kg)
+		if !pager.Next() {
+			break
+		}
 
 		weightProto.CreatedAt = timestamppb.New(createdAt)
 		if updatedAt.Valid {
@@ -107,6 +212,8 @@ func (r *RepositoryMeasurement) GetWeights(ctx context.Context) ([]*pbm.XWeight,
 		return nil, status.Errorf(codes.NotFound, "weight not found")
 	}
 
+	pager.SetHeader(ctx)
+
 	return weightsProto, nil
 }
 
@@ -118,13 +225,15 @@ func (r *RepositoryMeasurement) GetWeight(ctx context.Context, req *pbm.GetWeigh
 	`
 	var createdAt time.Time
 	var updatedAt sql.NullTime
+	var kg float64
 
 	err := r.pgpool.QueryRow(ctx, query, req.WeightId, req.UserId).Scan(
-		&weightProto.WeightId, &weightProto.UserId, &weightProto.WeightValue, &createdAt, &updatedAt)
+		&weightProto.WeightId, &weightProto.UserId, &kg, &createdAt, &updatedAt)
 
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weight: %v", err)
 	}
+	weightProto.WeightValue = shownWeight(ctx, kg)
 
 	weightProto.CreatedAt = timestamppb.New(createdAt)
 	if updatedAt.Valid {
@@ -160,14 +269,14 @@ func (r *RepositoryMeasurement) UpdateWeight(ctx context.Context, req *pbm.Updat
 	for _, update := range req.Updates {
 		switch update.Field {
 		case "weight_value":
-			newValue, err := strconv.ParseUint(update.NewValue, 10, 32)
+			newValue, err := parseReading(update.Field, update.NewValue)
 			if err != nil {
 				return nil, err
 			}
 			setClauses = append(setClauses, fmt.Sprintf("weight_value = $%d", argIndex))
-			args = append(args, update.NewValue)
+			args = append(args, storedWeight(ctx, newValue))
 			updatedFields["weight_value"] = update.NewValue
-			weightProto.WeightValue = int32(newValue)
+			weightProto.WeightValue = int32(math.Round(newValue))
 			argIndex++
 		case "UpdatedAt":
 			setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", argIndex))
@@ -241,7 +350,7 @@ func (r *RepositoryMeasurement) CreateWaterMeasurement(ctx context.Context, req
 		updatedAt = sql.NullTime{Valid: false}
 	}
 
-	err := r.pgpool.QueryRow(ctx, query, req.UserId, req.Water.Quantity, currentTime, updatedAt).Scan(&weightID)
+	err := r.pgpool.QueryRow(ctx, query, req.UserId, storedVolume(ctx, float64(req.Water.Quantity)), currentTime, updatedAt).Scan(&weightID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to insert weight: %v", err)
 	}
@@ -257,17 +366,24 @@ func (r *RepositoryMeasurement) CreateWaterMeasurement(ctx context.Context, req
 	return waterProto, nil
 }
 
-func (r *RepositoryMeasurement) GetWaterMeasurements(ctx context.Context) ([]*pbm.XWaterIntake, error) {
+func (r *RepositoryMeasurement) GetWaterMeasurements(ctx context.Context, userID string) ([]*pbm.XWaterIntake, error) {
 	waterProtos := make([]*pbm.XWaterIntake, 0)
-	// test without WHERE user_id = $1
+	page, err := waterPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, user_id, quantity, created_at, updated_at FROM water_intake`
+		SELECT id, user_id, quantity, created_at, updated_at, ` + page.CursorColumns() + ` FROM water_intake
+		WHERE user_id = $1` + page.Where() + page.OrderLimit()
 
-	rows, err := r.pgpool.Query(ctx, query)
+	rows, err := r.pgpool.Query(ctx, query, append([]any{userID}, page.Args()...)...)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 	}
 	defer rows.Close()
+
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -280,10 +396,15 @@ func (r *RepositoryMeasurement) GetWaterMeasurements(ctx context.Context) ([]*pb
 		var createdAt time.Time
 		var updatedAt sql.NullTime
 
-		err = rows.Scan(&waterProto.WaterIntakeId, &waterProto.UserId, &waterProto.WaterIntakeId, &createdAt, &updatedAt)
+		var ml float64
+		err = rows.Scan(&waterProto.WaterIntakeId, &waterProto.UserId, &ml, &createdAt, &updatedAt, &pager.SortValue, &pager.ID)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 		}
+		waterProto.Quantity = shownVolume(ctx, ml)
+		if !pager.Next() {
+			break
+		}
 
 		waterProto.CreatedAt = timestamppb.New(createdAt)
 		if updatedAt.Valid {
@@ -299,6 +420,8 @@ func (r *RepositoryMeasurement) GetWaterMeasurements(ctx context.Context) ([]*pb
 		return nil, status.Errorf(codes.NotFound, "weight not found")
 	}
 
+	pager.SetHeader(ctx)
+
 	return waterProtos, nil
 }
 
@@ -310,13 +433,15 @@ func (r *RepositoryMeasurement) GetWaterMeasurement(ctx context.Context, req *pb
 	`
 	var createdAt time.Time
 	var updatedAt sql.NullTime
+	var ml float64
 
 	err := r.pgpool.QueryRow(ctx, query, req.WaterIntakeId, req.UserId).Scan(
-		&waterProto.WaterIntakeId, &waterProto.UserId, &waterProto.Quantity, &createdAt, &updatedAt)
+		&waterProto.WaterIntakeId, &waterProto.UserId, &ml, &createdAt, &updatedAt)
 
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weight: %v", err)
 	}
+	waterProto.Quantity = shownVolume(ctx, ml)
 
 	waterProto.CreatedAt = timestamppb.New(createdAt)
 	if updatedAt.Valid {
@@ -351,14 +476,14 @@ func (r *RepositoryMeasurement) UpdateWaterMeasurement(ctx context.Context, req
 	for _, update := range req.Updates {
 		switch update.Field {
 		case "quantity":
-			newValue, err := strconv.ParseUint(update.NewValue, 10, 32)
+			newValue, err := parseReading(update.Field, update.NewValue)
 			if err != nil {
 				return nil, err
 			}
-			setClauses = append(setClauses, fmt.Sprintf("weight_value = $%d", argIndex))
-			args = append(args, update.NewValue)
-			updatedFields["weight_value"] = update.NewValue
-			waterProto.Quantity = int32(newValue)
+			setClauses = append(setClauses, fmt.Sprintf("quantity = $%d", argIndex))
+			args = append(args, storedVolume(ctx, newValue))
+			updatedFields["quantity"] = update.NewValue
+			waterProto.Quantity = int32(math.Round(newValue))
 			argIndex++
 		case "UpdatedAt":
 			setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", argIndex))
@@ -432,7 +557,7 @@ func (r *RepositoryMeasurement) CreateWasteLineMeasurement(ctx context.Context,
 		updatedAt = sql.NullTime{Valid: false}
 	}
 
-	err := r.pgpool.QueryRow(ctx, query, req.UserId, req.WasteLine.Measurement, currentTime, updatedAt).Scan(&weightID)
+	err := r.pgpool.QueryRow(ctx, query, req.UserId, storedLength(ctx, float64(req.WasteLine.Measurement)), currentTime, updatedAt).Scan(&weightID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to insert weight: %v", err)
 	}
@@ -448,17 +573,24 @@ func (r *RepositoryMeasurement) CreateWasteLineMeasurement(ctx context.Context,
 	return waterProto, nil
 }
 
-func (r *RepositoryMeasurement) GetWasteLineMeasurements(ctx context.Context) ([]*pbm.XWasteLine, error) {
+func (r *RepositoryMeasurement) GetWasteLineMeasurements(ctx context.Context, userID string) ([]*pbm.XWasteLine, error) {
 	wasteLineProtos := make([]*pbm.XWasteLine, 0)
-	// test without WHERE user_id = $1
+	page, err := waistLinesPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, user_id, quantity, created_at, updated_at FROM waist_line`
+		SELECT id, user_id, quantity, created_at, updated_at, ` + page.CursorColumns() + ` FROM waist_line
+		WHERE user_id = $1` + page.Where() + page.OrderLimit()
 
-	rows, err := r.pgpool.Query(ctx, query)
+	rows, err := r.pgpool.Query(ctx, query, append([]any{userID}, page.Args()...)...)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 	}
 	defer rows.Close()
+
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -471,10 +603,15 @@ func (r *RepositoryMeasurement) GetWasteLineMeasurements(ctx context.Context) ([
 		var createdAt time.Time
 		var updatedAt sql.NullTime
 
-		err = rows.Scan(&wastelineProto.WasteLineId, &wastelineProto.UserId, &wastelineProto.Measurement, &createdAt, &updatedAt)
+		var cm float64
+		err = rows.Scan(&wastelineProto.WasteLineId, &wastelineProto.UserId, &cm, &createdAt, &updatedAt, &pager.SortValue, &pager.ID)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "failed to fetch weights: %v", err)
 		}
+		wastelineProto.Measurement = shownLength(ctx, cm)
+		if !pager.Next() {
+			break
+		}
 
 		wastelineProto.CreatedAt = timestamppb.New(createdAt)
 		if updatedAt.Valid {
@@ -490,6 +627,8 @@ func (r *RepositoryMeasurement) GetWasteLineMeasurements(ctx context.Context) ([
 		return nil, status.Errorf(codes.NotFound, "weight not found")
 	}
 
+	pager.SetHeader(ctx)
+
 	return wasteLineProtos, nil
 }
 
@@ -501,13 +640,15 @@ func (r *RepositoryMeasurement) GetWasteLineMeasurement(ctx context.Context, req
 	`
 	var createdAt time.Time
 	var updatedAt sql.NullTime
+	var cm float64
 
 	err := r.pgpool.QueryRow(ctx, query, req.WasteLineId, req.UserId).Scan(
-		&waistlineProto.WasteLineId, &waistlineProto.UserId, &waistlineProto.Measurement, &createdAt, &updatedAt)
+		&waistlineProto.WasteLineId, &waistlineProto.UserId, &cm, &createdAt, &updatedAt)
 
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to fetch weight: %v", err)
 	}
+	waistlineProto.Measurement = shownLength(ctx, cm)
 
 	waistlineProto.CreatedAt = timestamppb.New(createdAt)
 	if updatedAt.Valid {
@@ -540,14 +681,14 @@ func (r *RepositoryMeasurement) UpdateWasteLineMeasurement(ctx context.Context,
 	for _, update := range req.Updates {
 		switch update.Field {
 		case "measurement":
-			newValue, err := strconv.ParseUint(update.NewValue, 10, 32)
+			newValue, err := parseReading(update.Field, update.NewValue)
 			if err != nil {
 				return nil, err
 			}
-			setClauses = append(setClauses, fmt.Sprintf("measurement = $%d", argIndex))
-			args = append(args, update.NewValue)
+			setClauses = append(setClauses, fmt.Sprintf("quantity = $%d", argIndex))
+			args = append(args, storedLength(ctx, newValue))
 			updatedFields["measurement"] = update.NewValue
-			waistlineProto.Measurement = int32(newValue)
+			waistlineProto.Measurement = int32(math.Round(newValue))
 			argIndex++
 		case "UpdatedAt":
 			setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", argIndex))
@@ -598,3 +739,65 @@ func (r *RepositoryMeasurement) DeleteWasteLineMeasurement(ctx context.Context,
 
 	return &pbm.NilRes{}, nil
 }
+
+// HYDRATION
+
+// HydrationLog loads the daily water intake and exercise minutes since the
+// given day, with the weight and activity level the targets are based on.
+func (r *RepositoryMeasurement) HydrationLog(ctx context.Context, userID string, since time.Time) (*domain.HydrationLog, error) {
+	log := &domain.HydrationLog{
+		IntakeMl:        make(map[time.Time]float64),
+		ExerciseMinutes: make(map[time.Time]float64),
+	}
+
+	err := r.pgpool.QueryRow(ctx, `
+		SELECT
+		  COALESCE(
+		    (SELECT weight_value::float8 FROM weight_measure WHERE user_id = $1 AND weight_value > 0 ORDER BY created_at DESC LIMIT 1),
+		    (SELECT weight::float8 FROM user_macro_distribution WHERE user_id = $1 ORDER BY is_current DESC, created_at DESC LIMIT 1),
+		    0),
+		  COALESCE(
+		    (SELECT activity FROM user_macro_distribution WHERE user_id = $1 ORDER BY is_current DESC, created_at DESC LIMIT 1),
+		    '')`, userID).Scan(&log.WeightKg, &log.Activity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hydration inputs: %w", err)
+	}
+
+	daily := func(query string, into map[time.Time]float64) error {
+		rows, err := r.pgpool.Query(ctx, query, userID, since)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var day time.Time
+			var v float64
+			if err = rows.Scan(&day, &v); err != nil {
+				return err
+			}
+			into[day.UTC()] = v
+		}
+		return rows.Err()
+	}
+
+	err = daily(`
+		SELECT date_trunc('day', created_at) AS day, SUM(quantity)::float8
+		FROM water_intake
+		WHERE user_id = $1 AND created_at >= $2
+		GROUP BY 1`, log.IntakeMl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch water intake: %w", err)
+	}
+
+	err = daily(`
+		SELECT date_trunc('day', start_time) AS day,
+		       SUM(COALESCE(duration_hours, 0) * 60 + COALESCE(duration_minutes, 0) + COALESCE(duration_seconds, 0) / 60.0)::float8
+		FROM exercise_session
+		WHERE user_id = $1 AND start_time >= $2
+		GROUP BY 1`, log.ExerciseMinutes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exercise sessions: %w", err)
+	}
+
+	return log, nil
+}
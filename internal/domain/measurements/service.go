@@ -10,22 +10,36 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/preferences"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware/grpcrequest"
 )
 
 type ServiceMeasurement struct {
 	pbm.UnimplementedUserMeasurementsServer
-	ctx  context.Context
-	repo domain.RepositoryMeasurement
+	ctx   context.Context
+	repo  domain.RepositoryMeasurement
+	units *preferences.Units
 }
 
-func NewMeasurementService(ctx context.Context, repo domain.RepositoryMeasurement) *ServiceMeasurement {
+func NewMeasurementService(ctx context.Context, repo domain.RepositoryMeasurement, units *preferences.Units) *ServiceMeasurement {
 	return &ServiceMeasurement{
-		ctx:  ctx,
-		repo: repo,
+		ctx:   ctx,
+		repo:  repo,
+		units: units,
 	}
 }
 
+// withUnits puts the caller's unit system on ctx so the repository can
+// convert readings to and from kg, cm and ml.
+func (s ServiceMeasurement) withUnits(ctx context.Context, userID string) (context.Context, error) {
+	if s.units == nil {
+		return ctx, nil
+	}
+	ctx, _, err := s.units.Resolve(ctx, userID)
+	return ctx, err
+}
+
 // Weights
 
 func (s ServiceMeasurement) CreateWeight(ctx context.Context, req *pbm.CreateWeightReq) (*pbm.CreateWeightRes, error) {
@@ -49,6 +63,11 @@ func (s ServiceMeasurement) CreateWeight(ctx context.Context, req *pbm.CreateWei
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.CreateWeight(ctx, req)
@@ -92,8 +111,16 @@ func (s ServiceMeasurement) GetWeights(ctx context.Context, req *pbm.GetWeightsR
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	res, err := s.repo.GetWeights(ctx)
+	ctx, err := s.withUnits(ctx, userID)
 	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.repo.GetWeights(ctx, userID)
+	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -133,6 +160,11 @@ func (s ServiceMeasurement) GetWeight(ctx context.Context, req *pbm.GetWeightReq
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	res, err := s.repo.GetWeight(ctx, req)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -209,6 +241,11 @@ func (s ServiceMeasurement) UpdateWeight(ctx context.Context, req *pbm.UpdateWei
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.UpdateWeight(ctx, req)
@@ -255,6 +292,11 @@ func (s ServiceMeasurement) CreateWaterMeasurement(ctx context.Context, req *pbm
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.CreateWaterMeasurement(ctx, req)
@@ -262,6 +304,8 @@ func (s ServiceMeasurement) CreateWaterMeasurement(ctx context.Context, req *pbm
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	s.setHydrationHeaders(ctx, userID)
+
 	span.SetAttributes(
 		attribute.String("request.id", req.Request.RequestId),
 		attribute.String("request.details", req.String()),
@@ -298,11 +342,21 @@ func (s ServiceMeasurement) GetWaterMeasurements(ctx context.Context, req *pbm.G
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	res, err := s.repo.GetWaterMeasurements(ctx)
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.repo.GetWaterMeasurements(ctx, userID)
 	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	s.setHydrationHeaders(ctx, userID)
+
 	span.SetAttributes(
 		attribute.String("request.id", req.Request.RequestId),
 		attribute.String("request.details", req.String()),
@@ -339,11 +393,18 @@ func (s ServiceMeasurement) GetWaterMeasurement(ctx context.Context, req *pbm.Ge
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	_, err := s.repo.GetWaterMeasurement(ctx, req)
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.repo.GetWaterMeasurement(ctx, req)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	s.setHydrationHeaders(ctx, userID)
+
 	span.SetAttributes(
 		attribute.String("request.id", req.Request.RequestId),
 		attribute.String("request.details", req.String()),
@@ -413,6 +474,11 @@ func (s ServiceMeasurement) UpdateWaterMeasurement(ctx context.Context, req *pbm
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.UpdateWaterMeasurement(ctx, req)
@@ -420,6 +486,8 @@ func (s ServiceMeasurement) UpdateWaterMeasurement(ctx context.Context, req *pbm
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	s.setHydrationHeaders(ctx, userID)
+
 	span.SetAttributes(
 		attribute.String("request.id", req.Request.RequestId),
 		attribute.String("request.details", req.String()),
@@ -459,6 +527,11 @@ func (s ServiceMeasurement) CreateWasteLineMeasurement(ctx context.Context, req
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.CreateWasteLineMeasurement(ctx, req)
@@ -502,8 +575,16 @@ func (s ServiceMeasurement) GetWasteLineMeasurements(ctx context.Context, req *p
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	res, err := s.repo.GetWasteLineMeasurements(ctx)
+	ctx, err := s.withUnits(ctx, userID)
 	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.repo.GetWasteLineMeasurements(ctx, userID)
+	if err != nil {
+		if pagination.BadRequest(err) {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -543,7 +624,12 @@ func (s ServiceMeasurement) GetWasteLineMeasurement(ctx context.Context, req *pb
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
-	_, err := s.repo.GetWasteLineMeasurement(ctx, req)
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.repo.GetWasteLineMeasurement(ctx, req)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -617,6 +703,11 @@ func (s ServiceMeasurement) UpdateWasteLineMeasurement(ctx context.Context, req
 		return nil, status.Error(codes.Unauthenticated, "userID is missing in metadata")
 	}
 
+	ctx, err := s.withUnits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	req.UserId = userID
 
 	res, err := s.repo.UpdateWasteLineMeasurement(ctx, req)
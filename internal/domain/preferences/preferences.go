@@ -0,0 +1,117 @@
+// Package preferences stores per-user display settings.
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/units"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const unitSystemTTL = 10 * time.Minute
+
+// Units resolves the unit system a request is answered in. The preference is
+// cached in redis because every measurement call reads it.
+type Units struct {
+	pgpool *pgxpool.Pool
+	redis  *redis.Client
+}
+
+func NewUnits(db *pgxpool.Pool, redis *redis.Client) *Units {
+	return &Units{pgpool: db, redis: redis}
+}
+
+func unitSystemKey(userID string) string {
+	return "preferences:unit_system:" + userID
+}
+
+// Preferred returns the user's stored system, metric for unknown users.
+func (u *Units) Preferred(ctx context.Context, userID string) (units.System, error) {
+	key := unitSystemKey(userID)
+	if u.redis != nil {
+		if cached, err := u.redis.Get(ctx, key).Result(); err == nil {
+			if system, err := units.ParseSystem(cached); err == nil {
+				return system, nil
+			}
+		}
+	}
+
+	var raw string
+	err := u.pgpool.QueryRow(ctx, `SELECT unit_system FROM users WHERE id = $1`, userID).Scan(&raw)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return units.Metric, nil
+		}
+		return "", fmt.Errorf("failed to fetch unit system: %w", err)
+	}
+	system, err := units.ParseSystem(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if u.redis != nil {
+		if err = u.redis.Set(ctx, key, string(system), unitSystemTTL).Err(); err != nil {
+			logger.Log.Warn("failed to cache unit system", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+	return system, nil
+}
+
+// SetPreferred stores the user's system.
+func (u *Units) SetPreferred(ctx context.Context, userID string, system units.System) error {
+	tag, err := u.pgpool.Exec(ctx, `UPDATE users SET unit_system = $2, updated_at = now() WHERE id = $1`, userID, string(system))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to store unit system: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "user not found")
+	}
+
+	if u.redis != nil {
+		if err = u.redis.Del(ctx, unitSystemKey(userID)).Err(); err != nil {
+			logger.Log.Warn("failed to invalidate unit system", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// Resolve returns the system for this request: the x-unit-system header when
+// present, otherwise the user's preference. The result is also stored on the
+// returned context and echoed back in the response header.
+func (u *Units) Resolve(ctx context.Context, userID string) (context.Context, units.System, error) {
+	system := units.Metric
+	requested := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(units.Header); len(v) > 0 {
+			requested = v[0]
+		}
+	}
+
+	var err error
+	if requested != "" {
+		system, err = units.ParseSystem(requested)
+		if err != nil {
+			return ctx, "", status.Error(codes.InvalidArgument, err.Error())
+		}
+	} else if userID != "" {
+		system, err = u.Preferred(ctx, userID)
+		if err != nil {
+			return ctx, "", status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(units.Header, string(system)))
+	return units.WithSystem(ctx, system), system, nil
+}
@@ -0,0 +1,137 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	// the zone database ships in the binary so slim images resolve zones too
+	_ "time/tzdata"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+// TimezoneHeader overrides the stored timezone for one request, and carries
+// the one used back in the response.
+const TimezoneHeader = "x-timezone"
+
+const timezoneTTL = 10 * time.Minute
+
+// Timezones resolves the IANA zone a user's days and weeks are counted in,
+// cached in redis like the unit system.
+type Timezones struct {
+	pgpool *pgxpool.Pool
+	redis  *redis.Client
+}
+
+func NewTimezones(db *pgxpool.Pool, redis *redis.Client) *Timezones {
+	return &Timezones{pgpool: db, redis: redis}
+}
+
+func timezoneKey(userID string) string {
+	return "preferences:timezone:" + userID
+}
+
+// ParseTimezone reads an IANA zone name such as Europe/Lisbon.
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// Preferred returns the user's stored zone, UTC for unknown users.
+func (t *Timezones) Preferred(ctx context.Context, userID string) (*time.Location, error) {
+	key := timezoneKey(userID)
+	if t.redis != nil {
+		if cached, err := t.redis.Get(ctx, key).Result(); err == nil {
+			if loc, err := ParseTimezone(cached); err == nil {
+				return loc, nil
+			}
+		}
+	}
+
+	var name string
+	err := t.pgpool.QueryRow(ctx, `SELECT timezone FROM users WHERE id = $1`, userID).Scan(&name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.UTC, nil
+		}
+		return nil, fmt.Errorf("failed to fetch timezone: %w", err)
+	}
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.redis != nil {
+		if err = t.redis.Set(ctx, key, loc.String(), timezoneTTL).Err(); err != nil {
+			logger.Log.Warn("failed to cache timezone", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+	return loc, nil
+}
+
+// SetPreferred stores the user's zone.
+func (t *Timezones) SetPreferred(ctx context.Context, userID, name string) error {
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	tag, err := t.pgpool.Exec(ctx, `UPDATE users SET timezone = $2, updated_at = now() WHERE id = $1`, userID, loc.String())
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to store timezone: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "user not found")
+	}
+
+	if t.redis != nil {
+		if err = t.redis.Del(ctx, timezoneKey(userID)).Err(); err != nil {
+			logger.Log.Warn("failed to invalidate timezone", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// Resolve returns the zone for this request: the x-timezone header when
+// present, otherwise the user's preference. The zone used is echoed back in
+// the response header.
+func (t *Timezones) Resolve(ctx context.Context, userID string) (*time.Location, error) {
+	loc := time.UTC
+	requested := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(TimezoneHeader); len(v) > 0 {
+			requested = v[0]
+		}
+	}
+
+	var err error
+	if requested != "" {
+		loc, err = ParseTimezone(requested)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	} else if userID != "" {
+		loc, err = t.Preferred(ctx, userID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(TimezoneHeader, loc.String()))
+	return loc, nil
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	pba "github.com/FACorreiaa/fitme-protos/modules/activity/generated"
 	pbc "github.com/FACorreiaa/fitme-protos/modules/calculator/generated"
@@ -26,12 +27,43 @@ type AuthRepository interface {
 	InsertUser(ctx context.Context, req *pb.InsertUserRequest) (*pb.InsertUserResponse, error)
 }
 
+// UserMacroOptions carries calculator inputs that have no field in the
+// fitme-protos messages yet.
+type UserMacroOptions struct {
+	BMRFormula     string
+	BodyFatPercent *float64
+	// CustomMacros is nil when a calories_distribution preset is used.
+	CustomMacros *CustomMacroOptions
+	Change       MacroChange
+}
+
+// MacroChange attributes a change of the active macro distribution.
+type MacroChange struct {
+	// Source is USER, TRAINER or ADAPTIVE.
+	Source string
+	// ChangedBy is the acting user, empty for adaptive changes.
+	ChangedBy string
+	Reason    string
+}
+
+// CustomMacroOptions is a trainer-defined distribution. Ratios are fractions
+// of calories; the per-kg targets are only set in per_kg mode.
+type CustomMacroOptions struct {
+	Mode         string
+	ProteinRatio float64
+	FatRatio     float64
+	CarbRatio    float64
+	ProteinPerKg float64
+	FatPerKg     float64
+}
+
 type CalculatorRepository interface {
-	CreateUserMacro(ctx context.Context, req *pbc.CreateUserMacroRequest) (*pbc.UserMacroDistribution, error)
+	CreateUserMacro(ctx context.Context, req *pbc.CreateUserMacroRequest, opts UserMacroOptions) (*pbc.UserMacroDistribution, error)
 	GetUsersMacros(ctx context.Context, req *pbc.GetAllUserMacrosRequest) (*pbc.GetAllUserMacrosResponse, error)
 	GetUserMacros(ctx context.Context, req *pbc.GetUserMacroRequest) (*pbc.GetUserMacroResponse, error)
 	DeleteUserMacro(ctx context.Context, req *pbc.DeleteUserMacroRequest) (*pbc.DeleteUserMacroResponse, error)
-	SetActiveUserMacro(ctx context.Context, userID, macroID string) (*pbc.UserMacroDistribution, error)
+	SetActiveUserMacro(ctx context.Context, userID, macroID string, change MacroChange) (*pbc.UserMacroDistribution, error)
+	IsTrainerOf(ctx context.Context, trainerID, clientID string) (bool, error)
 }
 
 type RepositoryActivity interface {
@@ -71,22 +103,36 @@ type RepositoryWorkout interface {
 
 type RepositoryMeasurement interface {
 	CreateWeight(ctx context.Context, req *pbm.CreateWeightReq) (*pbm.XWeight, error)
-	GetWeights(ctx context.Context) ([]*pbm.XWeight, error)
+	GetWeights(ctx context.Context, userID string) ([]*pbm.XWeight, error)
 	GetWeight(ctx context.Context, req *pbm.GetWeightReq) (*pbm.XWeight, error)
 	DeleteWeight(ctx context.Context, req *pbm.DeleteWeightReq) (*pbm.NilRes, error)
 	UpdateWeight(ctx context.Context, req *pbm.UpdateWeightReq) (*pbm.XWeight, error)
 
 	CreateWaterMeasurement(ctx context.Context, req *pbm.CreateWaterIntakeReq) (*pbm.XWaterIntake, error)
-	GetWaterMeasurements(ctx context.Context) ([]*pbm.XWaterIntake, error)
+	GetWaterMeasurements(ctx context.Context, userID string) ([]*pbm.XWaterIntake, error)
 	GetWaterMeasurement(ctx context.Context, req *pbm.GetWaterIntakeReq) (*pbm.XWaterIntake, error)
 	DeleteWaterMeasurement(ctx context.Context, req *pbm.DeleteWaterIntakeReq) (*pbm.NilRes, error)
 	UpdateWaterMeasurement(ctx context.Context, req *pbm.UpdateWaterIntakeReq) (*pbm.XWaterIntake, error)
 
 	CreateWasteLineMeasurement(ctx context.Context, req *pbm.CreateWasteLineReq) (*pbm.XWasteLine, error)
-	GetWasteLineMeasurements(ctx context.Context) ([]*pbm.XWasteLine, error)
+	GetWasteLineMeasurements(ctx context.Context, userID string) ([]*pbm.XWasteLine, error)
 	GetWasteLineMeasurement(ctx context.Context, req *pbm.GetWasteLineReq) (*pbm.XWasteLine, error)
 	DeleteWasteLineMeasurement(ctx context.Context, req *pbm.DeleteWasteLineReq) (*pbm.NilRes, error)
 	UpdateWasteLineMeasurement(ctx context.Context, req *pbm.UpdateWasteLineReq) (*pbm.XWasteLine, error)
+
+	HydrationLog(ctx context.Context, userID string, since time.Time) (*HydrationLog, error)
+}
+
+// HydrationLog holds what a user's daily water targets are computed from.
+// Days are midnight UTC.
+type HydrationLog struct {
+	// WeightKg is the latest weigh-in, or the current macro distribution's
+	// weight when nothing was logged; zero when neither exists.
+	WeightKg float64
+	// Activity is the current macro distribution's activity as stored.
+	Activity        string
+	IntakeMl        map[time.Time]float64
+	ExerciseMinutes map[time.Time]float64
 }
 
 // TrackMealProgressRepository interface
@@ -103,7 +149,7 @@ type MealPlanRepository interface {
 	CreateMealPlan(ctx context.Context, req *pbml.CreateMealPlanReq) (*pbml.XMealPlan, error)
 	UpdateMealPlan(ctx context.Context, req *pbml.UpdateMealPlanReq) (*pbml.XMealPlan, error)
 	DeleteMealPlan(ctx context.Context, req *pbml.DeleteMealPlanReq) (*pbml.NilRes, error)
-	GetUserCalorieLimit(ctx context.Context, userId string) (float64, error)
+	GetUserCalorieLimit(ctx context.Context, userId string, date time.Time) (float64, error)
 	GetMeal(ctx context.Context, req *pbml.GetMealReq) (*pbml.XMeal, error)
 	GetMeals(ctx context.Context, req *pbml.GetMealsReq) ([]*pbml.XMeal, error)
 	CreateMeal(ctx context.Context, req *pbml.CreateMealReq) (*pbml.XMeal, error)
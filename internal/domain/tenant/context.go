@@ -0,0 +1,57 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+)
+
+// The session interceptor stores the active gym next to "userID" and "role".
+const (
+	gymIDKey   = "gymID"
+	gymRoleKey = "gymRole"
+)
+
+func WithMembership(ctx context.Context, m Membership) context.Context {
+	ctx = context.WithValue(ctx, gymIDKey, m.GymID)
+	return context.WithValue(ctx, gymRoleKey, m.Role)
+}
+
+// FromContext returns the caller's active gym. ok is false for users that
+// belong to no gym; they only see the global catalogs.
+func FromContext(ctx context.Context) (m Membership, ok bool) {
+	gymID, _ := ctx.Value(gymIDKey).(string)
+	if gymID == "" {
+		return Membership{}, false
+	}
+	role, _ := ctx.Value(gymRoleKey).(Role)
+	return Membership{GymID: gymID, Role: role}, true
+}
+
+// GymArg is the caller's gym as a query argument, nil without one.
+func GymArg(ctx context.Context) any {
+	if m, ok := FromContext(ctx); ok {
+		return m.GymID
+	}
+	return nil
+}
+
+// Visible restricts a tenant-owned catalog to global rows and rows of the
+// caller's gym. argN is the placeholder number for the returned argument.
+func Visible(ctx context.Context, column string, argN int) (string, any) {
+	return fmt.Sprintf(" AND (%[1]s IS NULL OR %[1]s = $%[2]d::uuid)", column, argN), GymArg(ctx)
+}
+
+// OwnerGym is the gym new catalog rows belong to: the caller's gym for staff,
+// nil (global) for everyone else.
+func OwnerGym(ctx context.Context) any {
+	if m, ok := FromContext(ctx); ok && m.CanManage() {
+		return m.GymID
+	}
+	return nil
+}
+
+// Owned restricts writes to rows in OwnerGym, so staff only edit their own
+// gym's catalog and nobody outside a gym edits it.
+func Owned(ctx context.Context, column string, argN int) (string, any) {
+	return fmt.Sprintf(" AND %s IS NOT DISTINCT FROM $%d::uuid", column, argN), OwnerGym(ctx)
+}
@@ -0,0 +1,67 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScopesWithoutGym(t *testing.T) {
+	ctx := context.Background()
+
+	if _, ok := FromContext(ctx); ok {
+		t.Fatal("expected no membership")
+	}
+	if cond, arg := Visible(ctx, "gym_id", 3); cond != " AND (gym_id IS NULL OR gym_id = $3::uuid)" || arg != nil {
+		t.Errorf("Visible = %q, %v", cond, arg)
+	}
+	if cond, arg := Owned(ctx, "gym_id", 2); cond != " AND gym_id IS NOT DISTINCT FROM $2::uuid" || arg != nil {
+		t.Errorf("Owned = %q, %v", cond, arg)
+	}
+}
+
+func TestScopesForMembersAndStaff(t *testing.T) {
+	member := WithMembership(context.Background(), Membership{GymID: "g1", Role: RoleMember})
+	if _, arg := Visible(member, "gym_id", 1); arg != "g1" {
+		t.Errorf("member Visible arg = %v, want g1", arg)
+	}
+	if arg := OwnerGym(member); arg != nil {
+		t.Errorf("member OwnerGym = %v, want nil", arg)
+	}
+
+	staff := WithMembership(context.Background(), Membership{GymID: "g1", Role: RoleStaff})
+	if arg := OwnerGym(staff); arg != "g1" {
+		t.Errorf("staff OwnerGym = %v, want g1", arg)
+	}
+}
+
+type stubRepo struct {
+	Repository
+	memberships []Membership
+}
+
+func (s stubRepo) Memberships(context.Context, string) ([]Membership, error) {
+	return s.memberships, nil
+}
+
+func TestResolvePicksGym(t *testing.T) {
+	r := NewResolver(stubRepo{memberships: []Membership{
+		{GymID: "g1", Role: RoleMember},
+		{GymID: "g2", Role: RoleAdmin},
+		{GymID: "g3", Role: RoleStaff},
+	}}, nil)
+	ctx := context.Background()
+
+	m, ok, err := r.Resolve(ctx, "u1", "")
+	if err != nil || !ok || m.GymID != "g2" {
+		t.Errorf("default = %+v, %v, %v; want g2", m, ok, err)
+	}
+
+	m, ok, err = r.Resolve(ctx, "u1", "g1")
+	if err != nil || !ok || m.Role != RoleMember {
+		t.Errorf("requested = %+v, %v, %v; want g1 member", m, ok, err)
+	}
+
+	if _, _, err = r.Resolve(ctx, "u1", "other"); err == nil {
+		t.Error("expected an error for a gym the user is not part of")
+	}
+}
@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"context"
+	"time"
+)
+
+// GymHeader lets a user who belongs to several gyms pick the active one.
+// Without it the highest ranked membership is used.
+const GymHeader = "x-gym-id"
+
+type Role string
+
+const (
+	RoleMember Role = "MEMBER"
+	RoleStaff  Role = "STAFF"
+	RoleAdmin  Role = "ADMIN"
+)
+
+// rank orders roles so the strongest membership wins when no gym is picked.
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 2
+	case RoleStaff:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Membership is a user's link to a gym, either as staff (gym_staff) or as a
+// member (gym_members).
+type Membership struct {
+	GymID string `json:"gym_id"`
+	Role  Role   `json:"role"`
+}
+
+// CanManage reports whether the membership may edit the gym's catalogs.
+func (m Membership) CanManage() bool {
+	return m.Role == RoleStaff || m.Role == RoleAdmin
+}
+
+type Gym struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Address   string    `json:"address" db:"address"`
+	Phone     string    `json:"phone" db:"phone"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type Member struct {
+	UserID    string    `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	Role      Role      `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateGymRequest struct {
+	Name    string
+	Address string
+	Phone   string
+}
+
+type Repository interface {
+	Memberships(ctx context.Context, userID string) ([]Membership, error)
+	CreateGym(ctx context.Context, gym *Gym, adminID string) (*Gym, error)
+	ListMembers(ctx context.Context, gymID string) ([]*Member, error)
+	AddMember(ctx context.Context, gymID, userID string) error
+	RemoveMember(ctx context.Context, gymID, userID string) error
+	SetStaff(ctx context.Context, gymID, userID string, role Role) error
+	RemoveStaff(ctx context.Context, gymID, userID string) error
+}
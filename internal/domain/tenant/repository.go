@@ -0,0 +1,164 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+)
+
+type RepositoryTenant struct {
+	pgpool         *pgxpool.Pool
+	redis          *redis.Client
+	sessionManager *auth.SessionManager
+}
+
+func NewRepositoryTenant(db *pgxpool.Pool, redis *redis.Client, sessionManager *auth.SessionManager) *RepositoryTenant {
+	return &RepositoryTenant{pgpool: db, redis: redis, sessionManager: sessionManager}
+}
+
+func (r *RepositoryTenant) Memberships(ctx context.Context, userID string) ([]Membership, error) {
+	query := `
+		SELECT gym_id::text, role FROM gym_staff WHERE user_id = $1
+		UNION ALL
+		SELECT gym_id::text, 'MEMBER' FROM gym_members WHERE user_id = $1`
+
+	rows, err := r.pgpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gym memberships: %w", err)
+	}
+	defer rows.Close()
+
+	memberships := make([]Membership, 0)
+	for rows.Next() {
+		var m Membership
+		if err = rows.Scan(&m.GymID, &m.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan gym membership: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *RepositoryTenant) CreateGym(ctx context.Context, gym *Gym, adminID string) (*Gym, error) {
+	tx, err := r.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to start transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	created := *gym
+	err = tx.QueryRow(ctx, `
+		INSERT INTO gyms (name, address, phone, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+		RETURNING id, created_at`, gym.Name, gym.Address, gym.Phone).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gym: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO gym_staff (gym_id, user_id, role) VALUES ($1, $2, $3)`,
+		created.ID, adminID, RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add gym admin: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (r *RepositoryTenant) ListMembers(ctx context.Context, gymID string) ([]*Member, error) {
+	query := `
+		SELECT u.id, u.username, u.email, s.role, s.created_at
+		FROM gym_staff s JOIN users u ON u.id = s.user_id
+		WHERE s.gym_id = $1
+		UNION ALL
+		SELECT u.id, u.username, u.email, 'MEMBER', m.created_at
+		FROM gym_members m JOIN users u ON u.id = m.user_id
+		WHERE m.gym_id = $1
+		ORDER BY 5`
+
+	rows, err := r.pgpool.Query(ctx, query, gymID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gym members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]*Member, 0)
+	for rows.Next() {
+		var m Member
+		if err = rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan gym member: %w", err)
+		}
+		members = append(members, &m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *RepositoryTenant) AddMember(ctx context.Context, gymID, userID string) error {
+	_, err := r.pgpool.Exec(ctx, `
+		INSERT INTO gym_members (gym_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, gymID, userID)
+	if err != nil {
+		return userError(err, "failed to add gym member")
+	}
+	return nil
+}
+
+func (r *RepositoryTenant) RemoveMember(ctx context.Context, gymID, userID string) error {
+	tag, err := r.pgpool.Exec(ctx, `DELETE FROM gym_members WHERE gym_id = $1 AND user_id = $2`, gymID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove gym member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "gym member not found")
+	}
+	return nil
+}
+
+func (r *RepositoryTenant) SetStaff(ctx context.Context, gymID, userID string, role Role) error {
+	_, err := r.pgpool.Exec(ctx, `
+		INSERT INTO gym_staff (gym_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (gym_id, user_id) DO UPDATE SET role = EXCLUDED.role, updated_at = now()`,
+		gymID, userID, role)
+	if err != nil {
+		return userError(err, "failed to set gym staff")
+	}
+	return nil
+}
+
+func (r *RepositoryTenant) RemoveStaff(ctx context.Context, gymID, userID string) error {
+	tag, err := r.pgpool.Exec(ctx, `DELETE FROM gym_staff WHERE gym_id = $1 AND user_id = $2`, gymID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove gym staff: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "gym staff not found")
+	}
+	return nil
+}
+
+// userError maps a foreign key violation on user_id to NotFound.
+func userError(err error, msg string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return status.Error(codes.NotFound, "user not found")
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
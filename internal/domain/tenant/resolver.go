@@ -0,0 +1,94 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const membershipsTTL = 5 * time.Minute
+
+// Resolver looks up the active gym for a request. Memberships are cached in
+// redis because the lookup runs on every authenticated call.
+type Resolver struct {
+	repo  Repository
+	redis *redis.Client
+}
+
+func NewResolver(repo Repository, redis *redis.Client) *Resolver {
+	return &Resolver{repo: repo, redis: redis}
+}
+
+func membershipsKey(userID string) string {
+	return "tenant:memberships:" + userID
+}
+
+// Resolve returns the membership for requestedGymID, or the highest ranked
+// one when it is empty. ok is false if the user belongs to no gym. Asking
+// for a gym the user is not part of is PermissionDenied.
+func (r *Resolver) Resolve(ctx context.Context, userID, requestedGymID string) (m Membership, ok bool, err error) {
+	memberships, err := r.memberships(ctx, userID)
+	if err != nil {
+		return Membership{}, false, status.Error(codes.Internal, "failed to resolve gym membership")
+	}
+
+	for _, candidate := range memberships {
+		if requestedGymID != "" {
+			if candidate.GymID == requestedGymID {
+				return candidate, true, nil
+			}
+			continue
+		}
+		if !ok || candidate.Role.rank() > m.Role.rank() {
+			m, ok = candidate, true
+		}
+	}
+	if requestedGymID != "" {
+		return Membership{}, false, status.Error(codes.PermissionDenied, "not a member of the requested gym")
+	}
+
+	return m, ok, nil
+}
+
+// Invalidate drops the cached memberships after they change.
+func (r *Resolver) Invalidate(ctx context.Context, userID string) {
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Del(ctx, membershipsKey(userID)).Err(); err != nil {
+		logger.Log.Warn("failed to invalidate gym memberships", zap.String("user_id", userID), zap.Error(err))
+	}
+}
+
+func (r *Resolver) memberships(ctx context.Context, userID string) ([]Membership, error) {
+	key := membershipsKey(userID)
+	if r.redis != nil {
+		if cached, err := r.redis.Get(ctx, key).Bytes(); err == nil {
+			var memberships []Membership
+			if err = json.Unmarshal(cached, &memberships); err == nil {
+				return memberships, nil
+			}
+		}
+	}
+
+	memberships, err := r.repo.Memberships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.redis != nil {
+		data, _ := json.Marshal(memberships)
+		if err = r.redis.Set(ctx, key, data, membershipsTTL).Err(); err != nil {
+			logger.Log.Warn("failed to cache gym memberships", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+
+	return memberships, nil
+}
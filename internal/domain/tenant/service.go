@@ -0,0 +1,169 @@
+package tenant
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceTenant lets gym admins manage their own tenant. The methods take
+// plain Go arguments and act on the gym resolved for the session.
+type ServiceTenant struct {
+	ctx      context.Context
+	repo     Repository
+	resolver *Resolver
+}
+
+func NewServiceTenant(ctx context.Context, repo Repository, resolver *Resolver) *ServiceTenant {
+	return &ServiceTenant{ctx: ctx, repo: repo, resolver: resolver}
+}
+
+func callerID(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok || userID == "" {
+		return "", status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
+	return userID, nil
+}
+
+// requireGym returns the caller's active gym if their gym role allows it.
+func requireGym(ctx context.Context, adminOnly bool) (Membership, error) {
+	m, ok := FromContext(ctx)
+	if !ok {
+		return Membership{}, status.Error(codes.FailedPrecondition, "caller does not belong to a gym")
+	}
+	if !m.CanManage() || (adminOnly && m.Role != RoleAdmin) {
+		return Membership{}, status.Error(codes.PermissionDenied, "insufficient gym role")
+	}
+	return m, nil
+}
+
+// CreateGym creates a tenant with the caller as its first admin. Only users
+// with the GYM or ADMIN role may create gyms.
+func (s *ServiceTenant) CreateGym(ctx context.Context, req CreateGymRequest) (*Gym, error) {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "CreateGym")
+	defer span.End()
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if role, _ := ctx.Value("role").(string); role != "GYM" && role != "ADMIN" {
+		return nil, status.Error(codes.PermissionDenied, "only gym accounts can create gyms")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "gym name is required")
+	}
+
+	gym, err := s.repo.CreateGym(ctx, &Gym{Name: req.Name, Address: req.Address, Phone: req.Phone}, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.resolver.Invalidate(ctx, userID)
+
+	span.SetAttributes(attribute.String("gym.id", gym.ID))
+
+	return gym, nil
+}
+
+func (s *ServiceTenant) ListMembers(ctx context.Context) ([]*Member, error) {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "ListMembers")
+	defer span.End()
+
+	m, err := requireGym(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListMembers(ctx, m.GymID)
+}
+
+func (s *ServiceTenant) AddMember(ctx context.Context, userID string) error {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "AddMember")
+	defer span.End()
+
+	m, err := requireGym(ctx, false)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err = s.repo.AddMember(ctx, m.GymID, userID); err != nil {
+		return err
+	}
+	s.resolver.Invalidate(ctx, userID)
+
+	return nil
+}
+
+func (s *ServiceTenant) RemoveMember(ctx context.Context, userID string) error {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "RemoveMember")
+	defer span.End()
+
+	m, err := requireGym(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.RemoveMember(ctx, m.GymID, userID); err != nil {
+		return err
+	}
+	s.resolver.Invalidate(ctx, userID)
+
+	return nil
+}
+
+// SetStaff grants a user the STAFF or ADMIN role in the caller's gym.
+func (s *ServiceTenant) SetStaff(ctx context.Context, userID string, role Role) error {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "SetStaff")
+	defer span.End()
+
+	m, err := requireGym(ctx, true)
+	if err != nil {
+		return err
+	}
+	if role != RoleStaff && role != RoleAdmin {
+		return status.Errorf(codes.InvalidArgument, "unsupported gym role %q", role)
+	}
+	if userID == "" {
+		return status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err = s.repo.SetStaff(ctx, m.GymID, userID, role); err != nil {
+		return err
+	}
+	s.resolver.Invalidate(ctx, userID)
+
+	return nil
+}
+
+func (s *ServiceTenant) RemoveStaff(ctx context.Context, userID string) error {
+	tracer := otel.Tracer("Tenant")
+	ctx, span := tracer.Start(ctx, "RemoveStaff")
+	defer span.End()
+
+	m, err := requireGym(ctx, true)
+	if err != nil {
+		return err
+	}
+	if self, _ := callerID(ctx); self == userID {
+		return status.Error(codes.FailedPrecondition, "admins cannot remove themselves")
+	}
+
+	if err = s.repo.RemoveStaff(ctx, m.GymID, userID); err != nil {
+		return err
+	}
+	s.resolver.Invalidate(ctx, userID)
+
+	return nil
+}
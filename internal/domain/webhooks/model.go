@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+)
+
+const (
+	// PingEventType is sent by the test-ping call and never by the outbox.
+	PingEventType = "ping"
+
+	deliverJobKind     = "webhooks.deliver"
+	deliverMaxAttempts = 8
+)
+
+// SubscribableEvents are the event types an endpoint may subscribe to.
+var SubscribableEvents = map[string]bool{
+	string(events.WorkoutCompleted):  true,
+	string(events.WeightLogged):      true,
+	string(events.FoodLogged):        true,
+	string(events.FriendRequestSent): true,
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliverySucceeded DeliveryStatus = "SUCCEEDED"
+	DeliveryFailed    DeliveryStatus = "FAILED"
+)
+
+type Endpoint struct {
+	ID          string    `json:"id" db:"id"`
+	OwnerID     string    `json:"owner_id" db:"owner_id"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"-" db:"secret"`
+	EventTypes  []string  `json:"event_types" db:"event_types"`
+	Description string    `json:"description" db:"description"`
+	Active      bool      `json:"active" db:"active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type Delivery struct {
+	ID             string          `json:"id" db:"id"`
+	EndpointID     string          `json:"endpoint_id" db:"endpoint_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	UserID         string          `json:"user_id" db:"user_id"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         DeliveryStatus  `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus int             `json:"response_status" db:"response_status"`
+	ResponseBody   string          `json:"response_body" db:"response_body"`
+	LastError      string          `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+// Message is the JSON body posted to an endpoint.
+type Message struct {
+	DeliveryID string          `json:"delivery_id"`
+	EventID    string          `json:"event_id,omitempty"`
+	EventType  string          `json:"event_type"`
+	UserID     string          `json:"user_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Attempt is the outcome of a single HTTP delivery.
+type Attempt struct {
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+type RegisterEndpointRequest struct {
+	URL         string
+	EventTypes  []string
+	Description string
+}
+
+type RegisterEndpointResponse struct {
+	Endpoint *Endpoint
+	// Secret is only returned once, at registration.
+	Secret string
+}
+
+type ListDeliveriesRequest struct {
+	EndpointID string
+	Limit      int
+}
+
+type Repository interface {
+	CreateEndpoint(ctx context.Context, endpoint *Endpoint) (*Endpoint, error)
+	GetEndpoint(ctx context.Context, ownerID, endpointID string) (*Endpoint, error)
+	ListEndpoints(ctx context.Context, ownerID string) ([]*Endpoint, error)
+	DeleteEndpoint(ctx context.Context, ownerID, endpointID string) error
+	QueueDeliveries(ctx context.Context, evt events.Event) (int, error)
+	CreatePingDelivery(ctx context.Context, endpoint *Endpoint, payload json.RawMessage) (*Delivery, error)
+	GetDeliveryTarget(ctx context.Context, deliveryID string) (*Delivery, *Endpoint, error)
+	RecordAttempt(ctx context.Context, deliveryID string, attempt Attempt, final bool) error
+	ListDeliveries(ctx context.Context, ownerID string, req ListDeliveriesRequest) ([]*Delivery, error)
+	ReplayDelivery(ctx context.Context, ownerID, deliveryID string) (*Delivery, error)
+}
@@ -0,0 +1,343 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
+)
+
+type RepositoryWebhooks struct {
+	pgpool         *pgxpool.Pool
+	redis          *redis.Client
+	sessionManager *auth.SessionManager
+	queue          *jobs.Queue
+}
+
+func NewRepositoryWebhooks(db *pgxpool.Pool, redis *redis.Client, sessionManager *auth.SessionManager, queue *jobs.Queue) *RepositoryWebhooks {
+	return &RepositoryWebhooks{pgpool: db, redis: redis, sessionManager: sessionManager, queue: queue}
+}
+
+type deliverJob struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+const deliveryColumns = `id, endpoint_id, COALESCE(event_id::text, ''), event_type, COALESCE(user_id::text, ''),
+	payload, status, attempts, COALESCE(response_status, 0), COALESCE(response_body, ''),
+	COALESCE(last_error, ''), created_at, delivered_at`
+
+func scanDelivery(row pgx.Row) (*Delivery, error) {
+	var d Delivery
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.UserID, &d.Payload, &d.Status,
+		&d.Attempts, &d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *RepositoryWebhooks) CreateEndpoint(ctx context.Context, endpoint *Endpoint) (*Endpoint, error) {
+	query := `
+		INSERT INTO webhook_endpoints (owner_id, url, secret, event_types, description, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, now())
+		RETURNING id, active, created_at`
+
+	created := *endpoint
+	err := r.pgpool.QueryRow(ctx, query, endpoint.OwnerID, endpoint.URL, endpoint.Secret,
+		endpoint.EventTypes, endpoint.Description).Scan(&created.ID, &created.Active, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (r *RepositoryWebhooks) GetEndpoint(ctx context.Context, ownerID, endpointID string) (*Endpoint, error) {
+	query := `
+		SELECT id, owner_id, url, secret, event_types, COALESCE(description, ''), active, created_at
+		FROM webhook_endpoints
+		WHERE id = $1 AND owner_id = $2`
+
+	var e Endpoint
+	err := r.pgpool.QueryRow(ctx, query, endpointID, ownerID).Scan(
+		&e.ID, &e.OwnerID, &e.URL, &e.Secret, &e.EventTypes, &e.Description, &e.Active, &e.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "webhook endpoint not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	return &e, nil
+}
+
+func (r *RepositoryWebhooks) ListEndpoints(ctx context.Context, ownerID string) ([]*Endpoint, error) {
+	query := `
+		SELECT id, owner_id, url, event_types, COALESCE(description, ''), active, created_at
+		FROM webhook_endpoints
+		WHERE owner_id = $1
+		ORDER BY created_at`
+
+	rows, err := r.pgpool.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]*Endpoint, 0)
+	for rows.Next() {
+		var e Endpoint
+		if err = rows.Scan(&e.ID, &e.OwnerID, &e.URL, &e.EventTypes, &e.Description, &e.Active, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+func (r *RepositoryWebhooks) DeleteEndpoint(ctx context.Context, ownerID, endpointID string) error {
+	cmdTag, err := r.pgpool.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND owner_id = $2`, endpointID, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return status.Error(codes.NotFound, "webhook endpoint not found")
+	}
+	return nil
+}
+
+// QueueDeliveries creates a delivery for every endpoint interested in evt:
+// the user's own endpoints and those of their trainers. Deliveries are
+// unique per (endpoint, event) so a redelivered event is not sent twice.
+func (r *RepositoryWebhooks) QueueDeliveries(ctx context.Context, evt events.Event) (int, error) {
+	if evt.UserID == "" {
+		return 0, nil
+	}
+
+	tx, err := r.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM webhook_endpoints
+		WHERE active AND $2 = ANY(event_types)
+		  AND (owner_id = $1 OR owner_id IN (SELECT trainer_id FROM trainer_clients WHERE client_id = $1))`,
+		evt.UserID, string(evt.Type))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find webhook endpoints: %w", err)
+	}
+	var endpointIDs []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpointIDs = append(endpointIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	queued := 0
+	for _, endpointID := range endpointIDs {
+		var deliveryID string
+		err = tx.QueryRow(ctx, `
+			INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, user_id, payload, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (endpoint_id, event_id) DO NOTHING
+			RETURNING id`, endpointID, evt.ID, string(evt.Type), evt.UserID, evt.Payload, evt.CreatedAt).Scan(&deliveryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to create webhook delivery: %w", err)
+		}
+
+		if _, err = r.queue.EnqueueTx(ctx, tx, deliverJobKind, deliverJob{DeliveryID: deliveryID},
+			jobs.EnqueueOptions{MaxAttempts: deliverMaxAttempts}); err != nil {
+			return 0, err
+		}
+		queued++
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return queued, nil
+}
+
+func (r *RepositoryWebhooks) CreatePingDelivery(ctx context.Context, endpoint *Endpoint, payload json.RawMessage) (*Delivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_type, user_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING ` + deliveryColumns
+
+	d, err := scanDelivery(r.pgpool.QueryRow(ctx, query, endpoint.ID, PingEventType, endpoint.OwnerID, payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ping delivery: %w", err)
+	}
+	return d, nil
+}
+
+// errEndpointDeleted comes with a delivery whose endpoint is gone. Deleting
+// an endpoint cascades to its deliveries, so this only happens when the two
+// race; a delivery found without its endpoint is never sent.
+var errEndpointDeleted = status.Error(codes.NotFound, "webhook endpoint was deleted")
+
+func (r *RepositoryWebhooks) GetDeliveryTarget(ctx context.Context, deliveryID string) (*Delivery, *Endpoint, error) {
+	d, err := scanDelivery(r.pgpool.QueryRow(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, deliveryID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, status.Error(codes.NotFound, "webhook delivery not found")
+		}
+		return nil, nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	var e Endpoint
+	err = r.pgpool.QueryRow(ctx, `
+		SELECT id, owner_id, url, secret, event_types, COALESCE(description, ''), active, created_at
+		FROM webhook_endpoints WHERE id = $1`, d.EndpointID).Scan(
+		&e.ID, &e.OwnerID, &e.URL, &e.Secret, &e.EventTypes, &e.Description, &e.Active, &e.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, nil, errEndpointDeleted
+		}
+		return nil, nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	return d, &e, nil
+}
+
+// RecordAttempt stores the outcome of one HTTP attempt. A failed attempt
+// leaves the delivery PENDING unless it was the final one.
+func (r *RepositoryWebhooks) RecordAttempt(ctx context.Context, deliveryID string, attempt Attempt, final bool) error {
+	deliveryStatus := DeliveryPending
+	var lastError *string
+	var deliveredAt *time.Time
+	switch {
+	case attempt.Err == nil:
+		deliveryStatus = DeliverySucceeded
+		now := time.Now()
+		deliveredAt = &now
+	case final:
+		deliveryStatus = DeliveryFailed
+	}
+	if attempt.Err != nil {
+		msg := attempt.Err.Error()
+		lastError = &msg
+	}
+
+	var responseStatus *int
+	if attempt.StatusCode != 0 {
+		responseStatus = &attempt.StatusCode
+	}
+
+	_, err := r.pgpool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, response_body = $4,
+		    last_error = $5, delivered_at = COALESCE($6, delivered_at), updated_at = now()
+		WHERE id = $1`, deliveryID, string(deliveryStatus), responseStatus, attempt.Body, lastError, deliveredAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryWebhooks) ListDeliveries(ctx context.Context, ownerID string, req ListDeliveriesRequest) ([]*Delivery, error) {
+	query := `
+		SELECT d.id, d.endpoint_id, COALESCE(d.event_id::text, ''), d.event_type, COALESCE(d.user_id::text, ''),
+		       d.payload, d.status, d.attempts, COALESCE(d.response_status, 0), COALESCE(d.response_body, ''),
+		       COALESCE(d.last_error, ''), d.created_at, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE e.owner_id = $1 AND ($2 = '' OR d.endpoint_id::text = $2)
+		ORDER BY d.created_at DESC
+		LIMIT $3`
+
+	rows, err := r.pgpool.Query(ctx, query, ownerID, req.EndpointID, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// ReplayDelivery puts a delivery back in the queue with a fresh retry budget.
+func (r *RepositoryWebhooks) ReplayDelivery(ctx context.Context, ownerID, deliveryID string) (*Delivery, error) {
+	tx, err := r.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	query := `
+		UPDATE webhook_deliveries d
+		SET status = 'PENDING', last_error = NULL, updated_at = now()
+		FROM webhook_endpoints e
+		WHERE d.id = $1 AND e.id = d.endpoint_id AND e.owner_id = $2
+		RETURNING d.id, d.endpoint_id, COALESCE(d.event_id::text, ''), d.event_type, COALESCE(d.user_id::text, ''),
+		          d.payload, d.status, d.attempts, COALESCE(d.response_status, 0), COALESCE(d.response_body, ''),
+		          COALESCE(d.last_error, ''), d.created_at, d.delivered_at`
+
+	d, err := scanDelivery(tx.QueryRow(ctx, query, deliveryID, ownerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "webhook delivery not found")
+		}
+		return nil, fmt.Errorf("failed to reset webhook delivery: %w", err)
+	}
+
+	if _, err = r.queue.EnqueueTx(ctx, tx, deliverJobKind, deliverJob{DeliveryID: d.ID},
+		jobs.EnqueueOptions{MaxAttempts: deliverMaxAttempts}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return d, nil
+}
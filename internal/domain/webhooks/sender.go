@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Fitme-Signature"
+	EventHeader     = "X-Fitme-Event"
+	DeliveryHeader  = "X-Fitme-Delivery"
+
+	defaultSendTimeout  = 10 * time.Second
+	maxRedirects        = 5
+	maxRecordedBodySize = 1024
+)
+
+// Sender posts signed messages to endpoints.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender uses client, or when it is nil one that refuses to connect to
+// internal addresses and to follow redirects off https.
+func NewSender(client *http.Client) *Sender {
+	if client == nil {
+		client = publicClient()
+	}
+	return &Sender{client: client}
+}
+
+// publicClient checks every address it dials, after DNS resolution, so a
+// hostname that resolves or rebinds to an internal address is refused too.
+func publicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: defaultSendTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return fmt.Errorf("refusing to deliver to internal address %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   defaultSendTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errors.New("refusing to follow a redirect off https")
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// carrierGradeNAT is the shared address space of RFC 6598, which net.IP
+// does not count as private.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// internalIP reports whether ip is loopback, private, link-local or
+// otherwise not a public unicast address.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || carrierGradeNAT.Contains(ip)
+}
+
+// Send posts body to endpointURL. Any non-2xx response is reported as an
+// error so the caller retries.
+func (s *Sender) Send(ctx context.Context, endpointURL, secret string, msg Message, body []byte) Attempt {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return Attempt{Err: fmt.Errorf("failed to build request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fitme-webhooks/1")
+	req.Header.Set(EventHeader, msg.EventType)
+	req.Header.Set(DeliveryHeader, msg.DeliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return Attempt{Err: fmt.Errorf("request failed: %w", err)}
+	}
+	defer res.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(res.Body, maxRecordedBodySize))
+	attempt := Attempt{StatusCode: res.StatusCode, Body: string(respBody)}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Err = fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+
+	return attempt
+}
+
+// Sign returns the signature header value: "t=<unix>,v1=<hex hmac>", where
+// the HMAC-SHA256 covers "<unix>.<body>" so a captured request cannot be
+// replayed later with a fresh timestamp.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + computeMAC(secret, unix, body)
+}
+
+// Verify checks a signature header against body. Receivers can use it as a
+// reference implementation; tolerance bounds the accepted clock skew.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var unix, mac string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			unix = v
+		case "v1":
+			mac = v
+		}
+	}
+	if unix == "" || mac == "" {
+		return errors.New("malformed signature header")
+	}
+
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > tolerance || skew < -tolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(computeMAC(secret, unix, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func computeMAC(secret, unix string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(unix))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// validateURL accepts https URLs that don't name an internal host outright.
+// Hostnames are checked again on every delivery, once resolved.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return errors.New("url scheme must be https")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return errors.New("url host is required")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url host must be public")
+	}
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return errors.New("url host must be public")
+	}
+	return nil
+}
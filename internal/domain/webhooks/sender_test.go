@@ -0,0 +1,119 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSenderSignsAndPosts(t *testing.T) {
+	const secret = "whsec_test"
+
+	var gotEvent, gotDelivery string
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotEvent = r.Header.Get(EventHeader)
+		gotDelivery = r.Header.Get(DeliveryHeader)
+		verifyErr = Verify(secret, r.Header.Get(SignatureHeader), body, time.Now(), time.Minute)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	msg := Message{DeliveryID: "d-1", EventType: "WeightLogged", Data: json.RawMessage(`{"weight_value":80}`)}
+	body, _ := json.Marshal(msg)
+
+	attempt := NewSender(srv.Client()).Send(context.Background(), srv.URL, secret, msg, body)
+	if attempt.Err != nil {
+		t.Fatalf("unexpected error: %v", attempt.Err)
+	}
+	if attempt.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", attempt.StatusCode, http.StatusNoContent)
+	}
+	if verifyErr != nil {
+		t.Errorf("signature did not verify: %v", verifyErr)
+	}
+	if gotEvent != "WeightLogged" || gotDelivery != "d-1" {
+		t.Errorf("headers = %q/%q", gotEvent, gotDelivery)
+	}
+}
+
+func TestSenderReportsNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	attempt := NewSender(srv.Client()).Send(context.Background(), srv.URL, "s", Message{}, []byte(`{}`))
+	if attempt.Err == nil {
+		t.Fatal("expected error for 502 response")
+	}
+	if attempt.StatusCode != http.StatusBadGateway || attempt.Body != "boom\n" {
+		t.Errorf("attempt = %+v", attempt)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"a":1}`)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now, time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := Verify("secret", header, []byte(`{"a":2}`), now, time.Minute); err == nil {
+		t.Error("modified body accepted")
+	}
+	if err := Verify("other", header, body, now, time.Minute); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := Verify("secret", header, body, now.Add(10*time.Minute), time.Minute); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+}
+
+func TestSenderRefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	attempt := NewSender(nil).Send(context.Background(), srv.URL, "s", Message{}, []byte(`{}`))
+	if attempt.Err == nil || !strings.Contains(attempt.Err.Error(), "internal address") || called {
+		t.Errorf("delivery to %s: err = %v, reached = %v", srv.URL, attempt.Err, called)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://hooks.example.com/fitme", true},
+		{"https://93.184.216.34/hook", true},
+		{"http://hooks.example.com/fitme", false},
+		{"ftp://hooks.example.com", false},
+		{"https://", false},
+		{"https://localhost:8080/hook", false},
+		{"https://api.localhost./hook", false},
+		{"https://127.0.0.1/hook", false},
+		{"https://10.0.0.5/hook", false},
+		{"https://192.168.1.1/hook", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://100.64.0.1/hook", false},
+		{"https://[::1]/hook", false},
+		{"https://[fd00::1]/hook", false},
+		{"https://[::ffff:10.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		if err := validateURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("validateURL(%q) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/events"
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+// ServiceWebhooks manages endpoints and deliveries. The methods take plain
+// Go request types and read the caller from the session context.
+type ServiceWebhooks struct {
+	ctx    context.Context
+	repo   Repository
+	sender *Sender
+}
+
+func NewServiceWebhooks(ctx context.Context, repo Repository, sender *Sender) *ServiceWebhooks {
+	return &ServiceWebhooks{ctx: ctx, repo: repo, sender: sender}
+}
+
+// Register subscribes the service to the event bus and the delivery queue.
+func (s *ServiceWebhooks) Register(bus *events.Bus, queue *jobs.Queue) {
+	bus.SubscribeAll(s.handleEvent)
+	queue.Register(deliverJobKind, s.deliver)
+}
+
+func callerID(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok || userID == "" {
+		return "", status.Error(codes.Unauthenticated, "userID is missing in metadata")
+	}
+	return userID, nil
+}
+
+func (s *ServiceWebhooks) RegisterEndpoint(ctx context.Context, req RegisterEndpointRequest) (*RegisterEndpointResponse, error) {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "RegisterEndpoint")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateURL(req.URL); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(req.EventTypes) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one event type is required")
+	}
+	for _, t := range req.EventTypes {
+		if !SubscribableEvents[t] {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown event type %q", t)
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to generate secret")
+	}
+
+	endpoint, err := s.repo.CreateEndpoint(ctx, &Endpoint{
+		OwnerID:     ownerID,
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	span.SetAttributes(attribute.String("webhook.endpoint_id", endpoint.ID))
+
+	return &RegisterEndpointResponse{Endpoint: endpoint, Secret: secret}, nil
+}
+
+func (s *ServiceWebhooks) ListEndpoints(ctx context.Context) ([]*Endpoint, error) {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "ListEndpoints")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListEndpoints(ctx, ownerID)
+}
+
+func (s *ServiceWebhooks) DeleteEndpoint(ctx context.Context, endpointID string) error {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "DeleteEndpoint")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+	if endpointID == "" {
+		return status.Error(codes.InvalidArgument, "endpoint id is required")
+	}
+
+	return s.repo.DeleteEndpoint(ctx, ownerID, endpointID)
+}
+
+func (s *ServiceWebhooks) ListDeliveries(ctx context.Context, req ListDeliveriesRequest) ([]*Delivery, error) {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "ListDeliveries")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = defaultDeliveriesLimit
+	}
+	if req.Limit > maxDeliveriesLimit {
+		req.Limit = maxDeliveriesLimit
+	}
+
+	return s.repo.ListDeliveries(ctx, ownerID, req)
+}
+
+func (s *ServiceWebhooks) ReplayDelivery(ctx context.Context, deliveryID string) (*Delivery, error) {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "ReplayDelivery")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deliveryID == "" {
+		return nil, status.Error(codes.InvalidArgument, "delivery id is required")
+	}
+
+	return s.repo.ReplayDelivery(ctx, ownerID, deliveryID)
+}
+
+// PingEndpoint sends a test message synchronously and returns the recorded
+// delivery, so integrators can check their URL and signature verification.
+func (s *ServiceWebhooks) PingEndpoint(ctx context.Context, endpointID string) (*Delivery, error) {
+	tracer := otel.Tracer("Webhooks")
+	ctx, span := tracer.Start(ctx, "PingEndpoint")
+	defer span.End()
+
+	ownerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.repo.GetEndpoint(ctx, ownerID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(map[string]string{"message": "pong"})
+	delivery, err := s.repo.CreatePingDelivery(ctx, endpoint, payload)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	attempt := s.send(ctx, delivery, endpoint)
+	if err = s.repo.RecordAttempt(ctx, delivery.ID, attempt, true); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus = attempt.StatusCode
+	delivery.ResponseBody = attempt.Body
+	delivery.Status = DeliverySucceeded
+	if attempt.Err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.LastError = attempt.Err.Error()
+	}
+
+	span.SetAttributes(attribute.Int("webhook.response_status", attempt.StatusCode))
+
+	return delivery, nil
+}
+
+func (s *ServiceWebhooks) handleEvent(ctx context.Context, evt events.Event) error {
+	if !SubscribableEvents[string(evt.Type)] {
+		return nil
+	}
+	_, err := s.repo.QueueDeliveries(ctx, evt)
+	return err
+}
+
+func (s *ServiceWebhooks) deliver(ctx context.Context, job jobs.Job) error {
+	var payload deliverJob
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode delivery job: %w", err)
+	}
+
+	delivery, endpoint, err := s.repo.GetDeliveryTarget(ctx, payload.DeliveryID)
+	switch {
+	case errors.Is(err, errEndpointDeleted):
+		return s.repo.RecordAttempt(ctx, delivery.ID, Attempt{Err: err}, true)
+	case status.Code(err) == codes.NotFound:
+		// deleting the endpoint took the delivery with it; retrying can't
+		// bring either back
+		return nil
+	case err != nil:
+		return err
+	}
+	if delivery.Status == DeliverySucceeded {
+		return nil
+	}
+	if !endpoint.Active {
+		return s.repo.RecordAttempt(ctx, delivery.ID, Attempt{Err: fmt.Errorf("endpoint disabled")}, true)
+	}
+
+	attempt := s.send(ctx, delivery, endpoint)
+	final := job.Attempts >= job.MaxAttempts
+	if err = s.repo.RecordAttempt(ctx, delivery.ID, attempt, final); err != nil {
+		logger.Log.Error("failed to record webhook attempt", zap.String("delivery_id", delivery.ID), zap.Error(err))
+	}
+
+	// returning the error lets the job queue retry with backoff
+	return attempt.Err
+}
+
+func (s *ServiceWebhooks) send(ctx context.Context, delivery *Delivery, endpoint *Endpoint) Attempt {
+	msg := Message{
+		DeliveryID: delivery.ID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		UserID:     delivery.UserID,
+		CreatedAt:  delivery.CreatedAt.UTC().Truncate(time.Millisecond),
+		Data:       delivery.Payload,
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return Attempt{Err: fmt.Errorf("failed to encode message: %w", err)}
+	}
+
+	return s.sender.Send(ctx, endpoint.URL, endpoint.Secret, msg, body)
+}
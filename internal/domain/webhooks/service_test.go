@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
+)
+
+// deliveries is a Repository holding one delivery, whose endpoint may be
+// gone.
+type deliveries struct {
+	Repository
+	delivery *Delivery
+	endpoint *Endpoint
+	recorded []Attempt
+	final    bool
+}
+
+func (r *deliveries) GetDeliveryTarget(context.Context, string) (*Delivery, *Endpoint, error) {
+	switch {
+	case r.delivery == nil:
+		return nil, nil, status.Error(codes.NotFound, "webhook delivery not found")
+	case r.endpoint == nil:
+		return r.delivery, nil, errEndpointDeleted
+	}
+	return r.delivery, r.endpoint, nil
+}
+
+func (r *deliveries) RecordAttempt(_ context.Context, _ string, attempt Attempt, final bool) error {
+	r.recorded = append(r.recorded, attempt)
+	r.final = final
+	return nil
+}
+
+func deliveryJob(t *testing.T) jobs.Job {
+	t.Helper()
+	payload, err := json.Marshal(deliverJob{DeliveryID: "d-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobs.Job{Kind: deliverJobKind, Payload: payload, Attempts: 1, MaxAttempts: deliverMaxAttempts}
+}
+
+func TestDeliverToDeletedEndpoint(t *testing.T) {
+	// the endpoint went between queueing and sending: fail for good
+	repo := &deliveries{delivery: &Delivery{ID: "d-1", Status: DeliveryPending}}
+	s := NewServiceWebhooks(context.Background(), repo, NewSender(nil))
+	if err := s.deliver(context.Background(), deliveryJob(t)); err != nil {
+		t.Fatalf("deliver() = %v, want no retry", err)
+	}
+	if len(repo.recorded) != 1 || !repo.final {
+		t.Errorf("recorded %d attempts, final = %v; want one final attempt", len(repo.recorded), repo.final)
+	}
+
+	// the delete cascaded to the delivery: nothing to record or retry
+	repo = &deliveries{}
+	s = NewServiceWebhooks(context.Background(), repo, NewSender(nil))
+	if err := s.deliver(context.Background(), deliveryJob(t)); err != nil {
+		t.Fatalf("deliver() = %v, want no retry", err)
+	}
+	if len(repo.recorded) != 0 {
+		t.Errorf("recorded %d attempts for a delivery that is gone", len(repo.recorded))
+	}
+}
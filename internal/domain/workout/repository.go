@@ -21,6 +21,8 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain/auth"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
+	"github.com/FACorreiaa/fitme-grpc/internal/pagination"
 )
 
 type RepositoryWorkout struct {
@@ -41,14 +43,39 @@ func nullTimeToTimestamppb(nt sql.NullTime) *timestamppb.Timestamp {
 	return nil
 }
 
+var exercisesPage = pagination.Spec{
+	Sorts: map[string]pagination.SortKey{
+		"name":       {Expr: "COALESCE(name, '')", Type: pagination.Text},
+		"created_at": {Expr: "created_at", Type: pagination.Timestamp},
+	},
+	DefaultSort: "name",
+	IDExpr:      "id",
+	IDType:      pagination.UUID,
+	Filters: map[string]pagination.Filter{
+		"name":       {Expr: "name", Op: pagination.Contains},
+		"type":       {Expr: "type", Type: pagination.Text, Op: pagination.Eq},
+		"muscle":     {Expr: "muscle", Type: pagination.Text, Op: pagination.Eq},
+		"equipment":  {Expr: "equipment", Type: pagination.Text, Op: pagination.Eq},
+		"difficulty": {Expr: "difficulty", Type: pagination.Text, Op: pagination.Eq},
+	},
+}
+
 func (r *RepositoryWorkout) GetExercises(ctx context.Context, req *pbw.GetExercisesReq) (*pbw.GetExercisesRes, error) {
 	exercisesProtoList := make([]*pbw.XExercises, 0)
-	query := `SELECT DISTINCT
+
+	page, err := exercisesPage.Build(pagination.FromContext(ctx), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, gymID := tenant.Visible(ctx, "gym_id", 1)
+	query := `SELECT
     			id, name, type, muscle, equipment, difficulty,
-				instructions, video, custom_created, created_at, updated_at
-				FROM exercise_list`
+				instructions, video, custom_created, created_at, updated_at, ` + page.CursorColumns() + `
+				FROM exercise_list
+				WHERE TRUE` + visible + page.Where() + page.OrderLimit()
 
-	rows, err := r.pgpool.Query(ctx, query)
+	rows, err := r.pgpool.Query(ctx, query, append([]any{gymID}, page.Args()...)...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("no exercises found: %w", err)
@@ -57,6 +84,7 @@ func (r *RepositoryWorkout) GetExercises(ctx context.Context, req *pbw.GetExerci
 	}
 	defer rows.Close()
 
+	pager := page.Pager()
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
@@ -70,6 +98,7 @@ func (r *RepositoryWorkout) GetExercises(ctx context.Context, req *pbw.GetExerci
 		err := rows.Scan(
 			&e.ID, &e.Name, &e.ExerciseType, &e.MuscleGroup, &e.Equipment, &e.Difficulty,
 			&e.Instructions, &e.Video, &e.CustomCreated, &e.CreatedAt, &e.UpdatedAt,
+			&pager.SortValue, &pager.ID,
 		)
 
 		if err != nil {
@@ -78,6 +107,9 @@ func (r *RepositoryWorkout) GetExercises(ctx context.Context, req *pbw.GetExerci
 			}
 			return nil, status.Error(codes.Internal, "Internal server error")
 		}
+		if !pager.Next() {
+			break
+		}
 
 		createdAt := timestamppb.New(e.CreatedAt)
 		var updatedAt sql.NullTime
@@ -120,6 +152,8 @@ func (r *RepositoryWorkout) GetExercises(ctx context.Context, req *pbw.GetExerci
 		return nil, fmt.Errorf("no exercises found")
 	}
 
+	pager.SetHeader(ctx)
+
 	return &pbw.GetExercisesRes{
 		Success:  true,
 		Message:  "Exercises retrieved successfully",
@@ -140,12 +174,13 @@ func (r *RepositoryWorkout) GetExerciseID(ctx context.Context, req *pbw.GetExerc
 		return &pbw.GetExerciseIDRes{}, status.Error(codes.InvalidArgument, "workout ID is required")
 	}
 
+	visible, gymID := tenant.Visible(ctx, "gym_id", 2)
 	query := `SELECT 	id, name, type, muscle, equipment, difficulty,
 						instructions, video, custom_created, created_at, updated_at
 			   FROM exercise_list
-			   WHERE id = $1`
+			   WHERE id = $1` + visible
 
-	err := r.pgpool.QueryRow(ctx, query, id).Scan(
+	err := r.pgpool.QueryRow(ctx, query, id, gymID).Scan(
 		&exercise.ID, &exercise.Name, &exercise.ExerciseType, &exercise.MuscleGroup, &exercise.Equipment,
 		&exercise.Difficulty, &exercise.Instructions, &exercise.Video, &exercise.CustomCreated, &exercise.CreatedAt,
 		&exercise.UpdatedAt,
@@ -202,8 +237,8 @@ func (r *RepositoryWorkout) CreateExercise(ctx context.Context, req *pbw.CreateE
 	query := `
 				INSERT INTO exercise_list (name, type, muscle, equipment, difficulty,
                                    instructions, video,
-                                   created_at, updated_at)
-        		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                                   created_at, updated_at, gym_id)
+        		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
                 RETURNING id`
 
 	currentTime := time.Now()
@@ -219,6 +254,7 @@ func (r *RepositoryWorkout) CreateExercise(ctx context.Context, req *pbw.CreateE
 		req.Exercise.Video,
 		currentTime,
 		currentTime,
+		tenant.OwnerGym(ctx),
 	).Scan(&exerciseID)
 
 	setExerciseToUserQuery := `
@@ -266,9 +302,10 @@ func (r *RepositoryWorkout) CreateExercise(ctx context.Context, req *pbw.CreateE
 }
 
 func (r *RepositoryWorkout) DeleteExercise(ctx context.Context, req *pbw.DeleteExerciseReq) (*pbw.NilRes, error) {
+	owned, gymID := tenant.Owned(ctx, "gym_id", 2)
 	query := `DELETE FROM exercise_list WHERE id = $1
- 			  AND exercise_list.custom_created = true`
-	_, err := r.pgpool.Exec(ctx, query, req.ExerciseId)
+ 			  AND exercise_list.custom_created = true` + owned
+	_, err := r.pgpool.Exec(ctx, query, req.ExerciseId, gymID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete exercise: %w", err)
 	}
@@ -326,15 +363,13 @@ func (r *RepositoryWorkout) UpdateExercise(ctx context.Context, req *pbw.UpdateE
 	query += strings.Join(setClauses, ", ")
 	query += ` WHERE id = $` + fmt.Sprintf("%d", argIndex)
 	args = append(args, req.ExerciseId)
+	owned, gymID := tenant.Owned(ctx, "gym_id", argIndex+1)
+	query += owned
+	args = append(args, gymID)
 
-	_, err := r.pgpool.Exec(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update exercise: %w", err)
-	}
+	query += ` RETURNING id, name, muscle, equipment, difficulty, instructions, video`
 
-	updatedExercise.ExerciseId = req.ExerciseId
-	getQuery := `SELECT id, name, muscle, equipment, difficulty, instructions, video FROM exercise_list WHERE id = $1`
-	err = r.pgpool.QueryRow(ctx, getQuery, req.ExerciseId).Scan(
+	err := r.pgpool.QueryRow(ctx, query, args...).Scan(
 		&updatedExercise.ExerciseId,
 		&updatedExercise.Name,
 		&updatedExercise.MuscleGroup,
@@ -344,7 +379,10 @@ func (r *RepositoryWorkout) UpdateExercise(ctx context.Context, req *pbw.UpdateE
 		&updatedExercise.Video,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch updated exercise: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "exercise not found")
+		}
+		return nil, fmt.Errorf("failed to update exercise: %w", err)
 	}
 
 	return &pbw.UpdateExerciseRes{
@@ -872,9 +910,9 @@ func (r *RepositoryWorkout) fetchExerciseDetails(ctx context.Context, exerciseUU
         created_at,
         updated_at
       FROM exercise_list
-      WHERE id = ANY($1::uuid[])
-    `
-	rows, err := r.pgpool.Query(ctx, q, idStrings)
+      WHERE id = ANY($1::uuid[])`
+	visible, gymID := tenant.Visible(ctx, "gym_id", 2)
+	rows, err := r.pgpool.Query(ctx, q+visible, idStrings, gymID)
 	if err != nil {
 		return nil, fmt.Errorf("fetchExerciseDetails query error: %w", err)
 	}
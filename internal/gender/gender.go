@@ -0,0 +1,22 @@
+// Package gender reads the gender stored for users, which the calorie,
+// training load and nutrient formulas all branch on.
+package gender
+
+import "strings"
+
+const (
+	Male   = "MALE"
+	Female = "FEMALE"
+)
+
+// Normalize reads the gender columns, which hold MALE/FEMALE or the
+// calculator enum number. Anything else is empty.
+func Normalize(s string) string {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case Male, "1":
+		return Male
+	case Female, "2":
+		return Female
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package gender
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"MALE": Male, " female ": Female, "1": Male, "2": Female,
+		"": "", "0": "", "OTHER": "",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
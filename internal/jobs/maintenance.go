@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const (
+	KindPurgeOutbox = "maintenance.purge_outbox"
+	KindPurgeJobs   = "maintenance.purge_jobs"
+
+	retention = 7 * 24 * time.Hour
+)
+
+// RegisterMaintenance installs the housekeeping jobs that keep the outbox
+// and the queue itself from growing without bound.
+func RegisterMaintenance(ctx context.Context, q *Queue) error {
+	q.Register(KindPurgeOutbox, func(ctx context.Context, job Job) error {
+		tag, err := q.pgpool.Exec(ctx, `
+			DELETE FROM outbox_events
+			WHERE dispatched_at IS NOT NULL AND dispatched_at < now() - make_interval(secs => $1)`,
+			retention.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to purge outbox: %w", err)
+		}
+		logger.Log.Info("purged dispatched outbox events", zap.Int64("count", tag.RowsAffected()))
+		return nil
+	})
+
+	q.Register(KindPurgeJobs, func(ctx context.Context, job Job) error {
+		tag, err := q.pgpool.Exec(ctx, `
+			DELETE FROM jobs WHERE status = 'DONE' AND updated_at < $1`, time.Now().UTC().Add(-retention))
+		if err != nil {
+			return fmt.Errorf("failed to purge jobs: %w", err)
+		}
+		logger.Log.Info("purged finished jobs", zap.Int64("count", tag.RowsAffected()))
+		return nil
+	})
+
+	if err := q.Schedule(ctx, "purge-outbox", "30 3 * * *", KindPurgeOutbox, struct{}{}); err != nil {
+		return err
+	}
+	return q.Schedule(ctx, "purge-jobs", "45 3 * * *", KindPurgeJobs, struct{}{})
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type queueMetrics struct {
+	processed    *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	deadLettered *prometheus.CounterVec
+	inFlight     prometheus.Gauge
+}
+
+func newQueueMetrics() *queueMetrics {
+	return &queueMetrics{
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jobs_processed_total",
+			Help: "Jobs executed by the worker pool, by kind and result.",
+		}, []string{"kind", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "jobs_duration_seconds",
+			Help:    "Job handler execution time.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300},
+		}, []string{"kind"}),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jobs_dead_lettered_total",
+			Help: "Jobs moved to the dead letter state after exhausting retries.",
+		}, []string{"kind"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "jobs_in_flight",
+			Help: "Jobs currently being executed.",
+		}),
+	}
+}
+
+// RegisterMetrics exposes the queue metrics on registry.
+func (q *Queue) RegisterMetrics(registry *prometheus.Registry) error {
+	if registry == nil {
+		return errors.New("must provide a Prometheus registry")
+	}
+
+	registry.MustRegister(q.metrics.processed, q.metrics.duration, q.metrics.deadLettered, q.metrics.inFlight)
+	return nil
+}
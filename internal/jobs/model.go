@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "PENDING"
+	StatusRunning Status = "RUNNING"
+	StatusDone    Status = "DONE"
+	StatusDead    Status = "DEAD"
+)
+
+const defaultMaxAttempts = 5
+
+// Job is a unit of work claimed by a worker.
+type Job struct {
+	ID           string          `json:"id" db:"id"`
+	Kind         string          `json:"kind" db:"kind"`
+	Payload      json.RawMessage `json:"payload" db:"payload"`
+	Status       Status          `json:"status" db:"status"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	MaxAttempts  int             `json:"max_attempts" db:"max_attempts"`
+	RunAt        time.Time       `json:"run_at" db:"run_at"`
+	LastError    string          `json:"last_error" db:"last_error"`
+	ScheduleName string          `json:"schedule_name" db:"schedule_name"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Decode unmarshals the job payload into v.
+func (j Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler executes a job. Returning an error schedules a retry until the job
+// runs out of attempts and is dead-lettered. Jobs may run more than once.
+type Handler func(ctx context.Context, job Job) error
+
+type EnqueueOptions struct {
+	// RunAt delays the job; zero means as soon as possible.
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	// LeaseTimeout is how long a job may stay RUNNING before another worker
+	// assumes its owner died and puts it back in the queue.
+	LeaseTimeout time.Duration
+	// ShutdownTimeout bounds how long Stop waits for in-flight jobs.
+	ShutdownTimeout time.Duration
+}
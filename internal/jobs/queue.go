@@ -0,0 +1,355 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+const (
+	defaultWorkers         = 4
+	defaultPollInterval    = time.Second
+	defaultLeaseTimeout    = 10 * time.Minute
+	defaultShutdownTimeout = 30 * time.Second
+	maxRetryBackoff        = time.Hour
+	statusUpdateTimeout    = 5 * time.Second
+)
+
+// Queue is a Postgres backed job queue. Workers claim jobs with
+// FOR UPDATE SKIP LOCKED so any number of server replicas can share it.
+// All timestamps are stored in UTC.
+type Queue struct {
+	pgpool   *pgxpool.Pool
+	cfg      Config
+	metrics  *queueMetrics
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	wg         sync.WaitGroup
+	cancelJobs context.CancelFunc
+}
+
+func NewQueue(db *pgxpool.Pool, cfg Config) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.LeaseTimeout <= 0 {
+		cfg.LeaseTimeout = defaultLeaseTimeout
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	return &Queue{
+		pgpool:   db,
+		cfg:      cfg,
+		metrics:  newQueueMetrics(),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for kind. Workers only claim kinds that have a
+// handler, so jobs for unknown kinds wait instead of burning retries.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+func (q *Queue) kinds() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for k := range q.handlers {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+func (q *Queue) handler(kind string) (Handler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	h, ok := q.handlers[kind]
+	return h, ok
+}
+
+// Enqueue adds a job using the pool.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts EnqueueOptions) (string, error) {
+	return enqueue(ctx, q.pgpool, kind, payload, opts, "")
+}
+
+// EnqueueTx adds a job inside tx so it is only visible if tx commits.
+func (q *Queue) EnqueueTx(ctx context.Context, tx pgx.Tx, kind string, payload any, opts EnqueueOptions) (string, error) {
+	return enqueue(ctx, tx, kind, payload, opts, "")
+}
+
+func enqueue(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, kind string, payload any, opts EnqueueOptions, scheduleName string) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s payload: %w", kind, err)
+	}
+
+	runAt := opts.RunAt.UTC()
+	if opts.RunAt.IsZero() {
+		runAt = time.Now().UTC()
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	var id string
+	err = db.QueryRow(ctx, `
+		INSERT INTO jobs (kind, payload, run_at, max_attempts, schedule_name)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id`, kind, data, runAt, maxAttempts, scheduleName).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+
+	return id, nil
+}
+
+// Start launches the workers and the scheduler. They stop claiming work when
+// ctx is cancelled; call Stop afterwards to wait for in-flight jobs.
+func (q *Queue) Start(ctx context.Context) {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	q.cancelJobs = cancel
+
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, jobCtx)
+	}
+
+	q.wg.Add(1)
+	go q.schedule(ctx)
+
+	logger.Log.Info("job queue started", zap.Int("workers", q.cfg.Workers))
+}
+
+// Stop drains the queue. In-flight jobs get ShutdownTimeout to finish before
+// their context is cancelled; anything left RUNNING is recovered by lease
+// expiry on the next start.
+func (q *Queue) Stop() {
+	if q.cancelJobs == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(q.cfg.ShutdownTimeout):
+		logger.Log.Warn("job queue drain timed out, cancelling in-flight jobs")
+		q.cancelJobs()
+		<-done
+	}
+	q.cancelJobs()
+
+	logger.Log.Info("job queue stopped")
+}
+
+func (q *Queue) work(ctx, jobCtx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// keep going while there is work, then wait for the next tick
+		for ctx.Err() == nil {
+			job, ok, err := q.claim(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Log.Error("failed to claim job", zap.Error(err))
+				}
+				break
+			}
+			if !ok {
+				break
+			}
+			q.execute(jobCtx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) claim(ctx context.Context) (Job, bool, error) {
+	kinds := q.kinds()
+	if len(kinds) == 0 {
+		return Job{}, false, nil
+	}
+
+	query := `
+		UPDATE jobs
+		SET status = 'RUNNING', attempts = attempts + 1, locked_at = $2, updated_at = $2
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'PENDING' AND run_at <= $2 AND kind = ANY($1)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, status, attempts, max_attempts, run_at,
+		          COALESCE(schedule_name, ''), created_at`
+
+	var job Job
+	err := q.pgpool.QueryRow(ctx, query, kinds, time.Now().UTC()).Scan(
+		&job.ID, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.ScheduleName, &job.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Job{}, false, nil
+		}
+		return Job{}, false, err
+	}
+
+	return job, true, nil
+}
+
+func (q *Queue) execute(ctx context.Context, job Job) {
+	h, ok := q.handler(job.Kind)
+	if !ok {
+		q.finish(job, fmt.Errorf("no handler registered for %s", job.Kind))
+		return
+	}
+
+	q.metrics.inFlight.Inc()
+	start := time.Now()
+	err := runHandler(ctx, h, job)
+	q.metrics.duration.WithLabelValues(job.Kind).Observe(time.Since(start).Seconds())
+	q.metrics.inFlight.Dec()
+
+	q.finish(job, err)
+}
+
+func runHandler(ctx context.Context, h Handler, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (q *Queue) finish(job Job, jobErr error) {
+	// the job context may already be cancelled on shutdown, but the outcome
+	// should still be recorded
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var err error
+	switch {
+	case jobErr == nil:
+		q.metrics.processed.WithLabelValues(job.Kind, "success").Inc()
+		_, err = q.pgpool.Exec(ctx, `
+			UPDATE jobs SET status = 'DONE', locked_at = NULL, last_error = NULL, updated_at = $2
+			WHERE id = $1`, job.ID, now)
+
+	case job.Attempts >= job.MaxAttempts:
+		q.metrics.processed.WithLabelValues(job.Kind, "dead").Inc()
+		q.metrics.deadLettered.WithLabelValues(job.Kind).Inc()
+		logger.Log.Error("job dead-lettered",
+			zap.String("job_id", job.ID), zap.String("kind", job.Kind),
+			zap.Int("attempts", job.Attempts), zap.Error(jobErr))
+		_, err = q.pgpool.Exec(ctx, `
+			UPDATE jobs SET status = 'DEAD', locked_at = NULL, last_error = $2, updated_at = $3
+			WHERE id = $1`, job.ID, jobErr.Error(), now)
+
+	default:
+		q.metrics.processed.WithLabelValues(job.Kind, "retry").Inc()
+		logger.Log.Warn("job failed, retrying",
+			zap.String("job_id", job.ID), zap.String("kind", job.Kind),
+			zap.Int("attempts", job.Attempts), zap.Error(jobErr))
+		_, err = q.pgpool.Exec(ctx, `
+			UPDATE jobs SET status = 'PENDING', locked_at = NULL, last_error = $2, run_at = $3, updated_at = $4
+			WHERE id = $1`, job.ID, jobErr.Error(), now.Add(retryBackoff(job.Attempts)), now)
+	}
+
+	if err != nil {
+		logger.Log.Error("failed to record job result", zap.String("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// retryBackoff doubles from 5 seconds per attempt, capped at an hour.
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(float64(5*time.Second) * math.Pow(2, float64(attempts-1)))
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// DeadJobs lists dead-lettered jobs, newest first. An empty kind lists all.
+func (q *Queue) DeadJobs(ctx context.Context, kind string, limit int) ([]Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := q.pgpool.Query(ctx, `
+		SELECT id, kind, payload, status, attempts, max_attempts, run_at,
+		       COALESCE(last_error, ''), COALESCE(schedule_name, ''), created_at
+		FROM jobs
+		WHERE status = 'DEAD' AND ($1 = '' OR kind = $1)
+		ORDER BY updated_at DESC
+		LIMIT $2`, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var dead []Job
+	for rows.Next() {
+		var job Job
+		if err = rows.Scan(&job.ID, &job.Kind, &job.Payload, &job.Status, &job.Attempts,
+			&job.MaxAttempts, &job.RunAt, &job.LastError, &job.ScheduleName, &job.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		dead = append(dead, job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return dead, nil
+}
+
+// Requeue moves a dead job back to the queue with a fresh attempt budget.
+func (q *Queue) Requeue(ctx context.Context, jobID string) error {
+	now := time.Now().UTC()
+	tag, err := q.pgpool.Exec(ctx, `
+		UPDATE jobs SET status = 'PENDING', attempts = 0, run_at = $2, updated_at = $2
+		WHERE id = $1 AND status = 'DEAD'`, jobID, now)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("dead job %s not found", jobID)
+	}
+	return nil
+}
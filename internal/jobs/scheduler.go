@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/FACorreiaa/fitme-grpc/logger"
+)
+
+// Schedule creates or updates a recurring job. spec is a five field cron
+// expression in UTC (or @hourly, @daily, @weekly, @monthly). Re-registering
+// an unchanged schedule on every boot keeps its next run time.
+func (q *Queue) Schedule(ctx context.Context, name, spec, kind string, payload any) error {
+	cron, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", kind, err)
+	}
+
+	now := time.Now().UTC()
+	next := cron.Next(now)
+	if next.IsZero() {
+		return fmt.Errorf("cron spec %q never fires", spec)
+	}
+
+	_, err = q.pgpool.Exec(ctx, `
+		INSERT INTO job_schedules (name, kind, spec, payload, next_run_at, enabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, $6)
+		ON CONFLICT (name) DO UPDATE
+		SET kind = EXCLUDED.kind,
+		    payload = EXCLUDED.payload,
+		    enabled = TRUE,
+		    next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec
+		                       THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END,
+		    spec = EXCLUDED.spec,
+		    updated_at = $6`, name, kind, spec, data, next, now)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Unschedule disables a recurring job without touching jobs already queued.
+func (q *Queue) Unschedule(ctx context.Context, name string) error {
+	_, err := q.pgpool.Exec(ctx, `
+		UPDATE job_schedules SET enabled = FALSE, updated_at = $2 WHERE name = $1`, name, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to disable schedule %s: %w", name, err)
+	}
+	return nil
+}
+
+func (q *Queue) schedule(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := q.enqueueDue(ctx); err != nil && ctx.Err() == nil {
+			logger.Log.Error("failed to enqueue scheduled jobs", zap.Error(err))
+		}
+		if err := q.recoverLeases(ctx); err != nil && ctx.Err() == nil {
+			logger.Log.Error("failed to recover expired job leases", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enqueueDue turns every due schedule into a job. Missed runs (e.g. while
+// the service was down) collapse into a single job.
+func (q *Queue) enqueueDue(ctx context.Context) error {
+	tx, err := q.pgpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	now := time.Now().UTC()
+	rows, err := tx.Query(ctx, `
+		SELECT name, kind, spec, payload
+		FROM job_schedules
+		WHERE enabled AND next_run_at <= $1
+		FOR UPDATE SKIP LOCKED`, now)
+	if err != nil {
+		return fmt.Errorf("failed to fetch due schedules: %w", err)
+	}
+
+	type dueSchedule struct {
+		name, kind, spec string
+		payload          json.RawMessage
+	}
+	var due []dueSchedule
+	for rows.Next() {
+		var s dueSchedule
+		if err = rows.Scan(&s.name, &s.kind, &s.spec, &s.payload); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	for _, s := range due {
+		cron, parseErr := parseCron(s.spec)
+		var next time.Time
+		if parseErr == nil {
+			next = cron.Next(now)
+		}
+		if next.IsZero() {
+			logger.Log.Error("disabling schedule with invalid spec",
+				zap.String("schedule", s.name), zap.String("spec", s.spec), zap.Error(parseErr))
+			if _, err = tx.Exec(ctx, `UPDATE job_schedules SET enabled = FALSE, updated_at = $2 WHERE name = $1`,
+				s.name, now); err != nil {
+				return fmt.Errorf("failed to disable schedule %s: %w", s.name, err)
+			}
+			continue
+		}
+
+		if _, err = enqueue(ctx, tx, s.kind, s.payload, EnqueueOptions{RunAt: now}, s.name); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, `UPDATE job_schedules SET next_run_at = $2, updated_at = $3 WHERE name = $1`,
+			s.name, next, now); err != nil {
+			return fmt.Errorf("failed to advance schedule %s: %w", s.name, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// recoverLeases puts jobs whose worker vanished back in the queue. The
+// attempt they used still counts towards max_attempts.
+func (q *Queue) recoverLeases(ctx context.Context) error {
+	now := time.Now().UTC()
+	tag, err := q.pgpool.Exec(ctx, `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'DEAD'::job_status ELSE 'PENDING'::job_status END,
+		    locked_at = NULL,
+		    last_error = 'lease expired',
+		    run_at = $2,
+		    updated_at = $2
+		WHERE status = 'RUNNING' AND locked_at < $1`, now.Add(-q.cfg.LeaseTimeout), now)
+	if err != nil {
+		return err
+	}
+
+	if n := tag.RowsAffected(); n > 0 {
+		logger.Log.Warn("recovered jobs with expired leases", zap.Int64("count", n))
+	}
+	return nil
+}
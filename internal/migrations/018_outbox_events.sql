@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS "outbox_events" (
+                                               "id" UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+                                               "event_type" VARCHAR(100) NOT NULL,
+                                               "aggregate_id" UUID,
+                                               "user_id" UUID,
+                                               "payload" JSONB NOT NULL DEFAULT '{}'::jsonb,
+                                               "attempts" INTEGER NOT NULL DEFAULT 0,
+                                               "last_error" TEXT,
+                                               "available_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                               "dispatched_at" TIMESTAMP DEFAULT NULL,
+                                               "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- the dispatcher only ever looks at undelivered rows
+CREATE INDEX idx_outbox_events_pending
+  ON outbox_events (available_at, created_at)
+  WHERE dispatched_at IS NULL;
+
+CREATE INDEX idx_outbox_events_user_id ON outbox_events (user_id);
+
+-- subscribers record what they handled so redelivered events are no-ops
+CREATE TABLE IF NOT EXISTS "processed_events" (
+                                                 "event_id" UUID NOT NULL REFERENCES outbox_events (id) ON DELETE CASCADE,
+                                                 "handler" VARCHAR(100) NOT NULL,
+                                                 "processed_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                                 PRIMARY KEY (event_id, handler)
+);
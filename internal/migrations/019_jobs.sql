@@ -0,0 +1,34 @@
+CREATE TYPE job_status AS ENUM ('PENDING', 'RUNNING', 'DONE', 'DEAD');
+
+CREATE TABLE IF NOT EXISTS "jobs" (
+                                     "id" UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+                                     "kind" VARCHAR(100) NOT NULL,
+                                     "payload" JSONB NOT NULL DEFAULT '{}'::jsonb,
+                                     "status" job_status NOT NULL DEFAULT 'PENDING',
+                                     "attempts" INTEGER NOT NULL DEFAULT 0,
+                                     "max_attempts" INTEGER NOT NULL DEFAULT 5,
+                                     "run_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                     "locked_at" TIMESTAMP DEFAULT NULL,
+                                     "last_error" TEXT,
+                                     "schedule_name" VARCHAR(100),
+                                     "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                     "updated_at" TIMESTAMP DEFAULT NULL
+);
+
+-- workers claim from this index only
+CREATE INDEX idx_jobs_pending ON jobs (run_at) WHERE status = 'PENDING';
+-- lease recovery for workers that died mid-job
+CREATE INDEX idx_jobs_running ON jobs (locked_at) WHERE status = 'RUNNING';
+CREATE INDEX idx_jobs_dead ON jobs (kind, updated_at) WHERE status = 'DEAD';
+
+-- recurring jobs, enqueued into jobs whenever next_run_at passes
+CREATE TABLE IF NOT EXISTS "job_schedules" (
+                                              "name" VARCHAR(100) PRIMARY KEY,
+                                              "kind" VARCHAR(100) NOT NULL,
+                                              "spec" VARCHAR(100) NOT NULL,
+                                              "payload" JSONB NOT NULL DEFAULT '{}'::jsonb,
+                                              "next_run_at" TIMESTAMP NOT NULL,
+                                              "enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+                                              "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                              "updated_at" TIMESTAMP DEFAULT NULL
+);
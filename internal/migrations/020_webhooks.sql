@@ -0,0 +1,36 @@
+CREATE TYPE webhook_delivery_status AS ENUM ('PENDING', 'SUCCEEDED', 'FAILED');
+
+CREATE TABLE IF NOT EXISTS "webhook_endpoints" (
+                                                  "id" UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+                                                  "owner_id" UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+                                                  "url" TEXT NOT NULL,
+                                                  "secret" VARCHAR(128) NOT NULL,
+                                                  "event_types" TEXT[] NOT NULL,
+                                                  "description" TEXT,
+                                                  "active" BOOLEAN NOT NULL DEFAULT TRUE,
+                                                  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                                  "updated_at" TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX idx_webhook_endpoints_owner_id ON webhook_endpoints (owner_id);
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+                                                   "id" UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+                                                   "endpoint_id" UUID NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+                                                   -- NULL for test pings
+                                                   "event_id" UUID,
+                                                   "event_type" VARCHAR(100) NOT NULL,
+                                                   "user_id" UUID,
+                                                   "payload" JSONB NOT NULL DEFAULT '{}'::jsonb,
+                                                   "status" webhook_delivery_status NOT NULL DEFAULT 'PENDING',
+                                                   "attempts" INTEGER NOT NULL DEFAULT 0,
+                                                   "response_status" INTEGER,
+                                                   "response_body" TEXT,
+                                                   "last_error" TEXT,
+                                                   "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+                                                   "delivered_at" TIMESTAMP DEFAULT NULL,
+                                                   "updated_at" TIMESTAMP DEFAULT NULL,
+                                                   UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint_created ON webhook_deliveries (endpoint_id, created_at DESC);
@@ -0,0 +1,33 @@
+-- keyset pagination sorts on created_at, so it must never be NULL
+UPDATE exercise_list SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE exercise_list ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE ingredients SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE ingredients ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE weight_measure SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE weight_measure ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE water_intake SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE water_intake ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE waist_line SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE waist_line ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE activity SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE activity ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE users SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE meal_plans SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE meal_plans ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_exercise_list_created_id ON exercise_list (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_ingredients_user_created_id ON ingredients (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_weight_measure_created_id ON weight_measure (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_water_intake_created_id ON water_intake (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_waist_line_created_id ON waist_line (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_activity_created_id ON activity (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_created_id ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_meal_plans_user_created_id ON meal_plans (user_id, created_at, id);
@@ -0,0 +1,32 @@
+-- Gym members. A user can be a member of several gyms; the active one is
+-- picked per request by the session interceptor.
+CREATE TABLE IF NOT EXISTS gym_members (
+                                         gym_id UUID NOT NULL REFERENCES gyms(id) ON DELETE CASCADE,
+                                         user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                         created_at TIMESTAMP NOT NULL DEFAULT now(),
+                                         PRIMARY KEY (gym_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gym_members_user ON gym_members (user_id);
+CREATE INDEX IF NOT EXISTS idx_gym_staff_user ON gym_staff (user_id);
+
+-- Tenant-owned catalog rows. NULL means the global catalog shared by everyone.
+ALTER TABLE exercise_list ADD COLUMN IF NOT EXISTS gym_id UUID REFERENCES gyms(id) ON DELETE CASCADE;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS gym_id UUID REFERENCES gyms(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_exercise_list_gym ON exercise_list (gym_id) WHERE gym_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ingredients_gym ON ingredients (gym_id) WHERE gym_id IS NOT NULL;
+
+-- Row level security as a second line of defence. Policies only bind roles
+-- that do not own the tables (reporting users, read replicas, etc.), which
+-- set app.gym_id per transaction; the service scopes its queries explicitly.
+ALTER TABLE exercise_list ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ingredients ENABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON exercise_list;
+CREATE POLICY tenant_isolation ON exercise_list
+    USING (gym_id IS NULL OR gym_id = NULLIF(current_setting('app.gym_id', true), '')::uuid);
+
+DROP POLICY IF EXISTS tenant_isolation ON ingredients;
+CREATE POLICY tenant_isolation ON ingredients
+    USING (gym_id IS NULL OR gym_id = NULLIF(current_setting('app.gym_id', true), '')::uuid);
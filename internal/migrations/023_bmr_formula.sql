@@ -0,0 +1,3 @@
+ALTER TABLE user_macro_distribution
+    ADD COLUMN IF NOT EXISTS bmr_formula VARCHAR(32) NOT NULL DEFAULT 'mifflin_st_jeor',
+    ADD COLUMN IF NOT EXISTS body_fat_percent NUMERIC(4, 1);
@@ -0,0 +1,15 @@
+-- Trainer-defined distributions. Ratios are fractions of calories and sum to
+-- 1; the g/kg targets are only set when distribution_mode is 'per_kg'.
+ALTER TABLE user_macro_distribution
+    ADD COLUMN IF NOT EXISTS distribution_mode VARCHAR(16) NOT NULL DEFAULT 'preset',
+    ADD COLUMN IF NOT EXISTS custom_protein_ratio NUMERIC(5, 4),
+    ADD COLUMN IF NOT EXISTS custom_fat_ratio NUMERIC(5, 4),
+    ADD COLUMN IF NOT EXISTS custom_carb_ratio NUMERIC(5, 4),
+    ADD COLUMN IF NOT EXISTS protein_g_per_kg NUMERIC(4, 2),
+    ADD COLUMN IF NOT EXISTS fat_g_per_kg NUMERIC(4, 2);
+
+ALTER TABLE user_macro_distribution
+    ADD CONSTRAINT chk_custom_ratios_sum CHECK (
+        distribution_mode = 'preset'
+        OR abs(custom_protein_ratio + custom_fat_ratio + custom_carb_ratio - 1) <= 0.005
+    );
@@ -0,0 +1,23 @@
+-- A goal plan targets a weight by a date. Its macro distribution is a copy of
+-- the user's current one with the goal (and macros) set by the plan.
+CREATE TABLE IF NOT EXISTS goal_plans (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id          UUID          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    macro_id         UUID          NOT NULL REFERENCES user_macro_distribution (id) ON DELETE CASCADE,
+    start_weight     NUMERIC(5, 2) NOT NULL,
+    current_weight   NUMERIC(5, 2) NOT NULL,
+    target_weight    NUMERIC(5, 2) NOT NULL,
+    target_date      DATE          NOT NULL,
+    weekly_rate      NUMERIC(5, 2) NOT NULL,
+    daily_adjustment INTEGER       NOT NULL,
+    tdee             INTEGER       NOT NULL,
+    daily_goal       INTEGER       NOT NULL,
+    safe             BOOLEAN       NOT NULL DEFAULT TRUE,
+    warnings         TEXT[]        NOT NULL DEFAULT '{}',
+    active           BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at       TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS goal_plans_one_active
+    ON goal_plans (user_id) WHERE active;
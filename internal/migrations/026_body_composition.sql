@@ -0,0 +1,26 @@
+-- Body composition calculations, stored for trending. Inputs are metric and
+-- metrics that could not be derived are NULL.
+CREATE TABLE IF NOT EXISTS body_composition_snapshots (
+    id                       UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id                  UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    gender                   VARCHAR(16),
+    weight                   NUMERIC(5, 2),
+    height                   NUMERIC(5, 1),
+    waist                    NUMERIC(5, 1),
+    neck                     NUMERIC(5, 1),
+    hip                      NUMERIC(5, 1),
+    bmi                      NUMERIC(4, 1),
+    bmi_category             VARCHAR(16),
+    body_fat_percent         NUMERIC(4, 1),
+    body_fat_source          VARCHAR(16),
+    lean_mass                NUMERIC(5, 1),
+    fat_mass                 NUMERIC(5, 1),
+    ffmi                     NUMERIC(4, 1),
+    normalized_ffmi          NUMERIC(4, 1),
+    waist_to_height          NUMERIC(4, 2),
+    waist_to_height_category VARCHAR(16),
+    created_at               TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_body_composition_user_created
+    ON body_composition_snapshots (user_id, created_at DESC, id);
@@ -0,0 +1,11 @@
+-- Measurements are stored in SI (kg, cm, ml); the unit system only decides
+-- how values are read and shown at the API boundary.
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS unit_system VARCHAR(8) NOT NULL DEFAULT 'metric'
+    CHECK (unit_system IN ('metric', 'imperial'));
+
+-- Imperial macro calculations used to store the raw inches and pounds.
+UPDATE user_macro_distribution
+SET height = height * 2.54,
+    weight = weight * 0.45359237
+WHERE upper(system) IN ('IMPERIAL', '2');
@@ -0,0 +1,18 @@
+-- A carb cycle spreads the current macro distribution's goal over the week:
+-- high carb on training days, low on rest days, with optional refeeds and
+-- diet breaks. The schedule itself is computed, not stored.
+CREATE TABLE IF NOT EXISTS carb_cycles (
+    id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id                UUID          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    start_date             DATE          NOT NULL,
+    high_day_increase      NUMERIC(4, 3) NOT NULL DEFAULT 0.15,
+    refeed_every_weeks     SMALLINT      NOT NULL DEFAULT 0 CHECK (refeed_every_weeks >= 0),
+    diet_break_every_weeks SMALLINT      NOT NULL DEFAULT 0 CHECK (diet_break_every_weeks >= 0),
+    diet_break_weeks       SMALLINT      NOT NULL DEFAULT 0 CHECK (diet_break_weeks >= 0),
+    active                 BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at             TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    updated_at             TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS carb_cycles_one_active
+    ON carb_cycles (user_id) WHERE active;
@@ -0,0 +1,42 @@
+-- Every change of a user's active macro distribution, with a snapshot of the
+-- distribution and the diff against the previous entry. Adaptive updates
+-- change a distribution in place, so the snapshot is the only record of what
+-- it looked like before.
+CREATE TABLE IF NOT EXISTS macro_distribution_changes (
+    id                    UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id               UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    macro_id              UUID        REFERENCES user_macro_distribution (id) ON DELETE SET NULL,
+    source                VARCHAR(16) NOT NULL CHECK (source IN ('USER', 'TRAINER', 'ADAPTIVE')),
+    changed_by            UUID        REFERENCES users (id) ON DELETE SET NULL,
+    reason                TEXT,
+    age                   INTEGER     NOT NULL,
+    height                FLOAT8      NOT NULL,
+    weight                FLOAT8      NOT NULL,
+    activity              VARCHAR(32) NOT NULL,
+    objective             VARCHAR(32) NOT NULL,
+    calories_distribution VARCHAR(32) NOT NULL,
+    bmr_formula           VARCHAR(32) NOT NULL,
+    bmr                   INTEGER     NOT NULL,
+    tdee                  INTEGER     NOT NULL,
+    goal                  INTEGER     NOT NULL,
+    protein               INTEGER     NOT NULL,
+    fats                  INTEGER     NOT NULL,
+    carbs                 INTEGER     NOT NULL,
+    changes               JSONB       NOT NULL DEFAULT '[]',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS macro_distribution_changes_user_created
+    ON macro_distribution_changes (user_id, created_at DESC);
+
+-- Start the history from the distributions that are current today.
+INSERT INTO macro_distribution_changes (
+    user_id, macro_id, source, changed_by, reason,
+    age, height, weight, activity, objective, calories_distribution, bmr_formula,
+    bmr, tdee, goal, protein, fats, carbs, created_at
+)
+SELECT user_id, id, 'USER', user_id, 'current before history was kept',
+       age, height, weight, activity, objective, calories_distribution, bmr_formula,
+       bmr, tdee, goal, protein, fats, carbs, created_at
+FROM user_macro_distribution
+WHERE is_current;
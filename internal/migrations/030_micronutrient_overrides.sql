@@ -0,0 +1,11 @@
+-- Per-user micronutrient targets that replace the embedded reference intakes,
+-- e.g. a lower sodium limit. Amounts are in the ingredient's unit: grams for
+-- fiber and sugar, milligrams for the rest.
+CREATE TABLE IF NOT EXISTS micronutrient_overrides (
+    user_id    UUID          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    nutrient   VARCHAR(16)   NOT NULL CHECK (nutrient IN ('fiber', 'sugar', 'sodium', 'potassium', 'cholesterol')),
+    amount     NUMERIC(8, 2) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, nutrient)
+);
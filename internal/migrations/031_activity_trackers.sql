@@ -0,0 +1,34 @@
+-- Live workout trackers, previously held in the memory of whichever replica
+-- started them. RUNNING and PAUSED trackers are active; the reaper closes
+-- the ones left behind as ABANDONED.
+CREATE TABLE IF NOT EXISTS activity_trackers (
+    id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id             UUID         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    activity_id         UUID         NOT NULL,
+    session_name        VARCHAR(255) NOT NULL DEFAULT '',
+    status              VARCHAR(16)  NOT NULL CHECK (status IN ('RUNNING', 'PAUSED', 'STOPPED', 'ABANDONED')),
+    started_at          TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    last_seen_at        TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    ended_at            TIMESTAMPTZ,
+    exercise_session_id UUID REFERENCES exercise_session (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+-- one active tracker per user
+CREATE UNIQUE INDEX IF NOT EXISTS activity_trackers_one_active
+    ON activity_trackers (user_id) WHERE status IN ('RUNNING', 'PAUSED');
+
+CREATE INDEX IF NOT EXISTS activity_trackers_active_last_seen
+    ON activity_trackers (last_seen_at) WHERE status IN ('RUNNING', 'PAUSED');
+
+CREATE TABLE IF NOT EXISTS activity_tracker_pauses (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tracker_id UUID        NOT NULL REFERENCES activity_trackers (id) ON DELETE CASCADE,
+    paused_at  TIMESTAMPTZ NOT NULL,
+    resumed_at TIMESTAMPTZ,
+    CHECK (resumed_at IS NULL OR resumed_at >= paused_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS activity_tracker_pauses_one_open
+    ON activity_tracker_pauses (tracker_id) WHERE resumed_at IS NULL;
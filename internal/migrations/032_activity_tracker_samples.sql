@@ -0,0 +1,12 @@
+-- Sensor readings streamed during a live workout. seq comes from the client
+-- and makes readings resent after a reconnect idempotent.
+CREATE TABLE IF NOT EXISTS activity_tracker_samples (
+    tracker_id  UUID          NOT NULL REFERENCES activity_trackers (id) ON DELETE CASCADE,
+    seq         BIGINT        NOT NULL CHECK (seq > 0),
+    recorded_at TIMESTAMPTZ   NOT NULL,
+    heart_rate  SMALLINT CHECK (heart_rate BETWEEN 1 AND 250),
+    distance_m  NUMERIC(9, 1) CHECK (distance_m >= 0),
+    cadence     SMALLINT CHECK (cadence BETWEEN 1 AND 300),
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    PRIMARY KEY (tracker_id, seq)
+);
@@ -0,0 +1,11 @@
+-- Burn is computed from METs and the user's weight instead of a flat hourly
+-- figure. Existing figures are read as assuming the 70 kg reference body;
+-- calories_per_hour stays for the catalog RPCs.
+ALTER TABLE activity ADD COLUMN IF NOT EXISTS met NUMERIC(4, 1) CHECK (met > 0);
+
+UPDATE activity
+SET met = GREATEST(1.0, ROUND((calories_per_hour / 70)::numeric, 1))
+WHERE met IS NULL AND calories_per_hour > 0;
+
+COMMENT ON COLUMN activity.met IS 'metabolic equivalent: kcal per kg per hour';
+COMMENT ON COLUMN activity.calories_per_hour IS 'kcal per hour for a 70 kg body';
@@ -0,0 +1,53 @@
+-- Sessions imported from GPX, TCX and FIT files keep the track they came
+-- with. import_hash is the sha256 of the uploaded file, so the same file
+-- can't be imported twice. source is NULL for sessions recorded in the app.
+ALTER TABLE exercise_session
+    ADD COLUMN IF NOT EXISTS distance_m       NUMERIC(9, 1) CHECK (distance_m >= 0),
+    ADD COLUMN IF NOT EXISTS elevation_gain_m NUMERIC(7, 1) CHECK (elevation_gain_m >= 0),
+    ADD COLUMN IF NOT EXISTS avg_heart_rate   SMALLINT CHECK (avg_heart_rate BETWEEN 1 AND 250),
+    ADD COLUMN IF NOT EXISTS max_heart_rate   SMALLINT CHECK (max_heart_rate BETWEEN 1 AND 250),
+    ADD COLUMN IF NOT EXISTS source           VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS import_format    VARCHAR(8),
+    ADD COLUMN IF NOT EXISTS import_hash      CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercise_session_import_hash
+    ON exercise_session (user_id, import_hash) WHERE import_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_exercise_session_user_start
+    ON exercise_session (user_id, start_time);
+
+CREATE TABLE IF NOT EXISTS exercise_session_laps (
+    session_id     UUID          NOT NULL REFERENCES exercise_session (id) ON DELETE CASCADE,
+    lap_number     SMALLINT      NOT NULL CHECK (lap_number > 0),
+    start_time     TIMESTAMP     NOT NULL,
+    elapsed_ms     INTEGER       NOT NULL CHECK (elapsed_ms >= 0),
+    distance_m     NUMERIC(9, 1) NOT NULL DEFAULT 0,
+    calories       INTEGER,
+    avg_heart_rate SMALLINT,
+    max_heart_rate SMALLINT,
+    PRIMARY KEY (session_id, lap_number)
+);
+
+CREATE TABLE IF NOT EXISTS exercise_session_samples (
+    session_id  UUID          NOT NULL REFERENCES exercise_session (id) ON DELETE CASCADE,
+    seq         INTEGER       NOT NULL CHECK (seq > 0),
+    recorded_at TIMESTAMP     NOT NULL,
+    lat         DOUBLE PRECISION,
+    lon         DOUBLE PRECISION,
+    elevation_m NUMERIC(7, 1),
+    heart_rate  SMALLINT,
+    cadence     SMALLINT,
+    distance_m  NUMERIC(9, 1),
+    PRIMARY KEY (session_id, seq)
+);
+
+-- Catalog entries imports fall back to when the file's sport has no better
+-- match; METs from the Compendium of Physical Activities.
+INSERT INTO activity (id, name, calories_per_hour, duration_minutes, total_calories, met)
+SELECT gen_random_uuid(), v.name, v.met * 70, 60, v.met * 70, v.met
+FROM (VALUES ('Running, general', 8.0),
+             ('Cycling, general', 7.5),
+             ('Swimming, general', 7.0),
+             ('Walking, general', 3.5),
+             ('Hiking, general', 6.0),
+             ('Exercise, general', 5.0)) AS v (name, met)
+WHERE NOT EXISTS (SELECT 1 FROM activity a WHERE a.name = v.name AND a.user_id IS NULL);
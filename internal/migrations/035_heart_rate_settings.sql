@@ -0,0 +1,11 @@
+-- A user's own maximum and resting heart rate. Either may be left NULL, in
+-- which case the maximum comes from age and the resting rate is a typical
+-- adult's.
+CREATE TABLE IF NOT EXISTS user_heart_rate_settings (
+    user_id            UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    max_heart_rate     SMALLINT CHECK (max_heart_rate BETWEEN 100 AND 230),
+    resting_heart_rate SMALLINT CHECK (resting_heart_rate BETWEEN 25 AND 120),
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (max_heart_rate IS NULL OR resting_heart_rate IS NULL OR resting_heart_rate < max_heart_rate)
+);
@@ -0,0 +1,4 @@
+-- The IANA zone a user's days, weeks and months are counted in. Timestamps
+-- stay in UTC; the zone only decides where bucket boundaries fall.
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,15 @@
+-- Laps and planned intervals for live workouts. A tracker keeps the plan it
+-- follows and the times laps were marked; when it is saved, its laps land in
+-- exercise_session_laps with the kind of interval step each one covered.
+ALTER TABLE activity_trackers
+    ADD COLUMN IF NOT EXISTS interval_plan VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS activity_tracker_laps (
+    tracker_id UUID        NOT NULL REFERENCES activity_trackers (id) ON DELETE CASCADE,
+    lap_number SMALLINT    NOT NULL CHECK (lap_number > 0),
+    marked_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tracker_id, lap_number)
+);
+
+ALTER TABLE exercise_session_laps
+    ADD COLUMN IF NOT EXISTS kind VARCHAR(8) CHECK (kind IN ('WORK', 'REST'));
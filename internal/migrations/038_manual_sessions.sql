@@ -0,0 +1,5 @@
+-- Sessions can be logged by hand and edited after the fact. updated_at is
+-- NULL for sessions that were never edited. Sessions entered by hand are
+-- marked with source MANUAL, in the column migration 034 added.
+ALTER TABLE exercise_session
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
@@ -0,0 +1,16 @@
+-- Users keep their own activities next to the global catalog (user_id NULL).
+-- Deleting one only sets deleted_at, since sessions and stats refer to it.
+-- Catalog search is fuzzy, over a trigram index on the name.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE activity
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_user_name
+    ON activity (user_id, lower(name)) WHERE user_id IS NOT NULL AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_activity_name_trgm
+    ON activity USING gin (name gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_favourite_activities_user
+    ON favourite_activities (user_id);
@@ -0,0 +1,331 @@
+// Package pagination implements keyset pagination, sorting and filtering for
+// list RPCs. The list request messages in fitme-protos carry no paging
+// fields, so clients pass them as gRPC metadata and the next cursor comes
+// back in the response header:
+//
+//	x-page-size:      25               (default 50, capped at 200)
+//	x-page-cursor:    <opaque cursor>  (from the previous x-next-cursor)
+//	x-sort:           -created_at      (leading '-' sorts descending)
+//	x-filter-<name>:  value            (only whitelisted names)
+//
+// An empty x-next-cursor means the last page was reached.
+package pagination
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+
+	HeaderPageSize     = "x-page-size"
+	HeaderCursor       = "x-page-cursor"
+	HeaderSort         = "x-sort"
+	HeaderFilterPrefix = "x-filter-"
+	HeaderNextCursor   = "x-next-cursor"
+)
+
+// ColumnType is the Postgres type cursor values are cast back to.
+type ColumnType string
+
+const (
+	Text      ColumnType = "text"
+	Numeric   ColumnType = "numeric"
+	Timestamp ColumnType = "timestamp"
+	UUID      ColumnType = "uuid"
+	Int       ColumnType = "bigint"
+)
+
+type FilterOp int
+
+const (
+	Eq FilterOp = iota
+	// Contains is a case-insensitive substring match.
+	Contains
+	Gte
+	Lte
+)
+
+// SortKey is a sortable SQL expression. It must never be NULL, otherwise
+// keyset comparisons skip rows.
+type SortKey struct {
+	Expr string
+	Type ColumnType
+}
+
+type Filter struct {
+	Expr string
+	Type ColumnType
+	Op   FilterOp
+}
+
+// Spec whitelists what a list query can be sorted and filtered by.
+type Spec struct {
+	Sorts map[string]SortKey
+	// DefaultSort is a key of Sorts, optionally prefixed with '-'.
+	DefaultSort string
+	// IDExpr is a unique, non-null expression used to break ties.
+	IDExpr  string
+	IDType  ColumnType
+	Filters map[string]Filter
+}
+
+// Request is the paging input parsed from metadata.
+type Request struct {
+	Size    int
+	Cursor  string
+	Sort    string
+	Filters map[string]string
+}
+
+// FromContext reads the paging headers from incoming gRPC metadata. Missing
+// headers leave the defaults in place.
+func FromContext(ctx context.Context) Request {
+	req := Request{Filters: map[string]string{}}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return req
+	}
+
+	if v := md.Get(HeaderPageSize); len(v) > 0 {
+		req.Size, _ = strconv.Atoi(v[0])
+	}
+	if v := md.Get(HeaderCursor); len(v) > 0 {
+		req.Cursor = v[0]
+	}
+	if v := md.Get(HeaderSort); len(v) > 0 {
+		req.Sort = v[0]
+	}
+	for k, v := range md {
+		if name, ok := strings.CutPrefix(k, HeaderFilterPrefix); ok && len(v) > 0 {
+			req.Filters[name] = v[0]
+		}
+	}
+
+	return req
+}
+
+// BadRequest reports whether err rejects the paging headers themselves, an
+// unknown sort or filter or a malformed cursor, so services pass it on
+// instead of answering Internal.
+func BadRequest(err error) bool {
+	return status.Code(err) == codes.InvalidArgument
+}
+
+type cursor struct {
+	Sort        string `json:"s"`
+	Value       string `json:"v"`
+	ID          string `json:"i"`
+	Fingerprint string `json:"f"`
+}
+
+// Query holds the SQL fragments for one page.
+type Query struct {
+	conds    []string
+	args     []any
+	orderBy  string
+	size     int
+	sortName string
+	sortKey  SortKey
+	spec     Spec
+	filterFP string
+}
+
+// Build validates req against the spec. argOffset is the number of
+// positional arguments already used by the base query.
+func (s Spec) Build(req Request, argOffset int) (*Query, error) {
+	size := req.Size
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	sortName := req.Sort
+	if sortName == "" {
+		sortName = s.DefaultSort
+	}
+	key, ok := s.Sorts[strings.TrimPrefix(sortName, "-")]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported sort %q, allowed: %s", sortName, strings.Join(s.sortNames(), ", "))
+	}
+	desc := strings.HasPrefix(sortName, "-")
+
+	q := &Query{size: size, sortName: sortName, sortKey: key, spec: s}
+	arg := func(v any) string {
+		q.args = append(q.args, v)
+		return "$" + strconv.Itoa(argOffset+len(q.args))
+	}
+
+	names := make([]string, 0, len(req.Filters))
+	for name := range req.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, ok := s.Filters[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported filter %q", name)
+		}
+		value := req.Filters[name]
+		switch f.Op {
+		case Contains:
+			q.conds = append(q.conds, fmt.Sprintf("%s ILIKE '%%' || %s || '%%'", f.Expr, arg(value)))
+		case Gte:
+			q.conds = append(q.conds, fmt.Sprintf("%s >= %s::text::%s", f.Expr, arg(value), f.Type))
+		case Lte:
+			q.conds = append(q.conds, fmt.Sprintf("%s <= %s::text::%s", f.Expr, arg(value), f.Type))
+		default:
+			q.conds = append(q.conds, fmt.Sprintf("%s = %s::text::%s", f.Expr, arg(value), f.Type))
+		}
+	}
+	q.filterFP = fingerprint(req.Filters)
+
+	if req.Cursor != "" {
+		c, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sortName || c.Fingerprint != q.filterFP {
+			return nil, status.Error(codes.InvalidArgument, "cursor does not match the requested sort or filters")
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		q.conds = append(q.conds, fmt.Sprintf("(%s, %s) %s (%s::text::%s, %s::text::%s)",
+			key.Expr, s.IDExpr, op, arg(c.Value), key.Type, arg(c.ID), s.IDType))
+	}
+
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	q.orderBy = fmt.Sprintf("%s %s, %s %s", key.Expr, dir, s.IDExpr, dir)
+
+	return q, nil
+}
+
+func (s Spec) sortNames() []string {
+	names := make([]string, 0, len(s.Sorts))
+	for name := range s.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Where returns the extra conditions prefixed with AND, or "".
+func (q *Query) Where() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(q.conds, " AND ")
+}
+
+// Args returns the positional arguments for Where.
+func (q *Query) Args() []any {
+	return q.args
+}
+
+// CursorColumns is appended to the select list; scan it into the Pager.
+func (q *Query) CursorColumns() string {
+	return fmt.Sprintf("(%s)::text, (%s)::text", q.sortKey.Expr, q.spec.IDExpr)
+}
+
+// OrderLimit returns the ORDER BY and LIMIT clauses. One extra row is
+// fetched to know whether another page exists.
+func (q *Query) OrderLimit() string {
+	return fmt.Sprintf(" ORDER BY %s LIMIT %d", q.orderBy, q.size+1)
+}
+
+// Pager tracks the rows of a page and produces the next cursor.
+type Pager struct {
+	q       *Query
+	n       int
+	hasMore bool
+	last    cursor
+
+	// SortValue and ID are scan targets for CursorColumns.
+	SortValue string
+	ID        string
+}
+
+func (q *Query) Pager() *Pager {
+	return &Pager{q: q}
+}
+
+// Next must be called after scanning each row. It returns false for the
+// look-ahead row, which the caller should discard.
+func (p *Pager) Next() bool {
+	if p.n == p.q.size {
+		p.hasMore = true
+		return false
+	}
+	p.n++
+	p.last = cursor{Sort: p.q.sortName, Value: p.SortValue, ID: p.ID, Fingerprint: p.q.filterFP}
+	return true
+}
+
+// NextCursor is empty on the last page.
+func (p *Pager) NextCursor() string {
+	if !p.hasMore {
+		return ""
+	}
+	data, _ := json.Marshal(p.last)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// SetHeader sends the next cursor to the client. It is a no-op outside of
+// a gRPC handler, so repositories can also be called internally.
+func (p *Pager) SetHeader(ctx context.Context) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(HeaderNextCursor, p.NextCursor()))
+}
+
+func decodeCursor(raw string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, status.Error(codes.InvalidArgument, "malformed cursor")
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return c, status.Error(codes.InvalidArgument, "malformed cursor")
+	}
+	return c, nil
+}
+
+func fingerprint(filters map[string]string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(filters[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,101 @@
+package pagination
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testSpec = Spec{
+	Sorts: map[string]SortKey{
+		"name":       {Expr: "name", Type: Text},
+		"created_at": {Expr: "created_at", Type: Timestamp},
+	},
+	DefaultSort: "name",
+	IDExpr:      "id",
+	IDType:      UUID,
+	Filters: map[string]Filter{
+		"name": {Expr: "name", Op: Contains},
+		"type": {Expr: "type", Type: Text, Op: Eq},
+	},
+}
+
+func TestBuildDefaults(t *testing.T) {
+	q, err := testSpec.Build(Request{}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Where() != "" || len(q.Args()) != 0 {
+		t.Errorf("where = %q, args = %v", q.Where(), q.Args())
+	}
+	if want := " ORDER BY name ASC, id ASC LIMIT 51"; q.OrderLimit() != want {
+		t.Errorf("order = %q, want %q", q.OrderLimit(), want)
+	}
+}
+
+func TestBuildRejectsUnknownKeys(t *testing.T) {
+	for _, req := range []Request{
+		{Sort: "password"},
+		{Filters: map[string]string{"password": "x"}},
+		{Cursor: "not base64!"},
+	} {
+		_, err := testSpec.Build(req, 0)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Build(%+v) error = %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	req := Request{Size: 2, Sort: "-created_at", Filters: map[string]string{"type": "cardio"}}
+	q, err := testSpec.Build(req, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := q.Pager()
+	rows := [][2]string{{"2024-03-01", "c"}, {"2024-02-01", "b"}, {"2024-01-01", "a"}}
+	var kept int
+	for _, row := range rows {
+		p.SortValue, p.ID = row[0], row[1]
+		if !p.Next() {
+			break
+		}
+		kept++
+	}
+	if kept != 2 {
+		t.Fatalf("kept %d rows, want 2", kept)
+	}
+
+	req.Cursor = p.NextCursor()
+	if req.Cursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+	next, err := testSpec.Build(req, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(next.Where(), "(created_at, id) < ($5::text::timestamp, $6::text::uuid)") {
+		t.Errorf("where = %q", next.Where())
+	}
+	if args := next.Args(); len(args) != 3 || args[1] != "2024-02-01" || args[2] != "b" {
+		t.Errorf("args = %v", args)
+	}
+
+	req.Filters = map[string]string{"type": "strength"}
+	if _, err = testSpec.Build(req, 0); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("cursor reused with other filters: err = %v", err)
+	}
+}
+
+func TestLastPageHasNoCursor(t *testing.T) {
+	q, _ := testSpec.Build(Request{Size: 5}, 0)
+	p := q.Pager()
+	p.SortValue, p.ID = "a", "1"
+	p.Next()
+	if c := p.NextCursor(); c != "" {
+		t.Errorf("cursor = %q, want empty", c)
+	}
+}
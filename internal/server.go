@@ -45,7 +45,7 @@ func ServeGRPC(ctx context.Context, port string, container *ServiceContainer, re
 	tp := otel.GetTracerProvider()
 
 	// Bootstrap the gRPC server
-	server, listener, err := grpc.BootstrapServer(port, log, reg, tp)
+	server, listener, err := grpc.BootstrapServer(port, log, reg, tp, container.TenantResolver)
 	if err != nil {
 		return errors.Wrap(err, "failed to configure gRPC server")
 	}
@@ -0,0 +1,116 @@
+package units
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// System is how a user reads and enters measurements.
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+// Header lets a single request override the user's preferred system.
+const Header = "x-unit-system"
+
+// ParseSystem accepts the proto enum names ("METRIC"), the calculator's own
+// names ("Metric") and the stored values. Empty means metric.
+func ParseSystem(s string) (System, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "metric", "system_unspecified":
+		return Metric, nil
+	case "imperial":
+		return Imperial, nil
+	default:
+		return "", fmt.Errorf("unknown unit system %q", s)
+	}
+}
+
+// Mass reads v as kilograms or pounds.
+func (s System) Mass(v float64) Mass {
+	if s == Imperial {
+		return Pounds(v)
+	}
+	return Kilograms(v)
+}
+
+// MassValue expresses m in kilograms or pounds.
+func (s System) MassValue(m Mass) float64 {
+	if s == Imperial {
+		return m.Pounds()
+	}
+	return m.Kilograms()
+}
+
+// Length reads v as centimetres or inches, the units used for height and
+// circumferences.
+func (s System) Length(v float64) Length {
+	if s == Imperial {
+		return Inches(v)
+	}
+	return Centimetres(v)
+}
+
+// LengthValue expresses l in centimetres or inches.
+func (s System) LengthValue(l Length) float64 {
+	if s == Imperial {
+		return l.Inches()
+	}
+	return l.Centimetres()
+}
+
+// Volume reads v as millilitres or US fluid ounces.
+func (s System) Volume(v float64) Volume {
+	if s == Imperial {
+		return FluidOunces(v)
+	}
+	return Millilitres(v)
+}
+
+// VolumeValue expresses v in millilitres or US fluid ounces.
+func (s System) VolumeValue(v Volume) float64 {
+	if s == Imperial {
+		return v.FluidOunces()
+	}
+	return v.Millilitres()
+}
+
+func (s System) MassUnit() string {
+	if s == Imperial {
+		return "lb"
+	}
+	return "kg"
+}
+
+func (s System) LengthUnit() string {
+	if s == Imperial {
+		return "in"
+	}
+	return "cm"
+}
+
+func (s System) VolumeUnit() string {
+	if s == Imperial {
+		return "fl oz"
+	}
+	return "ml"
+}
+
+type contextKey struct{}
+
+// WithSystem records the system the current request is answered in.
+func WithSystem(ctx context.Context, s System) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the request's system, metric when none was set.
+func FromContext(ctx context.Context) System {
+	if s, ok := ctx.Value(contextKey{}).(System); ok {
+		return s
+	}
+	return Metric
+}
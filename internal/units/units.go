@@ -0,0 +1,109 @@
+// Package units converts body measurements between metric and imperial.
+//
+// Quantities are stored in SI: a Mass is kilograms, a Length is metres and a
+// Volume is litres. Values only leave SI at the API boundary, through a
+// System, so formulas and the database never see pounds or inches.
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	kgPerPound   = 0.45359237
+	metresPerIn  = 0.0254
+	inchesPerFt  = 12
+	litresPerOz  = 0.0295735295625
+	litresPerGal = 3.785411784
+)
+
+// Mass is in kilograms.
+type Mass float64
+
+func Kilograms(v float64) Mass { return Mass(v) }
+func Pounds(v float64) Mass    { return Mass(v * kgPerPound) }
+
+func (m Mass) Kilograms() float64 { return float64(m) }
+func (m Mass) Pounds() float64    { return float64(m) / kgPerPound }
+
+// Length is in metres.
+type Length float64
+
+func Metres(v float64) Length      { return Length(v) }
+func Centimetres(v float64) Length { return Length(v / 100) }
+func Inches(v float64) Length      { return Length(v * metresPerIn) }
+
+// FeetInches builds a length from a height such as 5 ft 11 in.
+func FeetInches(feet, inches float64) Length {
+	return Inches(feet*inchesPerFt + inches)
+}
+
+func (l Length) Metres() float64      { return float64(l) }
+func (l Length) Centimetres() float64 { return float64(l) * 100 }
+func (l Length) Inches() float64      { return float64(l) / metresPerIn }
+
+// FeetInches splits a length into whole feet and the remaining inches.
+func (l Length) FeetInches() (int, float64) {
+	total := l.Inches()
+	feet := math.Floor(total / inchesPerFt)
+	return int(feet), total - feet*inchesPerFt
+}
+
+// Volume is in litres.
+type Volume float64
+
+func Litres(v float64) Volume      { return Volume(v) }
+func Millilitres(v float64) Volume { return Volume(v / 1000) }
+func FluidOunces(v float64) Volume { return Volume(v * litresPerOz) }
+func Gallons(v float64) Volume     { return Volume(v * litresPerGal) }
+
+func (v Volume) Litres() float64      { return float64(v) }
+func (v Volume) Millilitres() float64 { return float64(v) * 1000 }
+func (v Volume) FluidOunces() float64 { return float64(v) / litresPerOz }
+
+var (
+	feetInchesPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(?:'|ft|feet)\s*(?:(\d+(?:\.\d+)?)\s*(?:"|''|in|inches)?)?$`)
+	valueUnitPattern  = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z"]*)$`)
+)
+
+// ParseLength reads a length such as `5'11"`, "5ft 11in", "180cm", "1.8m" or
+// "71in". A bare number is read in the system's length unit.
+func ParseLength(s string, system System) (Length, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if m := feetInchesPattern.FindStringSubmatch(s); m != nil {
+		feet, _ := strconv.ParseFloat(m[1], 64)
+		var inches float64
+		if m[2] != "" {
+			inches, _ = strconv.ParseFloat(m[2], 64)
+		}
+		if inches >= inchesPerFt {
+			return 0, fmt.Errorf("invalid length %q: inches must be below 12", s)
+		}
+		return FeetInches(feet, inches), nil
+	}
+
+	m := valueUnitPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid length %q", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid length %q", s)
+	}
+	switch m[2] {
+	case "":
+		return system.Length(v), nil
+	case "cm":
+		return Centimetres(v), nil
+	case "m":
+		return Metres(v), nil
+	case "in", `"`, "inches":
+		return Inches(v), nil
+	default:
+		return 0, fmt.Errorf("invalid length %q: unknown unit %q", s, m[2])
+	}
+}
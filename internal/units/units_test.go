@@ -0,0 +1,111 @@
+package units
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"kg to lb", Kilograms(80).Pounds(), 176.36981},
+		{"lb to kg", Pounds(176).Kilograms(), 79.8322571},
+		{"cm to in", Centimetres(180).Inches(), 70.8661417},
+		{"in to cm", Inches(71).Centimetres(), 180.34},
+		{"feet and inches", FeetInches(5, 11).Centimetres(), 180.34},
+		{"ml to fl oz", Millilitres(500).FluidOunces(), 16.9070114},
+		{"fl oz to ml", FluidOunces(8).Millilitres(), 236.5882365},
+		{"gallon to l", Gallons(1).Litres(), 3.785411784},
+		{"metric mass", Metric.MassValue(Metric.Mass(80)), 80},
+		{"imperial mass round trip", Imperial.MassValue(Imperial.Mass(176)), 176},
+		{"imperial length", Metric.LengthValue(Imperial.Length(71)), 180.34},
+		{"imperial volume", Metric.VolumeValue(Imperial.Volume(8)), 236.5882365},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if math.Abs(tt.got-tt.want) > 1e-5 {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLengthFeetInches(t *testing.T) {
+	feet, inches := Centimetres(180.34).FeetInches()
+	if feet != 5 || !near(inches, 11) {
+		t.Errorf("got %d ft %v in, want 5 ft 11 in", feet, inches)
+	}
+}
+
+func TestParseLength(t *testing.T) {
+	tests := []struct {
+		in      string
+		system  System
+		wantCm  float64
+		wantErr bool
+	}{
+		{`5'11"`, Metric, 180.34, false},
+		{"5ft 11in", Metric, 180.34, false},
+		{"5 feet", Metric, 152.4, false},
+		{"6'", Metric, 182.88, false},
+		{"180cm", Imperial, 180, false},
+		{"1.8m", Imperial, 180, false},
+		{"71in", Metric, 180.34, false},
+		{"180", Metric, 180, false},
+		{"71", Imperial, 180.34, false},
+		{`5'13"`, Metric, 0, true},
+		{"tall", Metric, 0, true},
+		{"180 furlongs", Metric, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLength(tt.in, tt.system)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !near(got.Centimetres(), tt.wantCm) {
+				t.Errorf("got %v cm, want %v", got.Centimetres(), tt.wantCm)
+			}
+		})
+	}
+}
+
+func TestParseSystem(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    System
+		wantErr bool
+	}{
+		{"", Metric, false},
+		{"METRIC", Metric, false},
+		{"Metric", Metric, false},
+		{"SYSTEM_UNSPECIFIED", Metric, false},
+		{"IMPERIAL", Imperial, false},
+		{" imperial ", Imperial, false},
+		{"cubits", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSystem(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSystem(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != Metric {
+		t.Errorf("default = %q, want metric", got)
+	}
+	if got := FromContext(WithSystem(context.Background(), Imperial)); got != Imperial {
+		t.Errorf("got %q, want imperial", got)
+	}
+}
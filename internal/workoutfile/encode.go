@@ -0,0 +1,233 @@
+package workoutfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Encode writes activities as one GPX or TCX document, a track or activity
+// each. GPX has no place for points without a position, so those are left
+// out; TCX keeps every point.
+func Encode(format Format, activities []*Activity) ([]byte, error) {
+	var doc any
+	switch format {
+	case GPX:
+		doc = gpxDocument(activities)
+	case TCX:
+		doc = tcxDocument(activities)
+	default:
+		return nil, fmt.Errorf("can't write %s files", format)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+const xmlTime = "2006-01-02T15:04:05Z"
+
+func formatXMLTime(t time.Time) string {
+	return t.UTC().Format(xmlTime)
+}
+
+// The element names carry their prefixes literally; the decoder matches on
+// local names, so what we write reads back.
+type gpxOut struct {
+	XMLName  xml.Name   `xml:"gpx"`
+	Version  string     `xml:"version,attr"`
+	Creator  string     `xml:"creator,attr"`
+	NS       string     `xml:"xmlns,attr"`
+	NSTPX    string     `xml:"xmlns:gpxtpx,attr"`
+	Metadata gpxOutMeta `xml:"metadata"`
+	Tracks   []gpxOutTrk
+}
+
+type gpxOutMeta struct {
+	Time string `xml:"time,omitempty"`
+}
+
+type gpxOutTrk struct {
+	XMLName xml.Name     `xml:"trk"`
+	Name    string       `xml:"name,omitempty"`
+	Type    string       `xml:"type,omitempty"`
+	Points  []gpxOutTrkp `xml:"trkseg>trkpt"`
+}
+
+type gpxOutTrkp struct {
+	Lat        float64       `xml:"lat,attr"`
+	Lon        float64       `xml:"lon,attr"`
+	Elevation  *float64      `xml:"ele,omitempty"`
+	Time       string        `xml:"time"`
+	Extensions *gpxOutTPXExt `xml:"extensions,omitempty"`
+}
+
+type gpxOutTPXExt struct {
+	HeartRate int `xml:"gpxtpx:TrackPointExtension>gpxtpx:hr,omitempty"`
+	Cadence   int `xml:"gpxtpx:TrackPointExtension>gpxtpx:cad,omitempty"`
+}
+
+func gpxDocument(activities []*Activity) gpxOut {
+	doc := gpxOut{
+		Version: "1.1",
+		Creator: "FitSphere",
+		NS:      "http://www.topografix.com/GPX/1/1",
+		NSTPX:   "http://www.garmin.com/xmlschemas/TrackPointExtension/v1",
+	}
+	for _, a := range activities {
+		if doc.Metadata.Time == "" && !a.Start.IsZero() {
+			doc.Metadata.Time = formatXMLTime(a.Start)
+		}
+		trk := gpxOutTrk{Name: a.Name, Type: a.Sport}
+		for _, p := range a.Points {
+			if !p.HasPosition {
+				continue
+			}
+			pt := gpxOutTrkp{Lat: p.Lat, Lon: p.Lon, Time: formatXMLTime(p.Time)}
+			if p.HasElevation {
+				ele := p.ElevationM
+				pt.Elevation = &ele
+			}
+			if p.HeartRate > 0 || p.Cadence > 0 {
+				pt.Extensions = &gpxOutTPXExt{HeartRate: p.HeartRate, Cadence: p.Cadence}
+			}
+			trk.Points = append(trk.Points, pt)
+		}
+		doc.Tracks = append(doc.Tracks, trk)
+	}
+	return doc
+}
+
+type tcxOut struct {
+	XMLName    xml.Name         `xml:"TrainingCenterDatabase"`
+	NS         string           `xml:"xmlns,attr"`
+	Activities []tcxOutActivity `xml:"Activities>Activity"`
+}
+
+type tcxOutActivity struct {
+	Sport string      `xml:"Sport,attr"`
+	ID    string      `xml:"Id"`
+	Laps  []tcxOutLap `xml:"Lap"`
+	Notes string      `xml:"Notes,omitempty"`
+}
+
+type tcxOutLap struct {
+	StartTime        string         `xml:"StartTime,attr"`
+	TotalTimeSeconds float64        `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64        `xml:"DistanceMeters"`
+	Calories         int            `xml:"Calories"`
+	AvgHeartRate     *tcxOutValue   `xml:"AverageHeartRateBpm,omitempty"`
+	MaxHeartRate     *tcxOutValue   `xml:"MaximumHeartRateBpm,omitempty"`
+	Intensity        string         `xml:"Intensity"`
+	TriggerMethod    string         `xml:"TriggerMethod"`
+	Points           []tcxOutTrkpnt `xml:"Track>Trackpoint"`
+}
+
+type tcxOutValue struct {
+	Value int `xml:"Value"`
+}
+
+type tcxOutTrkpnt struct {
+	Time      string          `xml:"Time"`
+	Position  *tcxOutPosition `xml:"Position,omitempty"`
+	Altitude  *float64        `xml:"AltitudeMeters,omitempty"`
+	Distance  *float64        `xml:"DistanceMeters,omitempty"`
+	HeartRate *tcxOutValue    `xml:"HeartRateBpm,omitempty"`
+	Cadence   int             `xml:"Cadence,omitempty"`
+}
+
+type tcxOutPosition struct {
+	Lat float64 `xml:"LatitudeDegrees"`
+	Lon float64 `xml:"LongitudeDegrees"`
+}
+
+// tcxSport maps onto the three sports TCX knows.
+func tcxSport(sport string) string {
+	switch sport {
+	case "running":
+		return "Running"
+	case "cycling":
+		return "Biking"
+	}
+	return "Other"
+}
+
+func tcxDocument(activities []*Activity) tcxOut {
+	doc := tcxOut{NS: "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"}
+	for _, a := range activities {
+		doc.Activities = append(doc.Activities, tcxOutActivity{
+			Sport: tcxSport(a.Sport),
+			ID:    formatXMLTime(a.Start),
+			Laps:  tcxLaps(a),
+			Notes: a.Name,
+		})
+	}
+	return doc
+}
+
+// tcxLaps writes the recorded laps with the points that fall in them, or
+// the whole activity as a single lap when there are none.
+func tcxLaps(a *Activity) []tcxOutLap {
+	laps := a.Laps
+	if len(laps) == 0 {
+		laps = []Lap{{
+			Start: a.Start, Elapsed: a.Duration(), DistanceM: a.DistanceM, Calories: a.Calories,
+			AvgHeartRate: a.AvgHeartRate, MaxHeartRate: a.MaxHeartRate,
+		}}
+	}
+
+	out := make([]tcxOutLap, 0, len(laps))
+	next := 0
+	for i, l := range laps {
+		lap := tcxOutLap{
+			StartTime:        formatXMLTime(l.Start),
+			TotalTimeSeconds: math.Round(l.Elapsed.Seconds()*1000) / 1000,
+			DistanceMeters:   l.DistanceM,
+			Calories:         l.Calories,
+			Intensity:        "Active",
+			TriggerMethod:    "Manual",
+		}
+		if l.AvgHeartRate > 0 {
+			lap.AvgHeartRate = &tcxOutValue{l.AvgHeartRate}
+		}
+		if l.MaxHeartRate > 0 {
+			lap.MaxHeartRate = &tcxOutValue{l.MaxHeartRate}
+		}
+		for ; next < len(a.Points); next++ {
+			p := a.Points[next]
+			if i+1 < len(laps) && !p.Time.Before(laps[i+1].Start) {
+				break
+			}
+			lap.Points = append(lap.Points, tcxTrackpoint(p))
+		}
+		out = append(out, lap)
+	}
+	return out
+}
+
+func tcxTrackpoint(p Point) tcxOutTrkpnt {
+	tp := tcxOutTrkpnt{Time: formatXMLTime(p.Time), Cadence: p.Cadence}
+	if p.HasPosition {
+		tp.Position = &tcxOutPosition{Lat: p.Lat, Lon: p.Lon}
+	}
+	if p.HasElevation {
+		ele := p.ElevationM
+		tp.Altitude = &ele
+	}
+	if p.DistanceM > 0 {
+		d := p.DistanceM
+		tp.Distance = &d
+	}
+	if p.HeartRate > 0 {
+		tp.HeartRate = &tcxOutValue{p.HeartRate}
+	}
+	return tp
+}
@@ -0,0 +1,336 @@
+package workoutfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// FIT is Garmin's binary format: a header, a stream of definition and data
+// messages, and a CRC. Only the messages a workout summary needs are read;
+// everything else is skipped by size.
+
+// FIT timestamps count seconds from 1989-12-31T00:00:00Z.
+const fitEpoch = 631065600
+
+// Global message numbers.
+const (
+	fitSession = 18
+	fitLap     = 19
+	fitRecord  = 20
+)
+
+const fitTimestamp = 253
+
+var errTruncated = errors.New("truncated file")
+
+type fitField struct {
+	num, size, baseType byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitField
+	devSize   int
+}
+
+// fitValues holds one data message's readable fields by field number.
+type fitValues map[byte]int64
+
+func (v fitValues) get(num byte) (int64, bool) {
+	x, ok := v[num]
+	return x, ok
+}
+
+func parseFIT(data []byte) (*Activity, error) {
+	if len(data) < 12 {
+		return nil, errTruncated
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || string(data[8:12]) != ".FIT" {
+		return nil, errors.New("missing .FIT signature")
+	}
+	end := headerSize + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end+2 > len(data) {
+		return nil, errTruncated
+	}
+	if got, want := fitCRC(data[:end]), binary.LittleEndian.Uint16(data[end:end+2]); got != want {
+		return nil, fmt.Errorf("checksum mismatch: %04x != %04x", got, want)
+	}
+
+	a := &Activity{}
+	defs := map[byte]*fitDefinition{}
+	var lastTimestamp uint32
+	pos := headerSize
+	for pos < end {
+		header := data[pos]
+		pos++
+
+		var local byte
+		var timeOffset = -1
+		switch {
+		case header&0x80 != 0: // compressed timestamp header
+			local = (header >> 5) & 0x03
+			timeOffset = int(header & 0x1f)
+		case header&0x40 != 0: // definition
+			def, n, err := readFITDefinition(data[pos:end], header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			defs[header&0x0f] = def
+			pos += n
+			continue
+		default:
+			local = header & 0x0f
+		}
+
+		def, ok := defs[local]
+		if !ok {
+			return nil, fmt.Errorf("data message for undefined local type %d", local)
+		}
+		values, n, err := readFITData(data[pos:end], def)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		if ts, ok := values.get(fitTimestamp); ok {
+			lastTimestamp = uint32(ts)
+		} else if timeOffset >= 0 {
+			ts := lastTimestamp&^0x1f | uint32(timeOffset)
+			if uint32(timeOffset) < lastTimestamp&0x1f {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+			values[fitTimestamp] = int64(ts)
+		}
+		a.addFITMessage(def.global, values)
+	}
+	return a, nil
+}
+
+func readFITDefinition(b []byte, developer bool) (*fitDefinition, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errTruncated
+	}
+	def := &fitDefinition{bigEndian: b[1] == 1}
+	if def.bigEndian {
+		def.global = binary.BigEndian.Uint16(b[2:4])
+	} else {
+		def.global = binary.LittleEndian.Uint16(b[2:4])
+	}
+	count := int(b[4])
+	pos := 5
+	if len(b) < pos+3*count {
+		return nil, 0, errTruncated
+	}
+	for i := 0; i < count; i++ {
+		def.fields = append(def.fields, fitField{num: b[pos], size: b[pos+1], baseType: b[pos+2]})
+		pos += 3
+	}
+	if developer {
+		if len(b) < pos+1 {
+			return nil, 0, errTruncated
+		}
+		devCount := int(b[pos])
+		pos++
+		if len(b) < pos+3*devCount {
+			return nil, 0, errTruncated
+		}
+		for i := 0; i < devCount; i++ {
+			def.devSize += int(b[pos+1])
+			pos += 3
+		}
+	}
+	return def, pos, nil
+}
+
+func readFITData(b []byte, def *fitDefinition) (fitValues, int, error) {
+	values := fitValues{}
+	pos := 0
+	for _, f := range def.fields {
+		size := int(f.size)
+		if len(b) < pos+size {
+			return nil, 0, errTruncated
+		}
+		if v, ok := fitValue(b[pos:pos+size], f.baseType, def.bigEndian); ok {
+			values[f.num] = v
+		}
+		pos += size
+	}
+	if len(b) < pos+def.devSize {
+		return nil, 0, errTruncated
+	}
+	return values, pos + def.devSize, nil
+}
+
+// fitValue decodes a single integer field. Arrays, strings and floats
+// aren't needed and come back as not ok, as do the format's invalid markers.
+func fitValue(b []byte, baseType byte, bigEndian bool) (int64, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	var raw uint64
+	var invalid uint64
+	signed := false
+	switch baseType & 0x1f {
+	case 0x00, 0x02, 0x0a, 0x0d: // enum, uint8, uint8z, byte
+		if len(b) != 1 {
+			return 0, false
+		}
+		raw, invalid = uint64(b[0]), 0xff
+	case 0x01: // sint8
+		if len(b) != 1 {
+			return 0, false
+		}
+		raw, invalid, signed = uint64(b[0]), 0x7f, true
+	case 0x03: // sint16
+		if len(b) != 2 {
+			return 0, false
+		}
+		raw, invalid, signed = uint64(order.Uint16(b)), 0x7fff, true
+	case 0x04, 0x0b: // uint16, uint16z
+		if len(b) != 2 {
+			return 0, false
+		}
+		raw, invalid = uint64(order.Uint16(b)), 0xffff
+	case 0x05: // sint32
+		if len(b) != 4 {
+			return 0, false
+		}
+		raw, invalid, signed = uint64(order.Uint32(b)), 0x7fffffff, true
+	case 0x06, 0x0c: // uint32, uint32z
+		if len(b) != 4 {
+			return 0, false
+		}
+		raw, invalid = uint64(order.Uint32(b)), 0xffffffff
+	default:
+		return 0, false
+	}
+
+	// the z types mark invalid with zero instead
+	if raw == invalid || (raw == 0 && (baseType&0x1f == 0x0a || baseType&0x1f == 0x0b || baseType&0x1f == 0x0c)) {
+		return 0, false
+	}
+	if signed {
+		shift := 64 - 8*uint(len(b))
+		return int64(raw<<shift) >> shift, true
+	}
+	return int64(raw), true
+}
+
+func fitTime(ts int64) time.Time {
+	return time.Unix(ts+fitEpoch, 0).UTC()
+}
+
+// semicircles converts the FIT position unit to degrees.
+func semicircles(v int64) float64 {
+	return float64(v) * 180 / math.Pow(2, 31)
+}
+
+func (a *Activity) addFITMessage(global uint16, v fitValues) {
+	switch global {
+	case fitRecord:
+		ts, ok := v.get(fitTimestamp)
+		if !ok {
+			return
+		}
+		p := Point{Time: fitTime(ts)}
+		lat, latOK := v.get(0)
+		lon, lonOK := v.get(1)
+		if latOK && lonOK {
+			p.Lat, p.Lon, p.HasPosition = semicircles(lat), semicircles(lon), true
+		}
+		if alt, ok := v.get(78); ok {
+			p.ElevationM, p.HasElevation = float64(alt)/5-500, true
+		} else if alt, ok := v.get(2); ok {
+			p.ElevationM, p.HasElevation = float64(alt)/5-500, true
+		}
+		if hr, ok := v.get(3); ok {
+			p.HeartRate = int(hr)
+		}
+		if cad, ok := v.get(4); ok {
+			p.Cadence = int(cad)
+		}
+		if d, ok := v.get(5); ok {
+			p.DistanceM = float64(d) / 100
+		}
+		a.Points = append(a.Points, p)
+
+	case fitLap:
+		start, ok := v.get(2)
+		if !ok {
+			return
+		}
+		l := Lap{Start: fitTime(start)}
+		if ms, ok := v.get(7); ok {
+			l.Elapsed = time.Duration(ms) * time.Millisecond
+		}
+		if d, ok := v.get(9); ok {
+			l.DistanceM = float64(d) / 100
+		}
+		if c, ok := v.get(11); ok {
+			l.Calories = int(c)
+		}
+		if hr, ok := v.get(15); ok {
+			l.AvgHeartRate = int(hr)
+		}
+		if hr, ok := v.get(16); ok {
+			l.MaxHeartRate = int(hr)
+		}
+		a.Laps = append(a.Laps, l)
+
+	case fitSession:
+		if sport, ok := v.get(5); ok && a.Sport == "" {
+			a.Sport = fitSport(sport)
+		}
+		if d, ok := v.get(9); ok {
+			a.DistanceM += float64(d) / 100
+		}
+		if c, ok := v.get(11); ok {
+			a.Calories += int(c)
+		}
+		if gain, ok := v.get(22); ok {
+			a.ElevationGainM += float64(gain)
+		}
+	}
+}
+
+func fitSport(v int64) string {
+	switch v {
+	case 1:
+		return "running"
+	case 2:
+		return "cycling"
+	case 5:
+		return "swimming"
+	case 11:
+		return "walking"
+	case 17:
+		return "hiking"
+	}
+	return "generic"
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+// fitCRC is the CRC-16 from the FIT SDK, run a nibble at a time.
+func fitCRC(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		tmp := fitCRCTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[c&0xf]
+		tmp = fitCRCTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[(c>>4)&0xf]
+	}
+	return crc
+}
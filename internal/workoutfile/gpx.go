@@ -0,0 +1,99 @@
+package workoutfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// GPX 1.1 with the Garmin TrackPointExtension for heart rate and cadence.
+// Elements are matched by local name, so namespace prefixes don't matter.
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+	HeartRate int      `xml:"extensions>TrackPointExtension>hr"`
+	Cadence   int      `xml:"extensions>TrackPointExtension>cad"`
+}
+
+func parseGPX(data []byte) (*Activity, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	a := &Activity{}
+	for _, trk := range f.Tracks {
+		if a.Name == "" {
+			a.Name = strings.TrimSpace(trk.Name)
+		}
+		if a.Sport == "" {
+			a.Sport = NormaliseSport(trk.Type)
+		}
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				t, err := parseXMLTime(p.Time)
+				if err != nil {
+					return nil, err
+				}
+				point := Point{
+					Time: t, Lat: p.Lat, Lon: p.Lon, HasPosition: true,
+					HeartRate: p.HeartRate, Cadence: p.Cadence,
+				}
+				if p.Elevation != nil {
+					point.ElevationM, point.HasElevation = *p.Elevation, true
+				}
+				a.Points = append(a.Points, point)
+			}
+		}
+	}
+	return a, nil
+}
+
+// parseXMLTime reads the xsd:dateTime stamps both formats use. A missing
+// time gives the zero time, which Parse drops.
+func parseXMLTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		// some devices leave out the zone; they record UTC
+		t, err = time.Parse("2006-01-02T15:04:05", s)
+	}
+	return t.UTC(), err
+}
+
+// NormaliseSport maps the sport names devices and the activity catalog use
+// onto running, cycling, swimming, hiking, walking or generic.
+func NormaliseSport(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "":
+		return ""
+	case strings.Contains(s, "run"), strings.Contains(s, "jog"):
+		return "running"
+	case strings.Contains(s, "bik"), strings.Contains(s, "cycl"), strings.Contains(s, "ride"):
+		return "cycling"
+	case strings.Contains(s, "swim"):
+		return "swimming"
+	case strings.Contains(s, "hik"):
+		return "hiking"
+	case strings.Contains(s, "walk"):
+		return "walking"
+	}
+	return "generic"
+}
@@ -0,0 +1,91 @@
+package workoutfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strings"
+	"time"
+)
+
+// Garmin Training Center Database v2, with the ActivityExtension RunCadence
+// that running watches write instead of Cadence.
+type tcxFile struct {
+	Activities []struct {
+		Sport string   `xml:"Sport,attr"`
+		ID    string   `xml:"Id"`
+		Laps  []tcxLap `xml:"Lap"`
+		Notes string   `xml:"Notes"`
+	} `xml:"Activities>Activity"`
+}
+
+type tcxLap struct {
+	StartTime        string     `xml:"StartTime,attr"`
+	TotalTimeSeconds float64    `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64    `xml:"DistanceMeters"`
+	Calories         int        `xml:"Calories"`
+	AvgHeartRate     int        `xml:"AverageHeartRateBpm>Value"`
+	MaxHeartRate     int        `xml:"MaximumHeartRateBpm>Value"`
+	Points           []tcxPoint `xml:"Track>Trackpoint"`
+}
+
+type tcxPoint struct {
+	Time           string   `xml:"Time"`
+	Lat            *float64 `xml:"Position>LatitudeDegrees"`
+	Lon            *float64 `xml:"Position>LongitudeDegrees"`
+	AltitudeMeters *float64 `xml:"AltitudeMeters"`
+	DistanceMeters float64  `xml:"DistanceMeters"`
+	HeartRate      int      `xml:"HeartRateBpm>Value"`
+	Cadence        int      `xml:"Cadence"`
+	RunCadence     int      `xml:"Extensions>TPX>RunCadence"`
+}
+
+func parseTCX(data []byte) (*Activity, error) {
+	var f tcxFile
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	a := &Activity{}
+	for _, act := range f.Activities {
+		if a.Sport == "" {
+			a.Sport = NormaliseSport(act.Sport)
+		}
+		if a.Name == "" {
+			a.Name = strings.TrimSpace(act.Notes)
+		}
+		for _, l := range act.Laps {
+			start, err := parseXMLTime(l.StartTime)
+			if err != nil {
+				return nil, err
+			}
+			a.Laps = append(a.Laps, Lap{
+				Start:        start,
+				Elapsed:      time.Duration(math.Round(l.TotalTimeSeconds * float64(time.Second))),
+				DistanceM:    l.DistanceMeters,
+				Calories:     l.Calories,
+				AvgHeartRate: l.AvgHeartRate,
+				MaxHeartRate: l.MaxHeartRate,
+			})
+
+			for _, p := range l.Points {
+				t, err := parseXMLTime(p.Time)
+				if err != nil {
+					return nil, err
+				}
+				point := Point{Time: t, HeartRate: p.HeartRate, Cadence: p.Cadence, DistanceM: p.DistanceMeters}
+				if point.Cadence == 0 {
+					point.Cadence = p.RunCadence
+				}
+				if p.Lat != nil && p.Lon != nil {
+					point.Lat, point.Lon, point.HasPosition = *p.Lat, *p.Lon, true
+				}
+				if p.AltitudeMeters != nil {
+					point.ElevationM, point.HasElevation = *p.AltitudeMeters, true
+				}
+				a.Points = append(a.Points, point)
+			}
+		}
+	}
+	return a, nil
+}
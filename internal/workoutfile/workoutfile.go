@@ -0,0 +1,255 @@
+// Package workoutfile reads recorded workouts from the files watches and
+// bike computers produce: GPX, TCX and FIT.
+package workoutfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format is a workout file format.
+type Format string
+
+const (
+	GPX Format = "GPX"
+	TCX Format = "TCX"
+	FIT Format = "FIT"
+)
+
+// ParseFormat reads a format name, case-insensitively.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(s), "."))); f {
+	case GPX, TCX, FIT:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported workout format %q", s)
+}
+
+// ErrNoData is returned for a well-formed file without any timed points.
+var ErrNoData = errors.New("workout file has no timed track points")
+
+// Point is one recorded sample. DistanceM is cumulative from the start and
+// zero when the file doesn't carry it.
+type Point struct {
+	Time         time.Time `json:"time"`
+	Lat          float64   `json:"lat,omitempty"`
+	Lon          float64   `json:"lon,omitempty"`
+	HasPosition  bool      `json:"has_position"`
+	ElevationM   float64   `json:"elevation_m,omitempty"`
+	HasElevation bool      `json:"has_elevation"`
+	HeartRate    int       `json:"heart_rate,omitempty"`
+	Cadence      int       `json:"cadence,omitempty"`
+	DistanceM    float64   `json:"distance_m,omitempty"`
+}
+
+// Lap is a lap as the device recorded it.
+type Lap struct {
+	Start        time.Time     `json:"start"`
+	Elapsed      time.Duration `json:"elapsed"`
+	DistanceM    float64       `json:"distance_m"`
+	Calories     int           `json:"calories,omitempty"`
+	AvgHeartRate int           `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate int           `json:"max_heart_rate,omitempty"`
+}
+
+// Activity is a parsed workout. Calories is what the device reported, zero
+// if nothing; the summary fields are filled in by Parse from the points
+// when the file has no totals of its own.
+type Activity struct {
+	Format         Format  `json:"format"`
+	Sport          string  `json:"sport"`
+	Name           string  `json:"name,omitempty"`
+	Points         []Point `json:"points"`
+	Laps           []Lap   `json:"laps"`
+	Calories       int     `json:"calories,omitempty"`
+	DistanceM      float64 `json:"distance_m"`
+	ElevationGainM float64 `json:"elevation_gain_m"`
+	AvgHeartRate   int     `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate   int     `json:"max_heart_rate,omitempty"`
+	AvgCadence     int     `json:"avg_cadence,omitempty"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Duration is the elapsed time between the first and last point.
+func (a *Activity) Duration() time.Duration {
+	return a.End.Sub(a.Start)
+}
+
+// DetectFormat guesses the format from the file name, then the content.
+func DetectFormat(name string, data []byte) (Format, error) {
+	if f, err := ParseFormat(filepath.Ext(name)); err == nil {
+		return f, nil
+	}
+	switch {
+	case len(data) >= 12 && string(data[8:12]) == ".FIT":
+		return FIT, nil
+	case bytes.Contains(head(data), []byte("<gpx")):
+		return GPX, nil
+	case bytes.Contains(head(data), []byte("<TrainingCenterDatabase")):
+		return TCX, nil
+	}
+	return "", fmt.Errorf("unrecognised workout file %q", name)
+}
+
+func head(data []byte) []byte {
+	if len(data) > 1024 {
+		return data[:1024]
+	}
+	return data
+}
+
+// Parse decodes a workout file. An empty format is detected from name and
+// content.
+func Parse(format Format, name string, data []byte) (*Activity, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var a *Activity
+	var err error
+	switch format {
+	case GPX:
+		a, err = parseGPX(data)
+	case TCX:
+		a, err = parseTCX(data)
+	case FIT:
+		a, err = parseFIT(data)
+	default:
+		return nil, fmt.Errorf("unsupported workout format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %w", format, err)
+	}
+	a.Format = format
+	if err = a.summarise(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Elevation noise below this is ignored when adding up climbs.
+const elevationThresholdM = 2.0
+
+// summarise orders the points and fills whatever totals the file left out.
+func (a *Activity) summarise() error {
+	points := a.Points[:0]
+	for _, p := range a.Points {
+		if !p.Time.IsZero() {
+			points = append(points, p)
+		}
+	}
+	a.Points = points
+	if len(a.Points) == 0 {
+		return ErrNoData
+	}
+	sort.SliceStable(a.Points, func(i, j int) bool { return a.Points[i].Time.Before(a.Points[j].Time) })
+	sort.SliceStable(a.Laps, func(i, j int) bool { return a.Laps[i].Start.Before(a.Laps[j].Start) })
+
+	a.Start = a.Points[0].Time
+	a.End = a.Points[len(a.Points)-1].Time
+	if a.Sport == "" {
+		a.Sport = "generic"
+	}
+
+	if a.DistanceM == 0 {
+		a.DistanceM = trackDistance(a.Points)
+	}
+	if a.ElevationGainM == 0 {
+		a.ElevationGainM = elevationGain(a.Points)
+	}
+	a.DistanceM = math.Round(a.DistanceM*10) / 10
+	a.ElevationGainM = math.Round(a.ElevationGainM*10) / 10
+
+	var hrSum, hrN, cadSum, cadN int
+	for _, p := range a.Points {
+		if p.HeartRate > 0 {
+			hrSum += p.HeartRate
+			hrN++
+			if p.HeartRate > a.MaxHeartRate {
+				a.MaxHeartRate = p.HeartRate
+			}
+		}
+		if p.Cadence > 0 {
+			cadSum += p.Cadence
+			cadN++
+		}
+	}
+	if a.AvgHeartRate == 0 && hrN > 0 {
+		a.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrN)))
+	}
+	if cadN > 0 {
+		a.AvgCadence = int(math.Round(float64(cadSum) / float64(cadN)))
+	}
+	if a.Calories == 0 {
+		for _, l := range a.Laps {
+			a.Calories += l.Calories
+		}
+	}
+	return nil
+}
+
+// trackDistance prefers the device's cumulative distance and falls back to
+// the great-circle distance between positions.
+func trackDistance(points []Point) float64 {
+	var recorded, travelled float64
+	var prev *Point
+	for i := range points {
+		p := &points[i]
+		recorded = math.Max(recorded, p.DistanceM)
+		if !p.HasPosition {
+			continue
+		}
+		if prev != nil {
+			travelled += haversine(prev.Lat, prev.Lon, p.Lat, p.Lon)
+		}
+		prev = p
+	}
+	if recorded > 0 {
+		return recorded
+	}
+	return travelled
+}
+
+const earthRadiusM = 6371008.8
+
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// elevationGain adds up climbs, ignoring wobbles smaller than the threshold
+// so GPS noise on the flat doesn't count as climbing.
+func elevationGain(points []Point) float64 {
+	var gain, ref float64
+	started := false
+	for _, p := range points {
+		if !p.HasElevation {
+			continue
+		}
+		switch {
+		case !started:
+			ref, started = p.ElevationM, true
+		case p.ElevationM < ref:
+			ref = p.ElevationM
+		case p.ElevationM-ref >= elevationThresholdM:
+			gain += p.ElevationM - ref
+			ref = p.ElevationM
+		}
+	}
+	return gain
+}
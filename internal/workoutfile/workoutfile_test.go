@@ -0,0 +1,178 @@
+package workoutfile
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file         string
+		format       Format
+		sport        string
+		points, laps int
+		start        time.Time
+		duration     time.Duration
+		distanceM    float64
+		elevationM   float64
+		calories     int
+		avgHR, maxHR int
+		avgCadence   int
+	}{
+		// 4 steps of 0.0009° latitude; the 1 m rise at the start is noise
+		{"morning_run.gpx", GPX, "running", 5, 0, time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC), 2 * time.Minute,
+			400.3, 11, 0, 144, 160, 85},
+		// RunCadence stands in for Cadence on the last point; calories are the laps'
+		{"intervals.tcx", TCX, "cycling", 3, 2, time.Date(2024, 5, 2, 18, 0, 0, 0, time.UTC), 2 * time.Minute,
+			1200, 5, 30, 143, 170, 90},
+		// two records use compressed timestamps, one has an invalid heart rate and
+		// the session's total ascent is invalid, so the gain comes from altitudes
+		{"tempo.fit", FIT, "running", 5, 1, time.Date(2024, 5, 3, 6, 30, 0, 0, time.UTC), 40 * time.Second,
+			223, 6, 35, 148, 170, 84},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			a, err := Parse("", tt.file, readFixture(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Format != tt.format || a.Sport != tt.sport {
+				t.Errorf("format, sport = %s, %s, want %s, %s", a.Format, a.Sport, tt.format, tt.sport)
+			}
+			if len(a.Points) != tt.points || len(a.Laps) != tt.laps {
+				t.Errorf("got %d points, %d laps, want %d, %d", len(a.Points), len(a.Laps), tt.points, tt.laps)
+			}
+			if !a.Start.Equal(tt.start) || a.Duration() != tt.duration {
+				t.Errorf("start, duration = %v, %v, want %v, %v", a.Start, a.Duration(), tt.start, tt.duration)
+			}
+			if math.Abs(a.DistanceM-tt.distanceM) > 0.5 || a.ElevationGainM != tt.elevationM {
+				t.Errorf("distance, gain = %v, %v, want %v, %v", a.DistanceM, a.ElevationGainM, tt.distanceM, tt.elevationM)
+			}
+			if a.Calories != tt.calories {
+				t.Errorf("calories = %d, want %d", a.Calories, tt.calories)
+			}
+			if a.AvgHeartRate != tt.avgHR || a.MaxHeartRate != tt.maxHR || a.AvgCadence != tt.avgCadence {
+				t.Errorf("heart rate %d/%d, cadence %d, want %d/%d, %d",
+					a.AvgHeartRate, a.MaxHeartRate, a.AvgCadence, tt.avgHR, tt.maxHR, tt.avgCadence)
+			}
+		})
+	}
+}
+
+func TestParseFITPoints(t *testing.T) {
+	a, err := Parse(FIT, "", readFixture(t, "tempo.fit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := a.Points[0]
+	if math.Abs(first.Lat-38.7) > 1e-6 || math.Abs(first.Lon+9.14) > 1e-6 || first.ElevationM != 50 {
+		t.Errorf("first point = %+v", first)
+	}
+	if a.Points[1].HeartRate != 0 {
+		t.Errorf("invalid heart rate read as %d", a.Points[1].HeartRate)
+	}
+	if got := a.Points[3].Time.Sub(a.Start); got != 30*time.Second || a.Points[3].HasElevation {
+		t.Errorf("compressed record at %v, elevation %v", got, a.Points[3].HasElevation)
+	}
+	if a.Laps[0].Elapsed != 40*time.Second || a.Laps[0].AvgHeartRate != 148 {
+		t.Errorf("lap = %+v", a.Laps[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	fit := readFixture(t, "tempo.fit")
+	corrupt := append([]byte(nil), fit...)
+	corrupt[20] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"corrupt.fit", corrupt},
+		{"short.fit", fit[:30]},
+		{"broken.gpx", []byte(`<gpx><trk><trkseg><trkpt lat="1"`)},
+		{"notes.txt", []byte("hello")},
+	}
+	for _, tt := range tests {
+		if _, err := Parse("", tt.name, tt.data); err == nil {
+			t.Errorf("%s parsed without error", tt.name)
+		}
+	}
+
+	empty := []byte(`<gpx><trk><trkseg><trkpt lat="1" lon="2"></trkpt></trkseg></trk></gpx>`)
+	if _, err := Parse(GPX, "", empty); !errors.Is(err, ErrNoData) {
+		t.Errorf("untimed track: err = %v, want ErrNoData", err)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Format
+	}{
+		{"ride.TCX", nil, TCX},
+		{"upload", readFixture(t, "tempo.fit"), FIT},
+		{"upload", []byte(`<?xml version="1.0"?><gpx version="1.1">`), GPX},
+		{"upload", []byte(`<?xml version="1.0"?><TrainingCenterDatabase>`), TCX},
+	}
+	for _, tt := range tests {
+		if got, err := DetectFormat(tt.name, tt.data); err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q) = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, file := range []string{"morning_run.gpx", "intervals.tcx", "tempo.fit"} {
+		want, err := Parse("", file, readFixture(t, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		positioned := 0
+		for _, p := range want.Points {
+			if p.HasPosition {
+				positioned++
+			}
+		}
+
+		for _, format := range []Format{GPX, TCX} {
+			data, err := Encode(format, []*Activity{want})
+			if err != nil {
+				t.Fatalf("%s as %s: %v", file, format, err)
+			}
+			got, err := Parse(format, "", data)
+			if err != nil {
+				t.Fatalf("%s as %s doesn't read back: %v\n%s", file, format, err, data)
+			}
+			if got.Sport != want.Sport || got.Name != want.Name || !got.Start.Equal(want.Start) {
+				t.Errorf("%s as %s: got %s %q from %v", file, format, got.Sport, got.Name, got.Start)
+			}
+			// GPX only keeps positioned points and measures distance between them
+			if format == GPX {
+				if len(got.Points) != positioned {
+					t.Errorf("%s as GPX: got %d points, want %d", file, len(got.Points), positioned)
+				}
+				continue
+			}
+			if len(got.Points) != len(want.Points) || len(got.Laps) != max(1, len(want.Laps)) ||
+				!got.End.Equal(want.End) || got.DistanceM != want.DistanceM || got.MaxHeartRate != want.MaxHeartRate {
+				t.Errorf("%s as TCX read back as %+v", file, got)
+			}
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/FACorreiaa/fitme-grpc/config"
 	"github.com/FACorreiaa/fitme-grpc/internal"
+	"github.com/FACorreiaa/fitme-grpc/internal/jobs"
 	"github.com/FACorreiaa/fitme-grpc/internal/metrics"
 	"github.com/FACorreiaa/fitme-grpc/logger"
 )
@@ -77,6 +78,21 @@ func setupDatabases(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, *re
 func startServices(ctx context.Context, cfg *config.Config, container *internal.ServiceContainer, reg *prometheus.Registry) error {
 	errChan := make(chan error, 2)
 
+	// Deliver outbox events until shutdown
+	go container.EventDispatcher.Run(ctx)
+
+	// Start background job workers; they are drained in main after shutdown
+	if err := container.JobQueue.RegisterMetrics(reg); err != nil {
+		return err
+	}
+	if err := jobs.RegisterMaintenance(ctx, container.JobQueue); err != nil {
+		logger.Log.Error("failed to register maintenance jobs", zap.Error(err))
+	}
+	if err := container.ServiceActivity.RegisterReaper(ctx, container.JobQueue); err != nil {
+		logger.Log.Error("failed to register activity tracker reaper", zap.Error(err))
+	}
+	container.JobQueue.Start(ctx)
+
 	// Start gRPC server
 	go func() {
 		if err := internal.ServeGRPC(ctx, cfg.Server.GrpcPort, container, reg); err != nil {
@@ -150,12 +166,16 @@ func main() {
 
 	metrics.InitPprof()
 
-	container := internal.NewServiceContainer(ctx, deps.DB, deps.Redis, brokers)
+	container := internal.NewServiceContainer(ctx, &cfg, deps.DB, deps.Redis, brokers)
 
 	if err = startServices(ctx, &cfg, container, reg); err != nil {
 		logger.Log.Error("service error", zap.Error(err))
 	}
 
+	// make sure workers stop claiming before draining, even on server error
+	cancel()
+	container.JobQueue.Stop()
+
 	deps.DB.Close()
 	deps.Redis.Close()
 }
@@ -10,62 +10,120 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
 )
 
 // Define your secret key for signing tokens
 
 // Claims struct
 
-func InterceptorSession() grpc.UnaryServerInterceptor {
+// InterceptorSession validates the access token and stores the caller in the
+// context. When tenants is set it also resolves the caller's active gym, which
+// repositories use to scope gym-owned catalogs.
+func InterceptorSession(tenants *tenant.Resolver) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		unauthenticatedMethods := map[string]bool{
-			"/fitSphere.auth.Auth/Register":        true,
-			"/fitSphere.auth.Auth/Login":           true,
-			"/fitSphere.auth.Auth/GetAllUsers":     true,
-			"calculator.Calculator/GetUsersMacros": true,
-			"CalculatorService/GetUserMacros":      true,
-			"CalculatorService/GetUserMacrosAll":   true,
-		}
-		if unauthenticatedMethods[info.FullMethod] {
-			return handler(ctx, req)
+		ctx, err := authenticate(ctx, info.FullMethod, tenants)
+		if err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
 
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing context metadata")
+// StreamInterceptorSession is InterceptorSession for streaming methods: the
+// handler sees the caller and gym through the stream's context.
+func StreamInterceptorSession(tenants *tenant.Resolver) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authenticate(ss.Context(), info.FullMethod, tenants)
+		if err != nil {
+			return err
 		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
 
-		authHeader := md["authorization"]
-		//if len(authHeader) == 0 || len(authHeader[0]) < 8 || authHeader[0][:7] != "Bearer " {
-		//	return nil, status.Error(codes.Unauthenticated, "missing or invalid auth token")
-		//}
-		//
-		//tokenString := authHeader[0][7:]
-		println(authHeader)
-		if len(authHeader) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "missing or invalid auth token")
-		}
+// authenticatedStream is a server stream carrying the authenticated context.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
 
-		tokenString := authHeader[0]
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
 
-		claims := &domain.Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return domain.JwtSecretKey, nil
-		})
-		if err != nil || !token.Valid {
-			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
-		}
+var unauthenticatedMethods = map[string]bool{
+	"/fitSphere.auth.Auth/Register":        true,
+	"/fitSphere.auth.Auth/Login":           true,
+	"/fitSphere.auth.Auth/GetAllUsers":     true,
+	"calculator.Calculator/GetUsersMacros": true,
+	"CalculatorService/GetUserMacros":      true,
+	"CalculatorService/GetUserMacrosAll":   true,
 
-		ctx = context.WithValue(ctx, "userID", claims.UserID)
-		ctx = context.WithValue(ctx, "role", claims.Role)
+	// server reflection, registered next to the services for debugging
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+}
 
-		return handler(ctx, req)
+// authenticate returns ctx with the caller of fullMethod and, when tenants
+// is set, their active gym.
+func authenticate(ctx context.Context, fullMethod string, tenants *tenant.Resolver) (context.Context, error) {
+	if unauthenticatedMethods[fullMethod] {
+		return ctx, nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing context metadata")
+	}
+
+	authHeader := md["authorization"]
+	//if len(authHeader) == 0 || len(authHeader[0]) < 8 || authHeader[0][:7] != "Bearer " {
+	//	return nil, status.Error(codes.Unauthenticated, "missing or invalid auth token")
+	//}
+	//
+	//tokenString := authHeader[0][7:]
+	if len(authHeader) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid auth token")
+	}
+
+	tokenString := authHeader[0]
+
+	claims := &domain.Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return domain.JwtSecretKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	ctx = context.WithValue(ctx, "userID", claims.UserID)
+	ctx = context.WithValue(ctx, "role", claims.Role)
+
+	if tenants != nil {
+		var requestedGymID string
+		if v := md.Get(tenant.GymHeader); len(v) > 0 {
+			requestedGymID = v[0]
+		}
+		membership, ok, err := tenants.Resolve(ctx, claims.UserID, requestedGymID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ctx = tenant.WithMembership(ctx, membership)
+		}
 	}
+	return ctx, nil
 }
 
 func hasPermission(userPermissions []string, requiredPermission string) bool {
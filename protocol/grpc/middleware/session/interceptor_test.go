@@ -0,0 +1,86 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FACorreiaa/fitme-grpc/internal/domain"
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
+)
+
+type memberships struct {
+	tenant.Repository
+}
+
+func (memberships) Memberships(context.Context, string) ([]tenant.Membership, error) {
+	return []tenant.Membership{{GymID: "g1", Role: tenant.RoleStaff}}, nil
+}
+
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s stream) Context() context.Context { return s.ctx }
+
+func signedToken(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &domain.Claims{UserID: userID, Role: "USER"}).
+		SignedString(domain.JwtSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestStreamInterceptorSession(t *testing.T) {
+	interceptor := StreamInterceptorSession(tenant.NewResolver(memberships{}, nil))
+	info := &grpc.StreamServerInfo{FullMethod: "/fitSphere.activity.Activity/TrackLive"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", signedToken(t, "u1")))
+	var userID string
+	var gym tenant.Membership
+	err := interceptor(nil, stream{ctx: ctx}, info, func(_ interface{}, ss grpc.ServerStream) error {
+		userID, _ = ss.Context().Value("userID").(string)
+		gym, _ = tenant.FromContext(ss.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "u1" || gym.GymID != "g1" {
+		t.Errorf("handler saw user %q in gym %q, want u1 in g1", userID, gym.GymID)
+	}
+
+	called := false
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "not-a-token"))
+	err = interceptor(nil, stream{ctx: ctx}, info, func(interface{}, grpc.ServerStream) error {
+		called = true
+		return nil
+	})
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Errorf("invalid token: err = %v, handler called = %v", err, called)
+	}
+}
+
+func TestStreamInterceptorSessionSkipsReflection(t *testing.T) {
+	interceptor := StreamInterceptorSession(nil)
+	for _, method := range []string{
+		"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+		"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+	} {
+		info := &grpc.StreamServerInfo{FullMethod: method}
+		err := interceptor(nil, stream{ctx: context.Background()}, info, func(interface{}, grpc.ServerStream) error {
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s without a token: %v", method, err)
+		}
+	}
+}
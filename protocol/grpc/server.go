@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 
+	"github.com/FACorreiaa/fitme-grpc/internal/domain/tenant"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware/grpclog"
 	"github.com/FACorreiaa/fitme-grpc/protocol/grpc/middleware/grpcprometheus"
@@ -27,6 +28,7 @@ func BootstrapServer(
 	log *zap.Logger,
 	registry *prometheus.Registry,
 	traceProvider trace.TracerProvider, // [currently not used directly, but available if needed]
+	tenants *tenant.Resolver,
 	opts ...grpc.ServerOption,
 ) (*grpc.Server, net.Listener, error) {
 
@@ -52,7 +54,8 @@ func BootstrapServer(
 	// Additional interceptors:
 	_, logInterceptor := grpclog.Interceptors(log)
 	_, recoveryInterceptor := grpcrecovery.Interceptors(grpcrecovery.RegisterMetrics(registry))
-	sessionInterceptor := session.InterceptorSession()
+	sessionInterceptor := session.InterceptorSession(tenants)
+	streamSessionInterceptor := session.StreamInterceptorSession(tenants)
 	requestIDInterceptor := grpcrequest.RequestIDMiddleware()
 
 	// Simple rate limiter for demonstration (10 requests/sec, 20 burst).
@@ -80,6 +83,7 @@ func BootstrapServer(
 			spanInterceptor.Stream,
 			promInterceptor.Stream,
 			logInterceptor.Stream,
+			streamSessionInterceptor,
 			recoveryInterceptor.Stream,
 		),
 	}